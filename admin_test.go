@@ -2,7 +2,6 @@ package main
 
 import (
 	"config-service/db"
-	"config-service/db/mongo"
 	"config-service/types"
 	"config-service/utils/consts"
 	"context"
@@ -228,7 +227,7 @@ func (suite *MainTestSuite) TestAdminActiveUsers() {
 	clusters3, _ := loadJson[*types.Cluster](user3ClustersBytes)
 	clusters := [][]*types.Cluster{clusters1, clusters2, clusters3}
 
-	_, err := mongo.GetWriteCollection(consts.ClustersCollection).DeleteMany(context.Background(), struct{}{})
+	_, err := db.GetStore().GetWriteCollection(consts.ClustersCollection).DeleteMany(context.Background(), struct{}{})
 	suite.NoError(err, "can't delete clusters collection")
 
 	for i, user := range users {
@@ -301,7 +300,7 @@ func (suite *MainTestSuite) TestAdminActiveUsers() {
 
 func (suite *MainTestSuite) TestAdminGetCustomers() {
 	//remove all existing customers
-	_, err := mongo.GetWriteCollection(consts.CustomersCollection).DeleteMany(context.Background(), struct{}{})
+	_, err := db.GetStore().GetWriteCollection(consts.CustomersCollection).DeleteMany(context.Background(), struct{}{})
 	if err != nil {
 		suite.FailNow(err.Error())
 	}
@@ -393,7 +392,7 @@ func (suite *MainTestSuite) TestAdminGetCustomers() {
 
 func (suite *MainTestSuite) TestAdminUpdateMany() {
 	//remove all existing exceptions
-	_, err := mongo.GetWriteCollection(consts.VulnerabilityExceptionPolicyPath).DeleteMany(context.Background(), struct{}{})
+	_, err := db.GetStore().GetWriteCollection(consts.VulnerabilityExceptionPolicyPath).DeleteMany(context.Background(), struct{}{})
 	if err != nil {
 		suite.FailNow(err.Error())
 	}
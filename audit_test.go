@@ -0,0 +1,101 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) getAuditEvents(filters map[string]string) []*types.AuditEvent {
+	req := armotypes.V2ListRequest{
+		OrderBy:      "timestamp:asc",
+		InnerFilters: []map[string]string{filters},
+	}
+	w := suite.doRequest(http.MethodPost, consts.AuditPath+"/query", req)
+	suite.Equal(http.StatusOK, w.Code)
+	result, err := decodeResponse[types.SearchResult[*types.AuditEvent]](w)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	return result.Response
+}
+
+func (suite *MainTestSuite) TestAuditTrail() {
+	posturePolicies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	policy := testPostDoc(suite, consts.PostureExceptionPolicyPath, posturePolicies[0], commonCmpFilter)
+	oldPolicy := Clone(policy)
+	policy.Attributes = map[string]interface{}{"test": "audit"}
+	testPutDoc(suite, consts.PostureExceptionPolicyPath, oldPolicy, policy, commonCmpFilter)
+	testDeleteDocByGUID(suite, consts.PostureExceptionPolicyPath, policy, commonCmpFilter)
+
+	events := suite.getAuditEvents(map[string]string{"docGUID": policy.GUID})
+	suite.Len(events, 3)
+	verbs := []string{}
+	for _, event := range events {
+		verbs = append(verbs, event.Verb)
+		suite.Equal(defaultUserGUID, event.Actor)
+		suite.Equal(defaultUserGUID, event.CustomerGUID)
+		suite.Equal(consts.PostureExceptionPolicyCollection, event.Collection)
+		suite.Equal(consts.PostureExceptionPolicyPath+pathSuffix(event.Verb, policy.GUID), event.Path)
+		suite.NotEmpty(event.Diff)
+	}
+	suite.Equal([]string{http.MethodPost, http.MethodPut, http.MethodDelete}, verbs)
+
+	//the update diff has the changed attribute
+	var attributeChange *types.AuditChange
+	for i := range events[1].Diff {
+		if events[1].Diff[i].Path == "attributes" {
+			attributeChange = &events[1].Diff[i]
+		}
+	}
+	if suite.NotNil(attributeChange) {
+		suite.JSONEq(`{"test":"audit"}`, string(attributeChange.New))
+	}
+	//the delete diff has only old values
+	for _, change := range events[2].Diff {
+		suite.Empty(change.New)
+	}
+
+	//bulk delete is recorded as a single event
+	newPolicies := testBulkPostDocs(suite, consts.PostureExceptionPolicyPath, posturePolicies[1:3], commonCmpFilter)
+	testBulkDeleteByGUIDWithBody(suite, consts.PostureExceptionPolicyPath, []string{newPolicies[0].GUID, newPolicies[1].GUID})
+	events = suite.getAuditEvents(map[string]string{"verb": http.MethodDelete, "path": consts.PostureExceptionPolicyPath + "/bulk"})
+	if suite.Len(events, 1) {
+		suite.Equal(int64(2), events[0].Count)
+		suite.Contains(events[0].Query, newPolicies[0].GUID)
+	}
+
+	//other customers do not see the events
+	suite.login("other-customer-guid")
+	suite.Empty(suite.getAuditEvents(map[string]string{"docGUID": policy.GUID}))
+
+	//only auditors search the audit trail
+	suite.loginWithRoles(defaultUserGUID, consts.EditorRole)
+	testBadRequest(suite, http.MethodPost, consts.AuditPath+"/query", `{"error":"missing read permission for /v1_audit"}`,
+		armotypes.V2ListRequest{InnerFilters: []map[string]string{{"docGUID": policy.GUID}}}, http.StatusForbidden)
+
+	//admin mutations are recorded with the admin as the actor
+	suite.loginAsAdmin("admin-customer-guid")
+	w := suite.doRequest(http.MethodPut, consts.AdminPath+"/updatePostureExceptionsSeverity",
+		types.PostureExceptionsSeverityUpdate{ControlIDS: []string{"C-0001"}, SeverityScore: 7})
+	suite.Equal(http.StatusOK, w.Code)
+	w = suite.doRequest(http.MethodPost, consts.AdminPath+consts.AuditPath+"/query", armotypes.V2ListRequest{
+		InnerFilters: []map[string]string{{"path": consts.AdminPath + "/updatePostureExceptionsSeverity"}},
+	})
+	suite.Equal(http.StatusOK, w.Code)
+	adminEvents, err := decodeResponse[types.SearchResult[*types.AuditEvent]](w)
+	suite.NoError(err)
+	if suite.Len(adminEvents.Response, 1) {
+		suite.Equal("admin-customer-guid", adminEvents.Response[0].Actor)
+		suite.Contains(adminEvents.Response[0].Query, "C-0001")
+	}
+}
+
+func pathSuffix(verb, guid string) string {
+	if verb == http.MethodPost || verb == http.MethodPut {
+		return ""
+	}
+	return "/" + guid
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"config-service/utils"
+	"crypto"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	defaultCustomerGUIDClaim = "customerGUID"
+	defaultEmailClaim        = "email"
+	defaultRolesClaim        = "roles"
+	defaultAdminRole         = "admin"
+	// clockSkew is the allowed difference between the token issuer clock and ours
+	clockSkew = 30 * time.Second
+)
+
+var (
+	hmacMethods      = []string{"HS256", "HS384", "HS512"}
+	publicKeyMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+var ErrMissingCustomerGUID = fmt.Errorf("token has no customer GUID claim")
+
+// Identity is the authenticated caller details taken from the token claims
+type Identity struct {
+	CustomerGUID string
+	Email        string
+	Roles        []string
+	Admin        bool
+}
+
+// JWTVerifier verifies signed bearer tokens and maps their claims to an Identity
+type JWTVerifier struct {
+	config  utils.JWTConfig
+	parser  *jwt.Parser
+	keyFunc jwt.Keyfunc
+}
+
+// NewJWTVerifier creates a verifier from the configuration, the issuer, the audience and a JWKS file or HMAC key are required
+func NewJWTVerifier(config utils.JWTConfig) (*JWTVerifier, error) {
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("jwt issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, fmt.Errorf("jwt audience is required")
+	}
+	if config.CustomerGUIDClaim == "" {
+		config.CustomerGUIDClaim = defaultCustomerGUIDClaim
+	}
+	if config.EmailClaim == "" {
+		config.EmailClaim = defaultEmailClaim
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = defaultRolesClaim
+	}
+	if config.AdminRole == "" {
+		config.AdminRole = defaultAdminRole
+	}
+	verifier := &JWTVerifier{config: config}
+	var methods []string
+	switch {
+	case config.JWKSFile != "" && config.HMACKey != "":
+		return nil, fmt.Errorf("only one of jwt jwksFile and hmacKey can be set")
+	case config.JWKSFile != "":
+		keys, err := loadJWKSFile(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.keyFunc = jwksKeyFunc(keys)
+		methods = publicKeyMethods
+	case config.HMACKey != "":
+		key := []byte(config.HMACKey)
+		verifier.keyFunc = func(*jwt.Token) (interface{}, error) { return key, nil }
+		methods = hmacMethods
+	default:
+		return nil, fmt.Errorf("one of jwt jwksFile or hmacKey is required")
+	}
+	verifier.parser = jwt.NewParser(
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+	return verifier, nil
+}
+
+// jwksKeyFunc selects the verification key by the token kid header, a token without kid is allowed when there is a single key
+func jwksKeyFunc(keys map[string]crypto.PublicKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+		key, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}
+}
+
+// Verify validates the token signature and registered claims and returns the caller identity
+func (v *JWTVerifier) Verify(tokenString string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(tokenString, claims, v.keyFunc); err != nil {
+		return nil, err
+	}
+	customerGUID, _ := claims[v.config.CustomerGUIDClaim].(string)
+	if customerGUID == "" {
+		return nil, ErrMissingCustomerGUID
+	}
+	email, _ := claims[v.config.EmailClaim].(string)
+	roles := claimStrings(claims[v.config.RolesClaim])
+	return &Identity{
+		CustomerGUID: customerGUID,
+		Email:        email,
+		Roles:        roles,
+		Admin:        slices.Contains(roles, v.config.AdminRole),
+	}, nil
+}
+
+// claimStrings returns the string values of a claim that can be a single string or an array
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"config-service/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "config-service"
+	testHMACKey  = "test-hmac-key"
+)
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":          testIssuer,
+		"aud":          testAudience,
+		"exp":          time.Now().Add(time.Hour).Unix(),
+		"customerGUID": "customer-guid",
+		"email":        "user@example.com",
+	}
+}
+
+func withClaims(claims jwt.MapClaims, key string, value interface{}) jwt.MapClaims {
+	claims[key] = value
+	return claims
+}
+
+func withoutClaim(claims jwt.MapClaims, key string) jwt.MapClaims {
+	delete(claims, key)
+	return claims
+}
+
+func signHMAC(t *testing.T, claims jwt.MapClaims, key string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	assert.NoError(t, err)
+	return token
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	verifier, err := NewJWTVerifier(utils.JWTConfig{Issuer: testIssuer, Audience: testAudience, HMACKey: testHMACKey})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *Identity
+		wantErr bool
+	}{
+		{
+			name:  "valid token",
+			token: signHMAC(t, validClaims(), testHMACKey),
+			want:  &Identity{CustomerGUID: "customer-guid", Email: "user@example.com"},
+		},
+		{
+			name:  "admin role in roles list",
+			token: signHMAC(t, withClaims(validClaims(), "roles", []string{"viewer", "admin"}), testHMACKey),
+			want:  &Identity{CustomerGUID: "customer-guid", Email: "user@example.com", Roles: []string{"viewer", "admin"}, Admin: true},
+		},
+		{
+			name:  "admin role as string",
+			token: signHMAC(t, withClaims(validClaims(), "roles", "admin"), testHMACKey),
+			want:  &Identity{CustomerGUID: "customer-guid", Email: "user@example.com", Roles: []string{"admin"}, Admin: true},
+		},
+		{
+			name:    "wrong key",
+			token:   signHMAC(t, validClaims(), "other-key"),
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			token:   signHMAC(t, withClaims(validClaims(), "iss", "https://other.example.com"), testHMACKey),
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			token:   signHMAC(t, withClaims(validClaims(), "aud", "other-service"), testHMACKey),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   signHMAC(t, withClaims(validClaims(), "exp", time.Now().Add(-time.Hour).Unix()), testHMACKey),
+			wantErr: true,
+		},
+		{
+			name:    "missing expiration",
+			token:   signHMAC(t, withoutClaim(validClaims(), "exp"), testHMACKey),
+			wantErr: true,
+		},
+		{
+			name:    "missing customer guid",
+			token:   signHMAC(t, withoutClaim(validClaims(), "customerGUID"), testHMACKey),
+			wantErr: true,
+		},
+		{
+			name: "none algorithm",
+			token: func() string {
+				token, _ := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return token
+			}(),
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-token",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-key", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-key", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+		},
+	}
+	data, _ := json.Marshal(jwks)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(jwksFile, data, 0600))
+
+	verifier, err := NewJWTVerifier(utils.JWTConfig{Issuer: testIssuer, Audience: testAudience, JWKSFile: jwksFile,
+		CustomerGUIDClaim: "tenant", RolesClaim: "groups", AdminRole: "superuser"})
+	assert.NoError(t, err)
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		claims := withClaims(withClaims(validClaims(), "tenant", "tenant-guid"), "groups", []string{"superuser"})
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "rsa key", token: sign(jwt.SigningMethodRS256, "rsa-key", rsaKey)},
+		{name: "ec key", token: sign(jwt.SigningMethodES256, "ec-key", ecKey)},
+		{name: "unknown kid", token: sign(jwt.SigningMethodRS256, "unknown", rsaKey), wantErr: true},
+		{name: "wrong key", token: sign(jwt.SigningMethodRS256, "rsa-key", otherKey), wantErr: true},
+		{name: "hmac with public key methods", token: signHMAC(t, validClaims(), testHMACKey), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &Identity{CustomerGUID: "tenant-guid", Email: "user@example.com", Roles: []string{"superuser"}, Admin: true}, got)
+		})
+	}
+}
+
+func TestNewJWTVerifierConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config utils.JWTConfig
+	}{
+		{name: "missing issuer", config: utils.JWTConfig{Audience: testAudience, HMACKey: testHMACKey}},
+		{name: "missing audience", config: utils.JWTConfig{Issuer: testIssuer, HMACKey: testHMACKey}},
+		{name: "missing key", config: utils.JWTConfig{Issuer: testIssuer, Audience: testAudience}},
+		{name: "both keys", config: utils.JWTConfig{Issuer: testIssuer, Audience: testAudience, HMACKey: testHMACKey, JWKSFile: "jwks.json"}},
+		{name: "missing jwks file", config: utils.JWTConfig{Issuer: testIssuer, Audience: testAudience, JWKSFile: "/not/exist/jwks.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewJWTVerifier(tt.config)
+			assert.Error(t, err)
+		})
+	}
+}
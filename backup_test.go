@@ -0,0 +1,131 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"config-service/types"
+	"config-service/utils/consts"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) TestBackupRestore() {
+	const (
+		source = "backup-source-guid"
+		target = "backup-target-guid"
+	)
+	clusters, clustersNames := loadJson[*types.Cluster](clustersJson)
+	policies, policiesNames := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	suite.login(source)
+	postedClusters := testBulkPostDocs(suite, consts.ClusterPath, clusters, newClusterCompareFilter)
+	testBulkPostDocs(suite, consts.PostureExceptionPolicyPath, policies, commonCmpFilter)
+	testPostDoc(suite, consts.TenantPath, &types.Customer{PortalBase: armotypes.PortalBase{Name: "backup customer", GUID: source}}, customerCompareFilter)
+
+	type restoreResponse struct {
+		CustomerGUID string           `json:"customerGUID"`
+		Restored     map[string]int64 `json:"restored"`
+		Skipped      map[string]int64 `json:"skipped"`
+	}
+	backupPath := func(customerGUID string) string {
+		return fmt.Sprintf("%s/customers/%s/backup", consts.AdminPath, customerGUID)
+	}
+	restorePath := func(customerGUID string) string {
+		return fmt.Sprintf("%s/customers/%s/restore", consts.AdminPath, customerGUID)
+	}
+	expectedCounts := map[string]int64{
+		consts.ClustersCollection:               int64(len(clusters)),
+		consts.PostureExceptionPolicyCollection: int64(len(policies)),
+		consts.CustomersCollection:              1,
+	}
+
+	//regular users cannot backup
+	testBadRequest(suite, http.MethodGet, backupPath(source), errorNotAdminUser, nil, http.StatusUnauthorized)
+
+	suite.loginAsAdmin("backup-admin-guid")
+	w := suite.doRequest(http.MethodGet, backupPath(source), nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Equal("application/gzip", w.Header().Get("Content-Type"))
+	archive := w.Body.Bytes()
+
+	//the archive has the manifest and a NDJSON file per collection
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	suite.NoError(err)
+	tarReader := tar.NewReader(gzipReader)
+	header, err := tarReader.Next()
+	suite.NoError(err)
+	suite.Equal("manifest.json", header.Name)
+	var manifest struct {
+		CustomerGUID string           `json:"customerGUID"`
+		Collections  map[string]int64 `json:"collections"`
+	}
+	suite.NoError(json.NewDecoder(tarReader).Decode(&manifest))
+	suite.Equal(source, manifest.CustomerGUID)
+	for collection, count := range expectedCounts {
+		suite.Equal(count, manifest.Collections[collection], collection)
+	}
+	files := map[string]bool{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		suite.NoError(err)
+		files[header.Name] = true
+	}
+	suite.True(files[consts.ClustersCollection+".ndjson"])
+	suite.True(files[consts.PostureExceptionPolicyCollection+".ndjson"])
+	suite.False(files[consts.AuditCollection+".ndjson"])
+
+	//unknown customer
+	testBadRequest(suite, http.MethodGet, backupPath("no-such-customer"), errorDocumentNotFound, nil, http.StatusNotFound)
+
+	//restoring the archive as the same customer skips the existing documents
+	w = suite.doRequest(http.MethodPost, restorePath(source), archive)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	response := decode[restoreResponse](suite, w.Body.Bytes())
+	suite.Empty(response.Restored)
+	for collection, count := range expectedCounts {
+		suite.Equal(count, response.Skipped[collection], collection)
+	}
+
+	//restore as another customer requires remap
+	testBadRequest(suite, http.MethodPost, restorePath(target),
+		fmt.Sprintf(`{"error":"the archive belongs to customer %s, use remap=true to restore it as customer %s"}`, source, target), archive, http.StatusBadRequest)
+	w = suite.doRequest(http.MethodPost, restorePath(target), []byte("not an archive"))
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.doRequest(http.MethodPost, restorePath(target)+"?remap=true", archive)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	response = decode[restoreResponse](suite, w.Body.Bytes())
+	suite.Equal(target, response.CustomerGUID)
+	for collection, count := range expectedCounts {
+		suite.Equal(count, response.Restored[collection], collection)
+	}
+	//restoring again skips the cloned documents
+	w = suite.doRequest(http.MethodPost, restorePath(target)+"?remap=true", archive)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	response = decode[restoreResponse](suite, w.Body.Bytes())
+	suite.Empty(response.Restored)
+	suite.Equal(int64(len(clusters)), response.Skipped[consts.ClustersCollection])
+
+	//the cloned customer has its own copy of the documents
+	suite.login(target)
+	testGetNameList(suite, consts.ClusterPath, clustersNames)
+	testGetNameList(suite, consts.PostureExceptionPolicyPath, policiesNames)
+	testGetDoc(suite, consts.CustomerPath, &types.Customer{PortalBase: armotypes.PortalBase{Name: "backup customer", GUID: target}}, customerCompareFilter)
+	w = suite.doRequest(http.MethodGet, consts.ClusterPath, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	for _, cluster := range decode[[]*types.Cluster](suite, w.Body.Bytes()) {
+		for _, posted := range postedClusters {
+			suite.NotEqual(posted.GUID, cluster.GUID)
+		}
+	}
+	//the source customer is unchanged
+	suite.login(source)
+	testGetNameList(suite, consts.ClusterPath, clustersNames)
+}
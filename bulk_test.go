@@ -0,0 +1,56 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) TestBulkPut() {
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	clusters = testBulkPostDocs(suite, consts.ClusterPath, clusters[:3], newClusterCompareFilter)
+	defer testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, []string{clusters[0].GUID, clusters[1].GUID, clusters[2].GUID})
+
+	update := func(cluster *types.Cluster) *types.Cluster {
+		return &types.Cluster{PortalBase: armotypes.PortalBase{
+			GUID:       cluster.GUID,
+			Attributes: map[string]interface{}{"test": "bulk"},
+		}}
+	}
+	w := suite.doRequest(http.MethodPut, consts.ClusterPath+"/bulk", []*types.Cluster{
+		update(clusters[0]),
+		update(clusters[1]),
+		update(&types.Cluster{PortalBase: armotypes.PortalBase{GUID: "not-exists"}}),
+		{PortalBase: armotypes.PortalBase{Name: "no guid"}},
+	})
+	suite.Equal(http.StatusOK, w.Code)
+	response := decode[types.BulkUpdateResponse](suite, w.Body.Bytes())
+	suite.False(response.Transactional)
+	suite.Equal([]types.BulkUpdateResult{
+		{GUID: clusters[0].GUID, Status: types.BulkStatusUpdated, Revision: 2},
+		{GUID: clusters[1].GUID, Status: types.BulkStatusUpdated, Revision: 2},
+		{GUID: "not-exists", Status: types.BulkStatusNotFound},
+		{Status: types.BulkStatusInvalid, Error: "guid is required"},
+	}, response.Results)
+
+	//the put validators kept the cluster alias
+	for _, cluster := range clusters[:2] {
+		w = suite.doRequest(http.MethodGet, consts.ClusterPath+"/"+cluster.GUID, nil)
+		suite.Equal(http.StatusOK, w.Code)
+		updated := decode[*types.Cluster](suite, w.Body.Bytes())
+		suite.Equal("bulk", updated.Attributes["test"])
+		suite.Equal(cluster.Attributes[consts.ShortNameAttribute], updated.Attributes[consts.ShortNameAttribute])
+	}
+	//each update is audited
+	events := suite.getAuditEvents(map[string]string{"docGUID": clusters[0].GUID, "verb": http.MethodPut})
+	if suite.Len(events, 1) {
+		suite.Equal(consts.ClusterPath+"/bulk", events[0].Path)
+	}
+
+	testBadRequest(suite, http.MethodPut, consts.ClusterPath+"/bulk", `{"error":"no documents in request"}`, []*types.Cluster{}, http.StatusBadRequest)
+	w = suite.doRequestWithHeaders(http.MethodPut, consts.ClusterPath+"/bulk", []*types.Cluster{update(clusters[2])}, map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Equal(`{"error":"If-Match is not supported in bulk requests"}`, w.Body.String())
+}
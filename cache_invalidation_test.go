@@ -0,0 +1,58 @@
+package main
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const cacheInvalidationPollInterval = 50 * time.Millisecond
+
+func (suite *MainTestSuite) TestCacheInvalidation() {
+	path := fmt.Sprintf("%s?%s=%s", consts.CustomerConfigPath, consts.ConfigNameParam, consts.GlobalConfigName)
+	getScanFrequency := func() armotypes.ScanFrequency {
+		w := suite.doRequest(http.MethodGet, path, nil)
+		suite.Equal(http.StatusOK, w.Code, w.Body.String())
+		return decode[*types.CustomerConfig](suite, w.Body.Bytes()).Settings.PostureScanConfig.ScanFrequency
+	}
+	//another instance updates the default config and publishes the invalidation of the customer configs
+	setScanFrequency := func(scanFrequency armotypes.ScanFrequency) {
+		_, err := db.GetStore().GetWriteCollection(consts.CustomerConfigCollection).UpdateOne(context.Background(),
+			bson.D{{Key: consts.NameField, Value: consts.GlobalConfigName}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "settings.postureScanConfig.scanFrequency", Value: scanFrequency}}}})
+		suite.NoError(err)
+	}
+	publishInvalidation := func() {
+		now := time.Now().UTC()
+		_, err := db.GetStore().GetWriteCollection(consts.CacheInvalidationsCollection).InsertOne(context.Background(), bson.D{
+			{Key: consts.IdField, Value: uuid.NewV4().String()},
+			{Key: "collection", Value: consts.CustomerConfigCollection},
+			{Key: "instanceID", Value: "other-instance"},
+			{Key: "timestamp", Value: now},
+			{Key: "expiryTime", Value: now.Add(time.Hour)},
+		})
+		suite.NoError(err)
+	}
+	scanFrequency := getScanFrequency()
+	suite.NotEmpty(scanFrequency)
+	defer func() {
+		setScanFrequency(scanFrequency)
+		publishInvalidation()
+		suite.Eventually(func() bool { return getScanFrequency() == scanFrequency }, 5*time.Second, cacheInvalidationPollInterval)
+	}()
+
+	//the cached default config is served until the invalidation is polled
+	setScanFrequency("1h")
+	time.Sleep(2 * cacheInvalidationPollInterval)
+	suite.Equal(scanFrequency, getScanFrequency())
+	publishInvalidation()
+	suite.Eventually(func() bool { return getScanFrequency() == "1h" }, 5*time.Second, cacheInvalidationPollInterval)
+}
@@ -1,7 +1,6 @@
 package db
 
 import (
-	"config-service/db/mongo"
 	"config-service/types"
 	"config-service/utils"
 	"context"
@@ -74,7 +73,7 @@ func AggregateWithTemplate[T any](ctx context.Context, limit, cursor int, collec
 		log.LogNTraceError("failed to unmarshal template", err, ctx)
 		return nil, err
 	}
-	dbCursor, err := mongo.GetReadCollection(collection).Aggregate(ctx, pipeline)
+	dbCursor, err := getReadCollection(collection).Aggregate(ctx, pipeline)
 	if err != nil {
 		log.LogNTraceError("failed aggregate", err, ctx)
 		return nil, err
@@ -0,0 +1,17 @@
+package db
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+)
+
+// InsertAuditEvent writes an audit event owned by the given customers to the audit collection
+func InsertAuditEvent(c context.Context, event *types.AuditEvent, customers []string) error {
+	defer log.LogNTraceEnterExit("InsertAuditEvent", c)()
+	dbDoc := types.NewDocument(event, "")
+	dbDoc.Customers = customers
+	_, err := getWriteCollection(consts.AuditCollection).InsertOne(c, dbDoc)
+	return err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BackupCollections returns the sorted names of the collections with customer documents, the audit trail is not backed up
+func BackupCollections(c context.Context) ([]string, error) {
+	collections, err := dbStore.ListCollectionNames(c)
+	if err != nil {
+		return nil, err
+	}
+	backupCollections := []string{}
+	for _, collection := range collections {
+		if collection != consts.AuditCollection {
+			backupCollections = append(backupCollections, collection)
+		}
+	}
+	sort.Strings(backupCollections)
+	return backupCollections, nil
+}
+
+// AdminForEachCustomerDoc calls fn with each document of the customer in the collection (including documents in the trash)
+// in the customers collection the customer document itself is returned
+func AdminForEachCustomerDoc(c context.Context, collection, customerGUID string, fn func(doc bson.Raw) error) error {
+	defer log.LogNTraceEnterExit(fmt.Sprintf("AdminForEachCustomerDoc %s", collection), c)()
+	filter := NewFilterBuilder().WithCustomers([]string{customerGUID})
+	if collection == consts.CustomersCollection {
+		filter = NewFilterBuilder().WithID(customerGUID)
+	}
+	//read from the primary for a consistent backup
+	cur, err := getWriteCollection(collection).Find(c, filter.get())
+	if err != nil {
+		return err
+	}
+	defer cur.Close(c)
+	for cur.Next(c) {
+		if err := fn(cur.Current); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// AdminRestoreDoc inserts a backed up document as is, returns false if a document with the same id exists
+func AdminRestoreDoc(c context.Context, collection string, doc bson.D) (bool, error) {
+	if _, err := getWriteCollection(collection).InsertOne(c, doc); err != nil {
+		if IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,225 @@
+package bsonquery
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EvalExpression evaluates an aggregation expression on a document
+func EvalExpression(doc bson.D, expr interface{}) (interface{}, error) {
+	switch v := expr.(type) {
+	case string:
+		switch {
+		case v == "$$ROOT" || v == "$$CURRENT":
+			return doc, nil
+		case strings.HasPrefix(v, "$$ROOT.") || strings.HasPrefix(v, "$$CURRENT."):
+			path := v[strings.Index(v, ".")+1:]
+			value, _ := ResolvePath(doc, strings.Split(path, "."))
+			return value, nil
+		case strings.HasPrefix(v, "$$"):
+			return nil, fmt.Errorf("use of undefined variable: %s", strings.TrimPrefix(v, "$$"))
+		case strings.HasPrefix(v, "$"):
+			value, _ := ResolvePath(doc, strings.Split(strings.TrimPrefix(v, "$"), "."))
+			return value, nil
+		}
+		return v, nil
+	case bson.A:
+		values := make(bson.A, 0, len(v))
+		for _, item := range v {
+			value, err := EvalExpression(doc, item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case bson.D:
+		if len(v) > 0 && strings.HasPrefix(v[0].Key, "$") {
+			if len(v) != 1 {
+				return nil, fmt.Errorf("an expression specification must contain exactly one field")
+			}
+			return evalOperator(doc, v[0])
+		}
+		result := bson.D{}
+		for _, e := range v {
+			value, err := EvalExpression(doc, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, bson.E{Key: e.Key, Value: value})
+		}
+		return result, nil
+	}
+	return expr, nil
+}
+
+func evalOperator(doc bson.D, op bson.E) (interface{}, error) {
+	if op.Key == "$literal" {
+		return op.Value, nil
+	}
+	value, err := EvalExpression(doc, op.Value)
+	if err != nil {
+		return nil, err
+	}
+	args, isArgs := value.(bson.A)
+	if !isArgs {
+		args = bson.A{value}
+	}
+	arg := func(i int) interface{} {
+		if i < len(args) {
+			return args[i]
+		}
+		return nil
+	}
+	switch op.Key {
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$cmp":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expression %s takes exactly 2 arguments", op.Key)
+		}
+		c := CompareValues(args[0], args[1])
+		switch op.Key {
+		case "$eq":
+			return c == 0, nil
+		case "$ne":
+			return c != 0, nil
+		case "$gt":
+			return c > 0, nil
+		case "$gte":
+			return c >= 0, nil
+		case "$lt":
+			return c < 0, nil
+		case "$lte":
+			return c <= 0, nil
+		}
+		return int32(sign(c)), nil
+	case "$and":
+		for _, a := range args {
+			if !IsTrue(a) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$or":
+		for _, a := range args {
+			if IsTrue(a) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$not":
+		return !IsTrue(arg(0)), nil
+	case "$ifNull":
+		for _, a := range args {
+			if a != nil {
+				return a, nil
+			}
+		}
+		return nil, nil
+	case "$cond":
+		if d, ok := value.(bson.D); ok {
+			ifValue, _ := GetField(d, "if")
+			thenValue, _ := GetField(d, "then")
+			elseValue, _ := GetField(d, "else")
+			args = bson.A{ifValue, thenValue, elseValue}
+		}
+		if IsTrue(arg(0)) {
+			return arg(1), nil
+		}
+		return arg(2), nil
+	case "$in":
+		arr, ok := arg(1).(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("$in requires an array as a second argument")
+		}
+		return ContainsValue(arr, arg(0)), nil
+	case "$size":
+		arr, ok := arg(0).(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("the argument to $size must be an array")
+		}
+		return int32(len(arr)), nil
+	case "$arrayElemAt":
+		arr, _ := arg(0).(bson.A)
+		index, _ := ToInt64(arg(1))
+		if index < 0 {
+			index += int64(len(arr))
+		}
+		if index < 0 || index >= int64(len(arr)) {
+			return nil, nil
+		}
+		return arr[index], nil
+	case "$first", "$last":
+		arr, _ := arg(0).(bson.A)
+		if len(arr) == 0 {
+			return nil, nil
+		}
+		if op.Key == "$first" {
+			return arr[0], nil
+		}
+		return arr[len(arr)-1], nil
+	case "$concat":
+		var sb strings.Builder
+		for _, a := range args {
+			if a == nil {
+				return nil, nil
+			}
+			sb.WriteString(fmt.Sprint(a))
+		}
+		return sb.String(), nil
+	case "$toLower", "$toUpper":
+		s := ""
+		if arg(0) != nil {
+			s = fmt.Sprint(arg(0))
+		}
+		if op.Key == "$toLower" {
+			return strings.ToLower(s), nil
+		}
+		return strings.ToUpper(s), nil
+	case "$toString":
+		if arg(0) == nil {
+			return nil, nil
+		}
+		if id, ok := arg(0).(primitive.ObjectID); ok {
+			return id.Hex(), nil
+		}
+		return fmt.Sprint(arg(0)), nil
+	case "$add":
+		var sum interface{} = int32(0)
+		for _, a := range args {
+			if sum, err = AddNumbers(sum, a); err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	case "$subtract", "$multiply", "$divide":
+		a, ok1 := ToFloat(arg(0))
+		b, ok2 := ToFloat(arg(1))
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s only supports numeric types", op.Key)
+		}
+		switch op.Key {
+		case "$subtract":
+			return a - b, nil
+		case "$multiply":
+			return a * b, nil
+		}
+		if b == 0 {
+			return nil, fmt.Errorf("can't $divide by zero")
+		}
+		return a / b, nil
+	}
+	return nil, fmt.Errorf("unrecognized expression '%s'", op.Key)
+}
+
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,339 @@
+package bsonquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Match returns true if the document matches the mongo query filter, the query operators of the in memory store are supported
+// the document and the filter can be of any type that marshals to a bson document
+func Match(doc, filter interface{}) (bool, error) {
+	d, err := ToDoc(doc)
+	if err != nil {
+		return false, err
+	}
+	f, err := ToDoc(filter)
+	if err != nil {
+		return false, err
+	}
+	return MatchDoc(d, f)
+}
+
+// MatchDoc returns true if the document matches the mongo query filter
+func MatchDoc(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		matched, err := matchElement(doc, e)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchElement(doc bson.D, e bson.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		subFilters, err := ToDocs(e.Value)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", e.Key, err)
+		}
+		if len(subFilters) == 0 {
+			return false, fmt.Errorf("%s must be a nonempty array", e.Key)
+		}
+		for _, sub := range subFilters {
+			matched, err := MatchDoc(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case e.Key == "$and" && !matched:
+				return false, nil
+			case e.Key == "$or" && matched:
+				return true, nil
+			case e.Key == "$nor" && matched:
+				return false, nil
+			}
+		}
+		return e.Key != "$or", nil
+	case "$expr":
+		value, err := EvalExpression(doc, e.Value)
+		if err != nil {
+			return false, err
+		}
+		return IsTrue(value), nil
+	case "$comment":
+		return true, nil
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, fmt.Errorf("unknown top level operator: %s", e.Key)
+	}
+	return MatchCondition(LookupValues(doc, e.Key), e.Value)
+}
+
+// MatchCondition matches the values found in a path against a condition (a value or an operators document)
+func MatchCondition(values []interface{}, condition interface{}) (bool, error) {
+	if ops, ok := condition.(bson.D); ok && IsOperatorsDoc(ops) {
+		for _, op := range ops {
+			matched, err := matchOperator(values, op, ops)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if regex, ok := condition.(primitive.Regex); ok {
+		return matchRegex(values, regex)
+	}
+	return MatchEquals(values, condition), nil
+}
+
+// IsOperatorsDoc returns true if the document is a field condition (e.g. {$gt: 1}) and not a query (e.g. {$or: [...]} or {field: 1})
+func IsOperatorsDoc(d bson.D) bool {
+	if len(d) == 0 || !strings.HasPrefix(d[0].Key, "$") {
+		return false
+	}
+	switch d[0].Key {
+	case "$and", "$or", "$nor", "$expr", "$comment":
+		return false
+	}
+	return true
+}
+
+func MatchEquals(values []interface{}, value interface{}) bool {
+	if len(values) == 0 {
+		return value == nil || value == primitive.Null{}
+	}
+	for _, v := range values {
+		if EqualValues(v, value) {
+			return true
+		}
+		if value == nil && (v == nil || v == primitive.Null{}) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperator(values []interface{}, op bson.E, ops bson.D) (bool, error) {
+	switch op.Key {
+	case "$eq":
+		return MatchEquals(values, op.Value), nil
+	case "$ne":
+		return !MatchEquals(values, op.Value), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, v := range values {
+			if typeOrder(v) != typeOrder(op.Value) {
+				continue
+			}
+			c := CompareValues(v, op.Value)
+			if (op.Key == "$gt" && c > 0) || (op.Key == "$gte" && c >= 0) ||
+				(op.Key == "$lt" && c < 0) || (op.Key == "$lte" && c <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$in", "$nin":
+		candidates, ok := op.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array", op.Key)
+		}
+		found := false
+		for _, candidate := range candidates {
+			var matched bool
+			if regex, ok := candidate.(primitive.Regex); ok {
+				var err error
+				if matched, err = matchRegex(values, regex); err != nil {
+					return false, err
+				}
+			} else {
+				matched = MatchEquals(values, candidate)
+			}
+			if matched {
+				found = true
+				break
+			}
+		}
+		return found == (op.Key == "$in"), nil
+	case "$exists":
+		return (len(values) > 0) == IsTrue(op.Value), nil
+	case "$regex":
+		regex, err := regexFromOperator(op.Value, ops)
+		if err != nil {
+			return false, err
+		}
+		return matchRegex(values, regex)
+	case "$options":
+		return true, nil
+	case "$not":
+		matched, err := MatchCondition(values, op.Value)
+		return !matched, err
+	case "$size":
+		size, ok := ToInt64(op.Value)
+		if !ok {
+			return false, fmt.Errorf("$size needs a number")
+		}
+		for _, v := range values {
+			if arr, ok := v.(bson.A); ok && int64(len(arr)) == size {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$all":
+		candidates, ok := op.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("$all needs an array")
+		}
+		for _, candidate := range candidates {
+			if !MatchEquals(values, candidate) {
+				return false, nil
+			}
+		}
+		return len(candidates) > 0, nil
+	case "$elemMatch":
+		cond, ok := op.Value.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("$elemMatch needs an Object")
+		}
+		for _, v := range values {
+			arr, ok := v.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, item := range arr {
+				matched, err := matchElem(item, cond)
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown operator: %s", op.Key)
+}
+
+// matchElem matches an array element against an $elemMatch condition
+func matchElem(item interface{}, cond bson.D) (bool, error) {
+	if IsOperatorsDoc(cond) {
+		return MatchCondition([]interface{}{item}, cond)
+	}
+	doc, ok := item.(bson.D)
+	if !ok {
+		return false, nil
+	}
+	return MatchDoc(doc, cond)
+}
+
+func regexFromOperator(value interface{}, ops bson.D) (primitive.Regex, error) {
+	options := ""
+	if opt, ok := GetField(ops, "$options"); ok {
+		options = fmt.Sprint(opt)
+	}
+	switch r := value.(type) {
+	case primitive.Regex:
+		if options != "" {
+			r.Options = options
+		}
+		return r, nil
+	case string:
+		return primitive.Regex{Pattern: r, Options: options}, nil
+	}
+	return primitive.Regex{}, fmt.Errorf("$regex has to be a string")
+}
+
+func compileRegex(regex primitive.Regex) (*regexp.Regexp, error) {
+	flags := ""
+	for _, o := range regex.Options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		}
+	}
+	pattern := regex.Pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+func matchRegex(values []interface{}, regex primitive.Regex) (bool, error) {
+	re, err := compileRegex(regex)
+	if err != nil {
+		return false, fmt.Errorf("invalid regular expression %s: %w", regex.Pattern, err)
+	}
+	for _, v := range values {
+		if s, ok := v.(string); ok && re.MatchString(s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FirstMatchingIndex returns the index of the first array element in path that matches the filter, used by the positional $ update operator
+func FirstMatchingIndex(doc bson.D, filter bson.D, arrayPath string) int {
+	values := LookupParts(doc, strings.Split(arrayPath, "."), false)
+	if len(values) != 1 {
+		return -1
+	}
+	arr, ok := values[0].(bson.A)
+	if !ok {
+		return -1
+	}
+	for _, e := range FlattenAnd(filter) {
+		if !strings.HasPrefix(e.Key, arrayPath) {
+			continue
+		}
+		rest := strings.TrimPrefix(strings.TrimPrefix(e.Key, arrayPath), ".")
+		for i, item := range arr {
+			var matched bool
+			var err error
+			switch {
+			case rest == "":
+				if elemCond, ok := elemMatchCondition(e.Value); ok {
+					matched, err = matchElem(item, elemCond)
+				} else {
+					matched, err = MatchCondition(LookupParts(item, nil, true), e.Value)
+				}
+			default:
+				matched, err = MatchCondition(LookupValues(item, rest), e.Value)
+			}
+			if err == nil && matched {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// elemMatchCondition returns the condition of an {$elemMatch: condition} value
+func elemMatchCondition(v interface{}) (bson.D, bool) {
+	cond, ok := v.(bson.D)
+	if !ok || len(cond) != 1 || cond[0].Key != "$elemMatch" {
+		return nil, false
+	}
+	elemCond, ok := cond[0].Value.(bson.D)
+	return elemCond, ok
+}
+
+// FlattenAnd returns the filter elements including the ones nested in $and
+func FlattenAnd(filter bson.D) bson.D {
+	flat := bson.D{}
+	for _, e := range filter {
+		if e.Key != "$and" {
+			flat = append(flat, e)
+			continue
+		}
+		if subFilters, err := ToDocs(e.Value); err == nil {
+			for _, sub := range subFilters {
+				flat = append(flat, FlattenAnd(sub)...)
+			}
+		}
+	}
+	return flat
+}
@@ -0,0 +1,494 @@
+package bsonquery
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ToDoc converts any bson marshalable value (struct, map, bson.D, bson.M) to a bson.D with normalized values
+func ToDoc(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	if d, ok := v.(bson.D); ok && isNormalized(d) {
+		return d, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+	doc := bson.D{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document: %w", err)
+	}
+	return doc, nil
+}
+
+// ToDocs converts a slice of bson marshalable values (e.g. mongo.Pipeline, []bson.M) to a slice of bson.D
+func ToDocs(v interface{}) ([]bson.D, error) {
+	if docs, ok := v.([]bson.D); ok {
+		return docs, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected an array of documents got %T", v)
+	}
+	docs := make([]bson.D, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		doc, err := ToDoc(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// isNormalized returns true if the document holds only values produced by bson unmarshal into bson.D
+func isNormalized(d bson.D) bool {
+	for _, e := range d {
+		if !isNormalizedValue(e.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNormalizedValue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil, string, bool, int32, int64, float64, primitive.DateTime, primitive.ObjectID,
+		primitive.Regex, primitive.Binary, primitive.Timestamp, primitive.Decimal128, primitive.Null:
+		return true
+	case bson.D:
+		return isNormalized(val)
+	case bson.A:
+		for i := range val {
+			if !isNormalizedValue(val[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// NormalizeValue converts a single go value to its normalized bson representation
+func NormalizeValue(v interface{}) (interface{}, error) {
+	if isNormalizedValue(v) {
+		return v, nil
+	}
+	doc, err := ToDoc(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil, err
+	}
+	return doc[0].Value, nil
+}
+
+func CloneDoc(d bson.D) bson.D {
+	if d == nil {
+		return nil
+	}
+	clone := make(bson.D, len(d))
+	for i := range d {
+		clone[i] = bson.E{Key: d[i].Key, Value: CloneValue(d[i].Value)}
+	}
+	return clone
+}
+
+func CloneValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bson.D:
+		return CloneDoc(val)
+	case bson.A:
+		clone := make(bson.A, len(val))
+		for i := range val {
+			clone[i] = CloneValue(val[i])
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// GetField returns the value of a top level key in a document
+func GetField(d bson.D, key string) (interface{}, bool) {
+	for i := range d {
+		if d[i].Key == key {
+			return d[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// LookupValues returns all the values found in path, arrays in the middle of the path are traversed
+// when the last value is an array, both the array and its elements are returned (mongo query semantics)
+func LookupValues(v interface{}, path string) []interface{} {
+	return LookupParts(v, strings.Split(path, "."), true)
+}
+
+func LookupParts(v interface{}, parts []string, expandLeafArrays bool) []interface{} {
+	if len(parts) == 0 {
+		if arr, ok := v.(bson.A); ok && expandLeafArrays {
+			return append([]interface{}{arr}, arr...)
+		}
+		return []interface{}{v}
+	}
+	switch val := v.(type) {
+	case bson.D:
+		if field, ok := GetField(val, parts[0]); ok {
+			return LookupParts(field, parts[1:], expandLeafArrays)
+		}
+	case bson.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+			if index >= 0 && index < len(val) {
+				return LookupParts(val[index], parts[1:], expandLeafArrays)
+			}
+			return nil
+		}
+		values := []interface{}{}
+		for i := range val {
+			if _, ok := val[i].(bson.D); ok {
+				values = append(values, LookupParts(val[i], parts, expandLeafArrays)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// ResolvePath returns the value in path as used by aggregation expressions (arrays in the middle of the path are mapped)
+func ResolvePath(v interface{}, parts []string) (interface{}, bool) {
+	if len(parts) == 0 {
+		return v, true
+	}
+	switch val := v.(type) {
+	case bson.D:
+		if field, ok := GetField(val, parts[0]); ok {
+			return ResolvePath(field, parts[1:])
+		}
+	case bson.A:
+		values := bson.A{}
+		for i := range val {
+			if item, ok := ResolvePath(val[i], parts); ok {
+				values = append(values, item)
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// SetPath sets value in path creating missing embedded documents
+func SetPath(d bson.D, parts []string, value interface{}) (bson.D, error) {
+	for i := range d {
+		if d[i].Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			d[i].Value = value
+			return d, nil
+		}
+		newValue, err := setValuePath(d[i].Value, parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		d[i].Value = newValue
+		return d, nil
+	}
+	if len(parts) == 1 {
+		return append(d, bson.E{Key: parts[0], Value: value}), nil
+	}
+	newValue, err := setValuePath(bson.D{}, parts[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return append(d, bson.E{Key: parts[0], Value: newValue}), nil
+}
+
+func setValuePath(current interface{}, parts []string, value interface{}) (interface{}, error) {
+	switch val := current.(type) {
+	case bson.D:
+		return SetPath(val, parts, value)
+	case bson.A:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("cannot create field '%s' in array", parts[0])
+		}
+		for len(val) <= index {
+			val = append(val, nil)
+		}
+		if len(parts) == 1 {
+			val[index] = value
+			return val, nil
+		}
+		item := val[index]
+		if item == nil {
+			item = bson.D{}
+		}
+		newItem, err := setValuePath(item, parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		val[index] = newItem
+		return val, nil
+	case nil:
+		return SetPath(bson.D{}, parts, value)
+	default:
+		return nil, fmt.Errorf("cannot create field '%s' in element of type %T", parts[0], current)
+	}
+}
+
+// UnsetPath removes the value in path, arrays in the middle of the path are traversed
+func UnsetPath(v interface{}, parts []string) interface{} {
+	switch val := v.(type) {
+	case bson.D:
+		for i := range val {
+			if val[i].Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				return append(val[:i:i], val[i+1:]...)
+			}
+			val[i].Value = UnsetPath(val[i].Value, parts[1:])
+			return val
+		}
+	case bson.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+			if index >= 0 && index < len(val) {
+				if len(parts) == 1 {
+					val[index] = nil
+				} else {
+					val[index] = UnsetPath(val[index], parts[1:])
+				}
+			}
+			return val
+		}
+		for i := range val {
+			val[i] = UnsetPath(val[i], parts)
+		}
+	}
+	return v
+}
+
+// typeOrder returns the bson comparison order of the value type
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 0
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, int, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime, time.Time:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	default:
+		return 100
+	}
+}
+
+func ToFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func ToInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		if n == math.Trunc(n) {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// CompareValues compares two normalized values using bson comparison order
+func CompareValues(a, b interface{}) int {
+	oa, ob := typeOrder(a), typeOrder(b)
+	if oa != ob {
+		return oa - ob
+	}
+	switch va := a.(type) {
+	case int32, int64, int, float64, primitive.Decimal128:
+		if ia, ok := ToInt64(va); ok {
+			if ib, ok := ToInt64(b); ok {
+				return compareOrdered(ia, ib)
+			}
+		}
+		fa, _ := ToFloat(va)
+		fb, _ := ToFloat(b)
+		return compareOrdered(fa, fb)
+	case string:
+		return strings.Compare(va, fmt.Sprint(b))
+	case bool:
+		vb := b.(bool)
+		if va == vb {
+			return 0
+		}
+		if !va {
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareOrdered(int64(va), dateTimeOf(b))
+	case time.Time:
+		return compareOrdered(primitive.NewDateTimeFromTime(va), primitive.DateTime(dateTimeOf(b)))
+	case primitive.ObjectID:
+		vb := b.(primitive.ObjectID)
+		return bytes.Compare(va[:], vb[:])
+	case bson.D:
+		vb := b.(bson.D)
+		for i := 0; i < len(va) && i < len(vb); i++ {
+			if c := strings.Compare(va[i].Key, vb[i].Key); c != 0 {
+				return c
+			}
+			if c := CompareValues(va[i].Value, vb[i].Value); c != 0 {
+				return c
+			}
+		}
+		return len(va) - len(vb)
+	case bson.A:
+		vb := b.(bson.A)
+		for i := 0; i < len(va) && i < len(vb); i++ {
+			if c := CompareValues(va[i], vb[i]); c != 0 {
+				return c
+			}
+		}
+		return len(va) - len(vb)
+	case primitive.Binary:
+		return bytes.Compare(va.Data, b.(primitive.Binary).Data)
+	case primitive.Timestamp:
+		vb := b.(primitive.Timestamp)
+		return compareOrdered(uint64(va.T)<<32|uint64(va.I), uint64(vb.T)<<32|uint64(vb.I))
+	case primitive.Regex:
+		return strings.Compare(va.String(), b.(primitive.Regex).String())
+	}
+	return 0
+}
+
+func dateTimeOf(v interface{}) int64 {
+	switch t := v.(type) {
+	case primitive.DateTime:
+		return int64(t)
+	case time.Time:
+		return int64(primitive.NewDateTimeFromTime(t))
+	}
+	return 0
+}
+
+func compareOrdered[T int64 | uint64 | float64 | primitive.DateTime](a, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func EqualValues(a, b interface{}) bool {
+	return typeOrder(a) == typeOrder(b) && CompareValues(a, b) == 0
+}
+
+// ValueKey returns a string key that is equal for equal values, used for grouping and sets
+func ValueKey(v interface{}) string {
+	if f, ok := ToFloat(v); ok {
+		return "n:" + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	raw, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return fmt.Sprintf("%T:%v", v, v)
+	}
+	return string(raw)
+}
+
+func IsTrue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return false
+	case bool:
+		return val
+	}
+	if f, ok := ToFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// ContainsValue returns true if the array has an item equal to the value
+func ContainsValue(arr bson.A, value interface{}) bool {
+	for _, item := range arr {
+		if EqualValues(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddNumbers returns the sum of two numbers, a float64 if one of them is a float64, an int32 if both are int32 and the sum fits, otherwise an int64
+func AddNumbers(a, b interface{}) (interface{}, error) {
+	if _, ok := ToFloat(b); !ok {
+		return nil, fmt.Errorf("cannot increment with non-numeric argument")
+	}
+	if _, ok := ToFloat(a); !ok {
+		return nil, fmt.Errorf("cannot apply to a value of non-numeric type %T", a)
+	}
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	if aFloat || bFloat {
+		fa, _ := ToFloat(a)
+		fb, _ := ToFloat(b)
+		return fa + fb, nil
+	}
+	ia, _ := ToInt64(a)
+	ib, _ := ToInt64(b)
+	sum := ia + ib
+	_, a32 := a.(int32)
+	_, b32 := b.(int32)
+	if a32 && b32 && sum == int64(int32(sum)) {
+		return int32(sum), nil
+	}
+	return sum, nil
+}
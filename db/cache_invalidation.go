@@ -0,0 +1,170 @@
+package db
+
+import (
+	"config-service/db/store"
+	"config-service/utils/consts"
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	//invalidations are kept for the instances that poll them
+	cacheInvalidationRetention = time.Hour
+	//invalidations are polled from before the last poll so invalidations of instances with skewed clocks are not missed
+	cacheInvalidationClockSkew = 5 * time.Second
+)
+
+// instanceID identifies the invalidations published by this instance, they are applied when published
+var instanceID = uuid.NewV4().String()
+
+// cacheInvalidation is a write of a collection with cached documents or query results, published to the other instances
+type cacheInvalidation struct {
+	GUID         string    `bson:"_id"`
+	Collection   string    `bson:"collection"`
+	CustomerGUID string    `bson:"customerGUID,omitempty"` //empty when documents of any customer were written
+	InstanceID   string    `bson:"instanceID"`
+	Timestamp    time.Time `bson:"timestamp"`
+	ExpiryTime   time.Time `bson:"expiryTime"`
+}
+
+// publishCacheInvalidation invalidates the cached documents of the collection and publishes the write to the other instances
+// writes of collections without cached documents or query results are not published
+func publishCacheInvalidation(c context.Context, collection, customerGUID string) {
+	if !hasCachedDocuments(collection) && !isQueryCached(collection) {
+		return
+	}
+	invalidateCachedDocuments(collection)
+	now := time.Now().UTC()
+	invalidation := cacheInvalidation{
+		GUID:         uuid.NewV4().String(),
+		Collection:   collection,
+		CustomerGUID: customerGUID,
+		InstanceID:   instanceID,
+		Timestamp:    now,
+		ExpiryTime:   now.Add(cacheInvalidationRetention),
+	}
+	//the write is done, other instances that miss the invalidation refresh their cached documents and query results when they expire
+	if _, err := dbStore.GetWriteCollection(consts.CacheInvalidationsCollection).InsertOne(context.WithoutCancel(c), invalidation); err != nil {
+		zap.L().Error("failed to publish cache invalidation", zap.Error(err), zap.String("collection", collection))
+	}
+}
+
+// applyCacheInvalidation invalidates the cached documents and query results of a write of another instance
+func applyCacheInvalidation(collection, customerGUID string) {
+	cache.invalidate(collection, customerGUID)
+	invalidateCachedDocuments(collection)
+}
+
+// RunCacheInvalidations applies the cache invalidations published by the other instances until the context is done
+// invalidations are read from a change stream when the store supports it, otherwise they are polled every poll interval
+func RunCacheInvalidations(ctx context.Context, pollInterval time.Duration) {
+	if collection, ok := getReadCollection(consts.CacheInvalidationsCollection).(store.ChangeStreamCollection); ok {
+		for {
+			err := watchCacheInvalidations(ctx, collection)
+			if isServerError(err, notReplicaSetErrorCode) {
+				zap.L().Info("change streams are not supported, polling cache invalidations")
+				break
+			} else if err != nil {
+				zap.L().Error("failed to watch cache invalidations", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+	}
+	pollCacheInvalidations(ctx, pollInterval)
+}
+
+// watchCacheInvalidations applies the invalidations read from the collection change stream
+func watchCacheInvalidations(ctx context.Context, collection store.ChangeStreamCollection) error {
+	pipeline := mongoDB.Pipeline{{{Key: "$match", Value: bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "fullDocument.instanceID", Value: bson.D{{Key: "$ne", Value: instanceID}}},
+	}}}}
+	stream, err := collection.Watch(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	//invalidations published while the stream was closed are missed
+	cachedDocuments.Range(func(_, i any) bool {
+		applyCacheInvalidation(i.(anyCachedDocument).collectionName(), "")
+		return true
+	})
+	queryCachedCollections.Range(func(collection, _ any) bool {
+		applyCacheInvalidation(collection.(string), "")
+		return true
+	})
+	for stream.Next(ctx) {
+		var change struct {
+			FullDocument cacheInvalidation `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		applyCacheInvalidation(change.FullDocument.Collection, change.FullDocument.CustomerGUID)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return stream.Err()
+}
+
+// pollCacheInvalidations applies the invalidations published since the last poll
+func pollCacheInvalidations(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	since := time.Now().UTC()
+	//the invalidations of the polled window that were applied, by id
+	applied := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pollStart := time.Now().UTC()
+			invalidations, err := findCacheInvalidations(ctx, since.Add(-cacheInvalidationClockSkew))
+			if err != nil {
+				zap.L().Error("failed to poll cache invalidations", zap.Error(err))
+				continue
+			}
+			for _, invalidation := range invalidations {
+				if _, ok := applied[invalidation.GUID]; !ok {
+					applied[invalidation.GUID] = invalidation.Timestamp
+					applyCacheInvalidation(invalidation.Collection, invalidation.CustomerGUID)
+				}
+			}
+			since = pollStart
+			for id, timestamp := range applied {
+				if timestamp.Before(since.Add(-cacheInvalidationClockSkew)) {
+					delete(applied, id)
+				}
+			}
+		}
+	}
+}
+
+// findCacheInvalidations returns the invalidations of the other instances published since the time
+func findCacheInvalidations(c context.Context, since time.Time) ([]cacheInvalidation, error) {
+	filter := NewFilterBuilder().
+		WithGreaterThanEqual("timestamp", since).
+		WithNotEqual("instanceID", instanceID)
+	cur, err := getReadCollection(consts.CacheInvalidationsCollection).Find(c, filter.get(), options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	invalidations := []cacheInvalidation{}
+	if err := cur.All(c, &invalidations); err != nil {
+		return nil, err
+	}
+	return invalidations, nil
+}
@@ -1,8 +1,8 @@
 package db
 
 import (
-	"config-service/db/mongo"
 	"config-service/types"
+	"config-service/utils/metrics"
 	"context"
 	"fmt"
 	"sync"
@@ -15,7 +15,9 @@ import (
 var cachedDocuments = sync.Map{}
 
 func AddCachedDocument[T types.DocContent](cacheKey, collection string, filterBuilder *FilterBuilder, updateInterval time.Duration) {
-	cachedDocuments.Store(cacheKey, newCachedDocument[T](collection, filterBuilder.get(), updateInterval))
+	cachedDoc := newCachedDocument[T](cacheKey, collection, filterBuilder.get(), updateInterval)
+	cachedDocuments.Store(cacheKey, cachedDoc)
+	metrics.SetCacheLastRefreshFunc(cacheKey, cachedDoc.lastRefresh)
 }
 
 func GetCachedDocument[T types.DocContent](cacheKey string) (T, error) {
@@ -28,18 +30,66 @@ func GetCachedDocument[T types.DocContent](cacheKey string) (T, error) {
 	return nil, fmt.Errorf("cached document %s not found", cacheKey)
 }
 
+// CheckCachedDocument refreshes a cached document if needed and returns an error if the document was never refreshed or is stale
+// a document is stale if it was not refreshed for two update intervals
+func CheckCachedDocument(cacheKey string) error {
+	i, ok := cachedDocuments.Load(cacheKey)
+	if !ok {
+		return fmt.Errorf("cached document %s not found", cacheKey)
+	}
+	lastRefresh, updateInterval, err := i.(anyCachedDocument).status()
+	if lastRefresh.IsZero() {
+		return fmt.Errorf("failed to refresh cached document %s: %w", cacheKey, err)
+	}
+	if age := time.Since(lastRefresh); age > 2*updateInterval {
+		return fmt.Errorf("cached document %s was last refreshed %s ago", cacheKey, age.Round(time.Second))
+	}
+	return nil
+}
+
+// anyCachedDocument is implemented by the cached documents of all types
+type anyCachedDocument interface {
+	status() (lastRefresh time.Time, updateInterval time.Duration, err error)
+	// invalidate refreshes the document on the next get
+	invalidate()
+	collectionName() string
+}
+
+// invalidateCachedDocuments refreshes the cached documents of the collection on their next get
+func invalidateCachedDocuments(collection string) {
+	cachedDocuments.Range(func(_, i any) bool {
+		if cachedDoc := i.(anyCachedDocument); cachedDoc.collectionName() == collection {
+			cachedDoc.invalidate()
+		}
+		return true
+	})
+}
+
+// hasCachedDocuments returns true if documents of the collection are cached
+func hasCachedDocuments(collection string) bool {
+	found := false
+	cachedDocuments.Range(func(_, i any) bool {
+		found = i.(anyCachedDocument).collectionName() == collection
+		return !found
+	})
+	return found
+}
+
 type cachedDocument[T types.DocContent] struct {
+	key              string
 	doc              T
 	lastRefreshError error
 	timeUpdated      time.Time
+	invalidated      bool //the collection was written since the last refresh
 	mutex            sync.RWMutex
 	updateInterval   time.Duration
 	queryFilter      bson.D
 	collection       string
 }
 
-func newCachedDocument[T types.DocContent](collection string, queryFilter bson.D, updateInterval time.Duration) *cachedDocument[T] {
+func newCachedDocument[T types.DocContent](key, collection string, queryFilter bson.D, updateInterval time.Duration) *cachedDocument[T] {
 	return &cachedDocument[T]{
+		key:            key,
 		doc:            nil,
 		updateInterval: updateInterval,
 		queryFilter:    queryFilter,
@@ -49,28 +99,68 @@ func newCachedDocument[T types.DocContent](collection string, queryFilter bson.D
 	}
 }
 
+// get returns the document, the last refreshed document is returned when a refresh fails and the error only when it was never refreshed
 func (c *cachedDocument[T]) get() (T, error) {
 	c.refresh()
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return c.doc, c.lastRefreshError
+	if c.timeUpdated.IsZero() {
+		return c.doc, c.lastRefreshError
+	}
+	return c.doc, nil
+}
+
+func (c *cachedDocument[T]) needsRefresh() bool {
+	return c.invalidated || time.Since(c.timeUpdated) > c.updateInterval
 }
 
 func (c *cachedDocument[T]) refresh() {
-	if time.Since(c.timeUpdated) > c.updateInterval {
-		c.mutex.Lock()
-		defer c.mutex.Unlock()
-		//check if not updated by another thread
-		if time.Since(c.timeUpdated) > c.updateInterval {
-			var doc T
-			if err := mongo.GetReadCollection(c.collection).FindOne(context.Background(), c.queryFilter).Decode(&doc); err != nil {
-				zap.L().Error("Failed to refresh cached document", zap.Error(err), zap.String("collection", c.collection), zap.Any("queryFilter", c.queryFilter))
-				c.lastRefreshError = err
-				return
-			}
-			c.doc = doc
-			c.lastRefreshError = nil
-			c.timeUpdated = time.Now()
-		}
+	c.mutex.RLock()
+	needsRefresh := c.needsRefresh()
+	c.mutex.RUnlock()
+	if !needsRefresh {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	//check if not updated by another thread
+	if !c.needsRefresh() {
+		return
 	}
+	var doc T
+	if err := getReadCollection(c.collection).FindOne(context.Background(), c.queryFilter).Decode(&doc); err != nil {
+		zap.L().Error("Failed to refresh cached document", zap.Error(err), zap.String("collection", c.collection), zap.Any("queryFilter", c.queryFilter))
+		c.lastRefreshError = err
+		metrics.CacheRefreshes.WithLabelValues(c.key, metrics.RefreshFailure).Inc()
+		return
+	}
+	c.doc = doc
+	c.lastRefreshError = nil
+	c.timeUpdated = time.Now()
+	c.invalidated = false
+	metrics.CacheRefreshes.WithLabelValues(c.key, metrics.RefreshSuccess).Inc()
+}
+
+func (c *cachedDocument[T]) status() (time.Time, time.Duration, error) {
+	c.refresh()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.timeUpdated, c.updateInterval, c.lastRefreshError
+}
+
+func (c *cachedDocument[T]) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.invalidated = true
+}
+
+func (c *cachedDocument[T]) collectionName() string {
+	return c.collection
+}
+
+// lastRefresh returns the time of the last successful refresh, zero if the document was not refreshed yet
+func (c *cachedDocument[T]) lastRefresh() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.timeUpdated
 }
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCachedDocument(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	c := context.WithValue(context.Background(), consts.CustomerGUID, "customer1")
+	c = context.WithValue(c, consts.Collection, consts.ClustersCollection)
+	clusters := GetStore().GetWriteCollection(consts.ClustersCollection)
+	doc := types.NewDocument(&types.Cluster{PortalBase: armotypes.PortalBase{Name: "cluster1"}}, "customer1")
+	_, err := clusters.InsertOne(c, doc)
+	assert.NoError(t, err)
+	AddCachedDocument[*types.Cluster]("cachedCluster", consts.ClustersCollection, NewFilterBuilder().WithID(doc.ID), time.Hour)
+	cachedName := func() string {
+		cluster, err := GetCachedDocument[*types.Cluster]("cachedCluster")
+		assert.NoError(t, err)
+		return cluster.Name
+	}
+	setName := func(name string) {
+		_, err := clusters.UpdateOne(c, bson.D{{Key: consts.IdField, Value: doc.ID}}, bson.D{{Key: "$set", Value: bson.D{{Key: consts.NameField, Value: name}}}})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "cluster1", cachedName())
+
+	//writes through the db package refresh the cached documents of the collection and are published to the other instances
+	_, err = UpdateDocument[*types.Cluster](c, doc.ID, bson.D{{Key: "$set", Value: bson.D{{Key: consts.NameField, Value: "cluster2"}}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "cluster2", cachedName())
+	invalidations, err := findCacheInvalidations(c, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, invalidations, "the invalidations of the instance are not applied again")
+	count, err := GetStore().GetReadCollection(consts.CacheInvalidationsCollection).CountDocuments(c, bson.D{{Key: "collection", Value: consts.ClustersCollection}, {Key: "customerGUID", Value: "customer1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	//writes of other instances refresh the cached documents when their invalidations are polled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunCacheInvalidations(ctx, 10*time.Millisecond)
+	setName("cluster3")
+	assert.Equal(t, "cluster2", cachedName())
+	now := time.Now().UTC()
+	_, err = GetStore().GetWriteCollection(consts.CacheInvalidationsCollection).InsertOne(c, cacheInvalidation{
+		GUID:       "invalidation1",
+		Collection: consts.ClustersCollection,
+		InstanceID: "other-instance",
+		Timestamp:  now,
+		ExpiryTime: now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return cachedName() == "cluster3" }, time.Second, 10*time.Millisecond)
+
+	//the last refreshed document is returned when a refresh fails
+	_, err = clusters.DeleteOne(c, bson.D{{Key: consts.IdField, Value: doc.ID}})
+	assert.NoError(t, err)
+	invalidateCachedDocuments(consts.ClustersCollection)
+	assert.Equal(t, "cluster3", cachedName())
+	assert.NoError(t, CheckCachedDocument("cachedCluster"))
+
+	//the refresh error is returned when the document was never refreshed
+	AddCachedDocument[*types.Cluster]("missingCluster", consts.ClustersCollection, NewFilterBuilder().WithID("missing"), time.Hour)
+	_, err = GetCachedDocument[*types.Cluster]("missingCluster")
+	assert.Error(t, err)
+	assert.Error(t, CheckCachedDocument("missingCluster"))
+}
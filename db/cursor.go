@@ -0,0 +1,259 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/exp/slices"
+)
+
+// pageCursor is the position of the last document of a page, clients get it as an opaque token to request the next page
+type pageCursor struct {
+	Sort   bson.D `bson:"sort"`   //the sort of the page ending with the unique tie breaker key, the next page must use the same sort
+	Values bson.A `bson:"values"` //the sort values of the last document, unique because of the tie breaker key
+	Offset int64  `bson:"offset"` //the number of documents up to the last document, the total of the next page is counted from it
+}
+
+// pagePosition is the sort of a page and the cursor it continues from (if any)
+type pagePosition struct {
+	sort     bson.D
+	previous *pageCursor
+	skip     int64
+}
+
+// InvalidCursorError is returned when a cursor token cannot be decoded or does not match the query sort
+type InvalidCursorError struct {
+	Reason string
+}
+
+func (e InvalidCursorError) Error() string {
+	return "invalid cursor: " + e.Reason
+}
+
+func IsInvalidCursorError(err error) bool {
+	return errors.As(err, &InvalidCursorError{})
+}
+
+// WithCursor sets the cursor token of the previous page, the results start after the cursor position instead of skipping documents
+func (f *FindOptions) WithCursor(cursor string) *FindOptions {
+	f.cursor = cursor
+	return f
+}
+
+// pageStages returns the aggregation stages of a page of results that follow the filter stages, nil if there is no page limit
+// sorted documents are sorted by the sort keys and then by the tie breaker key that must be unique (e.g. _id), unsorted documents keep their natural order
+// the page starts after the cursor when set and after the skipped documents otherwise, the cursor filter precedes the sort and the facet so the indexes are used
+// the cursor of unsorted documents has no sort values, the page skips the documents up to the cursor offset
+// the facet has the page results, the sort values of the last document of a full sorted page and the count of the documents that follow the cursor
+func (f *FindOptions) pageStages(tieBreaker string) (stages mongoDB.Pipeline, position *pagePosition, err error) {
+	if f.limit <= 0 {
+		return nil, nil, nil
+	}
+	position = &pagePosition{sort: bson.D{}, skip: f.skip}
+	if f.sort != nil {
+		position.sort = append(position.sort, f.sort.get()...)
+	}
+	if len(position.sort) > 0 && !slices.ContainsFunc(position.sort, func(e bson.E) bool { return e.Key == tieBreaker }) {
+		position.sort = append(position.sort, bson.E{Key: tieBreaker, Value: 1})
+	}
+	if f.cursor != "" {
+		previous, err := decodeCursor(f.cursor, position.sort)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(position.sort) == 0 {
+			position.skip = previous.Offset
+		} else {
+			stages = append(stages, bson.D{{Key: "$match", Value: previous.filter()}})
+			position.previous, position.skip = previous, 0
+		}
+	}
+	if len(position.sort) > 0 {
+		stages = append(stages, bson.D{{Key: "$sort", Value: position.sort}})
+	}
+	results := []bson.M{}
+	if position.skip > 0 {
+		results = append(results, bson.M{"$skip": position.skip})
+	}
+	results = append(results, bson.M{"$limit": f.limit})
+	if f.Projection().Len() > 0 {
+		results = append(results, bson.M{"$project": f.projection.get()})
+	}
+	keys := bson.D{}
+	for _, e := range position.sort {
+		keys = append(keys, bson.E{Key: e.Key, Value: 1})
+	}
+	facet := bson.M{
+		"limitedResults": results,
+		"count": []bson.M{
+			{"$count": "count"},
+		},
+	}
+	if len(keys) > 0 {
+		facet["sortValues"] = []bson.M{
+			{"$skip": position.skip + f.limit - 1},
+			{"$limit": 1},
+			{"$project": keys},
+		}
+	}
+	return append(stages, bson.D{{Key: "$facet", Value: facet}}), position, nil
+}
+
+// total returns the count of all the matching documents from the count of the documents that follow the cursor
+func (p *pagePosition) total(count int64) int64 {
+	if p == nil || p.previous == nil {
+		return count
+	}
+	return p.previous.Offset + count
+}
+
+// nextPageCursor returns the cursor token of the last document of a full page, empty if there are no more pages
+func (p *pagePosition) nextPageCursor(pageSize int, limit int64, sortValues []bson.Raw) (string, error) {
+	if p == nil || pageSize == 0 || int64(pageSize) < limit {
+		return "", nil
+	}
+	values := bson.A{}
+	if len(p.sort) > 0 {
+		if len(sortValues) != 1 {
+			return "", errors.New("missing sort values of the last page document")
+		}
+		var err error
+		if values, err = docSortValues(sortValues[0], p.sort); err != nil {
+			return "", err
+		}
+	}
+	cursor := pageCursor{Sort: p.sort, Values: values, Offset: p.skip + int64(pageSize)}
+	if p.previous != nil {
+		cursor.Offset += p.previous.Offset
+	}
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the page cursor of a token, the cursor must belong to a query with the given sort
+func decodeCursor(token string, sort bson.D) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, InvalidCursorError{Reason: "malformed token"}
+	}
+	cursor := &pageCursor{}
+	if err := bson.Unmarshal(data, cursor); err != nil || len(cursor.Values) != len(cursor.Sort) || cursor.Offset < 1 {
+		return nil, InvalidCursorError{Reason: "malformed token"}
+	}
+	if !sameSort(cursor.Sort, sort) {
+		return nil, InvalidCursorError{Reason: "the cursor belongs to a query with another order"}
+	}
+	//the token is not signed, the values are matched as is and must not have query operators
+	for _, value := range cursor.Values {
+		if hasOperatorKeys(value) {
+			return nil, InvalidCursorError{Reason: "malformed token"}
+		}
+	}
+	return cursor, nil
+}
+
+// hasOperatorKeys returns true if a value is or has a document with a $ prefixed key
+func hasOperatorKeys(value interface{}) bool {
+	switch v := value.(type) {
+	case bson.D:
+		for _, e := range v {
+			if strings.HasPrefix(e.Key, "$") || hasOperatorKeys(e.Value) {
+				return true
+			}
+		}
+	case bson.M:
+		for key, value := range v {
+			if strings.HasPrefix(key, "$") || hasOperatorKeys(value) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, value := range v {
+			if hasOperatorKeys(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filter returns the filter of the documents following the cursor sort values in the sort order
+func (p *pageCursor) filter() bson.D {
+	//documents with the same values of the preceding sort keys and a following value of the sort key
+	branches := bson.A{}
+	for i, e := range p.Sort {
+		for _, condition := range afterConditions(sortDirection(e.Value), p.Values[i]) {
+			branches = append(branches, append(p.equalValues(i), bson.E{Key: e.Key, Value: condition}))
+		}
+	}
+	return bson.D{{Key: "$or", Value: branches}}
+}
+
+// equalValues returns the filter of the documents with the cursor values of the first n sort keys
+func (p *pageCursor) equalValues(n int) bson.D {
+	equal := bson.D{}
+	for i := 0; i < n; i++ {
+		equal = append(equal, bson.E{Key: p.Sort[i].Key, Value: p.Values[i]})
+	}
+	return equal
+}
+
+// afterConditions returns the conditions of the values that follow the given value in the sort direction
+// missing and null values are sorted before all other values
+func afterConditions(direction int, value interface{}) []interface{} {
+	switch {
+	case value == nil && direction > 0:
+		return []interface{}{bson.D{{Key: "$ne", Value: nil}}}
+	case value == nil:
+		return nil
+	case direction > 0:
+		return []interface{}{bson.D{{Key: "$gt", Value: value}}}
+	default:
+		return []interface{}{bson.D{{Key: "$lt", Value: value}}, nil}
+	}
+}
+
+// docSortValues returns the values of the sort keys in a document, missing values are null
+func docSortValues(doc bson.Raw, sort bson.D) (bson.A, error) {
+	values := bson.A{}
+	for _, e := range sort {
+		var value interface{}
+		if raw, err := doc.LookupErr(strings.Split(e.Key, ".")...); err == nil {
+			if err := raw.Unmarshal(&value); err != nil {
+				return nil, err
+			}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func sameSort(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || sortDirection(a[i].Value) != sortDirection(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortDirection(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
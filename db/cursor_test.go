@@ -0,0 +1,135 @@
+package db
+
+import (
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCursorPagination(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	c := context.WithValue(context.WithValue(context.Background(), consts.Collection, consts.RepositoryCollection), consts.CustomerGUID, "customer-guid")
+	repos := []*types.Repository{}
+	for i := 0; i < 11; i++ {
+		repo := &types.Repository{}
+		//names and teams with ties, some repositories without a team
+		repo.Name = fmt.Sprintf("repo%d", i%4)
+		if i%3 != 0 {
+			repo.Attributes = map[string]interface{}{"team": fmt.Sprintf("team%d", i%2)}
+		}
+		repos = append(repos, repo)
+	}
+	_, err := InsertDocuments(c, repos)
+	assert.NoError(t, err)
+
+	sorts := map[string]func(*FindOptions){
+		"no sort":         func(f *FindOptions) {},
+		"name ascending":  func(f *FindOptions) { f.Sort().AddAscending("name") },
+		"name descending": func(f *FindOptions) { f.Sort().AddDescending("name") },
+		"team and name":   func(f *FindOptions) { f.Sort().AddDescending("attributes.team").AddAscending("name") },
+		"team ascending":  func(f *FindOptions) { f.Sort().AddAscending("attributes.team") },
+	}
+	for name, addSort := range sorts {
+		t.Run(name, func(t *testing.T) {
+			//all results in one page
+			findOpts := NewFindOptions().SetPagination(0, 20)
+			addSort(findOpts)
+			all, err := FindPaginatedForCustomer[*types.Repository](c, findOpts)
+			assert.NoError(t, err)
+			assert.Len(t, all.Response, 11)
+			assert.Empty(t, all.Cursor)
+			//pages by cursor
+			pages := []*types.Repository{}
+			cursor := ""
+			for i := 0; i < 4; i++ {
+				findOpts := NewFindOptions().SetPagination(0, 3).WithCursor(cursor)
+				addSort(findOpts)
+				page, err := FindPaginatedForCustomer[*types.Repository](c, findOpts)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, 11, page.Total.Value)
+				pages = append(pages, page.Response...)
+				if cursor = page.Cursor; cursor == "" {
+					break
+				}
+			}
+			assert.Empty(t, cursor)
+			assert.Equal(t, all.Response, pages)
+		})
+	}
+
+	//unsorted documents keep their natural order
+	unsorted, err := FindPaginatedForCustomer[*types.Repository](c, NewFindOptions().SetPagination(0, 20))
+	assert.NoError(t, err)
+	for i := range repos {
+		assert.Equal(t, repos[i].GUID, unsorted.Response[i].GUID)
+	}
+
+	//the cursor filter precedes the sort and the facet, documents are ordered by _id after the sort keys
+	byName := func(cursor string) *FindOptions {
+		findOpts := NewFindOptions().SetPagination(0, 3).WithCursor(cursor)
+		findOpts.Sort().AddAscending("name")
+		return findOpts
+	}
+	page, err := FindPaginatedForCustomer[*types.Repository](c, byName(""))
+	assert.NoError(t, err)
+	stages, _, err := byName(page.Cursor).pageStages(consts.IdField)
+	assert.NoError(t, err)
+	if assert.Len(t, stages, 3) {
+		assert.Equal(t, "$match", stages[0][0].Key)
+		assert.Equal(t, bson.D{{Key: "name", Value: 1}, {Key: consts.IdField, Value: 1}}, stages[1][0].Value)
+		assert.Equal(t, "$facet", stages[2][0].Key)
+	}
+
+	//documents inserted before the cursor do not shift the next page, the total is counted from the cursor offset
+	next, err := FindPaginatedForCustomer[*types.Repository](c, byName(page.Cursor))
+	assert.NoError(t, err)
+	first := &types.Repository{}
+	first.Name = "a-first-repo"
+	_, err = InsertDocuments(c, []*types.Repository{first})
+	assert.NoError(t, err)
+	shifted, err := FindPaginatedForCustomer[*types.Repository](c, byName(page.Cursor))
+	assert.NoError(t, err)
+	assert.Equal(t, next.Response, shifted.Response)
+	assert.Equal(t, 11, shifted.Total.Value)
+	_, err = DeleteByGUID[*types.Repository](c, first.GUID)
+	assert.NoError(t, err)
+
+	//a full last page has a cursor of an empty page
+	findOpts := NewFindOptions().SetPagination(0, 11)
+	page, err = FindPaginatedForCustomer[*types.Repository](c, findOpts)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, page.Cursor)
+	page, err = FindPaginatedForCustomer[*types.Repository](c, NewFindOptions().SetPagination(0, 11).WithCursor(page.Cursor))
+	assert.NoError(t, err)
+	assert.Empty(t, page.Response)
+	assert.Empty(t, page.Cursor)
+	assert.Equal(t, 11, page.Total.Value)
+
+	//invalid cursors
+	cursorOpts := NewFindOptions().SetPagination(0, 3)
+	cursorOpts.Sort().AddAscending("name")
+	page, err = FindPaginatedForCustomer[*types.Repository](c, cursorOpts)
+	assert.NoError(t, err)
+	_, err = FindPaginatedForCustomer[*types.Repository](c, NewFindOptions().SetPagination(0, 3).WithCursor(page.Cursor))
+	assert.True(t, IsInvalidCursorError(err))
+	assert.EqualError(t, err, "invalid cursor: the cursor belongs to a query with another order")
+	_, err = FindPaginatedForCustomer[*types.Repository](c, NewFindOptions().SetPagination(0, 3).WithCursor("not a cursor"))
+	assert.EqualError(t, err, "invalid cursor: malformed token")
+	//cursor values with query operators
+	for _, value := range []interface{}{bson.D{{Key: "$ne", Value: nil}}, bson.A{bson.D{{Key: "a", Value: bson.D{{Key: "$gt", Value: ""}}}}}} {
+		data, err := bson.Marshal(pageCursor{Sort: bson.D{{Key: "name", Value: 1}, {Key: consts.IdField, Value: 1}}, Values: bson.A{value, "guid"}, Offset: 3})
+		assert.NoError(t, err)
+		_, err = FindPaginatedForCustomer[*types.Repository](c, byName(base64.RawURLEncoding.EncodeToString(data)))
+		assert.EqualError(t, err, "invalid cursor: malformed token")
+	}
+}
@@ -34,6 +34,12 @@ func (f *FilterBuilder) get() bson.D {
 	return f.filter
 }
 
+// ExtJSON returns the filter as a canonical extended JSON query that keeps the values types
+func (f *FilterBuilder) ExtJSON() (string, error) {
+	data, err := bson.MarshalExtJSON(f.get(), true, false)
+	return string(data), err
+}
+
 func (f *FilterBuilder) WithGlobal() *FilterBuilder {
 	return f.WithValue(consts.CustomersField, "")
 }
@@ -71,18 +77,50 @@ func (f *FilterBuilder) WithName(name string) *FilterBuilder {
 	return f.WithValue(consts.NameField, name)
 }
 
+// WithCustomer filters the customer documents, documents in the trash of a soft delete collection are excluded unless WithDeleted is used
 func (f *FilterBuilder) WithCustomer(c context.Context) *FilterBuilder {
 	customerGUID, _ := c.Value(consts.CustomerGUID).(string)
-	if collection, _ := c.Value(consts.Collection).(string); collection == consts.CustomersCollection {
+	collection, _ := c.Value(consts.Collection).(string)
+	if collection == consts.CustomersCollection {
 		f.customerIsID = true
-		return f.WithID(customerGUID)
+		return f.WithID(customerGUID).withNotDeletedIn(collection)
 	}
-	return f.WithValue(consts.CustomersField, customerGUID)
+	return f.WithValue(consts.CustomersField, customerGUID).withNotDeletedIn(collection)
 }
 
+// WithCustomerAndGlobal filters the customer and global documents, documents in the trash of a soft delete collection are excluded unless WithDeleted is used
 func (f *FilterBuilder) WithCustomerAndGlobal(c context.Context) *FilterBuilder {
 	customerGUID, _ := c.Value(consts.CustomerGUID).(string)
-	return f.WithIn(consts.CustomersField, []string{customerGUID, ""})
+	collection, _ := c.Value(consts.Collection).(string)
+	return f.WithIn(consts.CustomersField, []string{customerGUID, ""}).withNotDeletedIn(collection)
+}
+
+// WithDeleted filters only documents in the trash (soft deleted)
+func (f *FilterBuilder) WithDeleted() *FilterBuilder {
+	for i := range f.filter {
+		if f.filter[i].Key == consts.DeletedAtField {
+			f.filter[i].Value = bson.D{{Key: "$exists", Value: true}}
+			return f
+		}
+	}
+	return f.WithValue(consts.DeletedAtField, bson.D{{Key: "$exists", Value: true}})
+}
+
+// withNotDeletedIn excludes the documents in the trash when the collection is a soft delete collection
+func (f *FilterBuilder) withNotDeletedIn(collection string) *FilterBuilder {
+	if !IsSoftDelete(collection) {
+		return f
+	}
+	return f.withNotDeleted()
+}
+
+func (f *FilterBuilder) withNotDeleted() *FilterBuilder {
+	for i := range f.filter {
+		if f.filter[i].Key == consts.DeletedAtField {
+			return f
+		}
+	}
+	return f.WithValue(consts.DeletedAtField, bson.D{{Key: "$exists", Value: false}})
 }
 
 func (f *FilterBuilder) WithCustomers(customers []string) *FilterBuilder {
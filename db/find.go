@@ -8,6 +8,7 @@ type FindOptions struct {
 	group        []string
 	limit        int64
 	skip         int64
+	cursor       string //the cursor of the previous page, replaces skip
 }
 
 func NewFindOptions() *FindOptions {
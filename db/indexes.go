@@ -0,0 +1,38 @@
+package db
+
+import (
+	"config-service/db/store"
+	"config-service/types"
+	"config-service/utils/log"
+	"context"
+)
+
+// ReconcileIndexes creates the missing declared indexes of all the collections and reports the extra indexes
+// stores that do not manage indexes have no reports and no indexes usage
+func ReconcileIndexes(c context.Context) ([]types.IndexesReport, error) {
+	defer log.LogNTraceEnterExit("ReconcileIndexes", c)()
+	indexStore, ok := dbStore.(store.IndexStore)
+	if !ok {
+		return []types.IndexesReport{}, nil
+	}
+	return indexStore.ReconcileIndexes(c)
+}
+
+// GetIndexesReports returns the last indexes reconciliation report of each collection
+func GetIndexesReports() []types.IndexesReport {
+	indexStore, ok := dbStore.(store.IndexStore)
+	if !ok {
+		return []types.IndexesReport{}
+	}
+	return indexStore.IndexesReports()
+}
+
+// GetIndexesUsage returns the usage of the indexes of the collection, of all the collections when empty
+func GetIndexesUsage(c context.Context, collection string) ([]types.IndexUsage, error) {
+	defer log.LogNTraceEnterExit("GetIndexesUsage", c)()
+	indexStore, ok := dbStore.(store.IndexStore)
+	if !ok {
+		return []types.IndexUsage{}, nil
+	}
+	return indexStore.IndexesUsage(c, collection)
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	stateField           = "state"
+	typeField            = "type"
+	leaseExpiryTimeField = "leaseExpiryTime"
+	cancelRequestedField = "cancelRequested"
+	creationTimeField    = "creationTime"
+)
+
+func IsJobLeaseLostError(err error) bool {
+	return errors.Is(err, JobLeaseLostError{})
+}
+
+// JobLeaseLostError is returned when a job is no longer leased by the worker that claimed it (e.g. the lease expired and the job was claimed again)
+type JobLeaseLostError struct {
+}
+
+func (e JobLeaseLostError) Error() string {
+	return "the job is no longer leased by the worker"
+}
+
+// leasedJobFilter returns the filter of a running job that was not claimed again since the given claim, the attempts count the claims
+func leasedJobFilter(job *types.Job) *FilterBuilder {
+	return NewFilterBuilder().WithID(job.GUID).WithValue(stateField, types.JobRunning).WithValue(attemptsField, job.Attempts)
+}
+
+// updateLeasedJob updates a job that is leased by the claim of the job, returns JobLeaseLostError if the job is no longer leased by it
+func updateLeasedJob(c context.Context, job *types.Job, update bson.D) error {
+	res, err := getWriteCollection(consts.JobsCollection).UpdateOne(c, leasedJobFilter(job).get(), withRevisionInc(update))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return JobLeaseLostError{}
+	}
+	return nil
+}
+
+// InsertJob adds a pending job to the jobs queue, jobs do not belong to a customer
+// a job with a GUID keeps it, inserting it again fails with a duplicate key error
+func InsertJob(c context.Context, job *types.Job) error {
+	defer log.LogNTraceEnterExit("InsertJob", c)()
+	job.State = types.JobPending
+	guid := job.GUID
+	doc := types.NewDocument(job, "")
+	if guid != "" {
+		doc.ID = guid
+		job.SetGUID(guid)
+	}
+	doc.Customers = []string{}
+	_, err := getWriteCollection(consts.JobsCollection).InsertOne(c, doc)
+	return err
+}
+
+// GetJob returns a job by GUID, nil if it does not exist
+func GetJob(c context.Context, guid string) (*types.Job, error) {
+	defer log.LogNTraceEnterExit("GetJob", c)()
+	var job types.Job
+	if err := getWriteCollection(consts.JobsCollection).FindOne(c, NewFilterBuilder().WithID(guid).get()).Decode(&job); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FindJobs returns the latest jobs in the state (any state if empty)
+func FindJobs(c context.Context, state string, limit int64) ([]*types.Job, error) {
+	defer log.LogNTraceEnterExit("FindJobs", c)()
+	filter := NewFilterBuilder()
+	if state != "" {
+		filter.WithValue(stateField, state)
+	}
+	findOpts := options.Find().SetSort(NewSortBuilder().AddDescending(creationTimeField).get()).SetLimit(limit)
+	cur, err := getReadCollection(consts.JobsCollection).Find(c, filter.get(), findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	jobs := []*types.Job{}
+	if err := cur.All(c, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimJob returns the oldest pending job of the given types, or a running job whose lease expired, and counts the attempt
+// the claimed job is leased to the caller until the lease expires or is renewed
+func ClaimJob(c context.Context, jobTypes []string, lease time.Duration) (*types.Job, error) {
+	now := time.Now().UTC()
+	filter := NewFilterBuilder().
+		WithIn(typeField, jobTypes).
+		AddOr(NewFilterBuilder().WithValue(stateField, types.JobPending),
+			NewFilterBuilder().WithValue(stateField, types.JobRunning).WithLowerThanEqual(leaseExpiryTimeField, now))
+	update := withRevisionInc(bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: stateField, Value: types.JobRunning},
+			{Key: leaseExpiryTimeField, Value: now.Add(lease)},
+			{Key: "startedTime", Value: now},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: attemptsField, Value: 1}}},
+	})
+	findOpts := options.FindOneAndUpdate().
+		SetSort(NewSortBuilder().AddAscending(creationTimeField).get()).
+		SetReturnDocument(options.After)
+	var job types.Job
+	if err := getWriteCollection(consts.JobsCollection).FindOneAndUpdate(c, filter.get(), update, findOpts).Decode(&job); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RenewJobLease extends the lease of a claimed job, returns nil if the job is no longer leased by the claim (e.g. the lease expired and the job was claimed again)
+func RenewJobLease(c context.Context, claimed *types.Job, lease time.Duration) (*types.Job, error) {
+	update := withRevisionInc(bson.D{{Key: "$set", Value: bson.D{{Key: leaseExpiryTimeField, Value: time.Now().UTC().Add(lease)}}}})
+	var job types.Job
+	if err := getWriteCollection(consts.JobsCollection).FindOneAndUpdate(c, leasedJobFilter(claimed).get(), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&job); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CheckpointJob saves the progress and result of a claimed job, returns JobLeaseLostError if the job is no longer leased by the claim
+func CheckpointJob(c context.Context, job *types.Job) error {
+	return updateLeasedJob(c, job, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "progress", Value: job.Progress},
+		{Key: "result", Value: job.Result},
+	}}})
+}
+
+// FinishJob saves the final state, result and error of a claimed job, returns JobLeaseLostError if the job is no longer leased by the claim
+func FinishJob(c context.Context, job *types.Job) error {
+	now := time.Now().UTC()
+	job.FinishedTime = &now
+	job.LeaseExpiryTime = nil
+	return updateLeasedJob(c, job, bson.D{{Key: "$set", Value: bson.D{
+		{Key: stateField, Value: job.State},
+		{Key: "progress", Value: job.Progress},
+		{Key: "result", Value: job.Result},
+		{Key: "error", Value: job.Error},
+		{Key: leaseExpiryTimeField, Value: nil},
+		{Key: "finishedTime", Value: job.FinishedTime},
+	}}})
+}
+
+// ReleaseJob returns a claimed job to the queue so another worker resumes it, returns JobLeaseLostError if the job is no longer leased by the claim
+func ReleaseJob(c context.Context, job *types.Job) error {
+	return updateLeasedJob(c, job, bson.D{{Key: "$set", Value: bson.D{
+		{Key: stateField, Value: types.JobPending},
+		{Key: leaseExpiryTimeField, Value: nil},
+	}}})
+}
+
+// CancelJob cancels a pending job and requests the worker of a running job to cancel it
+// returns the updated job, finished jobs are returned as they are and nil if the job does not exist
+func CancelJob(c context.Context, guid string) (*types.Job, error) {
+	defer log.LogNTraceEnterExit("CancelJob", c)()
+	now := time.Now().UTC()
+	findOpts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	cancels := []struct {
+		state  string
+		update bson.D
+	}{
+		{types.JobPending, bson.D{{Key: stateField, Value: types.JobCancelled}, {Key: "finishedTime", Value: now}}},
+		{types.JobRunning, bson.D{{Key: cancelRequestedField, Value: true}}},
+	}
+	for _, cancel := range cancels {
+		filter := NewFilterBuilder().WithID(guid).WithValue(stateField, cancel.state)
+		var job types.Job
+		err := getWriteCollection(consts.JobsCollection).FindOneAndUpdate(c, filter.get(), withRevisionInc(bson.D{{Key: "$set", Value: cancel.update}}), findOpts).Decode(&job)
+		if err == nil {
+			return &job, nil
+		} else if err != mongoDB.ErrNoDocuments {
+			return nil, err
+		}
+	}
+	return GetJob(c, guid)
+}
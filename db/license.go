@@ -0,0 +1,64 @@
+package db
+
+import (
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const licenseTypeField = "activeSubscription.licenseType"
+
+// GetCustomersLicenseTypes returns the license type of the active subscription of all customers by customer GUID
+// customers without an active subscription have an empty license type
+func GetCustomersLicenseTypes(c context.Context) (map[string]string, error) {
+	defer log.LogNTraceEnterExit("GetCustomersLicenseTypes", c)()
+	findOpts := options.Find().SetProjection(bson.D{{Key: consts.IdField, Value: 1}, {Key: licenseTypeField, Value: 1}})
+	cur, err := getReadCollection(consts.CustomersCollection).Find(c, bson.D{}, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	licenseTypes := map[string]string{}
+	for cur.Next(c) {
+		var customer customerLicense
+		if err := cur.Decode(&customer); err != nil {
+			return nil, err
+		}
+		licenseTypes[customer.GUID] = customer.licenseType()
+	}
+	return licenseTypes, cur.Err()
+}
+
+// GetCustomerLicenseType returns the license type of the active subscription of a customer
+// a customer without an active subscription or that does not exist has an empty license type
+func GetCustomerLicenseType(c context.Context, customerGUID string) (string, error) {
+	defer log.LogNTraceEnterExit("GetCustomerLicenseType", c)()
+	findOpts := options.FindOne().SetProjection(bson.D{{Key: consts.IdField, Value: 1}, {Key: licenseTypeField, Value: 1}})
+	var customer customerLicense
+	if err := getReadCollection(consts.CustomersCollection).FindOne(c, bson.M{consts.IdField: customerGUID}, findOpts).Decode(&customer); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return "", nil
+		}
+		return "", err
+	}
+	return customer.licenseType(), nil
+}
+
+// customerLicense is the projection of the customer license type
+type customerLicense struct {
+	GUID               string `bson:"_id"`
+	ActiveSubscription *struct {
+		LicenseType string `bson:"licenseType"`
+	} `bson:"activeSubscription"`
+}
+
+func (c customerLicense) licenseType() string {
+	if c.ActiveSubscription == nil {
+		return ""
+	}
+	return c.ActiveSubscription.LicenseType
+}
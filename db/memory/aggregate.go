@@ -0,0 +1,610 @@
+package memory
+
+import (
+	"config-service/db/bsonquery"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// aggregate runs an aggregation pipeline on the documents, the store is used by $lookup stages
+func (s *memoryStore) aggregate(docs []bson.D, pipeline []bson.D) ([]bson.D, error) {
+	var err error
+	for _, stage := range pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage specification object must contain exactly one field")
+		}
+		if docs, err = s.runStage(docs, stage[0]); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func (s *memoryStore) runStage(docs []bson.D, stage bson.E) ([]bson.D, error) {
+	switch stage.Key {
+	case "$match":
+		filter, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("the match filter must be an expression in an object")
+		}
+		return filterDocs(docs, filter)
+	case "$sort":
+		spec, ok := stage.Value.(bson.D)
+		if !ok || len(spec) == 0 {
+			return nil, fmt.Errorf("the $sort key specification must be an object")
+		}
+		return sortDocs(docs, spec), nil
+	case "$skip":
+		skip, ok := bsonquery.ToInt64(stage.Value)
+		if !ok || skip < 0 {
+			return nil, fmt.Errorf("invalid argument to $skip stage: %v", stage.Value)
+		}
+		return skipDocs(docs, skip), nil
+	case "$limit":
+		limit, ok := bsonquery.ToInt64(stage.Value)
+		if !ok || limit <= 0 {
+			return nil, fmt.Errorf("the limit must be positive")
+		}
+		return limitDocs(docs, limit), nil
+	case "$project":
+		spec, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("$project specification must be an object")
+		}
+		return projectDocs(docs, spec)
+	case "$set", "$addFields":
+		spec, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%s specification stage must be an object", stage.Key)
+		}
+		return addFields(docs, spec)
+	case "$unset":
+		fields := []string{}
+		switch v := stage.Value.(type) {
+		case string:
+			fields = append(fields, v)
+		case bson.A:
+			for i := range v {
+				fields = append(fields, fmt.Sprint(v[i]))
+			}
+		default:
+			return nil, fmt.Errorf("$unset specification must be a string or an array")
+		}
+		result := make([]bson.D, 0, len(docs))
+		for _, doc := range docs {
+			for _, field := range fields {
+				doc = bsonquery.UnsetPath(doc, strings.Split(field, ".")).(bson.D)
+			}
+			result = append(result, doc)
+		}
+		return result, nil
+	case "$count":
+		field, ok := stage.Value.(string)
+		if !ok || field == "" {
+			return nil, fmt.Errorf("the count field must be a non-empty string")
+		}
+		if len(docs) == 0 {
+			return []bson.D{}, nil
+		}
+		return []bson.D{{{Key: field, Value: int32(len(docs))}}}, nil
+	case "$facet":
+		spec, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("argument to $facet stage must be an object")
+		}
+		result := bson.D{}
+		for _, facet := range spec {
+			subPipeline, err := bsonquery.ToDocs(facet.Value)
+			if err != nil {
+				return nil, fmt.Errorf("$facet %s: %w", facet.Key, err)
+			}
+			subDocs := make([]bson.D, len(docs))
+			for i := range docs {
+				subDocs[i] = bsonquery.CloneDoc(docs[i])
+			}
+			facetDocs, err := s.aggregate(subDocs, subPipeline)
+			if err != nil {
+				return nil, err
+			}
+			values := make(bson.A, len(facetDocs))
+			for i := range facetDocs {
+				values[i] = facetDocs[i]
+			}
+			result = append(result, bson.E{Key: facet.Key, Value: values})
+		}
+		return []bson.D{result}, nil
+	case "$unwind":
+		return unwindDocs(docs, stage.Value)
+	case "$replaceRoot", "$replaceWith":
+		newRoot := stage.Value
+		if stage.Key == "$replaceRoot" {
+			spec, ok := stage.Value.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("$replaceRoot specification must be an object")
+			}
+			if newRoot, ok = bsonquery.GetField(spec, "newRoot"); !ok {
+				return nil, fmt.Errorf("no newRoot specified for the $replaceRoot stage")
+			}
+		}
+		result := make([]bson.D, 0, len(docs))
+		for _, doc := range docs {
+			value, err := bsonquery.EvalExpression(doc, newRoot)
+			if err != nil {
+				return nil, err
+			}
+			root, ok := value.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("'newRoot' expression must evaluate to an object, but resulting value was of type %T", value)
+			}
+			result = append(result, root)
+		}
+		return result, nil
+	case "$group":
+		spec, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("a group's fields must be specified in an object")
+		}
+		return groupDocs(docs, spec)
+	case "$lookup":
+		spec, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("the $lookup stage specification must be an object")
+		}
+		return s.lookupDocs(docs, spec)
+	}
+	return nil, fmt.Errorf("unrecognized pipeline stage name: '%s'", stage.Key)
+}
+
+func filterDocs(docs []bson.D, filter bson.D) ([]bson.D, error) {
+	result := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		matched, err := bsonquery.MatchDoc(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result = append(result, doc)
+		}
+	}
+	return result, nil
+}
+
+// sortDocs sorts the documents by the sort specification, arrays are compared by their min (ascending) or max (descending) element
+func sortDocs(docs []bson.D, spec bson.D) []bson.D {
+	type sortKey struct {
+		path       string
+		descending bool
+	}
+	keys := make([]sortKey, 0, len(spec))
+	for _, e := range spec {
+		order, _ := bsonquery.ToInt64(e.Value)
+		keys = append(keys, sortKey{path: e.Key, descending: order < 0})
+	}
+	sortValue := func(doc bson.D, key sortKey) interface{} {
+		values := bsonquery.LookupParts(doc, strings.Split(key.path, "."), false)
+		candidates := []interface{}{}
+		for _, v := range values {
+			if arr, ok := v.(bson.A); ok && len(arr) > 0 {
+				candidates = append(candidates, arr...)
+			} else {
+				candidates = append(candidates, v)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		best := candidates[0]
+		for _, v := range candidates[1:] {
+			c := bsonquery.CompareValues(v, best)
+			if (key.descending && c > 0) || (!key.descending && c < 0) {
+				best = v
+			}
+		}
+		return best
+	}
+	sorted := append([]bson.D{}, docs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, key := range keys {
+			c := bsonquery.CompareValues(sortValue(sorted[i], key), sortValue(sorted[j], key))
+			if c == 0 {
+				continue
+			}
+			if key.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return sorted
+}
+
+func skipDocs(docs []bson.D, skip int64) []bson.D {
+	if skip >= int64(len(docs)) {
+		return []bson.D{}
+	}
+	return docs[skip:]
+}
+
+func limitDocs(docs []bson.D, limit int64) []bson.D {
+	if limit > 0 && limit < int64(len(docs)) {
+		return docs[:limit]
+	}
+	return docs
+}
+
+// isExclusionProjection returns true if all the projected fields (except _id) are excluded
+func isExclusionProjection(spec bson.D) bool {
+	exclusion := false
+	for _, e := range spec {
+		if _, isExpr := e.Value.(bson.D); isExpr {
+			return false
+		}
+		if _, isPath := e.Value.(string); isPath {
+			return false
+		}
+		if bsonquery.IsTrue(e.Value) {
+			if e.Key != "_id" {
+				return false
+			}
+			continue
+		}
+		if e.Key != "_id" {
+			exclusion = true
+		}
+	}
+	return exclusion || (len(spec) == 1 && spec[0].Key == "_id")
+}
+
+func projectDocs(docs []bson.D, spec bson.D) ([]bson.D, error) {
+	result := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		projected, err := projectDoc(doc, spec)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, projected)
+	}
+	return result, nil
+}
+
+// projectDoc applies an inclusion, exclusion or expressions projection on a document
+func projectDoc(doc bson.D, spec bson.D) (bson.D, error) {
+	if len(spec) == 0 {
+		return doc, nil
+	}
+	if isExclusionProjection(spec) {
+		projected := bsonquery.CloneDoc(doc)
+		for _, e := range spec {
+			if !bsonquery.IsTrue(e.Value) {
+				projected = bsonquery.UnsetPath(projected, strings.Split(e.Key, ".")).(bson.D)
+			}
+		}
+		return projected, nil
+	}
+	projected := bson.D{}
+	includeID := true
+	for _, e := range spec {
+		if e.Key == "_id" && !bsonquery.IsTrue(e.Value) {
+			includeID = false
+		}
+	}
+	if id, ok := bsonquery.GetField(doc, "_id"); ok && includeID {
+		projected = append(projected, bson.E{Key: "_id", Value: id})
+	}
+	for _, e := range spec {
+		switch e.Value.(type) {
+		case bson.D, string, bson.A:
+			value, err := bsonquery.EvalExpression(doc, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				continue
+			}
+			if projected, err = bsonquery.SetPath(projected, strings.Split(e.Key, "."), value); err != nil {
+				return nil, err
+			}
+		default:
+			if e.Key == "_id" || !bsonquery.IsTrue(e.Value) {
+				continue
+			}
+			projected = includePath(doc, projected, strings.Split(e.Key, "."))
+		}
+	}
+	return projected, nil
+}
+
+// includePath copies the value in path from src to dst, arrays of documents in the path are projected element by element
+func includePath(src bson.D, dst bson.D, parts []string) bson.D {
+	value, ok := bsonquery.GetField(src, parts[0])
+	if !ok {
+		return dst
+	}
+	if len(parts) == 1 {
+		for i := range dst {
+			if dst[i].Key == parts[0] {
+				dst[i].Value = bsonquery.CloneValue(value)
+				return dst
+			}
+		}
+		return append(dst, bson.E{Key: parts[0], Value: bsonquery.CloneValue(value)})
+	}
+	var current interface{}
+	index := -1
+	for i := range dst {
+		if dst[i].Key == parts[0] {
+			current, index = dst[i].Value, i
+		}
+	}
+	var projected interface{}
+	switch v := value.(type) {
+	case bson.D:
+		sub, _ := current.(bson.D)
+		projected = includePath(v, sub, parts[1:])
+	case bson.A:
+		subArr, _ := current.(bson.A)
+		arr := bson.A{}
+		for i, item := range v {
+			itemDoc, ok := item.(bson.D)
+			if !ok {
+				continue
+			}
+			var sub bson.D
+			if i < len(subArr) {
+				sub, _ = subArr[i].(bson.D)
+			}
+			arr = append(arr, includePath(itemDoc, sub, parts[1:]))
+		}
+		projected = arr
+	default:
+		return dst
+	}
+	if index >= 0 {
+		dst[index].Value = projected
+		return dst
+	}
+	return append(dst, bson.E{Key: parts[0], Value: projected})
+}
+
+func addFields(docs []bson.D, spec bson.D) ([]bson.D, error) {
+	result := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		newDoc := bsonquery.CloneDoc(doc)
+		for _, e := range spec {
+			value, err := bsonquery.EvalExpression(doc, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			if newDoc, err = bsonquery.SetPath(newDoc, strings.Split(e.Key, "."), value); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, newDoc)
+	}
+	return result, nil
+}
+
+func unwindDocs(docs []bson.D, spec interface{}) ([]bson.D, error) {
+	path := ""
+	preserve := false
+	switch v := spec.(type) {
+	case string:
+		path = v
+	case bson.D:
+		p, _ := bsonquery.GetField(v, "path")
+		path, _ = p.(string)
+		if keep, ok := bsonquery.GetField(v, "preserveNullAndEmptyArrays"); ok {
+			preserve = bsonquery.IsTrue(keep)
+		}
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path option to $unwind stage should be prefixed with a '$': %s", path)
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "$"), ".")
+	result := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		value, found := bsonquery.ResolvePath(doc, parts)
+		arr, isArray := value.(bson.A)
+		switch {
+		case isArray && len(arr) > 0:
+			for _, item := range arr {
+				unwound, err := bsonquery.SetPath(bsonquery.CloneDoc(doc), parts, bsonquery.CloneValue(item))
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, unwound)
+			}
+		case isArray || !found || value == nil:
+			if preserve {
+				if isArray {
+					doc = bsonquery.UnsetPath(bsonquery.CloneDoc(doc), parts).(bson.D)
+				}
+				result = append(result, doc)
+			}
+		default:
+			result = append(result, doc)
+		}
+	}
+	return result, nil
+}
+
+type groupState struct {
+	id     interface{}
+	fields bson.D
+	counts map[string]int
+	sets   map[string]map[string]bool
+}
+
+func groupDocs(docs []bson.D, spec bson.D) ([]bson.D, error) {
+	idExpr, ok := bsonquery.GetField(spec, "_id")
+	if !ok {
+		return nil, fmt.Errorf("a group specification must include an _id")
+	}
+	groups := []*groupState{}
+	groupsByKey := map[string]*groupState{}
+	for _, doc := range docs {
+		id, err := bsonquery.EvalExpression(doc, idExpr)
+		if err != nil {
+			return nil, err
+		}
+		key := bsonquery.ValueKey(id)
+		group, ok := groupsByKey[key]
+		if !ok {
+			group = &groupState{id: id, counts: map[string]int{}, sets: map[string]map[string]bool{}}
+			groupsByKey[key] = group
+			groups = append(groups, group)
+		}
+		for _, field := range spec {
+			if field.Key == "_id" {
+				continue
+			}
+			if err := accumulate(group, doc, field); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result := make([]bson.D, 0, len(groups))
+	for _, group := range groups {
+		doc := bson.D{{Key: "_id", Value: group.id}}
+		for _, field := range spec {
+			if field.Key == "_id" {
+				continue
+			}
+			value, _ := bsonquery.GetField(group.fields, field.Key)
+			if acc, ok := field.Value.(bson.D); ok && len(acc) == 1 && acc[0].Key == "$avg" {
+				if count := group.counts[field.Key]; count > 0 {
+					sum, _ := bsonquery.ToFloat(value)
+					value = sum / float64(count)
+				} else {
+					value = nil
+				}
+			}
+			doc = append(doc, bson.E{Key: field.Key, Value: value})
+		}
+		result = append(result, doc)
+	}
+	return result, nil
+}
+
+func accumulate(group *groupState, doc bson.D, field bson.E) error {
+	acc, ok := field.Value.(bson.D)
+	if !ok || len(acc) != 1 {
+		return fmt.Errorf("the field '%s' must be an accumulator object", field.Key)
+	}
+	current, exists := bsonquery.GetField(group.fields, field.Key)
+	set := func(value interface{}) {
+		group.fields, _ = bsonquery.SetPath(group.fields, []string{field.Key}, value)
+	}
+	if acc[0].Key == "$count" {
+		sum, _ := bsonquery.AddNumbers(orZero(current), int32(1))
+		set(sum)
+		return nil
+	}
+	value, err := bsonquery.EvalExpression(doc, acc[0].Value)
+	if err != nil {
+		return err
+	}
+	switch acc[0].Key {
+	case "$sum", "$avg":
+		if !exists {
+			set(int32(0))
+			current = int32(0)
+		}
+		if _, isNumber := bsonquery.ToFloat(value); isNumber {
+			sum, _ := bsonquery.AddNumbers(current, value)
+			set(sum)
+			group.counts[field.Key]++
+		}
+	case "$push":
+		arr, _ := current.(bson.A)
+		set(append(arr, value))
+	case "$addToSet":
+		arr, _ := current.(bson.A)
+		if group.sets[field.Key] == nil {
+			group.sets[field.Key] = map[string]bool{}
+			arr = bson.A{}
+		}
+		if key := bsonquery.ValueKey(value); !group.sets[field.Key][key] {
+			group.sets[field.Key][key] = true
+			arr = append(arr, value)
+		}
+		set(arr)
+	case "$first":
+		if !exists {
+			set(value)
+		}
+	case "$last":
+		set(value)
+	case "$min", "$max":
+		if value == nil {
+			if !exists {
+				set(nil)
+			}
+			return nil
+		}
+		c := bsonquery.CompareValues(value, current)
+		if !exists || current == nil || (acc[0].Key == "$min" && c < 0) || (acc[0].Key == "$max" && c > 0) {
+			set(value)
+		}
+	default:
+		return fmt.Errorf("unknown group operator '%s'", acc[0].Key)
+	}
+	return nil
+}
+
+func orZero(v interface{}) interface{} {
+	if v == nil {
+		return int32(0)
+	}
+	return v
+}
+
+func (s *memoryStore) lookupDocs(docs []bson.D, spec bson.D) ([]bson.D, error) {
+	from, _ := bsonquery.GetField(spec, "from")
+	localField, _ := bsonquery.GetField(spec, "localField")
+	foreignField, _ := bsonquery.GetField(spec, "foreignField")
+	as, _ := bsonquery.GetField(spec, "as")
+	fromName, ok1 := from.(string)
+	localPath, ok2 := localField.(string)
+	foreignPath, ok3 := foreignField.(string)
+	asPath, ok4 := as.(string)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("$lookup requires from, localField, foreignField and as string fields")
+	}
+	foreignDocs := s.collectionDocs(fromName)
+	result := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		localValues := bsonquery.LookupValues(doc, localPath)
+		if len(localValues) == 0 {
+			localValues = []interface{}{nil}
+		}
+		matches := bson.A{}
+		for _, foreign := range foreignDocs {
+			if matchAnyValue(bsonquery.LookupValues(foreign, foreignPath), localValues) {
+				matches = append(matches, bsonquery.CloneDoc(foreign))
+			}
+		}
+		newDoc, err := bsonquery.SetPath(bsonquery.CloneDoc(doc), strings.Split(asPath, "."), matches)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, newDoc)
+	}
+	return result, nil
+}
+
+func matchAnyValue(values []interface{}, candidates []interface{}) bool {
+	for _, candidate := range candidates {
+		if _, isArray := candidate.(bson.A); isArray {
+			continue
+		}
+		if bsonquery.MatchEquals(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
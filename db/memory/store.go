@@ -0,0 +1,688 @@
+package memory
+
+import (
+	"config-service/db/bsonquery"
+	"config-service/db/store"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/exp/slices"
+)
+
+// now is replaceable for tests
+var now = time.Now
+
+// ttlMonitorInterval is the interval of removing expired documents
+var ttlMonitorInterval = time.Second
+
+// ttlFields is a map of collection name to a date field that expires the document (like the mongo TTL indexes with expireAfterSeconds 0)
+var ttlFields = map[string]string{
+	consts.UsersNotificationsCacheCollection: "expiryTime",
+	consts.WebhookDeliveriesCollection:       "expiryTime",
+}
+
+// memoryStore is an in memory implementation of store.Store
+// it emulates the subset of mongo queries, updates and aggregation stages used by the db package
+// it is meant for tests and local development, data is lost when the process exits
+type memoryStore struct {
+	mu          sync.RWMutex
+	collections map[string][]bson.D
+	stopTTL     chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewStore returns an empty in memory store
+func NewStore() store.Store {
+	s := &memoryStore{collections: map[string][]bson.D{}, stopTTL: make(chan struct{})}
+	go s.ttlMonitor()
+	return s
+}
+
+// ttlMonitor periodically removes expired documents until the store is disconnected
+func (s *memoryStore) ttlMonitor() {
+	ticker := time.NewTicker(ttlMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopTTL:
+			return
+		case <-ticker.C:
+			s.removeExpired()
+		}
+	}
+}
+
+func (s *memoryStore) removeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireBefore := primitive.NewDateTimeFromTime(now())
+	for collectionName, field := range ttlFields {
+		docs, ok := s.collections[collectionName]
+		if !ok {
+			continue
+		}
+		kept := make([]bson.D, 0, len(docs))
+		for _, doc := range docs {
+			if expiry, ok := bsonquery.GetField(doc, field); ok {
+				if date, isDate := expiry.(primitive.DateTime); isDate && date <= expireBefore {
+					continue
+				}
+			}
+			kept = append(kept, doc)
+		}
+		s.collections[collectionName] = kept
+	}
+}
+
+func (s *memoryStore) GetReadCollection(collectionName string) store.Collection {
+	return &collection{store: s, name: collectionName}
+}
+
+func (s *memoryStore) GetWriteCollection(collectionName string) store.Collection {
+	return &collection{store: s, name: collectionName}
+}
+
+func (s *memoryStore) ListCollectionNames(c context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *memoryStore) IndexCollection(collectionName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[collectionName]; !ok {
+		s.collections[collectionName] = []bson.D{}
+	}
+	return nil
+}
+
+func (s *memoryStore) Disconnect() {
+	s.stopOnce.Do(func() { close(s.stopTTL) })
+}
+
+// collectionDocs returns copies of the collection documents, caller must hold the store lock
+func (s *memoryStore) collectionDocs(collectionName string) []bson.D {
+	docs := s.collections[collectionName]
+	clones := make([]bson.D, len(docs))
+	for i := range docs {
+		clones[i] = bsonquery.CloneDoc(docs[i])
+	}
+	return clones
+}
+
+// collection is an in memory implementation of store.Collection
+type collection struct {
+	store *memoryStore
+	name  string
+}
+
+func (col *collection) docs() []bson.D {
+	return col.store.collectionDocs(col.name)
+}
+
+// find returns copies of the matching documents ordered by sort and limited by skip and limit
+func (col *collection) find(filter interface{}, sortSpec interface{}, skip, limit int64) ([]bson.D, error) {
+	filterDoc, err := bsonquery.ToDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := filterDocs(col.docs(), filterDoc)
+	if err != nil {
+		return nil, err
+	}
+	if sortSpec != nil {
+		spec, err := bsonquery.ToDoc(sortSpec)
+		if err != nil {
+			return nil, err
+		}
+		if len(spec) > 0 {
+			docs = sortDocs(docs, spec)
+		}
+	}
+	return limitDocs(skipDocs(docs, skip), limit), nil
+}
+
+func (col *collection) Find(c context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	col.store.mu.RLock()
+	defer col.store.mu.RUnlock()
+	var sortSpec, projection interface{}
+	var skip, limit int64
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Sort != nil {
+			sortSpec = opt.Sort
+		}
+		if opt.Projection != nil {
+			projection = opt.Projection
+		}
+		if opt.Skip != nil {
+			skip = *opt.Skip
+		}
+		if opt.Limit != nil {
+			limit = *opt.Limit
+			if limit < 0 {
+				limit = -limit
+			}
+		}
+	}
+	docs, err := col.find(filter, sortSpec, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	if docs, err = project(docs, projection); err != nil {
+		return nil, err
+	}
+	return newCursor(docs)
+}
+
+func (col *collection) FindOne(c context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	col.store.mu.RLock()
+	defer col.store.mu.RUnlock()
+	var sortSpec, projection interface{}
+	var skip int64
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Sort != nil {
+			sortSpec = opt.Sort
+		}
+		if opt.Projection != nil {
+			projection = opt.Projection
+		}
+		if opt.Skip != nil {
+			skip = *opt.Skip
+		}
+	}
+	docs, err := col.find(filter, sortSpec, skip, 1)
+	if err == nil {
+		docs, err = project(docs, projection)
+	}
+	return newSingleResult(docs, err)
+}
+
+func (col *collection) FindOneAndUpdate(c context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	col.store.mu.Lock()
+	defer col.store.mu.Unlock()
+	var sortSpec, projection interface{}
+	upsert := false
+	returnAfter := false
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Sort != nil {
+			sortSpec = opt.Sort
+		}
+		if opt.Projection != nil {
+			projection = opt.Projection
+		}
+		if opt.Upsert != nil {
+			upsert = *opt.Upsert
+		}
+		if opt.ReturnDocument != nil {
+			returnAfter = *opt.ReturnDocument == options.After
+		}
+	}
+	docs, err := col.find(filter, sortSpec, 0, 1)
+	if err != nil {
+		return newSingleResult(nil, err)
+	}
+	var before, after bson.D
+	if len(docs) == 0 {
+		if !upsert {
+			return newSingleResult(nil, nil)
+		}
+		if after, err = col.upsert(filter, update); err != nil {
+			return newSingleResult(nil, err)
+		}
+	} else {
+		before = docs[0]
+		if after, err = col.updateDoc(before, filter, update); err != nil {
+			return newSingleResult(nil, err)
+		}
+	}
+	result := after
+	if !returnAfter {
+		result = before
+	}
+	if result == nil {
+		return newSingleResult(nil, nil)
+	}
+	resultDocs, err := project([]bson.D{bsonquery.CloneDoc(result)}, projection)
+	return newSingleResult(resultDocs, err)
+}
+
+func (col *collection) Aggregate(c context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	col.store.mu.RLock()
+	defer col.store.mu.RUnlock()
+	stages, err := bsonquery.ToDocs(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := col.store.aggregate(col.docs(), stages)
+	if err != nil {
+		return nil, err
+	}
+	return newCursor(docs)
+}
+
+func (col *collection) CountDocuments(c context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	col.store.mu.RLock()
+	defer col.store.mu.RUnlock()
+	var skip, limit int64
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Skip != nil {
+			skip = *opt.Skip
+		}
+		if opt.Limit != nil {
+			limit = *opt.Limit
+		}
+	}
+	docs, err := col.find(filter, nil, skip, limit)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+func (col *collection) InsertOne(c context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	col.store.mu.Lock()
+	defer col.store.mu.Unlock()
+	doc, err := bsonquery.ToDoc(document)
+	if err != nil {
+		return nil, err
+	}
+	id, err := col.insert(doc)
+	if err != nil {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{toWriteError(0, err)}}
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+func (col *collection) InsertMany(c context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	col.store.mu.Lock()
+	defer col.store.mu.Unlock()
+	ordered := true
+	for _, opt := range opts {
+		if opt != nil && opt.Ordered != nil {
+			ordered = *opt.Ordered
+		}
+	}
+	result := &mongo.InsertManyResult{}
+	writeErrors := mongo.WriteErrors{}
+	for i, document := range documents {
+		doc, err := bsonquery.ToDoc(document)
+		if err != nil {
+			return nil, err
+		}
+		id, err := col.insert(doc)
+		if err != nil {
+			writeErrors = append(writeErrors, toWriteError(i, err))
+			if ordered {
+				break
+			}
+			continue
+		}
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+	if len(writeErrors) > 0 {
+		bulkErrors := make([]mongo.BulkWriteError, 0, len(writeErrors))
+		for _, writeError := range writeErrors {
+			bulkErrors = append(bulkErrors, mongo.BulkWriteError{WriteError: writeError})
+		}
+		return result, mongo.BulkWriteException{WriteErrors: bulkErrors}
+	}
+	return result, nil
+}
+
+func (col *collection) UpdateOne(c context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return col.update(filter, update, false, opts...)
+}
+
+func (col *collection) UpdateMany(c context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return col.update(filter, update, true, opts...)
+}
+
+func (col *collection) update(filter interface{}, update interface{}, many bool, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	col.store.mu.Lock()
+	defer col.store.mu.Unlock()
+	upsert := false
+	for _, opt := range opts {
+		if opt != nil && opt.Upsert != nil {
+			upsert = *opt.Upsert
+		}
+	}
+	limit := int64(1)
+	if many {
+		limit = 0
+	}
+	docs, err := col.find(filter, nil, 0, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := &mongo.UpdateResult{}
+	if len(docs) == 0 && upsert {
+		doc, err := col.upsert(filter, update)
+		if err != nil {
+			return nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID, _ = bsonquery.GetField(doc, "_id")
+		return result, nil
+	}
+	// validate all the updates before applying them so a failed update many does not leave partial changes
+	updated := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		newDoc, err := col.applyUpdate(doc, filter, update)
+		if err != nil {
+			return nil, err
+		}
+		updated = append(updated, newDoc)
+	}
+	if err := col.checkUniqueUpdates(updated); err != nil {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{toWriteError(0, err)}}
+	}
+	for i, doc := range docs {
+		result.MatchedCount++
+		if bsonquery.CompareValues(doc, updated[i]) != 0 {
+			result.ModifiedCount++
+			col.replace(updated[i])
+		}
+	}
+	return result, nil
+}
+
+func (col *collection) DeleteOne(c context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return col.delete(filter, 1)
+}
+
+func (col *collection) DeleteMany(c context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return col.delete(filter, 0)
+}
+
+func (col *collection) delete(filter interface{}, limit int64) (*mongo.DeleteResult, error) {
+	col.store.mu.Lock()
+	defer col.store.mu.Unlock()
+	filterDoc, err := bsonquery.ToDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]bson.D, 0, len(col.store.collections[col.name]))
+	var deleted int64
+	for _, doc := range col.store.collections[col.name] {
+		if limit == 0 || deleted < limit {
+			matched, err := bsonquery.MatchDoc(doc, filterDoc)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				deleted++
+				continue
+			}
+		}
+		kept = append(kept, doc)
+	}
+	if _, exists := col.store.collections[col.name]; exists {
+		col.store.collections[col.name] = kept
+	}
+	return &mongo.DeleteResult{DeletedCount: deleted}, nil
+}
+
+func (col *collection) Drop(c context.Context) error {
+	col.store.mu.Lock()
+	defer col.store.mu.Unlock()
+	delete(col.store.collections, col.name)
+	return nil
+}
+
+// insert adds the document to the collection generating an _id if missing, caller must hold the write lock
+func (col *collection) insert(doc bson.D) (interface{}, error) {
+	id, ok := bsonquery.GetField(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	}
+	if col.indexOf(id) >= 0 {
+		return nil, errDuplicateKey{collection: col.name, index: "_id_", key: fmt.Sprintf("_id: %v", id)}
+	}
+	if err := col.checkUnique(doc, col.store.collections[col.name]); err != nil {
+		return nil, err
+	}
+	col.store.collections[col.name] = append(col.store.collections[col.name], bsonquery.CloneDoc(doc))
+	return id, nil
+}
+
+// replace replaces the stored document with the same _id, caller must hold the write lock
+func (col *collection) replace(doc bson.D) {
+	id, _ := bsonquery.GetField(doc, "_id")
+	if i := col.indexOf(id); i >= 0 {
+		col.store.collections[col.name][i] = bsonquery.CloneDoc(doc)
+	}
+}
+
+func (col *collection) indexOf(id interface{}) int {
+	for i, doc := range col.store.collections[col.name] {
+		if docID, ok := bsonquery.GetField(doc, "_id"); ok && bsonquery.EqualValues(docID, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (col *collection) applyUpdate(doc bson.D, filter interface{}, update interface{}) (bson.D, error) {
+	filterDoc, err := bsonquery.ToDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	updateDoc, err := bsonquery.ToDoc(update)
+	if err != nil {
+		return nil, err
+	}
+	return applyUpdate(doc, updateDoc, filterDoc, false)
+}
+
+// updateDoc applies the update on a stored document and saves it, caller must hold the write lock
+func (col *collection) updateDoc(doc bson.D, filter interface{}, update interface{}) (bson.D, error) {
+	newDoc, err := col.applyUpdate(doc, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if err := col.checkUnique(newDoc, col.store.collections[col.name]); err != nil {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{toWriteError(0, err)}}
+	}
+	col.replace(newDoc)
+	return newDoc, nil
+}
+
+// checkUniqueUpdates checks the unique indexes of the updated documents against the collection with the updates applied, caller must hold the store lock
+func (col *collection) checkUniqueUpdates(updated []bson.D) error {
+	if len(col.uniqueIndexes()) == 0 {
+		return nil
+	}
+	docs := append([]bson.D{}, col.store.collections[col.name]...)
+	for _, doc := range updated {
+		id, _ := bsonquery.GetField(doc, "_id")
+		if i := col.indexOf(id); i >= 0 {
+			docs[i] = doc
+		}
+	}
+	for _, doc := range updated {
+		if err := col.checkUnique(doc, docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueIndexes returns the unique indexes declared by the routes of the collection (see types.SchemaInfo.Indexes), unique text indexes are not enforced
+func (col *collection) uniqueIndexes() []types.IndexInfo {
+	var unique []types.IndexInfo
+	for _, index := range types.GetCollectionIndexes(col.name) {
+		if index.Unique && !slices.ContainsFunc(index.Keys, func(key types.IndexKey) bool { return key.Text }) {
+			unique = append(unique, index)
+		}
+	}
+	return unique
+}
+
+// checkUnique returns an errDuplicateKey if another document of docs has the keys of the document in a unique index
+func (col *collection) checkUnique(doc bson.D, docs []bson.D) error {
+	indexes := col.uniqueIndexes()
+	if len(indexes) == 0 {
+		return nil
+	}
+	id, _ := bsonquery.GetField(doc, "_id")
+	for _, index := range indexes {
+		keys, err := indexKeys(index, doc)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		for _, other := range docs {
+			if otherID, _ := bsonquery.GetField(other, "_id"); bsonquery.EqualValues(otherID, id) {
+				continue
+			}
+			otherKeys, err := indexKeys(index, other)
+			if err != nil {
+				return err
+			}
+			for key, value := range keys {
+				if otherKeys[key] != "" {
+					return errDuplicateKey{collection: col.name, index: index.GetName(), key: value}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// indexKeys returns the keys of the document in the index, keyed by their value keys
+// like mongo a missing field is indexed as null, an array field has a key per element and documents not matching the partial filter are not indexed
+func indexKeys(index types.IndexInfo, doc bson.D) (map[string]string, error) {
+	if index.PartialFilter != nil {
+		filter, err := bsonquery.ToDoc(index.PartialFilter)
+		if err != nil {
+			return nil, err
+		}
+		if matched, err := bsonquery.MatchDoc(doc, filter); err != nil || !matched {
+			return nil, err
+		}
+	}
+	keys := map[string]string{"": ""}
+	for _, indexKey := range index.Keys {
+		values := bsonquery.LookupParts(doc, strings.Split(indexKey.Field, "."), false)
+		if len(values) == 1 {
+			if arr, ok := values[0].(bson.A); ok && len(arr) > 0 {
+				values = arr
+			}
+		}
+		if len(values) == 0 {
+			values = []interface{}{nil}
+		}
+		fieldKeys := make(map[string]string, len(keys)*len(values))
+		for key, value := range keys {
+			if value != "" {
+				value += ", "
+			}
+			for _, fieldValue := range values {
+				fieldKeys[key+"\x00"+bsonquery.ValueKey(fieldValue)] = fmt.Sprintf("%s%s: %v", value, indexKey.Field, fieldValue)
+			}
+		}
+		keys = fieldKeys
+	}
+	return keys, nil
+}
+
+// upsert inserts a new document built from the filter equality conditions and the update, caller must hold the write lock
+func (col *collection) upsert(filter interface{}, update interface{}) (bson.D, error) {
+	filterDoc, err := bsonquery.ToDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	updateDoc, err := bsonquery.ToDoc(update)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := applyUpdate(newUpsertDoc(filterDoc), updateDoc, filterDoc, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := bsonquery.GetField(doc, "_id"); !ok {
+		doc = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, doc...)
+	}
+	if _, err := col.insert(doc); err != nil {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{toWriteError(0, err)}}
+	}
+	return doc, nil
+}
+
+func project(docs []bson.D, projection interface{}) ([]bson.D, error) {
+	if projection == nil {
+		return docs, nil
+	}
+	spec, err := bsonquery.ToDoc(projection)
+	if err != nil {
+		return nil, err
+	}
+	return projectDocs(docs, spec)
+}
+
+func newCursor(docs []bson.D) (*mongo.Cursor, error) {
+	documents := make([]interface{}, len(docs))
+	for i := range docs {
+		documents[i] = docs[i]
+	}
+	return mongo.NewCursorFromDocuments(documents, nil, nil)
+}
+
+func newSingleResult(docs []bson.D, err error) *mongo.SingleResult {
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	if len(docs) == 0 {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(docs[0], nil, nil)
+}
+
+// errDuplicateKey is returned when writing a document with the _id or the keys of a unique index of another document
+type errDuplicateKey struct {
+	collection string
+	index      string
+	key        string
+}
+
+func (e errDuplicateKey) Error() string {
+	return fmt.Sprintf("E11000 duplicate key error collection: %s index: %s dup key: { %s }", e.collection, e.index, e.key)
+}
+
+func toWriteError(index int, err error) mongo.WriteError {
+	if _, ok := err.(errDuplicateKey); ok {
+		return mongo.WriteError{Index: index, Code: 11000, Message: err.Error()}
+	}
+	return mongo.WriteError{Index: index, Message: err.Error()}
+}
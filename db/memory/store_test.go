@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"config-service/db/bsonquery"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func testDocs() []interface{} {
+	return []interface{}{
+		bson.M{"_id": "1", "name": "a", "customers": bson.A{"c1"}, "count": 1, "tags": bson.A{"x", "y"},
+			"items": bson.A{bson.D{{Key: "id", Value: "i1"}, {Key: "score", Value: 5}}, bson.D{{Key: "id", Value: "i2"}, {Key: "score", Value: 7}}}},
+		bson.M{"_id": "2", "name": "b", "customers": bson.A{"c1", "c2"}, "count": 2, "tags": bson.A{"y"},
+			"items": bson.A{bson.D{{Key: "id", Value: "i3"}, {Key: "score", Value: 1}}}},
+		bson.M{"_id": "3", "name": "Cc", "customers": bson.A{"c2"}, "count": 3, "attributes": bson.M{"kind": "k8s"}},
+	}
+}
+
+func newTestCollection(t *testing.T) *collection {
+	s := NewStore()
+	t.Cleanup(s.Disconnect)
+	col := s.GetWriteCollection("test").(*collection)
+	_, err := col.InsertMany(context.Background(), testDocs())
+	assert.NoError(t, err)
+	return col
+}
+
+func findIDs(t *testing.T, col *collection, filter interface{}, opts ...*options.FindOptions) []string {
+	cursor, err := col.Find(context.Background(), filter, opts...)
+	assert.NoError(t, err)
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	assert.NoError(t, cursor.All(context.Background(), &docs))
+	ids := []string{}
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids
+}
+
+func TestFind(t *testing.T) {
+	col := newTestCollection(t)
+	tests := []struct {
+		name   string
+		filter interface{}
+		opts   *options.FindOptions
+		want   []string
+	}{
+		{name: "empty filter", filter: bson.D{}, want: []string{"1", "2", "3"}},
+		{name: "eq", filter: bson.M{"name": "b"}, want: []string{"2"}},
+		{name: "array contains", filter: bson.M{"customers": "c2"}, want: []string{"2", "3"}},
+		{name: "in", filter: bson.M{"name": bson.M{"$in": bson.A{"a", "Cc"}}}, want: []string{"1", "3"}},
+		{name: "nin", filter: bson.M{"name": bson.M{"$nin": bson.A{"a", "Cc"}}}, want: []string{"2"}},
+		{name: "ne", filter: bson.M{"name": bson.M{"$ne": "a"}}, want: []string{"2", "3"}},
+		{name: "range", filter: bson.D{{Key: "count", Value: bson.D{{Key: "$gte", Value: 2}, {Key: "$lte", Value: 3}}}}, want: []string{"2", "3"}},
+		{name: "exists", filter: bson.M{"attributes.kind": bson.M{"$exists": true}}, want: []string{"3"}},
+		{name: "not exists", filter: bson.M{"attributes": bson.M{"$exists": false}}, want: []string{"1", "2"}},
+		{name: "missing field equals nil", filter: bson.M{"attributes": nil}, want: []string{"1", "2"}},
+		{name: "regex ignore case", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^c"}, {Key: "$options", Value: "i"}}}}, want: []string{"3"}},
+		{name: "nested array field", filter: bson.M{"items.id": "i3"}, want: []string{"2"}},
+		{name: "elemMatch", filter: bson.M{"items": bson.M{"$elemMatch": bson.M{"id": "i1", "score": bson.M{"$gt": 4}}}}, want: []string{"1"}},
+		{name: "elemMatch or", filter: bson.M{"items": bson.M{"$elemMatch": bson.M{"$or": bson.A{bson.M{"id": "i3"}, bson.M{"score": 7}}}}}, want: []string{"1", "2"}},
+		{name: "or", filter: bson.M{"$or": bson.A{bson.M{"name": "a"}, bson.M{"count": 3}}}, want: []string{"1", "3"}},
+		{name: "and", filter: bson.M{"$and": bson.A{bson.M{"customers": "c1"}, bson.M{"tags": "y"}}}, want: []string{"1", "2"}},
+		{name: "not", filter: bson.M{"name": bson.M{"$not": bson.M{"$in": bson.A{"a"}}}}, want: []string{"2", "3"}},
+		{name: "size", filter: bson.M{"tags": bson.M{"$size": 2}}, want: []string{"1"}},
+		{name: "sort desc", filter: bson.D{}, opts: options.Find().SetSort(bson.D{{Key: "count", Value: -1}}), want: []string{"3", "2", "1"}},
+		{name: "skip and limit", filter: bson.D{}, opts: options.Find().SetSkip(1).SetLimit(1), want: []string{"2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []*options.FindOptions{}
+			if tt.opts != nil {
+				opts = append(opts, tt.opts)
+			}
+			assert.Equal(t, tt.want, findIDs(t, col, tt.filter, opts...))
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter interface{}
+		update interface{}
+		want   bson.M
+	}{
+		{
+			name:   "set nested",
+			filter: bson.M{"_id": "3"},
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "attributes.kind", Value: "aws"}, {Key: "attributes.new", Value: true}}}},
+			want:   bson.M{"attributes": bson.D{{Key: "kind", Value: "aws"}, {Key: "new", Value: true}}},
+		},
+		{
+			name:   "unset",
+			filter: bson.M{"_id": "3"},
+			update: bson.D{{Key: "$unset", Value: bson.M{"attributes": ""}}},
+			want:   bson.M{"attributes": nil},
+		},
+		{
+			name:   "add to set each",
+			filter: bson.M{"_id": "1"},
+			update: bson.D{{Key: "$addToSet", Value: bson.M{"tags": bson.M{"$each": bson.A{"y", "z"}}}}},
+			want:   bson.M{"tags": bson.A{"x", "y", "z"}},
+		},
+		{
+			name:   "pull in",
+			filter: bson.M{"_id": "1"},
+			update: bson.D{{Key: "$pull", Value: bson.M{"tags": bson.M{"$in": bson.A{"x"}}}}},
+			want:   bson.M{"tags": bson.A{"y"}},
+		},
+		{
+			name:   "push",
+			filter: bson.M{"_id": "1"},
+			update: bson.D{{Key: "$push", Value: bson.M{"tags": "z"}}},
+			want:   bson.M{"tags": bson.A{"x", "y", "z"}},
+		},
+		{
+			name:   "push at position",
+			filter: bson.M{"_id": "1"},
+			update: bson.D{{Key: "$push", Value: bson.M{"tags": bson.D{{Key: "$each", Value: bson.A{"z"}}, {Key: "$position", Value: 1}}}}},
+			want:   bson.M{"tags": bson.A{"x", "z", "y"}},
+		},
+		{
+			name:   "positional",
+			filter: bson.M{"items.id": bson.M{"$in": bson.A{"i2"}}},
+			update: bson.D{{Key: "$set", Value: bson.M{"items.$.score": 10}}},
+			want:   bson.M{"items": bson.A{bson.D{{Key: "id", Value: "i1"}, {Key: "score", Value: 5}}, bson.D{{Key: "id", Value: "i2"}, {Key: "score", Value: 10}}}},
+		},
+		{
+			name:   "inc",
+			filter: bson.M{"_id": "2"},
+			update: bson.D{{Key: "$inc", Value: bson.M{"count": 5}}},
+			want:   bson.M{"count": 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col := newTestCollection(t)
+			res, err := col.UpdateOne(context.Background(), tt.filter, tt.update)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), res.ModifiedCount)
+			var doc bson.D
+			assert.NoError(t, col.FindOne(context.Background(), tt.filter).Decode(&doc))
+			for key, value := range tt.want {
+				got, _ := bsonquery.GetField(doc, key)
+				want, _ := bsonquery.NormalizeValue(value)
+				assert.True(t, bsonquery.EqualValues(want, got), "field %s: got %v want %v", key, got, value)
+			}
+		})
+	}
+}
+
+func TestUpsertAndDuplicateKey(t *testing.T) {
+	col := newTestCollection(t)
+	res, err := col.UpdateOne(context.Background(), bson.M{"_id": "4", "name": "d"},
+		bson.D{{Key: "$set", Value: bson.M{"count": 4}}}, options.Update().SetUpsert(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "4", res.UpsertedID)
+	assert.Equal(t, []string{"4"}, findIDs(t, col, bson.M{"name": "d", "count": 4}))
+
+	_, err = col.InsertOne(context.Background(), bson.M{"_id": "4"})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	err = col.FindOne(context.Background(), bson.M{"_id": "5"}).Err()
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	deleted, err := col.DeleteMany(context.Background(), bson.M{"customers": "c1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted.DeletedCount)
+	count, err := col.CountDocuments(context.Background(), bson.D{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestUniqueIndexes(t *testing.T) {
+	types.SetAPIInfo("/uniqueIndexesTest", types.APIInfo{DBCollection: "uniqueIndexesTest", Schema: types.SchemaInfo{Indexes: []types.IndexInfo{
+		types.NewIndex("name", "customers").WithUnique(),
+		types.NewIndex("code").WithUnique().WithPartialFilter(map[string]interface{}{"code": map[string]interface{}{"$exists": true}}),
+	}}})
+	s := NewStore()
+	defer s.Disconnect()
+	col := s.GetWriteCollection("uniqueIndexesTest")
+	ctx := context.Background()
+	_, err := col.InsertMany(ctx, []interface{}{
+		bson.M{"_id": "1", "name": "a", "customers": bson.A{"c1", "c2"}, "code": 1},
+		bson.M{"_id": "2", "name": "a", "customers": bson.A{"c3"}},
+		bson.M{"_id": "3", "name": "b", "customers": bson.A{"c1"}},
+	})
+	assert.NoError(t, err, "documents without the partial filter field are not indexed")
+
+	//array fields are indexed by their elements
+	_, err = col.InsertOne(ctx, bson.M{"_id": "4", "name": "a", "customers": bson.A{"c2"}})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+	_, err = col.InsertOne(ctx, bson.M{"_id": "4", "name": "b", "customers": bson.A{"c2"}, "code": 1})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	//updates and upserts are rejected without changes
+	_, err = col.UpdateOne(ctx, bson.M{"_id": "3"}, bson.M{"$set": bson.M{"name": "a"}})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+	_, err = col.UpdateMany(ctx, bson.M{"name": bson.M{"$in": bson.A{"a", "b"}}}, bson.M{"$set": bson.M{"name": "c"}})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+	assert.Empty(t, findIDs(t, col.(*collection), bson.M{"name": "c"}))
+	err = col.FindOneAndUpdate(ctx, bson.M{"_id": "3"}, bson.M{"$set": bson.M{"code": 1}}).Err()
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+	_, err = col.UpdateOne(ctx, bson.M{"_id": "5"}, bson.M{"$set": bson.M{"name": "b", "customers": bson.A{"c1"}}}, options.Update().SetUpsert(true))
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	//a document keeps its own keys, updated documents may not share keys
+	_, err = col.UpdateOne(ctx, bson.M{"_id": "1"}, bson.M{"$set": bson.M{"count": 1}})
+	assert.NoError(t, err)
+	_, err = col.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": bson.A{"1", "2"}}}, bson.M{"$set": bson.M{"customers": bson.A{"c4"}, "name": "d"}})
+	assert.True(t, mongo.IsDuplicateKeyError(err), "updated documents with the same keys")
+	_, err = col.UpdateMany(ctx, bson.M{"name": "a"}, bson.M{"$set": bson.M{"name": "e"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, findIDs(t, col.(*collection), bson.M{"name": "e"}))
+}
+
+func TestAggregate(t *testing.T) {
+	col := newTestCollection(t)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"customers": "c1"}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$tags"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$facet", Value: bson.M{
+			"results": bson.A{bson.M{"$skip": 1}, bson.M{"$limit": 1}},
+			"count":   bson.A{bson.M{"$count": "count"}},
+		}}},
+	}
+	cursor, err := col.Aggregate(context.Background(), pipeline)
+	assert.NoError(t, err)
+	var result []struct {
+		Results []struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		} `bson:"results"`
+		Count []struct {
+			Count int `bson:"count"`
+		} `bson:"count"`
+	}
+	assert.NoError(t, cursor.All(context.Background(), &result))
+	assert.Len(t, result, 1)
+	assert.Equal(t, 2, result[0].Count[0].Count)
+	assert.Equal(t, "y", result[0].Results[0].ID)
+	assert.Equal(t, 2, result[0].Results[0].Count)
+}
+
+func TestTTL(t *testing.T) {
+	s := NewStore().(*memoryStore)
+	defer s.Disconnect()
+	col := s.GetWriteCollection(consts.UsersNotificationsCacheCollection)
+	past := primitive.NewDateTimeFromTime(now().Add(-time.Second))
+	_, err := col.InsertMany(context.Background(), []interface{}{
+		bson.M{"_id": "expired", "expiryTime": past},
+		bson.M{"_id": "valid"},
+	})
+	assert.NoError(t, err)
+	s.removeExpired()
+	count, err := col.CountDocuments(context.Background(), bson.D{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
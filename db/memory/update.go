@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"config-service/db/bsonquery"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// isUpdateOperatorsDoc returns true if the update is an operators document (e.g. {$set: ...}) and not a replacement document
+func isUpdateOperatorsDoc(update bson.D) bool {
+	return len(update) > 0 && strings.HasPrefix(update[0].Key, "$")
+}
+
+// applyUpdate applies the update operators on a copy of the document and returns it
+// filter is used to resolve the positional $ operator, insert is true when the document is created by an upsert
+func applyUpdate(doc bson.D, update bson.D, filter bson.D, insert bool) (bson.D, error) {
+	if !isUpdateOperatorsDoc(update) {
+		return nil, fmt.Errorf("update document requires atomic operators")
+	}
+	result := bsonquery.CloneDoc(doc)
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("modifiers operate on fields but we found type %T instead", op.Value)
+		}
+		for _, field := range fields {
+			if field.Key == "_id" && op.Key != "$setOnInsert" && !(op.Key == "$set" && insert) {
+				if current, ok := bsonquery.GetField(result, "_id"); !ok || !bsonquery.EqualValues(current, field.Value) {
+					return nil, fmt.Errorf("performing an update on the path '_id' would modify the immutable field '_id'")
+				}
+			}
+			path, err := resolvePositional(result, filter, field.Key)
+			if err != nil {
+				return nil, err
+			}
+			if result, err = applyOperator(result, op.Key, path, field.Value, insert); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// resolvePositional replaces the positional $ operator in path with the index of the first matching array element
+func resolvePositional(doc bson.D, filter bson.D, path string) ([]string, error) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if part != "$" {
+			continue
+		}
+		index := bsonquery.FirstMatchingIndex(doc, filter, strings.Join(parts[:i], "."))
+		if index < 0 {
+			return nil, fmt.Errorf("the positional operator did not find the match needed from the query")
+		}
+		parts[i] = fmt.Sprint(index)
+	}
+	return parts, nil
+}
+
+func applyOperator(doc bson.D, operator string, path []string, value interface{}, insert bool) (bson.D, error) {
+	switch operator {
+	case "$set":
+		return bsonquery.SetPath(doc, path, bsonquery.CloneValue(value))
+	case "$setOnInsert":
+		if !insert {
+			return doc, nil
+		}
+		return bsonquery.SetPath(doc, path, bsonquery.CloneValue(value))
+	case "$unset":
+		return bsonquery.UnsetPath(doc, path).(bson.D), nil
+	case "$inc":
+		current, _ := bsonquery.ResolvePath(doc, path)
+		if current == nil {
+			return bsonquery.SetPath(doc, path, value)
+		}
+		sum, err := bsonquery.AddNumbers(current, value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot apply $inc to %s: %w", strings.Join(path, "."), err)
+		}
+		return bsonquery.SetPath(doc, path, sum)
+	case "$max", "$min":
+		current, found := bsonquery.ResolvePath(doc, path)
+		c := bsonquery.CompareValues(value, current)
+		if !found || (operator == "$max" && c > 0) || (operator == "$min" && c < 0) {
+			return bsonquery.SetPath(doc, path, value)
+		}
+		return doc, nil
+	case "$push", "$addToSet":
+		current, _ := bsonquery.ResolvePath(doc, path)
+		arr, ok := current.(bson.A)
+		if current != nil && !ok {
+			return nil, fmt.Errorf("the field '%s' must be an array but is of type %T", strings.Join(path, "."), current)
+		}
+		position := len(arr)
+		if operator == "$push" {
+			if position, ok = pushPosition(value, len(arr)); !ok {
+				return nil, fmt.Errorf("the $position modifier of '%s' must be an integer", strings.Join(path, "."))
+			}
+		}
+		items := bson.A{}
+		for _, item := range eachValues(value) {
+			if operator == "$addToSet" && (bsonquery.ContainsValue(arr, item) || bsonquery.ContainsValue(items, item)) {
+				continue
+			}
+			items = append(items, bsonquery.CloneValue(item))
+		}
+		result := append(bson.A{}, arr[:position]...)
+		result = append(result, items...)
+		result = append(result, arr[position:]...)
+		return bsonquery.SetPath(doc, path, result)
+	case "$pull":
+		current, _ := bsonquery.ResolvePath(doc, path)
+		arr, ok := current.(bson.A)
+		if !ok {
+			return doc, nil
+		}
+		kept := bson.A{}
+		for _, item := range arr {
+			pull, err := pullMatch(item, value)
+			if err != nil {
+				return nil, err
+			}
+			if !pull {
+				kept = append(kept, item)
+			}
+		}
+		return bsonquery.SetPath(doc, path, kept)
+	case "$pullAll":
+		current, _ := bsonquery.ResolvePath(doc, path)
+		arr, ok := current.(bson.A)
+		values, _ := value.(bson.A)
+		if !ok {
+			return doc, nil
+		}
+		kept := bson.A{}
+		for _, item := range arr {
+			if !bsonquery.ContainsValue(values, item) {
+				kept = append(kept, item)
+			}
+		}
+		return bsonquery.SetPath(doc, path, kept)
+	case "$currentDate":
+		return bsonquery.SetPath(doc, path, primitive.NewDateTimeFromTime(now()))
+	}
+	return nil, fmt.Errorf("unknown modifier: %s", operator)
+}
+
+// eachValues returns the values of a {$each: [...]} modifier or the value itself
+func eachValues(value interface{}) bson.A {
+	if d, ok := value.(bson.D); ok && len(d) > 0 && d[0].Key == "$each" {
+		values, _ := d[0].Value.(bson.A)
+		return values
+	}
+	return bson.A{value}
+}
+
+// pushPosition returns the index to insert the items of a $push with a {$each: [...], $position: n} modifier, negative positions count from the end of the array
+func pushPosition(value interface{}, size int) (int, bool) {
+	d, ok := value.(bson.D)
+	if !ok || len(d) == 0 || d[0].Key != "$each" {
+		return size, true
+	}
+	positionValue, ok := bsonquery.GetField(d, "$position")
+	if !ok {
+		return size, true
+	}
+	position, ok := bsonquery.ToInt64(positionValue)
+	if !ok {
+		return 0, false
+	}
+	if position < 0 {
+		position += int64(size)
+	}
+	return int(max(0, min(position, int64(size)))), true
+}
+
+// pullMatch returns true if the array item matches a $pull condition
+func pullMatch(item interface{}, condition interface{}) (bool, error) {
+	cond, ok := condition.(bson.D)
+	if !ok {
+		return bsonquery.EqualValues(item, condition), nil
+	}
+	if bsonquery.IsOperatorsDoc(cond) {
+		return bsonquery.MatchCondition([]interface{}{item}, cond)
+	}
+	doc, ok := item.(bson.D)
+	if !ok {
+		return false, nil
+	}
+	return bsonquery.MatchDoc(doc, cond)
+}
+
+// newUpsertDoc creates the base document of an upsert from the equality conditions of the filter
+func newUpsertDoc(filter bson.D) bson.D {
+	doc := bson.D{}
+	for _, e := range bsonquery.FlattenAnd(filter) {
+		if strings.HasPrefix(e.Key, "$") {
+			continue
+		}
+		value := e.Value
+		if cond, ok := value.(bson.D); ok && bsonquery.IsOperatorsDoc(cond) {
+			eq, ok := bsonquery.GetField(cond, "$eq")
+			if !ok || len(cond) != 1 {
+				continue
+			}
+			value = eq
+		}
+		if _, isRegex := value.(primitive.Regex); isRegex {
+			continue
+		}
+		if newDoc, err := bsonquery.SetPath(doc, strings.Split(e.Key, "."), bsonquery.CloneValue(value)); err == nil {
+			doc = newDoc
+		}
+	}
+	return doc
+}
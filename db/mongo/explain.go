@@ -0,0 +1,22 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Explain returns the query planner explain of an aggregation pipeline of the collection
+func Explain(c context.Context, collectionName string, pipeline interface{}) (bson.Raw, error) {
+	if mongoDB == nil {
+		return nil, errNotConnected
+	}
+	return mongoDB.RunCommand(c, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "aggregate", Value: collectionName},
+			{Key: "pipeline", Value: pipeline},
+			{Key: "cursor", Value: bson.D{}},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Raw()
+}
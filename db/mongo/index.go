@@ -1,462 +1,331 @@
 package mongo
 
 import (
+	"bytes"
+	"config-service/types"
 	"config-service/utils/consts"
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
 )
 
-// collectionIndexes is a map of collection name to index models for collections that need custom indexes
-// if a collection is not in this map, it will use the default index
-var collectionIndexes = map[string][]mongo.IndexModel{
-	consts.CustomersCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "activeSubscription.licenseType", Value: 1},
-				{Key: "activeSubscription.subscriptionStatus", Value: 1},
-				{Key: "subscription_date", Value: 1},
-			},
-		},
-	},
-	consts.UsersNotificationsCacheCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "name", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "dataType", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "expiryTime", Value: 1},
-			},
-			Options: options.Index().SetExpireAfterSeconds(0),
-		},
-	},
-	consts.AttackChainsCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "name", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "clusterName", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "lastPostureScanTriggered", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "lastTimeEngineCompleted", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "creationTime", Value: -1},
-			},
-		},
-	},
-	consts.UsersNotificationsVulnerabilitiesCollection: {
-		{
-			Keys: bson.D{
-				{Key: "cveID", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "cluster", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "namespace", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "notificationType", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-	},
-	consts.CollaborationConfigCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "name", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "provider", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-	},
-	consts.IntegrationReferenceCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "provider", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "type", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.name", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.kind", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.namespace", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.cluster", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.repoHash", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.resourceID", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "owner.resourceHash", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.layerHash", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.controlID", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.baseScore", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.cveName", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.cveID", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.severity", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.severityScore", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.component", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.componentVersion", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedObjects.imageRepository", Value: 1},
-			},
-		},
-	},
-	consts.RuntimeIncidentCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "_id", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "_id", Value: 1},
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "name", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedAlerts.ruleID", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "relatedAlerts.timestamp", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-				// {Key: "customers", Value: 1}, can't have multiple fields in an index from different arrays
-				{Key: "relatedAlerts.timestamp", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-				{Key: "incidentSeverity", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "isDismissed", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "creationTimestamp", Value: -1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-				{Key: "workloadKind", Value: 1},
-				{Key: "workloadName", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-				{Key: "isDismissed", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-				{Key: "severityScore", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-				{Key: "timestamp", Value: 1},
-			},
-		},
-	},
-	consts.ClustersCollection: {
-		{
-			Keys: bson.D{
-				{Key: "_id", Value: 1},
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "_id", Value: 1},
-			},
-		},
-	},
+// collectionIndexes are the indexes of the collections that are not served by routes
+// the collections of routes declare their indexes in the route schema info (see types.SchemaInfo.Indexes)
+var collectionIndexes = map[string][]types.IndexInfo{
 	// We are paying the proce of not handling this index from config service but as a module
 	consts.TokensCollection: {
-		{
-			Keys: bson.D{
-				{Key: "customerGuid", Value: 1},
-				{Key: "value", Value: 1},
-				{Key: "type", Value: 1},
-			},
-		},
+		types.NewIndex("customerGuid", "value", "type"),
 	},
-	consts.VulnerabilityExceptionPolicyCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "name", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "attributes.namespaceOnly", Value: 1},
-				{Key: "designators.attributes.cluster", Value: 1},
-				{Key: "designators.attributes.namespace", Value: 1},
-				// can't index on both customer and designators, as they are in different arrays
-				// {Key: "customers", Value: 1},
-			},
-		},
+	consts.JobsCollection: {
+		types.NewIndex("state", "creationTime"),
+		types.NewIndex("-creationTime"),
 	},
-	consts.ContainerImageRegistriesCollection: {
-		{
-			Keys: bson.D{
-				{Key: "guid", Value: 1},
-			},
-		},
-		{
-			Keys: bson.D{
-				{Key: "customers", Value: 1},
-			},
-		},
+	consts.DiagnosticsCollection: {
+		types.NewIndex("route", "-timestamp"),
+		types.NewIndex("-timestamp"),
+		types.NewIndex("expiryTime").WithTTL(0),
+	},
+	consts.CacheInvalidationsCollection: {
+		types.NewIndex("timestamp"),
+		types.NewIndex("expiryTime").WithTTL(0),
 	},
 }
 
-// defaultIndex is the default index for all collections unless overridden in collectionIndexes
-var defaultIndex = []mongo.IndexModel{
-	{
-		Keys: bson.D{
-			{Key: "guid", Value: 1},
-		},
-	},
-	{
-		Keys: bson.D{
-			{Key: "name", Value: 1},
-		},
-	},
-	{
-		Keys: bson.D{
-			{Key: "customers", Value: 1},
-		},
-	},
+// versionsIndexes are the indexes of the version history collections
+var versionsIndexes = []types.IndexInfo{
+	types.NewIndex("docGUID", "customers", "-version"),
+	types.NewIndex("customers"),
 }
 
-func createIndexes() error {
-	zap.L().Info("creating indexes on mongo")
-	collections, err := ListCollectionNames(context.Background())
+// defaultIndexes are the indexes of the collections without declared indexes
+var defaultIndexes = []types.IndexInfo{
+	types.NewIndex("guid"),
+	types.NewIndex("name"),
+	types.NewIndex("customers"),
+}
+
+// idIndexName is the name of the index mongo creates on the _id of every collection
+const idIndexName = "_id_"
+
+// dropExtraIndexes drops the indexes that are not declared on reconciliation, otherwise they are only reported
+var dropExtraIndexes bool
+
+// last reconciliation report of each collection, by collection name
+var indexesReports = sync.Map{}
+
+// declaredIndexes returns the indexes declared by the routes of the collection, or the indexes of collections without routes
+func declaredIndexes(collectionName string) []types.IndexInfo {
+	if indexes := types.GetCollectionIndexes(collectionName); len(indexes) > 0 {
+		return indexes
+	}
+	if indexes, ok := collectionIndexes[collectionName]; ok {
+		return indexes
+	}
+	if strings.HasSuffix(collectionName, consts.VersionsCollectionSuffix) {
+		return versionsIndexes
+	}
+	return defaultIndexes
+}
+
+// ReconcileIndexes reconciles the indexes of the existing collections and of the collections with declared indexes
+// it must be called after the routes are added so the indexes declared by the routes are known
+func ReconcileIndexes(c context.Context) ([]types.IndexesReport, error) {
+	zap.L().Info("reconciling indexes on mongo")
+	collections, err := ListCollectionNames(c)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, path := range types.GetAllPaths() {
+		collections = append(collections, types.GetAPIInfo(path).DBCollection)
 	}
+	for collection := range collectionIndexes {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	collections = slices.Compact(collections)
+	reports := make([]types.IndexesReport, 0, len(collections))
+	var errs error
 	for _, collection := range collections {
-		if err := IndexCollection(collection); err != nil {
-			return err
+		report := reconcileCollection(c, collection, dropExtraIndexes)
+		if report.Error != "" {
+			errs = multierror.Append(errs, fmt.Errorf("collection %s: %s", collection, report.Error))
 		}
+		reports = append(reports, report)
 	}
-	return nil
+	return reports, errs
 }
 
+// IndexesReports returns the last reconciliation report of each collection sorted by collection name
+func IndexesReports() []types.IndexesReport {
+	reports := []types.IndexesReport{}
+	indexesReports.Range(func(_, value interface{}) bool {
+		reports = append(reports, value.(types.IndexesReport))
+		return true
+	})
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Collection < reports[j].Collection })
+	return reports
+}
+
+// IndexesStatus returns the errors of the collections whose indexes failed to be reconciled
+func IndexesStatus(c context.Context) error {
+	var err error
+	for _, report := range IndexesReports() {
+		if report.Error != "" {
+			err = multierror.Append(err, fmt.Errorf("failed to create indexes of collection %s: %s", report.Collection, report.Error))
+		}
+	}
+	return err
+}
+
+// IndexCollection creates the missing declared indexes of the collection (and the collection if it does not exist)
+// extra indexes are only reported, they are dropped by ReconcileIndexes once all the routes declared their indexes
 func IndexCollection(collectionName string) error {
-	if indexModels, ok := collectionIndexes[collectionName]; ok {
-		if indexModels == nil {
-			return nil
+	report := reconcileCollection(context.Background(), collectionName, false)
+	if report.Error != "" {
+		return fmt.Errorf("failed to index collection %s: %s", collectionName, report.Error)
+	}
+	return nil
+}
+
+// IndexesUsage returns the $indexStats usage of the indexes of the collection, of all the collections when empty
+func IndexesUsage(c context.Context, collectionName string) ([]types.IndexUsage, error) {
+	collections := []string{collectionName}
+	if collectionName == "" {
+		var err error
+		if collections, err = ListCollectionNames(c); err != nil {
+			return nil, err
 		}
-		// if collection has custom indexes, create them
-		zap.L().Info("creating custom indexes", zap.String("collection", collectionName), zap.Any("indexes", indexModels))
-		res, err := GetReadCollection(collectionName).Indexes().CreateMany(context.Background(), indexModels)
-		if err != nil {
-			zap.L().Error("failed to create custom indexes", zap.Error(err), zap.Any("result", res), zap.String("collection", collectionName))
-			return err
+		sort.Strings(collections)
+	}
+	usage := []types.IndexUsage{}
+	for _, collection := range collections {
+		declared := map[string]bool{idIndexName: true}
+		for _, index := range declaredIndexes(collection) {
+			declared[index.GetName()] = true
 		}
-		zap.L().Info("created custom indexes", zap.String("collection", collectionName), zap.Any("result", res))
-	} else {
-		// otherwise, create the default indexes
-		zap.L().Info("creating default indexes", zap.String("collection", collectionName), zap.Any("indexes", defaultIndex))
-		res, err := GetReadCollection(collectionName).Indexes().CreateMany(context.Background(), defaultIndex)
+		cur, err := GetReadCollection(collection).Aggregate(c, mongo.Pipeline{{{Key: "$indexStats", Value: bson.D{}}}})
 		if err != nil {
-			zap.L().Error("failed to create default indexes", zap.Error(err), zap.Any("result", res), zap.String("collection", collectionName))
-			return err
+			return nil, fmt.Errorf("failed to get index stats of collection %s: %w", collection, err)
+		}
+		var stats []struct {
+			Name     string   `bson:"name"`
+			Key      bson.Raw `bson:"key"`
+			Host     string   `bson:"host"`
+			Accesses struct {
+				Ops   int64     `bson:"ops"`
+				Since time.Time `bson:"since"`
+			} `bson:"accesses"`
+		}
+		if err := cur.All(c, &stats); err != nil {
+			return nil, fmt.Errorf("failed to get index stats of collection %s: %w", collection, err)
+		}
+		for _, stat := range stats {
+			usage = append(usage, types.IndexUsage{
+				Collection: collection,
+				Name:       stat.Name,
+				Keys:       stat.Key.String(),
+				Declared:   declared[stat.Name],
+				Ops:        stat.Accesses.Ops,
+				Since:      stat.Accesses.Since,
+				Host:       stat.Host,
+			})
 		}
-		zap.L().Info("created default indexes", zap.String("collection", collectionName), zap.Any("result", res))
 	}
-	return nil
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].Collection != usage[j].Collection {
+			return usage[i].Collection < usage[j].Collection
+		}
+		return usage[i].Name < usage[j].Name
+	})
+	return usage, nil
+}
+
+// existingIndex is the specification of an existing index
+type existingIndex struct {
+	Name               string                 `bson:"name"`
+	Unique             bool                   `bson:"unique"`
+	ExpireAfterSeconds *int32                 `bson:"expireAfterSeconds"`
+	PartialFilter      map[string]interface{} `bson:"partialFilterExpression"`
+}
+
+// reconcileCollection creates the missing declared indexes of the collection, the extra and changed indexes are reported and dropped when drop is true
+func reconcileCollection(c context.Context, collectionName string, drop bool) types.IndexesReport {
+	declared := declaredIndexes(collectionName)
+	report := types.IndexesReport{Collection: collectionName, ReconciledAt: time.Now().UTC(), Declared: make([]string, 0, len(declared))}
+	defer func() {
+		if report.Error != "" {
+			zap.L().Error("failed to reconcile indexes", zap.String("collection", collectionName), zap.String("error", report.Error))
+		} else if len(report.Extra) > 0 || len(report.Changed) > 0 {
+			zap.L().Warn("collection has undeclared indexes", zap.String("collection", collectionName),
+				zap.Strings("extra", report.Extra), zap.Strings("changed", report.Changed), zap.Strings("dropped", report.Dropped))
+		}
+		indexesReports.Store(collectionName, report)
+	}()
+	existing, err := listIndexes(c, collectionName)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	indexes := GetWriteCollection(collectionName).Indexes()
+	missing := []mongo.IndexModel{}
+	declaredNames := map[string]bool{}
+	for _, index := range declared {
+		name := index.GetName()
+		report.Declared = append(report.Declared, name)
+		declaredNames[name] = true
+		current, ok := existing[name]
+		if ok && sameIndexOptions(index, current) {
+			continue
+		}
+		if ok {
+			report.Changed = append(report.Changed, name)
+			if !drop {
+				continue
+			}
+			if _, err := indexes.DropOne(c, name); err != nil {
+				report.Error = err.Error()
+				return report
+			}
+			report.Dropped = append(report.Dropped, name)
+		}
+		missing = append(missing, indexModel(index))
+	}
+	for name := range existing {
+		if name == idIndexName || declaredNames[name] {
+			continue
+		}
+		report.Extra = append(report.Extra, name)
+	}
+	sort.Strings(report.Extra)
+	if drop {
+		for _, name := range report.Extra {
+			if _, err := indexes.DropOne(c, name); err != nil {
+				report.Error = err.Error()
+				return report
+			}
+			report.Dropped = append(report.Dropped, name)
+		}
+	}
+	if len(missing) == 0 {
+		return report
+	}
+	//creating indexes creates the collection if it does not exist
+	created, err := indexes.CreateMany(c, missing)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Created = created
+	zap.L().Info("created indexes", zap.String("collection", collectionName), zap.Strings("indexes", created))
+	return report
+}
+
+// listIndexes returns the existing indexes of the collection by name, a collection that does not exist has no indexes
+func listIndexes(c context.Context, collectionName string) (map[string]existingIndex, error) {
+	cur, err := GetReadCollection(collectionName).Indexes().List(c)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	existing := map[string]existingIndex{}
+	for cur.Next(c) {
+		var index existingIndex
+		if err := cur.Decode(&index); err != nil {
+			return nil, err
+		}
+		existing[index.Name] = index
+	}
+	return existing, cur.Err()
+}
+
+// sameIndexOptions returns true if the existing index has the options of the declared index
+func sameIndexOptions(declared types.IndexInfo, existing existingIndex) bool {
+	if declared.Unique != existing.Unique {
+		return false
+	}
+	if (declared.ExpireAfterSeconds == nil) != (existing.ExpireAfterSeconds == nil) ||
+		(declared.ExpireAfterSeconds != nil && *declared.ExpireAfterSeconds != *existing.ExpireAfterSeconds) {
+		return false
+	}
+	if len(declared.PartialFilter) == 0 || len(existing.PartialFilter) == 0 {
+		return len(declared.PartialFilter) == len(existing.PartialFilter)
+	}
+	//compare the filters by their json encoding, the keys are sorted and numbers of different types are equal
+	declaredFilter, err1 := json.Marshal(declared.PartialFilter)
+	existingFilter, err2 := json.Marshal(existing.PartialFilter)
+	return err1 == nil && err2 == nil && bytes.Equal(declaredFilter, existingFilter)
+}
+
+// indexModel returns the mongo index model of a declared index
+func indexModel(index types.IndexInfo) mongo.IndexModel {
+	keys := bson.D{}
+	for _, key := range index.Keys {
+		if key.Text {
+			keys = append(keys, bson.E{Key: key.Field, Value: "text"})
+		} else {
+			keys = append(keys, bson.E{Key: key.Field, Value: key.GetOrder()})
+		}
+	}
+	opts := options.Index().SetName(index.GetName())
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.ExpireAfterSeconds != nil {
+		opts.SetExpireAfterSeconds(*index.ExpireAfterSeconds)
+	}
+	if len(index.PartialFilter) > 0 {
+		opts.SetPartialFilterExpression(index.PartialFilter)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}
 }
@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"config-service/utils/metrics"
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// monitored commands, other commands (e.g. handshakes and pings) are not reported
+var monitoredCommands = map[string]bool{
+	"find":          true,
+	"getMore":       true,
+	"aggregate":     true,
+	"count":         true,
+	"distinct":      true,
+	"insert":        true,
+	"update":        true,
+	"delete":        true,
+	"findAndModify": true,
+}
+
+// newCommandMonitor reports the latency and errors of the mongo commands by collection and operation
+func newCommandMonitor() *event.CommandMonitor {
+	//collection of the started commands by request id
+	startedCommands := sync.Map{}
+	finished := func(evt event.CommandFinishedEvent, failed bool) {
+		collection, ok := startedCommands.LoadAndDelete(evt.RequestID)
+		if !ok {
+			return
+		}
+		metrics.MongoOperationDuration.WithLabelValues(collection.(string), evt.CommandName).Observe(evt.Duration.Seconds())
+		if failed {
+			metrics.MongoOperationErrors.WithLabelValues(collection.(string), evt.CommandName).Inc()
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			if !monitoredCommands[evt.CommandName] {
+				return
+			}
+			//the collection is the value of the command name field, getMore has the cursor id there and the collection in a field
+			field := evt.CommandName
+			if field == "getMore" {
+				field = "collection"
+			}
+			if collection, ok := evt.Command.Lookup(field).StringValueOK(); ok {
+				startedCommands.Store(evt.RequestID, collection)
+			}
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finished(evt.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finished(evt.CommandFinishedEvent, true)
+		},
+	}
+}
+
+// newPoolMonitor reports the open and checked out connections of the connection pools
+func newPoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			switch evt.Type {
+			case event.ConnectionCreated:
+				metrics.MongoPoolOpenConnections.Inc()
+			case event.ConnectionClosed:
+				metrics.MongoPoolOpenConnections.Dec()
+			case event.GetSucceeded:
+				metrics.MongoPoolInUseConnections.Inc()
+			case event.ConnectionReturned:
+				metrics.MongoPoolInUseConnections.Dec()
+			case event.GetFailed:
+				metrics.MongoPoolCheckoutFailures.Inc()
+			}
+		},
+	}
+}
@@ -3,6 +3,7 @@ package mongo
 import (
 	"config-service/utils"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -11,12 +12,18 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"go.uber.org/zap"
 )
 
 var mongoDB, mongoDBprimary *mongo.Database
 
+var errNotConnected = errors.New("not connected to mongo")
+
+// transactions are supported by replica sets
+var transactionsSupported bool
+
 func MustConnect(config utils.MongoConfig) {
 	if err := Connect(config); err != nil {
 		zap.L().Fatal("failed to connect to mongo", zap.Error(err))
@@ -29,27 +36,25 @@ func EnsureConnected() error {
 	var primaryDBPingError error
 
 	zap.L().Info("checking mongo connectivity")
-	if mongoDB != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			dbPingError = mongoDB.Client().Ping(context.TODO(), nil)
-		}()
-	}
-	if mongoDBprimary != nil && mongoDBprimary != mongoDB {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dbPingError = PingSecondary(context.TODO())
+	}()
+	if mongoDBprimary != mongoDB {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			primaryDBPingError = mongoDBprimary.Client().Ping(context.TODO(), nil)
+			primaryDBPingError = PingPrimary(context.TODO())
 		}()
 	}
 	wg.Wait()
 	var err error
 	if dbPingError != nil {
-		err = multierror.Append(err)
+		err = multierror.Append(err, dbPingError)
 	}
 	if primaryDBPingError != nil {
-		err = multierror.Append(err)
+		err = multierror.Append(err, primaryDBPingError)
 	}
 	if err != nil {
 		zap.L().Error("mongo connection failed", zap.Error(err))
@@ -59,9 +64,33 @@ func EnsureConnected() error {
 	return err
 }
 
+// PingPrimary pings the primary database used for writes
+func PingPrimary(c context.Context) error {
+	if mongoDBprimary == nil {
+		return errNotConnected
+	}
+	if err := mongoDBprimary.Client().Ping(c, readpref.Primary()); err != nil {
+		return fmt.Errorf("failed to ping primary: %w", err)
+	}
+	return nil
+}
+
+// PingSecondary pings the database used for reads, with a single node it is the primary
+func PingSecondary(c context.Context) error {
+	if mongoDB == nil {
+		return errNotConnected
+	}
+	if err := mongoDB.Client().Ping(c, nil); err != nil {
+		return fmt.Errorf("failed to ping secondary: %w", err)
+	}
+	return nil
+}
+
 func Connect(config utils.MongoConfig) error {
 	defaultOpts := options.Client().
-		SetRetryWrites(true)
+		SetRetryWrites(true).
+		SetMonitor(newCommandMonitor()).
+		SetPoolMonitor(newPoolMonitor())
 	if config.MaxPoolSize > 0 {
 		defaultOpts.SetMaxPoolSize(uint64(config.MaxPoolSize))
 	}
@@ -84,6 +113,7 @@ func Connect(config utils.MongoConfig) error {
 		} else if mongoDBprimary = primeClient.Database(config.DB, dbOptionsWriteConcern); mongoDBprimary == nil {
 			return fmt.Errorf("failed to connect to primary DB. database: %s /n url: %s", config.DB, primaryUrl)
 		}
+		transactionsSupported = true
 	} else {
 		zap.L().Info("connecting to single node " + config.Host)
 		mongoDB = dbClient.Database(config.DB, dbOptionsWriteConcern)
@@ -91,12 +121,11 @@ func Connect(config utils.MongoConfig) error {
 			return fmt.Errorf("failed to connect to DB. database: %s /n url: %s", config.DB, url)
 		}
 		mongoDBprimary = mongoDB
+		transactionsSupported = false
 	}
 
-	if err := createIndexes(); err != nil {
-		return err
-	}
-
+	//the indexes are reconciled by ReconcileIndexes once the routes declared their indexes
+	dropExtraIndexes = config.DropExtraIndexes
 	return EnsureConnected()
 }
 
@@ -118,6 +147,24 @@ func GetWriteCollection(collectionName string) *mongo.Collection {
 	return mongoDBprimary.Collection(collectionName)
 }
 
+// SupportsTransactions returns true when connected to a replica set
+func SupportsTransactions() bool {
+	return transactionsSupported
+}
+
+// WithTransaction runs fn in a transaction of the primary, the transaction is retried on transient errors
+func WithTransaction(c context.Context, fn func(c context.Context) error) error {
+	session, err := mongoDBprimary.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(c)
+	_, err = session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
 func ListCollectionNames(c context.Context) ([]string, error) {
 	return mongoDB.ListCollectionNames(c, bson.D{}, options.ListCollections().SetAuthorizedCollections(true).SetNameOnly(true))
 }
@@ -0,0 +1,61 @@
+package mongo
+
+import (
+	"config-service/db/store"
+	"config-service/types"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// mongoStore is the mongo implementation of store.Store, it uses the connection created by Connect
+type mongoStore struct{}
+
+// NewStore returns a store backed by the connected mongo database, Connect must be called before using it
+func NewStore() store.Store {
+	return mongoStore{}
+}
+
+func (mongoStore) GetReadCollection(collectionName string) store.Collection {
+	return GetReadCollection(collectionName)
+}
+
+func (mongoStore) GetWriteCollection(collectionName string) store.Collection {
+	return GetWriteCollection(collectionName)
+}
+
+func (mongoStore) ListCollectionNames(c context.Context) ([]string, error) {
+	return ListCollectionNames(c)
+}
+
+func (mongoStore) IndexCollection(collectionName string) error {
+	return IndexCollection(collectionName)
+}
+
+func (mongoStore) ReconcileIndexes(c context.Context) ([]types.IndexesReport, error) {
+	return ReconcileIndexes(c)
+}
+
+func (mongoStore) IndexesReports() []types.IndexesReport {
+	return IndexesReports()
+}
+
+func (mongoStore) IndexesUsage(c context.Context, collectionName string) ([]types.IndexUsage, error) {
+	return IndexesUsage(c, collectionName)
+}
+
+func (mongoStore) Explain(c context.Context, collectionName string, pipeline interface{}) (bson.Raw, error) {
+	return Explain(c, collectionName, pipeline)
+}
+
+func (mongoStore) SupportsTransactions() bool {
+	return SupportsTransactions()
+}
+
+func (mongoStore) WithTransaction(c context.Context, fn func(c context.Context) error) error {
+	return WithTransaction(c, fn)
+}
+
+func (mongoStore) Disconnect() {
+	Disconnect()
+}
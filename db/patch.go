@@ -0,0 +1,796 @@
+package db
+
+import (
+	"bytes"
+	"config-service/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/exp/slices"
+)
+
+// JSON merge patch (RFC 7396) and JSON patch (RFC 6902) of documents
+// a patch is applied on the JSON representation of a document and the paths it changed are translated to $set, $unset, $push and $pull update operators
+// paths that cannot be translated (e.g. a pulled item that is not unique in the array) are updated with $set of the closest field that can be
+
+const (
+	setOperator   = "$set"
+	unsetOperator = "$unset"
+	pushOperator  = "$push"
+	pullOperator  = "$pull"
+)
+
+// Patch is a parsed JSON merge patch or JSON patch
+type Patch interface {
+	// apply patches the JSON document and returns the patched document and the changes made to it
+	apply(doc interface{}) (interface{}, []patchChange, error)
+}
+
+// patchChange is a change made by a patch to a path of the JSON document
+type patchChange struct {
+	operator string   //$set, $unset, $push or $pull
+	path     []string //the changed path, for $push and $pull the path of the array
+	index    int      //the index of the pushed or pulled array item
+}
+
+// NewMergePatch parses a JSON merge patch, the patch must be a JSON object
+func NewMergePatch(data []byte) (Patch, error) {
+	patch, err := decodeJSON(data)
+	if err != nil {
+		return nil, InvalidPatchError{Reason: err.Error()}
+	}
+	object, ok := patch.(map[string]interface{})
+	if !ok {
+		return nil, InvalidPatchError{Reason: "merge patch must be a JSON object"}
+	}
+	return mergePatch(object), nil
+}
+
+// NewJSONPatch parses a JSON patch, the patch must be an array of operations
+func NewJSONPatch(data []byte) (Patch, error) {
+	var operations []struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &operations); err != nil {
+		return nil, InvalidPatchError{Reason: "JSON patch must be an array of operations"}
+	}
+	patch := make(jsonPatch, 0, len(operations))
+	for i, operation := range operations {
+		op := jsonPatchOperation{op: operation.Op}
+		switch operation.Op {
+		case "add", "remove", "replace", "move", "copy", "test":
+		default:
+			return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: unknown op %q", i, operation.Op)}
+		}
+		if operation.Path == nil {
+			return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: path is required", i)}
+		}
+		var err error
+		if op.path, err = parsePointer(*operation.Path); err != nil {
+			return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: %s", i, err.Error())}
+		}
+		switch operation.Op {
+		case "move", "copy":
+			if operation.From == nil {
+				return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: from is required", i)}
+			}
+			if op.from, err = parsePointer(*operation.From); err != nil {
+				return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: %s", i, err.Error())}
+			}
+			if operation.Op == "move" && len(op.from) < len(op.path) && isPathPrefix(op.from, op.path) {
+				return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: cannot move %s into itself", i, *operation.From)}
+			}
+		case "add", "replace", "test":
+			if len(operation.Value) == 0 {
+				return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: value is required", i)}
+			}
+			if op.value, err = decodeJSON(operation.Value); err != nil {
+				return nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: %s", i, err.Error())}
+			}
+		}
+		patch = append(patch, op)
+	}
+	return patch, nil
+}
+
+// PatchDocument applies the patch on a copy of doc and returns the patched document and the update command that applies the patch on the db document
+// validate is called with the patched document and may modify it, the fields it modifies are updated as well
+// a patch that modifies a read only field returns ReadOnlyFieldError, a patch that does not modify the document returns NoFieldsToUpdateError
+func PatchDocument[T types.DocContent](doc T, patch Patch, validate func(T) (T, error), readOnlyFields ...string) (T, bson.D, error) {
+	var patched T
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return patched, nil, err
+	}
+	tree, err := decodeJSON(data)
+	if err != nil {
+		return patched, nil, err
+	}
+	tree, changes, err := patch.apply(tree)
+	if err != nil {
+		return patched, nil, err
+	}
+	if data, err = json.Marshal(tree); err != nil {
+		return patched, nil, err
+	}
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return patched, nil, InvalidPatchError{Reason: fmt.Sprintf("patched document is invalid: %s", err.Error())}
+	}
+	oldFields, err := toBsonDoc(doc)
+	if err != nil {
+		return patched, nil, err
+	}
+	patchedFields, err := toBsonDoc(patched)
+	if err != nil {
+		return patched, nil, err
+	}
+	//changes of unknown fields or changes that keep the field value do not modify the document
+	if !slices.ContainsFunc(changes, func(change patchChange) bool { return !equalFields(oldFields, patchedFields, change.path[0]) }) {
+		return patched, nil, NoFieldsToUpdateError{}
+	}
+	if patched, err = validate(patched); err != nil {
+		return patched, nil, err
+	}
+	newFields, err := toBsonDoc(patched)
+	if err != nil {
+		return patched, nil, err
+	}
+	for _, field := range changedFields(patchedFields, newFields) {
+		changes = append(changes, patchChange{operator: setOperator, path: []string{field}})
+	}
+	changes = slices.DeleteFunc(changes, func(change patchChange) bool { return equalFields(oldFields, newFields, change.path[0]) })
+	for _, change := range changes {
+		if slices.Contains(readOnlyFields, change.path[0]) {
+			return patched, nil, ReadOnlyFieldError{Field: change.path[0]}
+		}
+	}
+	return patched, patchUpdateCommand(oldFields, newFields, changes), nil
+}
+
+// ///////////////////////////////////merge patch///////////////////////////////////////
+
+type mergePatch map[string]interface{}
+
+func (p mergePatch) apply(doc interface{}) (interface{}, []patchChange, error) {
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, nil, InvalidPatchError{Reason: "document is not a JSON object"}
+	}
+	var changes []patchChange
+	mergeObject(object, p, nil, &changes)
+	return object, changes, nil
+}
+
+// mergeObject merges the patch object into the target object, null values remove fields
+func mergeObject(target, patch map[string]interface{}, path []string, changes *[]patchChange) {
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := patch[key]
+		keyPath := appendPath(path, key)
+		if value == nil {
+			if _, ok := target[key]; ok {
+				delete(target, key)
+				*changes = append(*changes, patchChange{operator: unsetOperator, path: keyPath})
+			}
+			continue
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			if targetObject, ok := target[key].(map[string]interface{}); ok {
+				mergeObject(targetObject, object, keyPath, changes)
+				continue
+			}
+			//merge into an empty object to remove the null values
+			merged := map[string]interface{}{}
+			mergeObject(merged, object, keyPath, &[]patchChange{})
+			value = merged
+		}
+		target[key] = value
+		*changes = append(*changes, patchChange{operator: setOperator, path: keyPath})
+	}
+}
+
+// ///////////////////////////////////JSON patch///////////////////////////////////////
+
+type jsonPatchOperation struct {
+	op    string
+	path  []string
+	from  []string
+	value interface{}
+}
+
+type jsonPatch []jsonPatchOperation
+
+func (p jsonPatch) apply(doc interface{}) (interface{}, []patchChange, error) {
+	var changes []patchChange
+	for i, operation := range p {
+		var err error
+		var opChanges []patchChange
+		switch operation.op {
+		case "add":
+			doc, opChanges, err = addValue(doc, operation.path, operation.value)
+		case "remove":
+			doc, opChanges, err = removeValue(doc, operation.path)
+		case "replace":
+			doc, opChanges, err = replaceValue(doc, operation.path, operation.value)
+		case "move", "copy":
+			var value interface{}
+			if value, err = getValue(doc, operation.from); err != nil {
+				break
+			}
+			if operation.op == "move" {
+				if doc, opChanges, err = removeValue(doc, operation.from); err != nil {
+					break
+				}
+			} else {
+				value = copyJSON(value)
+			}
+			var addChanges []patchChange
+			doc, addChanges, err = addValue(doc, operation.path, value)
+			opChanges = append(opChanges, addChanges...)
+		case "test":
+			var value interface{}
+			if value, err = getValue(doc, operation.path); err == nil && !equalJSON(value, operation.value) {
+				return nil, nil, PatchTestFailedError{Path: pointerString(operation.path)}
+			}
+		}
+		if err != nil {
+			return nil, nil, InvalidPatchError{Reason: fmt.Sprintf("operation %d: %s", i, err.Error())}
+		}
+		changes = append(changes, opChanges...)
+	}
+	return doc, changes, nil
+}
+
+// addValue adds a field to an object, inserts an item to an array or replaces the document when the path is empty
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, []patchChange, error) {
+	if len(path) == 0 {
+		return replaceDocument(doc, value)
+	}
+	var change patchChange
+	doc, err := updateParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			change = patchChange{operator: setOperator, path: path}
+			return p, nil
+		case []interface{}:
+			index := len(p)
+			if key != "-" {
+				var err error
+				if index, err = arrayIndex(key, len(p)+1); err != nil {
+					return nil, err
+				}
+			}
+			p = append(p[:index], append([]interface{}{value}, p[index:]...)...)
+			change = patchChange{operator: pushOperator, path: path[:len(path)-1], index: index}
+			return p, nil
+		}
+		return nil, fmt.Errorf("%s is not an object or an array", pointerString(path[:len(path)-1]))
+	})
+	return doc, []patchChange{change}, err
+}
+
+// removeValue removes a field of an object or an item of an array
+func removeValue(doc interface{}, path []string) (interface{}, []patchChange, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("the document cannot be removed")
+	}
+	var change patchChange
+	doc, err := updateParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerString(path))
+			}
+			delete(p, key)
+			change = patchChange{operator: unsetOperator, path: path}
+			return p, nil
+		case []interface{}:
+			index, err := arrayIndex(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+			change = patchChange{operator: pullOperator, path: path[:len(path)-1], index: index}
+			return append(p[:index:index], p[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("%s is not an object or an array", pointerString(path[:len(path)-1]))
+	})
+	return doc, []patchChange{change}, err
+}
+
+// replaceValue replaces an existing value
+func replaceValue(doc interface{}, path []string, value interface{}) (interface{}, []patchChange, error) {
+	if len(path) == 0 {
+		return replaceDocument(doc, value)
+	}
+	doc, err := updateParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerString(path))
+			}
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			index, err := arrayIndex(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+			p[index] = value
+			return p, nil
+		}
+		return nil, fmt.Errorf("%s is not an object or an array", pointerString(path[:len(path)-1]))
+	})
+	return doc, []patchChange{{operator: setOperator, path: path}}, err
+}
+
+// replaceDocument replaces the whole document, the changes set the fields of the new document and unset the removed fields
+func replaceDocument(doc interface{}, value interface{}) (interface{}, []patchChange, error) {
+	newDoc, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("document must be a JSON object")
+	}
+	oldDoc, _ := doc.(map[string]interface{})
+	var changes []patchChange
+	for key := range newDoc {
+		changes = append(changes, patchChange{operator: setOperator, path: []string{key}})
+	}
+	for key := range oldDoc {
+		if _, ok := newDoc[key]; !ok {
+			changes = append(changes, patchChange{operator: unsetOperator, path: []string{key}})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path[0] < changes[j].path[0] })
+	return newDoc, changes, nil
+}
+
+// updateParent calls update with the parent of the path and the last path token and returns the document with the updated parent
+func updateParent(node interface{}, path []string, update func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return update(node, path[0])
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s does not exist", path[0])
+		}
+		updated, err := updateParent(child, path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []interface{}:
+		index, err := arrayIndex(path[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+		updated, err := updateParent(n[index], path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		n[index] = updated
+		return n, nil
+	}
+	return nil, fmt.Errorf("%s is not an object or an array", path[0])
+}
+
+// getValue returns the value at the path
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch n := doc.(type) {
+		case map[string]interface{}:
+			value, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerString(path[:i+1]))
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(n))
+			if err != nil {
+				return nil, err
+			}
+			doc = n[index]
+		default:
+			return nil, fmt.Errorf("%s is not an object or an array", pointerString(path[:i]))
+		}
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token, the index must be less than size
+func arrayIndex(token string, size int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	if index >= size {
+		return 0, fmt.Errorf("array index %d is out of range", index)
+	}
+	return index, nil
+}
+
+// parsePointer parses a JSON pointer (RFC 6901) to its tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %s", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = pointerUnescaper.Replace(tokens[i])
+	}
+	return tokens, nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func pointerString(path []string) string {
+	var sb strings.Builder
+	for _, token := range path {
+		sb.WriteString("/")
+		sb.WriteString(pointerEscaper.Replace(token))
+	}
+	return sb.String()
+}
+
+// decodeJSON decodes a JSON value keeping the numbers as json.Number so they are not rounded
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func copyJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			res[key] = copyJSON(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = copyJSON(item)
+		}
+		return res
+	}
+	return value
+}
+
+// equalJSON compares JSON values, numbers are equal if they have the same value
+func equalJSON(a, b interface{}) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if av == bv {
+			return true
+		}
+		af, aErr := av.Float64()
+		bf, bErr := bv.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			if other, ok := bv[key]; !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equalJSON(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// ///////////////////////////////////update command///////////////////////////////////////
+
+type patchUpdate struct {
+	operator string
+	path     []string
+	value    interface{}
+}
+
+// patchUpdateCommand translates the changes to an update command, the values are taken from the old and new db representations of the document
+func patchUpdateCommand(oldFields, newFields bson.D, changes []patchChange) bson.D {
+	var updates []patchUpdate
+	for _, change := range changes {
+		updates = addPatchUpdate(updates, translateChange(oldFields, newFields, change), newFields)
+	}
+	update := bson.D{}
+	for _, operator := range []string{setOperator, unsetOperator, pushOperator, pullOperator} {
+		fields := bson.D{}
+		for _, u := range updates {
+			if u.operator == operator {
+				fields = append(fields, bson.E{Key: strings.Join(u.path, "."), Value: u.value})
+			}
+		}
+		if len(fields) > 0 {
+			update = append(update, bson.E{Key: operator, Value: fields})
+		}
+	}
+	return update
+}
+
+// translateChange returns the update of a change or $set of the closest path with the new value when the change cannot be translated
+func translateChange(oldFields, newFields bson.D, change patchChange) patchUpdate {
+	for _, token := range change.path {
+		if token == "" || strings.Contains(token, ".") || strings.HasPrefix(token, "$") {
+			//not a valid db field path
+			return setUpdate(change.path[:1], newFields)
+		}
+	}
+	switch change.operator {
+	case unsetOperator:
+		if _, ok := lookupField(oldFields, change.path); ok {
+			if _, ok := lookupField(newFields, change.path); !ok {
+				return patchUpdate{operator: unsetOperator, path: change.path, value: ""}
+			}
+		}
+	case pushOperator:
+		oldArray, oldOk := lookupField(oldFields, change.path)
+		newArray, newOk := lookupField(newFields, change.path)
+		oldItems, _ := oldArray.(bson.A)
+		newItems, _ := newArray.(bson.A)
+		if oldOk && newOk && oldItems != nil && len(newItems) == len(oldItems)+1 && change.index <= len(oldItems) {
+			value := newItems[change.index]
+			if change.index == len(oldItems) {
+				return patchUpdate{operator: pushOperator, path: change.path, value: value}
+			}
+			return patchUpdate{operator: pushOperator, path: change.path, value: bson.D{{Key: "$each", Value: bson.A{value}}, {Key: "$position", Value: change.index}}}
+		}
+	case pullOperator:
+		oldArray, _ := lookupField(oldFields, change.path)
+		newArray, _ := lookupField(newFields, change.path)
+		oldItems, _ := oldArray.(bson.A)
+		newItems, _ := newArray.(bson.A)
+		if change.index < len(oldItems) && len(newItems) == len(oldItems)-1 {
+			if condition, ok := pullCondition(oldItems[change.index]); ok {
+				//pull removes all the matching items
+				matching := 0
+				for _, item := range oldItems {
+					if matchPullCondition(item, condition) {
+						matching++
+					}
+				}
+				if matching == 1 {
+					return patchUpdate{operator: pullOperator, path: change.path, value: condition}
+				}
+			}
+		}
+	}
+	return setUpdate(change.path, newFields)
+}
+
+// pullCondition returns the $pull condition of an array item, documents are matched by the values of their fields since the order of map fields is not kept
+func pullCondition(item interface{}) (bson.D, bool) {
+	doc, ok := item.(bson.D)
+	if !ok {
+		return bson.D{{Key: "$in", Value: bson.A{item}}}, true
+	}
+	condition := bson.D{}
+	var addFields func(doc bson.D, prefix string) bool
+	addFields = func(doc bson.D, prefix string) bool {
+		for _, e := range doc {
+			if e.Key == "" || strings.Contains(e.Key, ".") || strings.HasPrefix(e.Key, "$") {
+				return false
+			}
+			if value, ok := e.Value.(bson.D); ok && len(value) > 0 {
+				if !addFields(value, prefix+e.Key+".") {
+					return false
+				}
+				continue
+			}
+			condition = append(condition, bson.E{Key: prefix + e.Key, Value: e.Value})
+		}
+		return true
+	}
+	return condition, addFields(doc, "") && len(condition) > 0
+}
+
+// matchPullCondition returns true if the array item matches the $pull condition
+func matchPullCondition(item interface{}, condition bson.D) bool {
+	if len(condition) == 1 && condition[0].Key == "$in" {
+		return equalValues(item, condition[0].Value.(bson.A)[0])
+	}
+	for _, e := range condition {
+		if value, ok := lookupField(item, strings.Split(e.Key, ".")); !ok || !equalValues(value, e.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// setUpdate sets the path to its new value, if the path does not exist in the new document its parent is set or the field is unset
+func setUpdate(path []string, newFields bson.D) patchUpdate {
+	if value, ok := lookupField(newFields, path); ok {
+		return patchUpdate{operator: setOperator, path: path, value: value}
+	}
+	if len(path) == 1 {
+		return patchUpdate{operator: unsetOperator, path: path, value: ""}
+	}
+	return setUpdate(path[:len(path)-1], newFields)
+}
+
+// addPatchUpdate adds an update, updates of the same path or of a path and its sub paths conflict and are replaced by $set of the shortest path
+func addPatchUpdate(updates []patchUpdate, update patchUpdate, newFields bson.D) []patchUpdate {
+	shortest := update.path
+	kept := make([]patchUpdate, 0, len(updates))
+	for _, u := range updates {
+		if isPathPrefix(u.path, update.path) || isPathPrefix(update.path, u.path) {
+			if len(u.path) < len(shortest) {
+				shortest = u.path
+			}
+			continue
+		}
+		kept = append(kept, u)
+	}
+	if len(kept) == len(updates) {
+		return append(updates, update)
+	}
+	return addPatchUpdate(kept, setUpdate(shortest, newFields), newFields)
+}
+
+func isPathPrefix(prefix, path []string) bool {
+	return len(prefix) <= len(path) && slices.Equal(prefix, path[:len(prefix)])
+}
+
+func appendPath(path []string, token string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), token)
+}
+
+// lookupField returns the value of a path in a db document
+func lookupField(doc interface{}, path []string) (interface{}, bool) {
+	for _, token := range path {
+		switch d := doc.(type) {
+		case bson.D:
+			found := false
+			for _, e := range d {
+				if e.Key == token {
+					doc, found = e.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case bson.A:
+			index, err := arrayIndex(token, len(d))
+			if err != nil {
+				return nil, false
+			}
+			doc = d[index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// equalFields returns true if the field has the same value in both documents or is missing in both
+func equalFields(a, b bson.D, field string) bool {
+	aValue, aOk := lookupField(a, []string{field})
+	bValue, bOk := lookupField(b, []string{field})
+	return aOk == bOk && equalValues(aValue, bValue)
+}
+
+// equalValues compares db values, the fields of documents may be in any order
+func equalValues(a, b interface{}) bool {
+	switch av := a.(type) {
+	case bson.D:
+		bv, ok := b.(bson.D)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for _, e := range av {
+			if value, ok := lookupField(bv, []string{e.Key}); !ok || !equalValues(e.Value, value) {
+				return false
+			}
+		}
+		return true
+	case bson.A:
+		bv, ok := b.(bson.A)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equalValues(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// changedFields returns the top level fields that have different values in the documents
+func changedFields(a, b bson.D) []string {
+	var fields []string
+	for _, e := range b {
+		if !equalFields(a, b, e.Key) {
+			fields = append(fields, e.Key)
+		}
+	}
+	for _, e := range a {
+		if _, ok := lookupField(b, []string{e.Key}); !ok {
+			fields = append(fields, e.Key)
+		}
+	}
+	return fields
+}
+
+// InvalidPatchError is returned when a patch is malformed or cannot be applied on the document
+type InvalidPatchError struct {
+	Reason string
+}
+
+func (e InvalidPatchError) Error() string {
+	return "invalid patch: " + e.Reason
+}
+
+func IsInvalidPatchError(err error) bool {
+	return errors.As(err, &InvalidPatchError{})
+}
+
+// PatchTestFailedError is returned when a test operation of a JSON patch does not match the document
+type PatchTestFailedError struct {
+	Path string
+}
+
+func (e PatchTestFailedError) Error() string {
+	return fmt.Sprintf("test of %s failed", e.Path)
+}
+
+func IsPatchTestFailedError(err error) bool {
+	return errors.As(err, &PatchTestFailedError{})
+}
+
+// ReadOnlyFieldError is returned when a patch modifies a read only field
+type ReadOnlyFieldError struct {
+	Field string
+}
+
+func (e ReadOnlyFieldError) Error() string {
+	return fmt.Sprintf("%s is read only", e.Field)
+}
+
+func IsReadOnlyFieldError(err error) bool {
+	return errors.As(err, &ReadOnlyFieldError{})
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"config-service/types"
+	"testing"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/armosec/armoapi-go/identifiers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPatchDocument(t *testing.T) {
+	designator := func(cluster string) identifiers.PortalDesignator {
+		return identifiers.PortalDesignator{DesignatorType: identifiers.DesignatorAttributes, Attributes: map[string]string{"cluster": cluster}}
+	}
+	designatorFields := func(cluster string) bson.D {
+		return bson.D{{Key: "designatorType", Value: string(identifiers.DesignatorAttributes)}, {Key: "attributes", Value: bson.D{{Key: "cluster", Value: cluster}}}}
+	}
+	newPolicy := func() *types.VulnerabilityExceptionPolicy {
+		return &types.VulnerabilityExceptionPolicy{
+			PortalBase:   armotypes.PortalBase{GUID: "p1", Name: "policy", Attributes: map[string]interface{}{"a": "1", "b": "2"}},
+			CreationTime: "2024-01-01T00:00:00Z",
+			Actions:      []armotypes.VulnerabilityExceptionPolicyActions{armotypes.Ignore, armotypes.Ignore},
+			Designatores: []identifiers.PortalDesignator{designator("c1"), designator("c2"), designator("c3")},
+		}
+	}
+	noValidation := func(doc *types.VulnerabilityExceptionPolicy) (*types.VulnerabilityExceptionPolicy, error) {
+		return doc, nil
+	}
+
+	tests := []struct {
+		name       string
+		mergePatch string
+		jsonPatch  string
+		validate   func(*types.VulnerabilityExceptionPolicy) (*types.VulnerabilityExceptionPolicy, error)
+		want       bson.D
+		wantErr    error
+	}{
+		{
+			name:       "merge patch sets and unsets nested fields",
+			mergePatch: `{"attributes":{"b":null,"c":"3"},"reason":"r"}`,
+			want: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "attributes.c", Value: "3"}, {Key: "reason", Value: "r"}}},
+				{Key: "$unset", Value: bson.D{{Key: "attributes.b", Value: ""}}},
+			},
+		},
+		{
+			name:       "merge patch replaces arrays",
+			mergePatch: `{"designators":[{"designatorType":"Attributes","attributes":{"cluster":"c4"}}]}`,
+			want:       bson.D{{Key: "$set", Value: bson.D{{Key: "designators", Value: bson.A{designatorFields("c4")}}}}},
+		},
+		{
+			name:       "merge patch of a key that is not a field path sets the parent",
+			mergePatch: `{"attributes":{"x.y":"1"}}`,
+			want:       bson.D{{Key: "$set", Value: bson.D{{Key: "attributes", Value: bson.D{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}, {Key: "x.y", Value: "1"}}}}}},
+		},
+		{
+			name:      "remove array item pulls it",
+			jsonPatch: `[{"op":"remove","path":"/designators/1"}]`,
+			want:      bson.D{{Key: "$pull", Value: bson.D{{Key: "designators", Value: bson.D{{Key: "designatorType", Value: string(identifiers.DesignatorAttributes)}, {Key: "attributes.cluster", Value: "c2"}}}}}},
+		},
+		{
+			name:      "remove array item that is not unique sets the array",
+			jsonPatch: `[{"op":"remove","path":"/actions/0"}]`,
+			want:      bson.D{{Key: "$set", Value: bson.D{{Key: "actions", Value: bson.A{string(armotypes.Ignore)}}}}},
+		},
+		{
+			name:      "add to the end of an array pushes the item",
+			jsonPatch: `[{"op":"add","path":"/designators/-","value":{"designatorType":"Attributes","attributes":{"cluster":"c4"}}}]`,
+			want:      bson.D{{Key: "$push", Value: bson.D{{Key: "designators", Value: designatorFields("c4")}}}},
+		},
+		{
+			name:      "add at an array index pushes the item at position",
+			jsonPatch: `[{"op":"add","path":"/designators/0","value":{"designatorType":"Attributes","attributes":{"cluster":"c4"}}}]`,
+			want: bson.D{{Key: "$push", Value: bson.D{{Key: "designators", Value: bson.D{
+				{Key: "$each", Value: bson.A{designatorFields("c4")}},
+				{Key: "$position", Value: 0},
+			}}}}},
+		},
+		{
+			name:      "conflicting changes set the common path",
+			jsonPatch: `[{"op":"remove","path":"/designators/1"},{"op":"add","path":"/designators/-","value":{"designatorType":"Attributes","attributes":{"cluster":"c4"}}}]`,
+			want:      bson.D{{Key: "$set", Value: bson.D{{Key: "designators", Value: bson.A{designatorFields("c1"), designatorFields("c3"), designatorFields("c4")}}}}},
+		},
+		{
+			name:      "replace, move and copy",
+			jsonPatch: `[{"op":"replace","path":"/designators/0/attributes/cluster","value":"c5"},{"op":"move","from":"/attributes/a","path":"/attributes/d"},{"op":"copy","from":"/attributes/b","path":"/reason"}]`,
+			want: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "designators.0.attributes.cluster", Value: "c5"}, {Key: "attributes.d", Value: "1"}, {Key: "reason", Value: "2"}}},
+				{Key: "$unset", Value: bson.D{{Key: "attributes.a", Value: ""}}},
+			},
+		},
+		{
+			name:      "test passes",
+			jsonPatch: `[{"op":"test","path":"/attributes/a","value":"1"},{"op":"replace","path":"/attributes/a","value":"2"}]`,
+			want:      bson.D{{Key: "$set", Value: bson.D{{Key: "attributes.a", Value: "2"}}}},
+		},
+		{
+			name:       "fields modified by validate are set",
+			mergePatch: `{"attributes":{"c":"3"}}`,
+			validate: func(doc *types.VulnerabilityExceptionPolicy) (*types.VulnerabilityExceptionPolicy, error) {
+				doc.Reason = "validated"
+				return doc, nil
+			},
+			want: bson.D{{Key: "$set", Value: bson.D{{Key: "attributes.c", Value: "3"}, {Key: "reason", Value: "validated"}}}},
+		},
+		{
+			name:      "test fails",
+			jsonPatch: `[{"op":"test","path":"/attributes/a","value":"2"},{"op":"replace","path":"/attributes/a","value":"3"}]`,
+			wantErr:   PatchTestFailedError{Path: "/attributes/a"},
+		},
+		{
+			name:      "read only field",
+			jsonPatch: `[{"op":"replace","path":"/name","value":"other"}]`,
+			wantErr:   ReadOnlyFieldError{Field: "name"},
+		},
+		{
+			name:      "read only field with the same value",
+			jsonPatch: `[{"op":"replace","path":"/name","value":"policy"}]`,
+			wantErr:   NoFieldsToUpdateError{},
+		},
+		{
+			name:       "unknown field",
+			mergePatch: `{"unknown":"value"}`,
+			wantErr:    NoFieldsToUpdateError{},
+		},
+		{
+			name:      "remove missing field",
+			jsonPatch: `[{"op":"remove","path":"/attributes/x"}]`,
+			wantErr:   InvalidPatchError{Reason: "operation 0: /attributes/x does not exist"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch Patch
+			var err error
+			if tt.mergePatch != "" {
+				patch, err = NewMergePatch([]byte(tt.mergePatch))
+			} else {
+				patch, err = NewJSONPatch([]byte(tt.jsonPatch))
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			validate := tt.validate
+			if validate == nil {
+				validate = noValidation
+			}
+			policy := newPolicy()
+			_, update, err := PatchDocument(policy, patch, validate, policy.GetReadOnlyFields()...)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			if !equalValues(tt.want, update) {
+				assert.Equal(t, tt.want, update)
+			}
+			assert.Equal(t, newPolicy(), policy, "the patched document is a copy")
+		})
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	for _, patch := range []string{`[]`, `[{"op":"test","path":"/a","value":null}]`, `[{"op":"move","from":"/a","path":"/b"}]`} {
+		_, err := NewJSONPatch([]byte(patch))
+		assert.NoError(t, err, patch)
+	}
+	for patch, reason := range map[string]string{
+		`{}`:                                        "JSON patch must be an array of operations",
+		`[{"op":"unknown","path":"/a"}]`:            `operation 0: unknown op "unknown"`,
+		`[{"op":"remove"}]`:                         "operation 0: path is required",
+		`[{"op":"remove","path":"a"}]`:              "operation 0: invalid JSON pointer a",
+		`[{"op":"add","path":"/a"}]`:                "operation 0: value is required",
+		`[{"op":"copy","path":"/a"}]`:               "operation 0: from is required",
+		`[{"op":"move","from":"/a","path":"/a/b"}]`: "operation 0: cannot move /a into itself",
+	} {
+		_, err := NewJSONPatch([]byte(patch))
+		assert.Equal(t, InvalidPatchError{Reason: reason}, err, patch)
+	}
+	_, err := NewMergePatch([]byte(`[]`))
+	assert.Equal(t, InvalidPatchError{Reason: "merge patch must be a JSON object"}, err)
+
+	tokens, err := parsePointer("/a~1b/c~0d/~01")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/b", "c~d", "~1"}, tokens)
+}
@@ -0,0 +1,312 @@
+package db
+
+import (
+	"config-service/db/store"
+	"config-service/utils/consts"
+	"config-service/utils/metrics"
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryCacheConfig configures the size limits of the query cache
+type QueryCacheConfig struct {
+	MaxEntries int   //max number of cached results, 0 disables the cache
+	MaxBytes   int64 //max size of the cached results, 0 does not limit the size
+}
+
+// queryCacheEntry is a cached query result, kept as bson so each hit returns a copy the caller can modify
+type queryCacheEntry struct {
+	key          string
+	collection   string
+	customerGUID string
+	value        []byte
+	expiry       time.Time
+}
+
+// queryCache is a least recently used cache of the results of the db read functions
+// results are cached by customer, collection and query, writes to a collection invalidate the results of the writing customer
+type queryCache struct {
+	lock    sync.Mutex
+	conf    QueryCacheConfig
+	entries map[string]*list.Element
+	lru     *list.List //most recently used first
+	//cached keys by collection and customer
+	scopes map[string]map[string]map[string]bool
+	//incremented on each invalidation of a collection and of the cache, results of queries started before an invalidation are not cached
+	generations map[string]uint64
+	epoch       uint64
+	size        int64
+}
+
+var cache = newQueryCache()
+
+// queryCachedCollections are the collections of the routes that cache their query results
+var queryCachedCollections = sync.Map{}
+
+// SetQueryCached marks a collection as query cached, its writes are published to the other instances to invalidate their cached results
+func SetQueryCached(collection string) {
+	queryCachedCollections.Store(collection, true)
+}
+
+// isQueryCached returns true if the query results of the collection are cached when the query cache is enabled
+func isQueryCached(collection string) bool {
+	_, ok := queryCachedCollections.Load(collection)
+	return ok && cache.enabled()
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+		scopes:      map[string]map[string]map[string]bool{},
+		generations: map[string]uint64{},
+	}
+}
+
+// SetQueryCache sets the size limits of the query cache and removes the cached results
+// results are cached only for requests of routes that enable the cache (see consts.QueryCacheTTL)
+func SetQueryCache(conf QueryCacheConfig) {
+	ClearQueryCache()
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.conf = conf
+}
+
+// ClearQueryCache removes the cached query results, e.g. after documents were written directly through the store
+func ClearQueryCache() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.epoch++
+	cache.entries = map[string]*list.Element{}
+	cache.lru.Init()
+	cache.scopes = map[string]map[string]map[string]bool{}
+	cache.size = 0
+	metrics.QueryCacheBytes.Set(0)
+}
+
+func (q *queryCache) enabled() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.conf.MaxEntries > 0
+}
+
+func (q *queryCache) generation(collection string) uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.epoch + q.generations[collection]
+}
+
+func (q *queryCache) get(key string) ([]byte, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	element, ok := q.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*queryCacheEntry)
+	if time.Now().After(entry.expiry) {
+		q.remove(element)
+		return nil, false
+	}
+	q.lru.MoveToFront(element)
+	return entry.value, true
+}
+
+// set caches the result unless the collection was invalidated since the query started (the generation changed)
+func (q *queryCache) set(entry *queryCacheEntry, generation uint64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.conf.MaxEntries <= 0 || q.epoch+q.generations[entry.collection] != generation {
+		return
+	}
+	if q.conf.MaxBytes > 0 && int64(len(entry.value)) > q.conf.MaxBytes {
+		return
+	}
+	if element, ok := q.entries[entry.key]; ok {
+		q.remove(element)
+	}
+	q.entries[entry.key] = q.lru.PushFront(entry)
+	if q.scopes[entry.collection] == nil {
+		q.scopes[entry.collection] = map[string]map[string]bool{}
+	}
+	if q.scopes[entry.collection][entry.customerGUID] == nil {
+		q.scopes[entry.collection][entry.customerGUID] = map[string]bool{}
+	}
+	q.scopes[entry.collection][entry.customerGUID][entry.key] = true
+	q.size += int64(len(entry.value))
+	//evict the least recently used results
+	for len(q.entries) > q.conf.MaxEntries || (q.conf.MaxBytes > 0 && q.size > q.conf.MaxBytes) {
+		q.remove(q.lru.Back())
+		metrics.QueryCacheEvictions.Inc()
+	}
+	metrics.QueryCacheBytes.Set(float64(q.size))
+}
+
+func (q *queryCache) remove(element *list.Element) {
+	entry := q.lru.Remove(element).(*queryCacheEntry)
+	delete(q.entries, entry.key)
+	delete(q.scopes[entry.collection][entry.customerGUID], entry.key)
+	q.size -= int64(len(entry.value))
+	metrics.QueryCacheBytes.Set(float64(q.size))
+}
+
+// invalidate removes the cached results of the customer in the collection, of all the customers when the customer is empty
+func (q *queryCache) invalidate(collection, customerGUID string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.generations[collection]++
+	for customer, keys := range q.scopes[collection] {
+		if customerGUID != "" && customer != customerGUID {
+			continue
+		}
+		for key := range keys {
+			q.remove(q.entries[key])
+		}
+		delete(q.scopes[collection], customer)
+	}
+}
+
+// cachedQuery returns the cached result of the query if the route of the request enables the cache, otherwise runs the query and caches its result
+func cachedQuery[R any](c context.Context, collection, customerGUID, operation string, query func() string, run func() (R, error)) (R, error) {
+	ttl, _ := c.Value(consts.QueryCacheTTL).(time.Duration)
+	if ttl <= 0 || !cache.enabled() {
+		return run()
+	}
+	var result R
+	key := fmt.Sprintf("%s|%s|%s|%T|%s", collection, customerGUID, operation, result, query())
+	if value, ok := cache.get(key); ok {
+		var cached struct {
+			Value R `bson:"v"`
+		}
+		if err := bson.Unmarshal(value, &cached); err == nil {
+			metrics.QueryCacheRequests.WithLabelValues(collection, metrics.CacheHit).Inc()
+			return cached.Value, nil
+		}
+	}
+	metrics.QueryCacheRequests.WithLabelValues(collection, metrics.CacheMiss).Inc()
+	generation := cache.generation(collection)
+	result, err := run()
+	if err != nil {
+		return result, err
+	}
+	if value, err := bson.Marshal(bson.D{{Key: "v", Value: result}}); err == nil {
+		cache.set(&queryCacheEntry{
+			key:          key,
+			collection:   collection,
+			customerGUID: customerGUID,
+			value:        value,
+			expiry:       time.Now().Add(ttl),
+		}, generation)
+	}
+	return result, nil
+}
+
+// cacheKey returns the normalized query of the find options
+func (f *FindOptions) cacheKey() string {
+	query := bson.D{
+		{Key: "group", Value: f.group},
+		{Key: "limit", Value: f.limit},
+		{Key: "skip", Value: f.skip},
+		{Key: "cursor", Value: f.cursor},
+	}
+	if f.filter != nil {
+		query = append(query, bson.E{Key: "filter", Value: f.filter.get()})
+	}
+	if f.unwindfilter != nil {
+		query = append(query, bson.E{Key: "unwindFilter", Value: f.unwindfilter.get()})
+	}
+	if f.projection != nil {
+		query = append(query, bson.E{Key: "projection", Value: f.projection.get()})
+	}
+	if f.sort != nil {
+		query = append(query, bson.E{Key: "sort", Value: f.sort.get()})
+	}
+	return extJSON(query)
+}
+
+// writeScope returns the customer whose cached results are invalidated by a write, empty for writes of admins and background tasks that may write documents of any customer
+func writeScope(c context.Context) string {
+	if admin, _ := c.Value(consts.AdminAccess).(bool); admin {
+		return ""
+	}
+	customerGUID, _ := c.Value(consts.CustomerGUID).(string)
+	return customerGUID
+}
+
+type transactionWritesKey struct{}
+
+// transactionWrites are the collections and customers written in a transaction, invalidated again after the transaction ends
+// queries that run before the transaction is committed read and cache the prior documents
+type transactionWrites struct {
+	lock   sync.Mutex
+	writes map[[2]string]bool
+}
+
+// invalidateWrite invalidates the cached query results and documents of a write, the writes in a transaction are published to the other instances after the transaction
+func invalidateWrite(c context.Context, collection string) {
+	customerGUID := writeScope(c)
+	cache.invalidate(collection, customerGUID)
+	if writes, ok := c.Value(transactionWritesKey{}).(*transactionWrites); ok {
+		writes.lock.Lock()
+		defer writes.lock.Unlock()
+		writes.writes[[2]string{collection, customerGUID}] = true
+		return
+	}
+	publishCacheInvalidation(c, collection, customerGUID)
+}
+
+// invalidatingCollection invalidates the cached query results of the collection on writes
+type invalidatingCollection struct {
+	store.Collection
+	name string
+}
+
+func (i invalidatingCollection) FindOneAndUpdate(c context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongoDB.SingleResult {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.FindOneAndUpdate(c, filter, update, opts...)
+}
+
+func (i invalidatingCollection) InsertOne(c context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongoDB.InsertOneResult, error) {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.InsertOne(c, document, opts...)
+}
+
+func (i invalidatingCollection) InsertMany(c context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongoDB.InsertManyResult, error) {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.InsertMany(c, documents, opts...)
+}
+
+func (i invalidatingCollection) UpdateOne(c context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongoDB.UpdateResult, error) {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.UpdateOne(c, filter, update, opts...)
+}
+
+func (i invalidatingCollection) UpdateMany(c context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongoDB.UpdateResult, error) {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.UpdateMany(c, filter, update, opts...)
+}
+
+func (i invalidatingCollection) DeleteOne(c context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongoDB.DeleteResult, error) {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.DeleteOne(c, filter, opts...)
+}
+
+func (i invalidatingCollection) DeleteMany(c context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongoDB.DeleteResult, error) {
+	defer invalidateWrite(c, i.name)
+	return i.Collection.DeleteMany(c, filter, opts...)
+}
+
+func (i invalidatingCollection) Drop(c context.Context) error {
+	defer func() {
+		cache.invalidate(i.name, "")
+		publishCacheInvalidation(c, i.name, "")
+	}()
+	return i.Collection.Drop(c)
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestQueryCache(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	SetQueryCache(QueryCacheConfig{MaxEntries: 2})
+	defer SetQueryCache(QueryCacheConfig{})
+	newContext := func(customerGUID string) context.Context {
+		c := context.WithValue(context.Background(), consts.Collection, consts.ClustersCollection)
+		c = context.WithValue(c, consts.QueryCacheTTL, time.Minute)
+		return context.WithValue(c, consts.CustomerGUID, customerGUID)
+	}
+	c1, c2 := newContext("customer1"), newContext("customer2")
+	insertDirectly := func(c context.Context, name string) {
+		//writes through the store do not invalidate the cache
+		doc := types.NewDocument(&types.Cluster{PortalBase: armotypes.PortalBase{Name: name}}, c.Value(consts.CustomerGUID).(string))
+		_, err := GetStore().GetWriteCollection(consts.ClustersCollection).InsertOne(c, doc)
+		assert.NoError(t, err)
+	}
+	names := func(c context.Context) []string {
+		docs, err := GetAllForCustomer[*types.Cluster](c, false)
+		assert.NoError(t, err)
+		names := []string{}
+		for _, doc := range docs {
+			names = append(names, doc.Name)
+		}
+		return names
+	}
+
+	//empty results are cached
+	assert.Equal(t, []string{}, names(c1))
+	assert.Equal(t, []string{}, names(c2))
+	insertDirectly(c1, "cluster1")
+	insertDirectly(c2, "cluster2")
+	assert.Equal(t, []string{}, names(c1))
+	assert.Equal(t, []string{}, names(c2))
+
+	//writes invalidate the results of the customer
+	_, err := InsertDocuments(c1, []*types.Cluster{{PortalBase: armotypes.PortalBase{Name: "cluster3"}}})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cluster1", "cluster3"}, names(c1))
+	assert.Equal(t, []string{}, names(c2))
+
+	//cached results are copies
+	docs, err := GetAllForCustomer[*types.Cluster](c1, false)
+	assert.NoError(t, err)
+	docs[0].Name = "modified"
+	assert.ElementsMatch(t, []string{"cluster1", "cluster3"}, names(c1))
+
+	//writes of admins invalidate the results of all the customers
+	admin := context.WithValue(c1, consts.AdminAccess, true)
+	_, err = AdminUpdateMany(admin, NewFilterBuilder().WithName("none"), bson.D{{Key: "$set", Value: bson.D{{Key: "description", Value: "none"}}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cluster2"}, names(c2))
+
+	//the least recently used results are evicted
+	count, err := FindCountForCustomer(c2, NewFindOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count.Total.Value)
+	assert.ElementsMatch(t, []string{"cluster1", "cluster3"}, names(c1))
+	insertDirectly(c2, "cluster4")
+	assert.ElementsMatch(t, []string{"cluster2", "cluster4"}, names(c2))
+
+	//requests of routes without the query cache are not cached
+	insertDirectly(c2, "cluster5")
+	docs, err = GetAllForCustomer[*types.Cluster](context.WithValue(c2, consts.QueryCacheTTL, time.Duration(0)), false)
+	assert.NoError(t, err)
+	assert.Len(t, docs, 3)
+	assert.ElementsMatch(t, []string{"cluster2", "cluster4"}, names(c2))
+	ClearQueryCache()
+	assert.ElementsMatch(t, []string{"cluster2", "cluster4", "cluster5"}, names(c2))
+}
+
+func TestQueryCacheInvalidationOfOtherInstances(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	SetQueryCache(QueryCacheConfig{MaxEntries: 10})
+	defer SetQueryCache(QueryCacheConfig{})
+	SetQueryCached(consts.ClustersCollection)
+	defer queryCachedCollections.Delete(consts.ClustersCollection)
+	//the instances share the store, each instance has its own id and query cache
+	defer func(id string, queryCache *queryCache) { instanceID, cache = id, queryCache }(instanceID, cache)
+	instanceA, instanceB := newQueryCache(), newQueryCache()
+	instanceA.conf, instanceB.conf = cache.conf, cache.conf
+	useInstance := func(id string, queryCache *queryCache) {
+		instanceID, cache = id, queryCache
+	}
+	c := context.WithValue(context.Background(), consts.Collection, consts.ClustersCollection)
+	c = context.WithValue(c, consts.QueryCacheTTL, time.Minute)
+	c = context.WithValue(c, consts.CustomerGUID, "customer1")
+	names := func() []string {
+		docs, err := GetAllForCustomer[*types.Cluster](c, false)
+		assert.NoError(t, err)
+		names := []string{}
+		for _, doc := range docs {
+			names = append(names, doc.Name)
+		}
+		return names
+	}
+
+	//instance B caches the results, the write of instance A is published
+	useInstance("instance-b", instanceB)
+	assert.Equal(t, []string{}, names())
+	useInstance("instance-a", instanceA)
+	_, err := InsertDocuments(c, []*types.Cluster{{PortalBase: armotypes.PortalBase{Name: "cluster1"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cluster1"}, names())
+
+	//instance B serves its cached results until it applies the invalidation
+	useInstance("instance-b", instanceB)
+	assert.Equal(t, []string{}, names())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunCacheInvalidations(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	assert.Eventually(t, func() bool { return len(names()) == 1 }, time.Second, 10*time.Millisecond)
+}
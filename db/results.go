@@ -1,8 +1,11 @@
 package db
 
+import "go.mongodb.org/mongo-driver/bson"
+
 type paginatedResult[T any] struct {
 	Count          []countResult `bson:"count"`
 	LimitedResults []T           `bson:"limitedResults"`
+	SortValues     []bson.Raw    `bson:"sortValues"` //the sort values of the last document of a full page, for the next page cursor
 }
 type countResult struct {
 	Count int64 `bson:"count"`
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+)
+
+// PurgeOlderThan removes the documents of the collection that match the filter and have a timestamp before the given time
+// the timestamp field is matched both as a date and as an RFC3339 string, in dry run the matching documents are only counted
+func PurgeOlderThan(c context.Context, collection, timestampField string, before time.Time, filter *FilterBuilder, dryRun bool) (int64, error) {
+	defer log.LogNTraceEnterExit("PurgeOlderThan", c)()
+	before = before.UTC()
+	purgeFilter := NewFilterBuilder().WithFilter(filter).AddOr(
+		NewFilterBuilder().WithLowerThanEqual(timestampField, before),
+		NewFilterBuilder().WithLowerThanEqual(timestampField, before.Format(time.RFC3339)))
+	if dryRun {
+		return getReadCollection(collection).CountDocuments(c, purgeFilter.get())
+	}
+	res, err := getWriteCollection(collection).DeleteMany(c, purgeFilter.get())
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// GetCollectionCustomers returns the customers that have documents in the collection
+func GetCollectionCustomers(c context.Context, collection string) ([]string, error) {
+	defer log.LogNTraceEnterExit("GetCollectionCustomers", c)()
+	pipeline := mongoDB.Pipeline{
+		{{Key: "$unwind", Value: "$" + consts.CustomersField}},
+		{{Key: "$group", Value: bson.D{{Key: consts.IdField, Value: "$" + consts.CustomersField}}}},
+	}
+	cur, err := getReadCollection(collection).Aggregate(c, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	customers := []string{}
+	for cur.Next(c) {
+		var customer struct {
+			GUID string `bson:"_id"`
+		}
+		if err := cur.Decode(&customer); err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer.GUID)
+	}
+	return customers, cur.Err()
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"config-service/utils/consts"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/exp/slices"
+)
+
+// helpers for optimistic concurrency control using the document revision counter
+
+// maxConditionalWriteAttempts is the number of times a read-modify-write is retried when the document is modified concurrently
+const maxConditionalWriteAttempts = 5
+
+type revisionResult struct {
+	Revision int64 `bson:"revision"`
+}
+
+// ExpectedRevisionsFromContext returns the document revisions the request is allowed to modify
+// ok is false when the request does not have revision preconditions
+func ExpectedRevisionsFromContext(c context.Context) (revisions []int64, ok bool) {
+	revisions, ok = c.Value(consts.IfMatch).([]int64)
+	return revisions, ok
+}
+
+// WithRevisions filters documents with one of the given revisions, revision 0 also matches documents without a revision
+func (f *FilterBuilder) WithRevisions(revisions []int64) *FilterBuilder {
+	values := bson.A{}
+	for _, revision := range revisions {
+		values = append(values, revision)
+		if revision == 0 {
+			values = append(values, nil)
+		}
+	}
+	return f.WithIn(consts.RevisionField, values)
+}
+
+// withExpectedRevisions adds the revisions expected by the request (if any) to the filter
+func (f *FilterBuilder) withExpectedRevisions(c context.Context) *FilterBuilder {
+	if revisions, ok := ExpectedRevisionsFromContext(c); ok {
+		return f.WithRevisions(revisions)
+	}
+	return f
+}
+
+// checkExpectedRevisions returns RevisionMismatchError if the request expects other revisions than the given one
+func checkExpectedRevisions(c context.Context, revision int64) error {
+	if revisions, ok := ExpectedRevisionsFromContext(c); ok && !slices.Contains(revisions, revision) {
+		return RevisionMismatchError{}
+	}
+	return nil
+}
+
+// contendedWriteError returns the error of a conditional write whose attempts all failed on concurrent modifications
+// only requests with revision preconditions fail with RevisionMismatchError
+func contendedWriteError(c context.Context) error {
+	if _, ok := ExpectedRevisionsFromContext(c); ok {
+		return RevisionMismatchError{}
+	}
+	return ConcurrentModificationError{}
+}
+
+// checkDocRevision reads the current revision of a customer document and checks it against the revisions expected by the request
+// used when a conditional write did not match, a missing document is not a mismatch
+func checkDocRevision(c context.Context, collection, id string) error {
+	if _, ok := ExpectedRevisionsFromContext(c); !ok {
+		return nil
+	}
+	var result revisionResult
+	if err := getWriteCollection(collection).
+		FindOne(c,
+			NewFilterBuilder().
+				WithCustomer(c).
+				WithID(id).
+				get(), options.FindOne().SetProjection(NewProjectionBuilder().Include(consts.RevisionField).get())).
+		Decode(&result); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+	return checkExpectedRevisions(c, result.Revision)
+}
+
+// decodeWithRevision decodes a single result into doc and returns the document revision
+func decodeWithRevision(res *mongoDB.SingleResult, doc interface{}) (int64, error) {
+	if err := res.Decode(doc); err != nil {
+		return 0, err
+	}
+	var result revisionResult
+	if err := res.Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Revision, nil
+}
+
+// withRevisionInc adds an increment of the document revision to an update command
+func withRevisionInc(update interface{}) interface{} {
+	switch u := update.(type) {
+	case bson.D:
+		res := make(bson.D, 0, len(u)+1)
+		merged := false
+		for _, e := range u {
+			if inc, ok := appendRevisionInc(e.Value); ok && e.Key == "$inc" && !merged {
+				e = bson.E{Key: e.Key, Value: inc}
+				merged = true
+			}
+			res = append(res, e)
+		}
+		if !merged {
+			res = append(res, bson.E{Key: "$inc", Value: bson.D{{Key: consts.RevisionField, Value: int64(1)}}})
+		}
+		return res
+	case bson.M:
+		res := bson.M{}
+		for k, v := range u {
+			res[k] = v
+		}
+		if inc, ok := appendRevisionInc(res["$inc"]); ok {
+			res["$inc"] = inc
+		} else {
+			res["$inc"] = bson.D{{Key: consts.RevisionField, Value: int64(1)}}
+		}
+		return res
+	}
+	return update
+}
+
+// appendRevisionInc returns a copy of an $inc specification with the revision increment
+func appendRevisionInc(inc interface{}) (interface{}, bool) {
+	switch i := inc.(type) {
+	case bson.D:
+		return append(append(bson.D{}, i...), bson.E{Key: consts.RevisionField, Value: int64(1)}), true
+	case bson.M:
+		res := bson.M{consts.RevisionField: int64(1)}
+		for k, v := range i {
+			res[k] = v
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+func IsRevisionMismatchError(err error) bool {
+	return errors.Is(err, RevisionMismatchError{})
+}
+
+// RevisionMismatchError is returned when the document revision is not one of the revisions expected by the request
+type RevisionMismatchError struct {
+}
+
+func (e RevisionMismatchError) Error() string {
+	return "document revision does not match"
+}
+
+func IsConcurrentModificationError(err error) bool {
+	return errors.Is(err, ConcurrentModificationError{})
+}
+
+// ConcurrentModificationError is returned when a document without revision preconditions is modified concurrently by all the write attempts
+type ConcurrentModificationError struct {
+}
+
+func (e ConcurrentModificationError) Error() string {
+	return "document was modified concurrently"
+}
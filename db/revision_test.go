@@ -0,0 +1,59 @@
+package db
+
+import (
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestContendedUpdate(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	c := context.WithValue(context.WithValue(context.Background(), consts.Collection, consts.RepositoryCollection), consts.CustomerGUID, "customer-guid")
+	repo := &types.Repository{}
+	repo.Name = "repo"
+	docs, err := InsertDocuments(c, []*types.Repository{repo})
+	assert.NoError(t, err)
+	guid := docs[0].GUID
+
+	//every attempt is modified by another write before it is applied
+	writes := 0
+	contended := func(ctx context.Context) error {
+		_, _, err := UpdateDocumentWithFunc(ctx, guid, func(*types.Repository) (bson.D, error) {
+			writes++
+			if _, err := UpdateOne(c, guid, GetUpdateSetFieldCommand("attributes.writes", writes)); err != nil {
+				return nil, err
+			}
+			return GetUpdateSetFieldCommand(consts.NameField, "updated"), nil
+		})
+		return err
+	}
+	//requests without If-Match do not fail on a precondition
+	err = contended(c)
+	assert.True(t, IsConcurrentModificationError(err), err)
+	assert.False(t, IsRevisionMismatchError(err))
+	assert.Equal(t, maxConditionalWriteAttempts, writes)
+
+	//requests with If-Match fail on the precondition, all the revisions are expected so every attempt is contended
+	err = contended(context.WithValue(c, consts.IfMatch, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}))
+	assert.True(t, IsRevisionMismatchError(err), err)
+	assert.Equal(t, 2*maxConditionalWriteAttempts, writes)
+}
+
+func TestInsertDBDocumentWithRevision(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	c := context.WithValue(context.WithValue(context.Background(), consts.Collection, consts.RepositoryCollection), consts.CustomerGUID, "customer-guid")
+	_, revision, err := InsertDBDocumentWithRevision(c, types.Document[*types.Repository]{ID: "restored", Revision: 4, Content: &types.Repository{}, Customers: []string{"customer-guid"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), revision)
+	//documents without a revision are inserted as new documents
+	_, revision, err = InsertDBDocumentWithRevision(c, types.Document[*types.Repository]{ID: "new", Content: &types.Repository{}, Customers: []string{"customer-guid"}})
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewDocumentRevision, revision)
+}
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"config-service/db/store"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	//max number of slow queries recorded concurrently, slow queries are only logged when exceeded
+	maxSlowQueriesRecorders = 4
+	//timeout of explaining and recording a slow query
+	slowQueryRecordTimeout = 10 * time.Second
+	//number of latest slow queries summarized by route
+	slowQueriesSummaryLimit = 1000
+)
+
+// SlowQueriesConfig configures the slow queries diagnostics
+type SlowQueriesConfig struct {
+	Threshold time.Duration //queries that take longer are logged and recorded, 0 disables the diagnostics
+	Explain   bool          //explain the recorded slow queries and keep their winning plan
+	Retention time.Duration //recorded slow queries are removed after the retention, 0 keeps them
+}
+
+var slowQueriesConfig atomic.Pointer[SlowQueriesConfig]
+
+// limits the concurrent recording of slow queries
+var slowQueriesRecorders = make(chan struct{}, maxSlowQueriesRecorders)
+
+// SetSlowQueries sets the slow queries diagnostics configuration
+func SetSlowQueries(conf SlowQueriesConfig) {
+	slowQueriesConfig.Store(&conf)
+}
+
+// observeQuery logs the query and records it in the diagnostics collection if it took longer than the slow query threshold
+// the query is recorded in the background, after the request context is done
+func observeQuery(c context.Context, collection, operation string, filter *FilterBuilder, sortBuilder *SortBuilder, pipeline mongoDB.Pipeline, start time.Time) {
+	conf := slowQueriesConfig.Load()
+	duration := time.Since(start)
+	if conf == nil || conf.Threshold <= 0 || duration < conf.Threshold {
+		return
+	}
+	now := time.Now().UTC()
+	record := &types.SlowQuery{
+		GUID:       uuid.NewV4().String(),
+		Collection: collection,
+		Operation:  operation,
+		DurationMs: duration.Milliseconds(),
+		Filter:     extJSON(filter.get()),
+		Pipeline:   extJSON(pipeline),
+		Timestamp:  now,
+	}
+	if sortBuilder != nil && sortBuilder.Len() > 0 {
+		record.Sort = extJSON(sortBuilder.get())
+	}
+	record.Route, _ = c.Value(consts.RoutePath).(string)
+	record.CustomerGUID, _ = c.Value(consts.CustomerGUID).(string)
+	if conf.Retention > 0 {
+		expiryTime := now.Add(conf.Retention)
+		record.ExpiryTime = &expiryTime
+	}
+	logger := log.GetLogger(c)
+	logger.Warn("slow query",
+		zap.String("route", record.Route),
+		zap.String("collection", collection),
+		zap.String("operation", operation),
+		zap.Duration("duration", duration),
+		zap.String("filter", record.Filter),
+		zap.String("sort", record.Sort),
+		zap.String("pipeline", record.Pipeline))
+
+	select {
+	case slowQueriesRecorders <- struct{}{}:
+	default:
+		return
+	}
+	//the request context may be reused once the request is done
+	go func() {
+		defer func() { <-slowQueriesRecorders }()
+		ctx, cancel := context.WithTimeout(context.Background(), slowQueryRecordTimeout)
+		defer cancel()
+		if conf.Explain {
+			explainQuery(ctx, record, pipeline)
+		}
+		if _, err := getWriteCollection(consts.DiagnosticsCollection).InsertOne(ctx, record); err != nil {
+			logger.Error("failed to record slow query", zap.Error(err))
+		}
+	}()
+}
+
+// explainQuery sets the winning plan of the slow query, stores that cannot explain queries leave it empty
+func explainQuery(c context.Context, record *types.SlowQuery, pipeline mongoDB.Pipeline) {
+	explainStore, ok := dbStore.(store.ExplainStore)
+	if !ok {
+		return
+	}
+	explain, err := explainStore.Explain(c, record.Collection, pipeline)
+	if err != nil {
+		record.ExplainError = err.Error()
+		return
+	}
+	record.WinningPlan, record.PlanSummary = winningPlan(explain)
+}
+
+// winningPlan returns the winning plan of an explain result and the summary of its stages (e.g. "FETCH, IXSCAN customers_1")
+// the winning plan is nested in the pipeline stages (or shards) of aggregation explains
+func winningPlan(explain bson.Raw) (plan, summary string) {
+	winning, ok := lookupDocument(explain, "winningPlan")
+	if !ok {
+		return "", ""
+	}
+	//slot based execution plans keep the classic plan stages in the query plan
+	stages := winning
+	if queryPlan, ok := winning.Lookup("queryPlan").DocumentOK(); ok {
+		stages = queryPlan
+	}
+	return extJSON(winning), strings.Join(planStages(stages, nil), ", ")
+}
+
+// lookupDocument returns the first document field with the key, searching nested documents and arrays
+func lookupDocument(doc bson.Raw, key string) (bson.Raw, bool) {
+	elements, err := doc.Elements()
+	if err != nil {
+		return nil, false
+	}
+	for _, element := range elements {
+		if value, ok := element.Value().DocumentOK(); ok && element.Key() == key {
+			return value, true
+		}
+	}
+	for _, element := range elements {
+		for _, nested := range nestedDocuments(element.Value()) {
+			if value, ok := lookupDocument(nested, key); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func nestedDocuments(value bson.RawValue) []bson.Raw {
+	if doc, ok := value.DocumentOK(); ok {
+		return []bson.Raw{doc}
+	}
+	array, ok := value.ArrayOK()
+	if !ok {
+		return nil
+	}
+	values, err := array.Values()
+	if err != nil {
+		return nil
+	}
+	docs := []bson.Raw{}
+	for _, v := range values {
+		if doc, ok := v.DocumentOK(); ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// planStages returns the stages of a plan from the root to the leaves, index scans have the index name
+func planStages(plan bson.Raw, stages []string) []string {
+	if stage, ok := plan.Lookup("stage").StringValueOK(); ok {
+		if indexName, ok := plan.Lookup("indexName").StringValueOK(); ok {
+			stage += " " + indexName
+		}
+		stages = append(stages, stage)
+	}
+	if input, ok := plan.Lookup("inputStage").DocumentOK(); ok {
+		stages = planStages(input, stages)
+	}
+	if inputs, ok := plan.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := inputs.Values()
+		for _, value := range values {
+			if input, ok := value.DocumentOK(); ok {
+				stages = planStages(input, stages)
+			}
+		}
+	}
+	return stages
+}
+
+// extJSON returns the relaxed extended JSON of a document or an array
+func extJSON(value interface{}) string {
+	//only documents are marshaled, arrays are the value of a wrapper document
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(string(b), `{"v":`), "}")
+}
+
+// FindSlowQueries returns the latest slow queries, filtered by route and collection when not empty
+func FindSlowQueries(c context.Context, route, collection string, limit int64) ([]types.SlowQuery, error) {
+	defer log.LogNTraceEnterExit("FindSlowQueries", c)()
+	filter := NewFilterBuilder()
+	if route != "" {
+		filter.WithValue("route", route)
+	}
+	if collection != "" {
+		filter.WithValue("collection", collection)
+	}
+	findOpts := options.Find().SetSort(NewSortBuilder().AddDescending("timestamp").get()).SetLimit(limit)
+	cur, err := getReadCollection(consts.DiagnosticsCollection).Find(c, filter.get(), findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	slowQueries := []types.SlowQuery{}
+	if err := cur.All(c, &slowQueries); err != nil {
+		return nil, err
+	}
+	return slowQueries, nil
+}
+
+// GetSlowQueriesRoutes summarizes the latest slow queries by route with the latest query of each plan, slowest routes first
+func GetSlowQueriesRoutes(c context.Context) ([]types.SlowQueriesRoute, error) {
+	defer log.LogNTraceEnterExit("GetSlowQueriesRoutes", c)()
+	slowQueries, err := FindSlowQueries(c, "", "", slowQueriesSummaryLimit)
+	if err != nil {
+		return nil, err
+	}
+	routes := map[string]*types.SlowQueriesRoute{}
+	routesPlans := map[string]map[string]bool{}
+	summaries := []*types.SlowQueriesRoute{}
+	//slow queries are sorted by time, latest first
+	for _, slowQuery := range slowQueries {
+		route, ok := routes[slowQuery.Route]
+		if !ok {
+			route = &types.SlowQueriesRoute{Route: slowQuery.Route, LastTimestamp: slowQuery.Timestamp, Plans: []types.SlowQuery{}}
+			routes[slowQuery.Route] = route
+			routesPlans[slowQuery.Route] = map[string]bool{}
+			summaries = append(summaries, route)
+		}
+		route.Count++
+		if slowQuery.DurationMs > route.MaxDurationMs {
+			route.MaxDurationMs = slowQuery.DurationMs
+		}
+		if !routesPlans[slowQuery.Route][slowQuery.PlanSummary] {
+			routesPlans[slowQuery.Route][slowQuery.PlanSummary] = true
+			route.Plans = append(route.Plans, slowQuery)
+		}
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].MaxDurationMs > summaries[j].MaxDurationMs
+	})
+	result := make([]types.SlowQueriesRoute, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+	return result, nil
+}
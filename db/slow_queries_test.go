@@ -0,0 +1,64 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWinningPlan(t *testing.T) {
+	mustRaw := func(doc bson.M) bson.Raw {
+		raw, err := bson.Marshal(doc)
+		assert.NoError(t, err)
+		return raw
+	}
+	indexScan := bson.M{
+		"stage": "FETCH",
+		"inputStage": bson.M{
+			"stage":     "IXSCAN",
+			"indexName": "customers_1",
+		},
+	}
+
+	//classic plan of an aggregation explain
+	plan, summary := winningPlan(mustRaw(bson.M{
+		"stages": bson.A{
+			bson.M{"$cursor": bson.M{"queryPlanner": bson.M{"winningPlan": indexScan, "rejectedPlans": bson.A{}}}},
+			bson.M{"$facet": bson.M{}},
+		},
+	}))
+	assert.Equal(t, "FETCH, IXSCAN customers_1", summary)
+	assert.JSONEq(t, `{"stage":"FETCH","inputStage":{"stage":"IXSCAN","indexName":"customers_1"}}`, plan)
+
+	//slot based execution plan
+	_, summary = winningPlan(mustRaw(bson.M{
+		"queryPlanner": bson.M{"winningPlan": bson.M{"queryPlan": indexScan, "slotBasedPlan": bson.M{"stages": "..."}}},
+	}))
+	assert.Equal(t, "FETCH, IXSCAN customers_1", summary)
+
+	//plan with several inputs
+	_, summary = winningPlan(mustRaw(bson.M{
+		"queryPlanner": bson.M{"winningPlan": bson.M{
+			"stage": "OR",
+			"inputStages": bson.A{
+				bson.M{"stage": "IXSCAN", "indexName": "name_1"},
+				bson.M{"stage": "COLLSCAN"},
+			},
+		}},
+	}))
+	assert.Equal(t, "OR, IXSCAN name_1, COLLSCAN", summary)
+
+	//no plan
+	plan, summary = winningPlan(mustRaw(bson.M{"ok": 1}))
+	assert.Empty(t, plan)
+	assert.Empty(t, summary)
+}
+
+func TestExtJSON(t *testing.T) {
+	assert.Equal(t, `[{"$match":{"customers":"guid"}},{"$limit":10}]`,
+		extJSON(bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "customers", Value: "guid"}}}}, bson.D{{Key: "$limit", Value: int64(10)}}}))
+	assert.Equal(t, `{"timestamp":{"$lt":{"$date":"2024-01-02T00:00:00Z"}}}`,
+		extJSON(bson.D{{Key: "timestamp", Value: bson.D{{Key: "$lt", Value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}}}))
+}
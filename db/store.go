@@ -0,0 +1,50 @@
+package db
+
+import (
+	"config-service/db/store"
+	"context"
+)
+
+// dbStore is the storage backend used by all db functions
+var dbStore store.Store
+
+// SetStore sets the storage backend, must be called before any db function is used
+func SetStore(s store.Store) {
+	dbStore = s
+}
+
+// GetStore returns the storage backend
+func GetStore() store.Store {
+	return dbStore
+}
+
+func getReadCollection(collection string) store.Collection {
+	return dbStore.GetReadCollection(collection)
+}
+
+// getWriteCollection returns the collection for write operations, its writes invalidate the cached query results of the collection
+func getWriteCollection(collection string) store.Collection {
+	return invalidatingCollection{Collection: dbStore.GetWriteCollection(collection), name: collection}
+}
+
+// SupportsTransactions returns true if the store supports multi-document transactions
+func SupportsTransactions() bool {
+	transactionStore, ok := dbStore.(store.TransactionStore)
+	return ok && transactionStore.SupportsTransactions()
+}
+
+// WithTransaction runs fn in a transaction when the store supports transactions, otherwise fn runs without a transaction
+// the db functions called by fn must use the context passed to it, fn may be called more than once when the transaction is retried
+func WithTransaction(c context.Context, fn func(c context.Context) error) error {
+	if !SupportsTransactions() {
+		return fn(c)
+	}
+	writes := &transactionWrites{writes: map[[2]string]bool{}}
+	defer func() {
+		for write := range writes.writes {
+			cache.invalidate(write[0], write[1])
+			publishCacheInvalidation(c, write[0], write[1])
+		}
+	}()
+	return dbStore.(store.TransactionStore).WithTransaction(context.WithValue(c, transactionWritesKey{}, writes), fn)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"config-service/types"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection is the set of collection operations used by the db package
+// *mongo.Collection implements it, other backends must return driver cursors and results (e.g. mongo.NewCursorFromDocuments)
+type Collection interface {
+	Find(c context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	FindOne(c context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
+	FindOneAndUpdate(c context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+	Aggregate(c context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+	CountDocuments(c context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	InsertOne(c context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	InsertMany(c context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	UpdateOne(c context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(c context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteOne(c context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	DeleteMany(c context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	Drop(c context.Context) error
+}
+
+// ChangeStreamCollection is implemented by collections that support change streams, *mongo.Collection implements it
+// watchers of collections without change streams poll the documents
+type ChangeStreamCollection interface {
+	Watch(c context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+}
+
+// TransactionStore is implemented by stores that support multi-document transactions
+type TransactionStore interface {
+	// SupportsTransactions returns true if transactions are available (e.g. mongo is a replica set)
+	SupportsTransactions() bool
+	// WithTransaction runs fn in a transaction that is committed if fn returns nil, the operations of fn must use the context passed to it
+	// fn may be called more than once when the transaction is retried
+	WithTransaction(c context.Context, fn func(c context.Context) error) error
+}
+
+// IndexStore is implemented by stores that reconcile the collections indexes with the declared indexes
+type IndexStore interface {
+	// ReconcileIndexes creates the missing declared indexes of all the collections and reports (or drops) the extra indexes
+	ReconcileIndexes(c context.Context) ([]types.IndexesReport, error)
+	// IndexesReports returns the last reconciliation report of each collection
+	IndexesReports() []types.IndexesReport
+	// IndexesUsage returns the usage of the indexes of the collection, of all the collections when empty
+	IndexesUsage(c context.Context, collectionName string) ([]types.IndexUsage, error)
+}
+
+// ExplainStore is implemented by stores that explain the query plans of aggregation pipelines
+type ExplainStore interface {
+	// Explain returns the query planner explain of the pipeline, the winning plan is under a "winningPlan" field
+	Explain(c context.Context, collectionName string, pipeline interface{}) (bson.Raw, error)
+}
+
+// Store is a storage backend for the db package
+type Store interface {
+	// GetReadCollection returns a collection for read operations (may be served by a secondary)
+	GetReadCollection(collectionName string) Collection
+	// GetWriteCollection returns a collection for write operations
+	GetWriteCollection(collectionName string) Collection
+	// ListCollectionNames returns the names of all existing collections
+	ListCollectionNames(c context.Context) ([]string, error)
+	// IndexCollection creates the collection indexes (and the collection if it does not exist)
+	IndexCollection(collectionName string) error
+	// Disconnect releases the store resources
+	Disconnect()
+}
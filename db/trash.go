@@ -0,0 +1,139 @@
+package db
+
+import (
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// collections with soft delete, deleted documents are moved to the trash instead of being removed
+var softDeleteCollections = sync.Map{}
+
+// SetSoftDelete marks a collection as soft delete, its customer documents are moved to the trash when deleted
+func SetSoftDelete(collection string) {
+	softDeleteCollections.Store(collection, true)
+}
+
+// IsSoftDelete returns true if deleted documents of the collection are moved to the trash
+func IsSoftDelete(collection string) bool {
+	_, ok := softDeleteCollections.Load(collection)
+	return ok
+}
+
+// SoftDeleteCollections returns the sorted names of the soft delete collections
+func SoftDeleteCollections() []string {
+	collections := []string{}
+	softDeleteCollections.Range(func(key, _ interface{}) bool {
+		collections = append(collections, key.(string))
+		return true
+	})
+	sort.Strings(collections)
+	return collections
+}
+
+// GetDeletedForCustomer returns the customer documents in the trash, latest deleted first
+func GetDeletedForCustomer[T any](c context.Context) ([]T, error) {
+	defer log.LogNTraceEnterExit("GetDeletedForCustomer", c)()
+	findOpts := NewFindOptions()
+	findOpts.Filter().WithCustomer(c).WithDeleted()
+	findOpts.Sort().AddDescending(consts.DeletedAtField)
+	return AdminFind[T](c, findOpts)
+}
+
+// RestoreDeleted moves a customer document out of the trash and returns the restored document with its revision
+// if the request has revision preconditions the document is restored only if its revision is expected, otherwise RevisionMismatchError is returned
+// requests without revision preconditions get ConcurrentModificationError if the document is modified concurrently on every attempt
+func RestoreDeleted[T any](c context.Context, id string) (*T, int64, error) {
+	defer log.LogNTraceEnterExit("RestoreDeleted", c)()
+	collection, err := readCollection(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	for attempt := 0; attempt < maxConditionalWriteAttempts; attempt++ {
+		var deleted T
+		revision, err := decodeWithRevision(getWriteCollection(collection).FindOne(c, NewFilterBuilder().WithCustomer(c).WithDeleted().WithID(id).get()), &deleted)
+		if err != nil {
+			if err == mongoDB.ErrNoDocuments {
+				return nil, 0, nil
+			}
+			return nil, 0, err
+		}
+		if err := checkExpectedRevisions(c, revision); err != nil {
+			return nil, 0, err
+		}
+		var restored T
+		filter := NewFilterBuilder().WithCustomer(c).WithDeleted().WithID(id).WithRevisions([]int64{revision}).get()
+		newRevision, err := decodeWithRevision(getWriteCollection(collection).FindOneAndUpdate(c, filter, withRevisionInc(GetUpdateUnsetFieldCommand(consts.DeletedAtField)),
+			options.FindOneAndUpdate().SetReturnDocument(options.After)), &restored)
+		if err == mongoDB.ErrNoDocuments {
+			//modified or restored after it was read
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		return &restored, newRevision, nil
+	}
+	return nil, 0, contendedWriteError(c)
+}
+
+// PurgeDeleted removes the documents of all customers that were moved to the trash of a collection before the given time
+func PurgeDeleted(c context.Context, collection string, deletedBefore time.Time) (int64, error) {
+	defer log.LogNTraceEnterExit("PurgeDeleted", c)()
+	filter := NewFilterBuilder().WithLowerThanEqual(consts.DeletedAtField, deletedBefore)
+	res, err := getWriteCollection(collection).DeleteMany(c, filter.get())
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// PurgeTrash removes the documents that were moved to the trash before the given time from all the soft delete collections
+// and returns the number of purged documents per collection
+func PurgeTrash(c context.Context, deletedBefore time.Time) (map[string]int64, error) {
+	defer log.LogNTraceEnterExit("PurgeTrash", c)()
+	purged := map[string]int64{}
+	var purgeErrs error
+	for _, collection := range SoftDeleteCollections() {
+		count, err := PurgeDeleted(c, collection, deletedBefore)
+		if err != nil {
+			purgeErrs = multierror.Append(purgeErrs, fmt.Errorf("failed to purge trash of collection %s: %w", collection, err))
+			continue
+		}
+		purged[collection] = count
+	}
+	return purged, purgeErrs
+}
+
+// RunTrashPurge purges documents that are in the trash longer than retention every interval until the context is done
+func RunTrashPurge(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := PurgeTrash(ctx, time.Now().UTC().Add(-retention))
+			if err != nil {
+				zap.L().Error("failed to purge trash", zap.Error(err), zap.Any("purged", purged))
+				continue
+			}
+			zap.L().Info("purged trash", zap.Any("purged", purged))
+		}
+	}
+}
+
+// softDeleteCommand marks documents as deleted
+func softDeleteCommand() bson.D {
+	return GetUpdateSetFieldCommand(consts.DeletedAtField, time.Now().UTC())
+}
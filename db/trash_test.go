@@ -0,0 +1,75 @@
+package db
+
+import (
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSoftDelete(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	c := context.WithValue(context.WithValue(context.Background(), consts.Collection, consts.RepositoryCollection), consts.CustomerGUID, "customer-guid")
+	SetSoftDelete(consts.RepositoryCollection)
+	defer softDeleteCollections.Delete(consts.RepositoryCollection)
+	assert.True(t, IsSoftDelete(consts.RepositoryCollection))
+	assert.False(t, IsSoftDelete(consts.ClustersCollection))
+	//only the queries of soft delete collections exclude the trash
+	assert.Contains(t, NewFilterBuilder().WithCustomer(c).get(), bson.E{Key: consts.DeletedAtField, Value: bson.D{{Key: "$exists", Value: false}}})
+	clustersContext := context.WithValue(c, consts.Collection, consts.ClustersCollection)
+	assert.Equal(t, bson.D{{Key: consts.CustomersField, Value: "customer-guid"}}, NewFilterBuilder().WithCustomer(clustersContext).get())
+	assert.Equal(t, bson.D{{Key: consts.CustomersField, Value: bson.D{{Key: "$in", Value: []string{"customer-guid", ""}}}}}, NewFilterBuilder().WithCustomerAndGlobal(clustersContext).get())
+
+	repo1, repo2 := &types.Repository{}, &types.Repository{}
+	repo1.Name, repo2.Name = "repo1", "repo2"
+	docs, err := InsertDocuments(c, []*types.Repository{repo1, repo2})
+	assert.NoError(t, err)
+	deleted, revision, err := DeleteByGUIDWithRevision[*types.Repository](c, docs[0].GUID)
+	assert.NoError(t, err)
+	assert.NotNil(t, deleted)
+	assert.Equal(t, int64(1), revision)
+
+	//the deleted document is in the trash only
+	doc, err := GetDocByGUID[*types.Repository](c, docs[0].GUID)
+	assert.NoError(t, err)
+	assert.Nil(t, doc)
+	trash, err := GetDeletedForCustomer[*types.Repository](c)
+	assert.NoError(t, err)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, docs[0].GUID, trash[0].GUID)
+	}
+	count, err := CountDocs(c, NewFilterBuilder())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	//trash newer than the purge time is kept
+	purged, err := PurgeTrash(c, time.Now().UTC().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{consts.RepositoryCollection: 0}, purged)
+
+	restored, revision, err := RestoreDeleted[*types.Repository](c, docs[0].GUID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, restored) {
+		assert.Equal(t, docs[0].GUID, (*restored).GUID)
+	}
+	assert.Equal(t, int64(3), revision)
+	restored, _, err = RestoreDeleted[*types.Repository](c, docs[0].GUID)
+	assert.NoError(t, err)
+	assert.Nil(t, restored)
+
+	deletedCount, err := BulkDelete[*types.Repository](c, *NewFilterBuilder())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deletedCount)
+	purged, err = PurgeTrash(c, time.Now().UTC())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{consts.RepositoryCollection: 2}, purged)
+	trash, err = GetDeletedForCustomer[*types.Repository](c)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+}
@@ -73,3 +73,46 @@ func GetMultipleUpdateSetFieldCommand(fieldNameToValue map[string]interface{}) b
 	}
 	return res
 }
+
+// GetReplaceDocCommand creates update command that replaces the content of a document
+// fields of the old content that are missing in the new content are removed
+func GetReplaceDocCommand[T types.DocContent](oldContent, newContent T) (bson.D, error) {
+	newFields, err := toBsonDoc(newContent)
+	if err != nil {
+		return nil, err
+	}
+	oldFields, err := toBsonDoc(oldContent)
+	if err != nil {
+		return nil, err
+	}
+	if len(newFields) == 0 {
+		return nil, NoFieldsToUpdateError{}
+	}
+	newKeys := map[string]bool{}
+	for _, field := range newFields {
+		newKeys[field.Key] = true
+	}
+	unset := bson.D{}
+	for _, field := range oldFields {
+		if !newKeys[field.Key] {
+			unset = append(unset, bson.E{Key: field.Key, Value: ""})
+		}
+	}
+	update := bson.D{bson.E{Key: "$set", Value: newFields}}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	return update, nil
+}
+
+func toBsonDoc(i interface{}) (bson.D, error) {
+	data, err := bson.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
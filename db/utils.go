@@ -1,7 +1,6 @@
 package db
 
 import (
-	"config-service/db/mongo"
 	"config-service/types"
 	"config-service/utils/consts"
 	"config-service/utils/log"
@@ -11,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/armosec/armoapi-go/armotypes"
 	"github.com/hashicorp/go-multierror"
@@ -22,7 +22,7 @@ import (
 
 // triggers collection actions - called by the router builder on startup
 func ValidateCollection(collection string) error {
-	return mongo.IndexCollection(collection)
+	return dbStore.IndexCollection(collection)
 }
 
 //////////////////////////////////Sugar functions for mongo using values in gin context /////////////////////////////////////////
@@ -35,7 +35,7 @@ func AdminUpdateMany(c context.Context, filter *FilterBuilder, update bson.D) (i
 		return 0, err
 	}
 
-	res, err := mongo.GetWriteCollection(collection).UpdateMany(c, filter.get(), update)
+	res, err := getWriteCollection(collection).UpdateMany(c, filter.get(), withRevisionInc(update))
 	return res.ModifiedCount, err
 }
 
@@ -71,8 +71,7 @@ func FindForCustomer[T any](c context.Context, findOpts *FindOptions) ([]T, erro
 // AdminFind search for docs of all customers (unless filtered by caller)
 func AdminFind[T any](c context.Context, findOps *FindOptions) ([]T, error) {
 	defer log.LogNTraceEnterExit(fmt.Sprintf("AdminFind %+v", findOps), c)()
-	collection, _, err := ReadContext(c)
-	result := []T{}
+	collection, customerGUID, err := ReadContext(c)
 	if err != nil {
 		return nil, err
 	}
@@ -88,16 +87,54 @@ func AdminFind[T any](c context.Context, findOps *FindOptions) ([]T, error) {
 	dbFindOptions.SetSort(findOps.sort.get())
 	dbFindOptions.SetSkip(int64(findOps.skip))
 
-	if cur, err := mongo.GetReadCollection(collection).
-		Find(c, findOps.filter.get(), dbFindOptions); err != nil {
-		return nil, err
-	} else {
-		defer cur.Close(c)
-		if err := cur.All(c, &result); err != nil {
+	return cachedQuery(c, collection, customerGUID, "find", findOps.cacheKey, func() ([]T, error) {
+		result := []T{}
+		if cur, err := getReadCollection(collection).
+			Find(c, findOps.filter.get(), dbFindOptions); err != nil {
 			return nil, err
+		} else {
+			defer cur.Close(c)
+			if err := cur.All(c, &result); err != nil {
+				return nil, err
+			}
 		}
+		return result, nil
+	})
+}
+
+// ForEachForCustomer calls fn with each customer doc matching the find options, the docs are decoded one by one from the db cursor
+// the iteration stops on the first error of fn
+func ForEachForCustomer[T any](c context.Context, findOps *FindOptions, fn func(doc T) error) error {
+	defer log.LogNTraceEnterExit(fmt.Sprintf("ForEachForCustomer %+v", findOps), c)()
+	collection, _, err := ReadContext(c)
+	if err != nil {
+		return err
 	}
-	return result, nil
+	if findOps == nil {
+		findOps = NewFindOptions()
+	}
+	findOps.Filter().WithCustomer(c)
+	if findOps.projection.Len() == 0 {
+		findOps.projection.Exclude(GetSchemaFromContext(c).MustExcludeFields...)
+	}
+	dbFindOptions := options.Find().
+		SetProjection(findOps.projection.get()).
+		SetSort(findOps.sort.get())
+	cur, err := getReadCollection(collection).Find(c, findOps.filter.get(), dbFindOptions)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(c)
+	for cur.Next(c) {
+		var doc T
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
 }
 
 func FindCountForCustomer(c context.Context, findOps *FindOptions) (*types.CountResult, error) {
@@ -106,13 +143,16 @@ func FindCountForCustomer(c context.Context, findOps *FindOptions) (*types.Count
 		findOps = &FindOptions{}
 	}
 	filter := findOps.Filter().WithCustomer(c)
-	count, err := CountDocs(c, filter)
-	if err != nil {
-		return nil, err
-	}
-	countRes := &types.CountResult{}
-	countRes.SetCount(count)
-	return countRes, nil
+	collection, customerGUID, _ := ReadContext(c)
+	return cachedQuery(c, collection, customerGUID, "count", findOps.cacheKey, func() (*types.CountResult, error) {
+		count, err := CountDocs(c, filter)
+		if err != nil {
+			return nil, err
+		}
+		countRes := &types.CountResult{}
+		countRes.SetCount(count)
+		return countRes, nil
+	})
 }
 
 func FindPaginatedForCustomer[T any](c context.Context, findOps *FindOptions) (*types.SearchResult[T], error) {
@@ -121,10 +161,16 @@ func FindPaginatedForCustomer[T any](c context.Context, findOps *FindOptions) (*
 		findOps = &FindOptions{}
 	}
 	findOps.Filter().WithCustomer(c)
+	collection, customerGUID, _ := ReadContext(c)
 	if GetSchemaFromContext(c).GetNestedDocPath() != "" {
-		return AdminFindNestedPaginated[T](c, findOps)
+		baseDocId := BaseDocIDFromContext(c)
+		return cachedQuery(c, collection, customerGUID, "findNested/"+baseDocId, findOps.cacheKey, func() (*types.SearchResult[T], error) {
+			return AdminFindNestedPaginated[T](c, findOps)
+		})
 	}
-	return AdminFindPaginated[T](c, findOps)
+	return cachedQuery(c, collection, customerGUID, "findPaginated", findOps.cacheKey, func() (*types.SearchResult[T], error) {
+		return AdminFindPaginated[T](c, findOps)
+	})
 }
 
 // AdminFindPaginated search for docs of all customers (unless filtered by caller) and return paginated result
@@ -138,18 +184,9 @@ func AdminFindPaginated[T any](c context.Context, findOps *FindOptions) (*types.
 		findOps = &FindOptions{}
 	}
 
-	resultsPipe := []bson.M{}
-	if findOps.limit > 0 {
-		if findOps.Sort().Len() > 0 {
-			resultsPipe = append(resultsPipe, bson.M{"$sort": findOps.sort.get()})
-		}
-		if findOps.skip > 0 {
-			resultsPipe = append(resultsPipe, bson.M{"$skip": findOps.skip})
-		}
-		resultsPipe = append(resultsPipe, bson.M{"$limit": findOps.limit})
-		if findOps.Projection().Len() > 0 {
-			resultsPipe = append(resultsPipe, bson.M{"$project": findOps.projection.get()})
-		}
+	pageStages, position, err := findOps.pageStages(consts.IdField)
+	if err != nil {
+		return nil, err
 	}
 
 	pipeline := mongoDB.Pipeline{ // support only count with no actual results (limit = 0)
@@ -160,18 +197,11 @@ func AdminFindPaginated[T any](c context.Context, findOps *FindOptions) (*types.
 			},
 		}}},
 	}
-	if len(resultsPipe) > 0 { // Regular search with results
-		pipeline = mongoDB.Pipeline{
-			{{Key: "$match", Value: findOps.filter.get()}},
-			{{Key: "$facet", Value: bson.M{
-				"limitedResults": resultsPipe,
-				"count": []bson.M{
-					{"$count": "count"},
-				},
-			}}},
-		}
+	if len(pageStages) > 0 { // Regular search with results
+		pipeline = append(mongoDB.Pipeline{{{Key: "$match", Value: findOps.filter.get()}}}, pageStages...)
 	}
-	cursor, err := mongo.GetReadCollection(collection).Aggregate(c, pipeline)
+	start := time.Now()
+	cursor, err := getReadCollection(collection).Aggregate(c, pipeline)
 	if err != nil {
 		return nil, err
 	}
@@ -183,13 +213,17 @@ func AdminFindPaginated[T any](c context.Context, findOps *FindOptions) (*types.
 			return nil, err
 		}
 	}
+	observeQuery(c, collection, types.SlowQueryFind, findOps.filter, findOps.sort, pipeline, start)
 	searchRes := &types.SearchResult[T]{}
 	var count int64
 	if len(result.Count) > 0 {
 		count = result.Count[0].Count
 	}
-	searchRes.SetCount(count)
+	searchRes.SetCount(position.total(count))
 	searchRes.SetResults(result.LimitedResults)
+	if searchRes.Cursor, err = position.nextPageCursor(len(result.LimitedResults), findOps.limit, result.SortValues); err != nil {
+		return nil, err
+	}
 	return searchRes, nil
 }
 
@@ -212,18 +246,10 @@ func AdminFindNestedPaginated[T any](c context.Context, findOps *FindOptions) (*
 		return nil, errors.New("nestedDocPath is empty")
 	}
 
-	resultsPipe := []bson.M{}
-	if findOps.limit > 0 {
-		if findOps.Sort().Len() > 0 {
-			resultsPipe = append(resultsPipe, bson.M{"$sort": findOps.sort.get()})
-		}
-		if findOps.skip > 0 {
-			resultsPipe = append(resultsPipe, bson.M{"$skip": findOps.skip})
-		}
-		resultsPipe = append(resultsPipe, bson.M{"$limit": findOps.limit})
-		if findOps.Projection().Len() > 0 {
-			resultsPipe = append(resultsPipe, bson.M{"$project": findOps.projection.get()})
-		}
+	//nested documents have no _id, they are ordered by their GUID after the sort keys
+	pageStages, position, err := findOps.pageStages(consts.GUIDField)
+	if err != nil {
+		return nil, err
 	}
 
 	filtersList := findOps.filter.get()
@@ -231,25 +257,24 @@ func AdminFindNestedPaginated[T any](c context.Context, findOps *FindOptions) (*
 	if len(filtersList) < 1 {
 		return nil, errors.New("missing base doc filters")
 	}
-	matchOnBaseDoc := bson.D{filtersList[len(filtersList)-1], bson.E{Key: consts.IdField, Value: baseDocId}} //customer and base doc guid filter
+	baseDocFiltersStart := len(filtersList) - 1
+	if baseDocFiltersStart > 0 && filtersList[baseDocFiltersStart].Key == consts.DeletedAtField {
+		//the trash filter follows the customer filter
+		baseDocFiltersStart--
+	}
+	matchOnBaseDoc := append(bson.D{}, filtersList[baseDocFiltersStart:]...)
+	matchOnBaseDoc = append(matchOnBaseDoc, bson.E{Key: consts.IdField, Value: baseDocId}) //customer and base doc guid filter
 	pipeline := mongoDB.Pipeline{
 		{{Key: "$match", Value: matchOnBaseDoc}},
 		{{Key: "$unwind", Value: fmt.Sprintf("$%s", nestedDocPath)}},
 		{{Key: "$replaceRoot", Value: bson.M{"newRoot": fmt.Sprintf("$%s", nestedDocPath)}}},
 	}
-	if len(filtersList) > 1 {
-		matchOnNestedDoc := filtersList[:len(filtersList)-1] // all filters except the customer guid
+	if baseDocFiltersStart > 0 {
+		matchOnNestedDoc := filtersList[:baseDocFiltersStart] // all filters except the customer guid
 		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: []bson.D{matchOnNestedDoc}}}}})
 	}
-	if len(resultsPipe) > 0 {
-		pipeline = append(pipeline, bson.D{
-			{Key: "$facet", Value: bson.M{
-				"limitedResults": resultsPipe,
-				"count": []bson.M{
-					{"$count": "count"},
-				},
-			}}},
-		)
+	if len(pageStages) > 0 {
+		pipeline = append(pipeline, pageStages...)
 	} else { // support only count with no actual results (limit = 0)
 		pipeline = append(pipeline, bson.D{
 			{Key: "$facet", Value: bson.M{
@@ -260,7 +285,8 @@ func AdminFindNestedPaginated[T any](c context.Context, findOps *FindOptions) (*
 		})
 	}
 
-	cursor, err := mongo.GetReadCollection(collection).Aggregate(c, pipeline)
+	start := time.Now()
+	cursor, err := getReadCollection(collection).Aggregate(c, pipeline)
 	if err != nil {
 		return nil, err
 	}
@@ -272,13 +298,17 @@ func AdminFindNestedPaginated[T any](c context.Context, findOps *FindOptions) (*
 			return nil, err
 		}
 	}
+	observeQuery(c, collection, types.SlowQueryFindNested, findOps.filter, findOps.sort, pipeline, start)
 	searchRes := &types.SearchResult[T]{}
 	var count int64
 	if len(result.Count) > 0 {
 		count = result.Count[0].Count
 	}
-	searchRes.SetCount(count)
+	searchRes.SetCount(position.total(count))
 	searchRes.SetResults(result.LimitedResults)
+	if searchRes.Cursor, err = position.nextPageCursor(len(result.LimitedResults), findOps.limit, result.SortValues); err != nil {
+		return nil, err
+	}
 	return searchRes, nil
 }
 
@@ -288,7 +318,10 @@ func AggregateForCustomer(c context.Context, findOps *FindOptions) (*armotypes.U
 		findOps = &FindOptions{}
 	}
 	findOps.Filter().WithCustomer(c)
-	return AdminAggregate(c, findOps)
+	collection, customerGUID, _ := ReadContext(c)
+	return cachedQuery(c, collection, customerGUID, "uniqueValues", findOps.cacheKey, func() (*armotypes.UniqueValuesResponseV2, error) {
+		return AdminAggregate(c, findOps)
+	})
 }
 
 // AdminAggregate search for docs of all customers (unless filtered by caller) and return aggregated result
@@ -316,13 +349,14 @@ func AdminAggregate(c context.Context, findOps *FindOptions) (*armotypes.UniqueV
 		}
 		fieldFilter.WithFilter(findOps.filter)
 		errGroup.Go(func() error {
-			cursor, err := mongo.GetReadCollection(collection).Aggregate(ctx,
-				uniqueValuePipeline(fields,
-					fieldFilter.get(),
-					findOps.UnwindFilter().filter,
-					findOps.skip,
-					findOps.limit,
-					GetSchemaFromContext(c)))
+			pipeline := uniqueValuePipeline(fields,
+				fieldFilter.get(),
+				findOps.UnwindFilter().filter,
+				findOps.skip,
+				findOps.limit,
+				GetSchemaFromContext(c))
+			start := time.Now()
+			cursor, err := getReadCollection(collection).Aggregate(ctx, pipeline)
 			if err != nil {
 				return fmt.Errorf("failed to aggregate field %s: %w", field, err)
 			}
@@ -334,6 +368,7 @@ func AdminAggregate(c context.Context, findOps *FindOptions) (*armotypes.UniqueV
 					return fmt.Errorf("failed to decode field %s: %w", field, err)
 				}
 			}
+			observeQuery(c, collection, types.SlowQueryUniqueValues, fieldFilter, nil, pipeline, start)
 			results.Store(field, result)
 			return nil
 		})
@@ -367,79 +402,126 @@ func AdminAggregate(c context.Context, findOps *FindOptions) (*armotypes.UniqueV
 
 // UpdateDocument updates document by GUID and update command
 func UpdateDocument[T any](c context.Context, id string, update bson.D) ([]T, error) {
+	docs, _, err := UpdateDocumentWithRevision[T](c, id, update)
+	return docs, err
+}
+
+// UpdateDocumentWithRevision updates document by GUID and update command and returns the old and new documents with the new document revision
+// the old document is the exact previous revision (revision - 1) of the new document
+// if the request has revision preconditions the document is updated only if its revision is expected, otherwise RevisionMismatchError is returned
+// requests without revision preconditions get ConcurrentModificationError if the document is modified concurrently on every attempt
+func UpdateDocumentWithRevision[T any](c context.Context, id string, update bson.D) ([]T, int64, error) {
 	defer log.LogNTraceEnterExit("UpdateDocument", c)()
+	return updateDocumentWithFunc(c, id, func(T) (bson.D, error) { return update, nil })
+}
+
+// UpdateDocumentWithFunc updates document by GUID with the update command returned by buildUpdate for the current document
+// the update is applied only if the document was not modified after it was read, otherwise the document is read again and buildUpdate is called with it
+// errors returned by buildUpdate are returned as is, see UpdateDocumentWithRevision for the other results
+func UpdateDocumentWithFunc[T any](c context.Context, id string, buildUpdate func(oldDoc T) (bson.D, error)) ([]T, int64, error) {
+	defer log.LogNTraceEnterExit("UpdateDocumentWithFunc", c)()
+	return updateDocumentWithFunc(c, id, buildUpdate)
+}
+
+func updateDocumentWithFunc[T any](c context.Context, id string, buildUpdate func(oldDoc T) (bson.D, error)) ([]T, int64, error) {
 	collection, _, err := ReadContext(c)
 	if err != nil {
-		return nil, err
-	}
-	var oldDoc T
-	if err := mongo.GetReadCollection(collection).
-		FindOne(c,
-			NewFilterBuilder().
-				WithCustomer(c).
-				WithID(id).
-				get()).
-		Decode(&oldDoc); err != nil {
+		return nil, 0, err
+	}
+	for attempt := 0; attempt < maxConditionalWriteAttempts; attempt++ {
+		//read from the write collection, a stale read would never match the revision filter
+		var oldDoc T
+		oldRevision, err := decodeWithRevision(getWriteCollection(collection).
+			FindOne(c,
+				NewFilterBuilder().
+					WithCustomer(c).
+					WithID(id).
+					get()), &oldDoc)
+		if err != nil {
+			if err == mongoDB.ErrNoDocuments {
+				return nil, 0, nil
+			}
+			log.LogNTraceError("failed to get document by id", err, c)
+			return nil, 0, err
+		}
+		if err := checkExpectedRevisions(c, oldRevision); err != nil {
+			return nil, 0, err
+		}
+		update, err := buildUpdate(oldDoc)
+		if err != nil {
+			return nil, 0, err
+		}
+		var newDoc T
+		filter := NewFilterBuilder().WithCustomer(c).WithID(id).WithRevisions([]int64{oldRevision}).get()
+		newRevision, err := decodeWithRevision(getWriteCollection(collection).FindOneAndUpdate(c, filter, withRevisionInc(update),
+			options.FindOneAndUpdate().SetReturnDocument(options.After)), &newDoc)
 		if err == mongoDB.ErrNoDocuments {
-			return nil, nil
+			//modified or deleted after it was read
+			continue
+		} else if err != nil {
+			return nil, 0, err
 		}
-		log.LogNTraceError("failed to get document by id", err, c)
-		return nil, err
+		return []T{oldDoc, newDoc}, newRevision, nil
 	}
-	var newDoc T
-	filter := NewFilterBuilder().WithCustomer(c).WithID(id).get()
-	if err := mongo.GetWriteCollection(collection).FindOneAndUpdate(c, filter, update,
-		options.FindOneAndUpdate().SetReturnDocument(options.After)).
-		Decode(&newDoc); err != nil {
-		return nil, err
-	}
-	return []T{oldDoc, newDoc}, nil
+	return nil, 0, contendedWriteError(c)
 }
 
 func AddToArray(c context.Context, id string, arrayPath string, values ...interface{}) (modified int64, err error) {
 	defer log.LogNTraceEnterExit("AddToArray", c)()
-	collection, _, err := ReadContext(c)
-	if err != nil {
-		return 0, err
-	}
-	//filter documents that already have this value in the array
-	filter := NewFilterBuilder().WithCustomer(c).WithID(id).get()
-
+	//filter documents that already have these values in the array
+	filter := NewFilterBuilder().WithValue(arrayPath, bson.D{{Key: "$not", Value: bson.D{{Key: "$all", Value: values}}}})
 	update := GetUpdateAddToSetCommand(arrayPath, values...)
-	res, err := mongo.GetWriteCollection(collection).UpdateOne(c, filter, update)
-	if res != nil {
-		modified = res.ModifiedCount
-	}
-	return modified, err
+	return updateOne(c, id, filter, update)
 }
 
 func UpdateOne(c context.Context, id string, update interface{}) (modified int64, err error) {
 	defer log.LogNTraceEnterExit("UpdateOne", c)()
-	collection, _, err := ReadContext(c)
-	if err != nil {
-		return 0, err
-	}
-	filterBuilder := NewFilterBuilder().WithCustomer(c).WithID(id)
-	res, err := mongo.GetWriteCollection(collection).UpdateOne(c, filterBuilder.get(), update)
-	if res != nil {
-		modified = res.ModifiedCount
-	}
-	return modified, err
+	return updateOne(c, id, nil, update)
+}
+
+// SetField sets the value of a document field, documents that already have this value are not modified
+func SetField(c context.Context, id string, fieldPath string, value interface{}) (modified int64, err error) {
+	defer log.LogNTraceEnterExit("SetField", c)()
+	filter := NewFilterBuilder().WithNotEqual(fieldPath, value)
+	update := GetUpdateSetFieldCommand(fieldPath, value)
+	return updateOne(c, id, filter, update)
+}
+
+// UnsetField removes a document field, documents without this field are not modified
+func UnsetField(c context.Context, id string, fieldPath string) (modified int64, err error) {
+	defer log.LogNTraceEnterExit("UnsetField", c)()
+	filter := NewFilterBuilder().AddExists(fieldPath, true)
+	update := GetUpdateUnsetFieldCommand(fieldPath)
+	return updateOne(c, id, filter, update)
 }
 
 func PullFromArray(c context.Context, id string, arrayPath string, values ...interface{}) (modified int64, err error) {
 	defer log.LogNTraceEnterExit("PullFromArray", c)()
+	//filter documents that have none of these values in the array
+	filter := NewFilterBuilder().WithIn(arrayPath, values)
+	update := GetUpdatePullFromSetCommand(arrayPath, values...)
+	return updateOne(c, id, filter, update)
+}
+
+// updateOne updates a customer document by id and increments its revision
+// filter is used to skip documents that the update does not change so their revision stays the same
+func updateOne(c context.Context, id string, filter *FilterBuilder, update interface{}) (modified int64, err error) {
 	collection, _, err := ReadContext(c)
 	if err != nil {
 		return 0, err
 	}
-	filterBuilder := NewFilterBuilder().WithCustomer(c).WithID(id)
-	update := GetUpdatePullFromSetCommand(arrayPath, values...)
-	res, err := mongo.GetWriteCollection(collection).UpdateOne(c, filterBuilder.get(), update)
-	if res != nil {
-		modified = res.ModifiedCount
+	filterBuilder := NewFilterBuilder().WithCustomer(c).WithID(id).withExpectedRevisions(c)
+	if filter != nil {
+		filterBuilder.WithFilter(filter)
+	}
+	res, err := getWriteCollection(collection).UpdateOne(c, filterBuilder.get(), withRevisionInc(update))
+	if err != nil {
+		return 0, err
+	}
+	if res.MatchedCount == 0 {
+		return 0, checkDocRevision(c, collection, id)
 	}
-	return modified, err
+	return res.ModifiedCount, nil
 }
 
 // DocExist returns true if at least one document with given filter exists
@@ -453,7 +535,7 @@ func DocExist(c context.Context, filterBuilder *FilterBuilder) (bool, error) {
 		WithCustomer(c).
 		WithFilter(filterBuilder).
 		get()
-	n, err := mongo.GetReadCollection(collection).CountDocuments(c, filter, options.Count().SetLimit(1))
+	n, err := getReadCollection(collection).CountDocuments(c, filter, options.Count().SetLimit(1))
 	return n > 0, err
 }
 
@@ -467,10 +549,16 @@ func DocWithNameExist(c context.Context, name string) (bool, error) {
 
 // GetDocByGUID returns document by GUID owned by customer
 func GetDocByGUID[T any](c context.Context, guid string) (*T, error) {
+	doc, _, err := GetDocByGUIDWithRevision[T](c, guid)
+	return doc, err
+}
+
+// GetDocByGUIDWithRevision returns document by GUID owned by customer and the document revision
+func GetDocByGUIDWithRevision[T any](c context.Context, guid string) (*T, int64, error) {
 	defer log.LogNTraceEnterExit("GetDocByGUID", c)()
 	collection, _, err := ReadContext(c)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	findOneOpts := make([]*options.FindOneOptions, 0, 1)
 	schema := GetSchemaFromContext(c)
@@ -478,20 +566,20 @@ func GetDocByGUID[T any](c context.Context, guid string) (*T, error) {
 		findOneOpts = append(findOneOpts, options.FindOne().SetProjection(NewProjectionBuilder().Exclude(schema.MustExcludeFields...).get()))
 	}
 	var result T
-	if err := mongo.GetReadCollection(collection).
+	revision, err := decodeWithRevision(getReadCollection(collection).
 		FindOne(c,
 			NewFilterBuilder().
 				WithCustomer(c).
 				WithID(guid).
-				get(), findOneOpts...).
-		Decode(&result); err != nil {
+				get(), findOneOpts...), &result)
+	if err != nil {
 		if err == mongoDB.ErrNoDocuments {
-			return nil, nil
+			return nil, 0, nil
 		}
 		log.LogNTraceError("failed to get document by id", err, c)
-		return nil, err
+		return nil, 0, err
 	}
-	return &result, nil
+	return &result, revision, nil
 }
 
 // GetDo returns document by given filter
@@ -506,7 +594,7 @@ func GetDoc[T any](c context.Context, filter *FilterBuilder) (*T, error) {
 	if filter != nil {
 		bfilter = filter.get()
 	}
-	if err := mongo.GetReadCollection(collection).
+	if err := getReadCollection(collection).
 		FindOne(c, bfilter).
 		Decode(&result); err != nil {
 		if err == mongoDB.ErrNoDocuments {
@@ -521,25 +609,22 @@ func GetDoc[T any](c context.Context, filter *FilterBuilder) (*T, error) {
 // GetDocByName returns document by name
 func GetDocByName[T any](c context.Context, name string) (*T, error) {
 	defer log.LogNTraceEnterExit("GetDocByName", c)()
-	collection, _, err := ReadContext(c)
+	collection, customerGUID, err := ReadContext(c)
 	if err != nil {
 		return nil, err
 	}
-	var result T
-	if err := mongo.GetReadCollection(collection).
-		FindOne(c,
-			NewFilterBuilder().
-				WithCustomer(c).
-				WithName(name).
-				get()).
-		Decode(&result); err != nil {
-		if err == mongoDB.ErrNoDocuments {
-			return nil, nil
+	filter := NewFilterBuilder().WithCustomer(c).WithName(name)
+	return cachedQuery(c, collection, customerGUID, "findOne", func() string { return extJSON(filter.get()) }, func() (*T, error) {
+		var result T
+		if err := getReadCollection(collection).FindOne(c, filter.get()).Decode(&result); err != nil {
+			if err == mongoDB.ErrNoDocuments {
+				return nil, nil
+			}
+			log.LogNTraceError("failed to get document by name", err, c)
+			return nil, err
 		}
-		log.LogNTraceError("failed to get document by name", err, c)
-		return nil, err
-	}
-	return &result, nil
+		return &result, nil
+	})
 }
 
 // CountDocs counts documents that match the filter
@@ -553,20 +638,28 @@ func CountDocs(c context.Context, filterBuilder *FilterBuilder) (int64, error) {
 		WithCustomer(c).
 		WithFilter(filterBuilder).
 		get()
-	return mongo.GetReadCollection(collection).CountDocuments(c, filter)
+	return getReadCollection(collection).CountDocuments(c, filter)
 }
 
 func InsertDBDocument[T types.DocContent](c context.Context, dbDoc types.Document[T]) (T, error) {
+	doc, _, err := InsertDBDocumentWithRevision(c, dbDoc)
+	return doc, err
+}
+
+// InsertDBDocumentWithRevision inserts the document and returns its content with the inserted revision, a document without a revision is inserted as a new document
+func InsertDBDocumentWithRevision[T types.DocContent](c context.Context, dbDoc types.Document[T]) (T, int64, error) {
 	defer log.LogNTraceEnterExit("InsertDBDocument", c)()
 	collection, err := readCollection(c)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	if _, err := mongo.GetWriteCollection(collection).InsertOne(c, dbDoc); err != nil {
-		return nil, err
-	} else {
-		return dbDoc.Content, nil
+	if dbDoc.Revision == 0 {
+		dbDoc.Revision = types.NewDocumentRevision
+	}
+	if _, err := getWriteCollection(collection).InsertOne(c, dbDoc); err != nil {
+		return nil, 0, err
 	}
+	return dbDoc.Content, dbDoc.Revision, nil
 }
 
 func InsertDocuments[T types.DocContent](c context.Context, docs []T) ([]T, error) {
@@ -581,13 +674,13 @@ func InsertDocuments[T types.DocContent](c context.Context, docs []T) ([]T, erro
 	}
 
 	if len(dbDocs) == 1 {
-		if _, err := mongo.GetWriteCollection(collection).InsertOne(c, dbDocs[0]); err != nil {
+		if _, err := getWriteCollection(collection).InsertOne(c, dbDocs[0]); err != nil {
 			return nil, err
 		} else {
 			return docs, nil
 		}
 	} else {
-		if _, err := mongo.GetWriteCollection(collection).InsertMany(c, dbDocs); err != nil {
+		if _, err := getWriteCollection(collection).InsertMany(c, dbDocs); err != nil {
 			return nil, err
 		} else {
 			return docs, nil
@@ -596,44 +689,64 @@ func InsertDocuments[T types.DocContent](c context.Context, docs []T) ([]T, erro
 }
 
 func DeleteByName[T types.DocContent](c context.Context, name string) (deletedDoc *T, err error) {
-	defer log.LogNTraceEnterExit("DeleteByName", c)()
-	collection, err := readCollection(c)
-	if err != nil {
-		return nil, err
-	}
-	toBeDeleted, err := GetDocByName[T](c, name)
-	if err != nil {
-		return nil, err
-	} else if toBeDeleted == nil {
-		return nil, nil
-	}
+	deletedDoc, _, err = DeleteByNameWithRevision[T](c, name)
+	return deletedDoc, err
+}
 
-	if res, err := mongo.GetWriteCollection(collection).DeleteOne(c, bson.M{consts.IdField: (*toBeDeleted).GetGUID()}); err != nil {
-		return nil, err
-	} else if res.DeletedCount == 0 {
-		return nil, nil
-	}
-	return toBeDeleted, nil
+// DeleteByNameWithRevision deletes document by name and returns the deleted document with its revision
+func DeleteByNameWithRevision[T types.DocContent](c context.Context, name string) (deletedDoc *T, revision int64, err error) {
+	defer log.LogNTraceEnterExit("DeleteByName", c)()
+	return deleteDoc[T](c, NewFilterBuilder().WithCustomer(c).WithName(name))
 }
 
 func DeleteByGUID[T types.DocContent](c context.Context, guid string) (deletedDoc *T, err error) {
+	deletedDoc, _, err = DeleteByGUIDWithRevision[T](c, guid)
+	return deletedDoc, err
+}
+
+// DeleteByGUIDWithRevision deletes document by GUID and returns the deleted document with its revision
+func DeleteByGUIDWithRevision[T types.DocContent](c context.Context, guid string) (deletedDoc *T, revision int64, err error) {
 	defer log.LogNTraceEnterExit("DeleteByGUID", c)()
+	return deleteDoc[T](c, NewFilterBuilder().WithCustomer(c).WithID(guid))
+}
+
+// deleteDoc reads and deletes the customer document that matches the filter, the deleted document is the exact last revision of the document
+// in soft delete collections the document is moved to the trash
+// if the request has revision preconditions the document is deleted only if its revision is expected, otherwise RevisionMismatchError is returned
+// requests without revision preconditions get ConcurrentModificationError if the document is modified concurrently on every attempt
+func deleteDoc[T types.DocContent](c context.Context, filter *FilterBuilder) (*T, int64, error) {
 	collection, err := readCollection(c)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	toBeDeleted, err := GetDocByGUID[T](c, guid)
-	if err != nil {
-		return nil, err
-	} else if toBeDeleted == nil {
-		return nil, nil
-	}
-	if res, err := mongo.GetWriteCollection(collection).DeleteOne(c, bson.M{consts.IdField: guid}); err != nil {
-		return nil, err
-	} else if res.DeletedCount == 0 {
-		return nil, nil
+	for attempt := 0; attempt < maxConditionalWriteAttempts; attempt++ {
+		var toBeDeleted T
+		revision, err := decodeWithRevision(getWriteCollection(collection).FindOne(c, filter.get()), &toBeDeleted)
+		if err != nil {
+			if err == mongoDB.ErrNoDocuments {
+				return nil, 0, nil
+			}
+			log.LogNTraceError("failed to get document", err, c)
+			return nil, 0, err
+		}
+		if err := checkExpectedRevisions(c, revision); err != nil {
+			return nil, 0, err
+		}
+		deleteFilter := NewFilterBuilder().WithValue(consts.IdField, toBeDeleted.GetGUID()).WithRevisions([]int64{revision})
+		if IsSoftDelete(collection) {
+			if res, err := getWriteCollection(collection).UpdateOne(c, deleteFilter.withNotDeleted().get(), withRevisionInc(softDeleteCommand())); err != nil {
+				return nil, 0, err
+			} else if res.ModifiedCount == 1 {
+				return &toBeDeleted, revision, nil
+			}
+		} else if res, err := getWriteCollection(collection).DeleteOne(c, deleteFilter.get()); err != nil {
+			return nil, 0, err
+		} else if res.DeletedCount == 1 {
+			return &toBeDeleted, revision, nil
+		}
+		//modified or deleted after it was read
 	}
-	return toBeDeleted, nil
+	return nil, 0, contendedWriteError(c)
 }
 
 func BulkDeleteByName[T types.DocContent](c context.Context, names []string) (deletedCount int64, err error) {
@@ -642,6 +755,7 @@ func BulkDeleteByName[T types.DocContent](c context.Context, names []string) (de
 	return BulkDelete[T](c, *filter)
 }
 
+// BulkDelete deletes the customer documents that match the filter, in soft delete collections the documents are moved to the trash
 func BulkDelete[T types.DocContent](c context.Context, filter FilterBuilder) (deletedCount int64, err error) {
 	defer log.LogNTraceEnterExit("BulkDelete", c)()
 	collection, err := readCollection(c)
@@ -649,12 +763,57 @@ func BulkDelete[T types.DocContent](c context.Context, filter FilterBuilder) (de
 		return 0, err
 	}
 	filter.WithCustomer(c)
-	if res, err := mongo.GetWriteCollection(collection).DeleteMany(c, filter.get()); err != nil {
+	if IsSoftDelete(collection) {
+		if res, err := getWriteCollection(collection).UpdateMany(c, filter.get(), withRevisionInc(softDeleteCommand())); err != nil {
+			return 0, err
+		} else {
+			return res.ModifiedCount, nil
+		}
+	}
+	if res, err := getWriteCollection(collection).DeleteMany(c, filter.get()); err != nil {
 		return 0, err
 	} else {
 		return res.DeletedCount, nil
 	}
 }
+
+// DeletedDoc is a document deleted by BulkDeleteWithRevisions and its revision when it was deleted
+type DeletedDoc[T types.DocContent] struct {
+	Doc      T
+	Revision int64
+}
+
+// BulkDeleteWithRevisions deletes the customer documents that match the filter one by one and returns the deleted documents with their revisions
+// used to keep the versions of the deleted documents, it should run in a transaction (see WithTransaction) so the versions are kept with the deletes
+func BulkDeleteWithRevisions[T types.DocContent](c context.Context, filter FilterBuilder) ([]DeletedDoc[T], error) {
+	defer log.LogNTraceEnterExit("BulkDeleteWithRevisions", c)()
+	collection, err := readCollection(c)
+	if err != nil {
+		return nil, err
+	}
+	filter.WithCustomer(c)
+	cur, err := getWriteCollection(collection).Find(c, filter.get(), options.Find().SetProjection(NewProjectionBuilder().Include(consts.IdField).get()))
+	if err != nil {
+		return nil, err
+	}
+	var ids []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cur.All(c, &ids); err != nil {
+		return nil, err
+	}
+	deleted := []DeletedDoc[T]{}
+	for _, id := range ids {
+		doc, revision, err := deleteDoc[T](c, NewFilterBuilder().WithCustomer(c).WithID(id.ID))
+		if err != nil {
+			return nil, err
+		} else if doc != nil {
+			deleted = append(deleted, DeletedDoc[T]{Doc: *doc, Revision: revision})
+		}
+	}
+	return deleted, nil
+}
+
 func DeleteCustomerDocs(c context.Context) (deletedCount int64, err error) {
 	defer log.LogNTraceEnterExit("DeleteAllCustomerDocs", c)()
 	customerGUID, err := readCustomerGUID(c)
@@ -669,7 +828,7 @@ func AdminDeleteCustomersDocs(c context.Context, customerGUIDs ...string) (delet
 	if len(customerGUIDs) == 0 {
 		return 0, nil
 	}
-	collections, err := mongo.ListCollectionNames(c)
+	collections, err := dbStore.ListCollectionNames(c)
 	if err != nil {
 		return 0, err
 	}
@@ -691,7 +850,7 @@ func AdminDeleteCustomersDocs(c context.Context, customerGUIDs ...string) (delet
 	go func(customerGUIDs []string) {
 		defer wg.Done()
 		idsFilter := NewFilterBuilder().WithIDs(customerGUIDs)
-		res, err := mongo.GetWriteCollection(consts.CustomersCollection).DeleteMany(c, idsFilter.get())
+		res, err := getWriteCollection(consts.CustomersCollection).DeleteMany(c, idsFilter.get())
 		if err != nil {
 			errChanel <- err
 		}
@@ -703,13 +862,14 @@ func AdminDeleteCustomersDocs(c context.Context, customerGUIDs ...string) (delet
 	//delete all the customers docs in all collections
 	ownersFilter := NewFilterBuilder().WithCustomers(customerGUIDs)
 	for _, collection := range collections {
-		if collection == consts.CustomersCollection {
+		//customers are deleted above and the audit trail is kept after the customers data is deleted
+		if collection == consts.CustomersCollection || collection == consts.AuditCollection {
 			continue
 		}
 		wg.Add(1)
 		go func(collection string, customerGUIDs []string) {
 			defer wg.Done()
-			res, err := mongo.GetWriteCollection(collection).DeleteMany(c, ownersFilter.get())
+			res, err := getWriteCollection(collection).DeleteMany(c, ownersFilter.get())
 			if err != nil {
 				log.LogNTraceError(fmt.Sprintf("AdminDeleteAllCustomerDocs errors when deleting documents in collection:%s", collection), err, c)
 				errChanel <- err
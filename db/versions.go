@@ -0,0 +1,112 @@
+package db
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	docGUIDField   = "docGUID"
+	versionField   = "version"
+	timestampField = "timestamp"
+)
+
+// VersionsCollection returns the name of the version history collection of a collection
+func VersionsCollection(collection string) string {
+	return collection + consts.VersionsCollectionSuffix
+}
+
+// InsertDocVersion stores a prior revision of a customer document in the version history collection
+// and removes the versions of the document that exceed the retention
+func InsertDocVersion[T types.DocContent](c context.Context, version *types.DocVersion[T], retention types.VersionRetention) error {
+	defer log.LogNTraceEnterExit("InsertDocVersion", c)()
+	collection, customerGUID, err := ReadContext(c)
+	if err != nil {
+		return err
+	}
+	version.ID = uuid.NewV4().String()
+	version.Customers = []string{customerGUID}
+	if version.Timestamp.IsZero() {
+		version.Timestamp = time.Now().UTC()
+	}
+	versions := getWriteCollection(VersionsCollection(collection))
+	if _, err := versions.InsertOne(c, version); err != nil {
+		return err
+	}
+
+	docFilter := NewFilterBuilder().WithCustomers([]string{customerGUID}).WithValue(docGUIDField, version.DocGUID)
+	if retention.MaxAge > 0 {
+		filter := NewFilterBuilder().WithFilter(docFilter).WithLowerThanEqual(timestampField, time.Now().UTC().Add(-retention.MaxAge))
+		if _, err := versions.DeleteMany(c, filter.get()); err != nil {
+			return err
+		}
+	}
+	if retention.MaxVersions > 0 {
+		//find the newest version to remove
+		var oldest types.DocVersion[T]
+		findOpts := options.FindOne().
+			SetSort(NewSortBuilder().AddDescending(versionField).get()).
+			SetSkip(int64(retention.MaxVersions)).
+			SetProjection(NewProjectionBuilder().Include(versionField).get())
+		if err := versions.FindOne(c, docFilter.get(), findOpts).Decode(&oldest); err != nil {
+			if err == mongoDB.ErrNoDocuments {
+				return nil
+			}
+			return err
+		}
+		filter := NewFilterBuilder().WithFilter(docFilter).WithLowerThanEqual(versionField, oldest.Version)
+		if _, err := versions.DeleteMany(c, filter.get()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDocVersions returns the stored versions of a customer document, newest first
+func GetDocVersions[T types.DocContent](c context.Context, docGUID string) ([]types.DocVersion[T], error) {
+	defer log.LogNTraceEnterExit("GetDocVersions", c)()
+	collection, customerGUID, err := ReadContext(c)
+	if err != nil {
+		return nil, err
+	}
+	filter := NewFilterBuilder().WithCustomers([]string{customerGUID}).WithValue(docGUIDField, docGUID)
+	findOpts := options.Find().SetSort(NewSortBuilder().AddDescending(versionField).get())
+	cur, err := getReadCollection(VersionsCollection(collection)).Find(c, filter.get(), findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	versions := []types.DocVersion[T]{}
+	if err := cur.All(c, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetDocVersion returns a stored version of a customer document, nil if not found
+func GetDocVersion[T types.DocContent](c context.Context, docGUID string, version int64) (*types.DocVersion[T], error) {
+	defer log.LogNTraceEnterExit("GetDocVersion", c)()
+	collection, customerGUID, err := ReadContext(c)
+	if err != nil {
+		return nil, err
+	}
+	filter := NewFilterBuilder().
+		WithCustomers([]string{customerGUID}).
+		WithValue(docGUIDField, docGUID).
+		WithValue(versionField, version)
+	var result types.DocVersion[T]
+	if err := getReadCollection(VersionsCollection(collection)).FindOne(c, filter.get()).Decode(&result); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &result, nil
+}
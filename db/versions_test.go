@@ -0,0 +1,64 @@
+package db
+
+import (
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertDocVersionRetention(t *testing.T) {
+	SetStore(memory.NewStore())
+	defer GetStore().Disconnect()
+	c := context.WithValue(context.WithValue(context.Background(), consts.Collection, consts.CustomerConfigCollection), consts.CustomerGUID, "customer-guid")
+	otherCustomer := context.WithValue(c, consts.CustomerGUID, "other-customer-guid")
+
+	insert := func(c context.Context, docGUID string, version int64, timestamp time.Time, retention types.VersionRetention) {
+		docVersion := &types.DocVersion[*types.CustomerConfig]{
+			DocGUID:   docGUID,
+			Version:   version,
+			Timestamp: timestamp,
+			Content:   &types.CustomerConfig{GUID: docGUID},
+		}
+		assert.NoError(t, InsertDocVersion(c, docVersion, retention))
+	}
+	versionNumbers := func(c context.Context, docGUID string) []int64 {
+		versions, err := GetDocVersions[*types.CustomerConfig](c, docGUID)
+		assert.NoError(t, err)
+		numbers := []int64{}
+		for _, version := range versions {
+			numbers = append(numbers, version.Version)
+		}
+		return numbers
+	}
+
+	now := time.Now().UTC()
+	for version := int64(1); version <= 5; version++ {
+		insert(c, "doc1", version, now, types.VersionRetention{MaxVersions: 3})
+	}
+	insert(c, "doc2", 1, now, types.VersionRetention{MaxVersions: 3})
+	insert(otherCustomer, "doc1", 1, now, types.VersionRetention{MaxVersions: 3})
+	assert.Equal(t, []int64{5, 4, 3}, versionNumbers(c, "doc1"))
+	assert.Equal(t, []int64{1}, versionNumbers(c, "doc2"))
+	assert.Equal(t, []int64{1}, versionNumbers(otherCustomer, "doc1"))
+
+	version, err := GetDocVersion[*types.CustomerConfig](c, "doc1", 4)
+	assert.NoError(t, err)
+	if assert.NotNil(t, version) {
+		assert.Equal(t, "doc1", version.Content.GetGUID())
+		assert.Equal(t, []string{"customer-guid"}, version.Customers)
+	}
+	version, err = GetDocVersion[*types.CustomerConfig](c, "doc1", 1)
+	assert.NoError(t, err)
+	assert.Nil(t, version)
+
+	//old versions are removed by max age
+	insert(c, "doc3", 1, now.Add(-48*time.Hour), types.VersionRetention{})
+	insert(c, "doc3", 2, now.Add(-time.Hour), types.VersionRetention{})
+	insert(c, "doc3", 3, now, types.VersionRetention{MaxAge: 24 * time.Hour})
+	assert.Equal(t, []int64{3, 2}, versionNumbers(c, "doc3"))
+}
@@ -0,0 +1,450 @@
+package db
+
+import (
+	"config-service/db/store"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	WatchInsert = "insert"
+	WatchUpdate = "update"
+	WatchDelete = "delete"
+	// WatchReset is sent when the watch cannot resume from the last event id, the documents should be reloaded
+	WatchReset = "reset"
+
+	pollEventIDPrefix = "poll-"
+	//number of latest polled events kept to resume from
+	pollHistorySize = 1000
+	//pollers without watchers are stopped after the idle timeout, watchers that reconnect before it can resume
+	pollerIdleTimeout = time.Minute
+	//change stream errors codes of change streams that are not supported or cannot resume
+	notReplicaSetErrorCode           = 40573
+	changeStreamFatalErrorCode       = 280
+	changeStreamHistoryLostErrorCode = 286
+)
+
+var watchPollInterval = 2 * time.Second
+
+// SetWatchPollInterval sets the interval of polling the documents of collections without change streams
+func SetWatchPollInterval(interval time.Duration) {
+	if interval > 0 {
+		watchPollInterval = interval
+	}
+}
+
+// WatchForCustomer sends the changes of the customer documents that match the filter to events until the context is done
+// changes are read from a change stream when the collection supports it, otherwise the documents are polled
+// lastEventID resumes after a previously sent event, a reset event is sent if the watch cannot resume from it
+func WatchForCustomer[T types.DocContent](c context.Context, filter *FilterBuilder, lastEventID string, events chan<- types.ChangeEvent[T]) error {
+	defer log.LogNTraceEnterExit("WatchForCustomer", c)()
+	collection, customerGUID, err := ReadContext(c)
+	if err != nil {
+		return err
+	}
+	customerFilter := NewFilterBuilder().WithCustomer(c)
+	if filter != nil {
+		customerFilter.WithFilter(filter)
+	}
+	w := &watcher[T]{
+		collection:   collection,
+		customerGUID: customerGUID,
+		filter:       customerFilter,
+		projection:   NewProjectionBuilder().Exclude(GetSchemaFromContext(c).MustExcludeFields...),
+		events:       events,
+	}
+	if changeStreamCollection, ok := getReadCollection(collection).(store.ChangeStreamCollection); ok && !strings.HasPrefix(lastEventID, pollEventIDPrefix) {
+		err := w.watchChangeStream(c, changeStreamCollection, lastEventID)
+		if !isServerError(err, notReplicaSetErrorCode) {
+			return err
+		}
+		log.LogNTrace("change streams are not supported, polling documents", c)
+	}
+	return w.watchPolling(c, lastEventID)
+}
+
+type watcher[T types.DocContent] struct {
+	collection   string
+	customerGUID string
+	filter       *FilterBuilder
+	projection   *ProjectionBuilder
+	events       chan<- types.ChangeEvent[T]
+}
+
+func (w *watcher[T]) send(c context.Context, event types.ChangeEvent[T]) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-c.Done():
+		return false
+	}
+}
+
+// watchChangeStream sends the changes read from the collection change stream, the event ids are the stream resume tokens
+// documents deleted while the watcher was disconnected are not sent when resuming
+func (w *watcher[T]) watchChangeStream(c context.Context, collection store.ChangeStreamCollection, resumeToken string) error {
+	//the customer documents that match the filter, used to send only deletes of documents the watcher can see
+	known, err := findRevisions(c, w.collection, w.filter.get())
+	if err != nil {
+		return err
+	}
+	pipeline := mongoDB.Pipeline{{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "fullDocument." + consts.CustomersField, Value: w.customerGUID}},
+		bson.D{{Key: "operationType", Value: WatchDelete}},
+	}}}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != "" {
+		opts.SetResumeAfter(bson.D{{Key: "_data", Value: resumeToken}})
+	}
+	stream, err := collection.Watch(c, pipeline, opts)
+	if resumeToken != "" && (isServerError(err, changeStreamHistoryLostErrorCode) || isServerError(err, changeStreamFatalErrorCode)) {
+		if !w.send(c, types.ChangeEvent[T]{Operation: WatchReset}) {
+			return nil
+		}
+		stream, err = collection.Watch(c, pipeline, opts.SetResumeAfter(nil))
+	}
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(c) {
+		var change struct {
+			OperationType string `bson:"operationType"`
+			DocumentKey   struct {
+				ID string `bson:"_id"`
+			} `bson:"documentKey"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		event := types.ChangeEvent[T]{ID: stream.ResumeToken().Lookup("_data").StringValue(), DocGUID: change.DocumentKey.ID}
+		_, wasKnown := known[event.DocGUID]
+		if change.OperationType == WatchDelete {
+			if !wasKnown {
+				continue
+			}
+			event.Operation = WatchDelete
+		} else {
+			//read the document to check it still matches the filter (e.g. not moved to the trash)
+			doc, revision, err := w.findMatching(c, event.DocGUID)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				if !wasKnown {
+					continue
+				}
+				event.Operation = WatchDelete
+			} else {
+				event.Operation, event.Document, event.Revision = WatchUpdate, *doc, revision
+				if !wasKnown {
+					event.Operation = WatchInsert
+				}
+			}
+		}
+		if event.Operation == WatchDelete {
+			delete(known, event.DocGUID)
+		} else {
+			known[event.DocGUID] = event.Revision
+		}
+		if !w.send(c, event) {
+			return nil
+		}
+	}
+	if c.Err() != nil {
+		return nil
+	}
+	return stream.Err()
+}
+
+// watchPolling sends the changes found by polling the documents, watchers of the same documents share the poller
+func (w *watcher[T]) watchPolling(c context.Context, lastEventID string) error {
+	p, err := getPoller(c, w.collection, w.filter.get(), w.projection.get())
+	if err != nil {
+		return err
+	}
+	defer p.release()
+	seq, ok := p.resumeSeq(lastEventID)
+	if !ok && !w.send(c, types.ChangeEvent[T]{Operation: WatchReset}) {
+		return nil
+	}
+	for {
+		events, changed := p.eventsAfter(seq)
+		for _, polled := range events {
+			seq = polled.seq
+			event := types.ChangeEvent[T]{
+				ID:        p.eventID(polled.seq),
+				Operation: polled.operation,
+				DocGUID:   polled.docGUID,
+				Revision:  polled.revision,
+			}
+			if polled.doc != nil {
+				if err := bson.Unmarshal(polled.doc, &event.Document); err != nil {
+					return err
+				}
+			}
+			if !w.send(c, event) {
+				return nil
+			}
+		}
+		select {
+		case <-changed:
+		case <-c.Done():
+			return nil
+		}
+	}
+}
+
+// findMatching returns a document by id if it matches the watcher filter
+func (w *watcher[T]) findMatching(c context.Context, id string) (*T, int64, error) {
+	filter := NewFilterBuilder().WithValue("$and", bson.A{w.filter.get()}).WithValue(consts.IdField, id)
+	var doc T
+	revision, err := decodeWithRevision(getReadCollection(w.collection).FindOne(c, filter.get(), options.FindOne().SetProjection(w.projection.get())), &doc)
+	if err == mongoDB.ErrNoDocuments {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	return &doc, revision, nil
+}
+
+// findRevisions returns the revisions of the documents that match the filter by id
+func findRevisions(c context.Context, collection string, filter bson.D) (map[string]int64, error) {
+	projection := NewProjectionBuilder().Include(consts.IdField, consts.RevisionField)
+	cur, err := getReadCollection(collection).Find(c, filter, options.Find().SetProjection(projection.get()))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	var results []struct {
+		ID       string `bson:"_id"`
+		Revision int64  `bson:"revision"`
+	}
+	if err := cur.All(c, &results); err != nil {
+		return nil, err
+	}
+	revisions := make(map[string]int64, len(results))
+	for _, result := range results {
+		revisions[result.ID] = result.Revision
+	}
+	return revisions, nil
+}
+
+func isServerError(err error, code int) bool {
+	var serverErr mongoDB.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(code)
+}
+
+// pollers share the polling of documents between the watchers of the pod and keep the latest events so watchers can resume after reconnecting
+var (
+	pollers      = map[string]*poller{}
+	pollersMutex sync.Mutex
+)
+
+type polledEvent struct {
+	seq       int64
+	operation string
+	docGUID   string
+	revision  int64
+	doc       bson.Raw
+}
+
+type poller struct {
+	key        string
+	id         string //unique id of the poller, a part of the event ids so events of stopped pollers are not resumed
+	collection string
+	filter     bson.D
+	projection bson.D
+	mutex      sync.Mutex
+	changed    chan struct{}    //closed when events are added
+	revisions  map[string]int64 //the revisions of the documents in the last poll by id
+	events     []polledEvent
+	lastSeq    int64
+	watchers   int
+	idleSince  time.Time
+}
+
+// getPoller returns the running poller of the filtered documents or starts a new one
+func getPoller(c context.Context, collection string, filter, projection bson.D) (*poller, error) {
+	key := fmt.Sprintf("%s/%v/%v", collection, filter, projection)
+	pollersMutex.Lock()
+	defer pollersMutex.Unlock()
+	if p, ok := pollers[key]; ok {
+		p.mutex.Lock()
+		p.watchers++
+		p.mutex.Unlock()
+		return p, nil
+	}
+	//the first poll is the base for the changes
+	revisions, err := findRevisions(c, collection, filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &poller{
+		key:        key,
+		id:         strings.ReplaceAll(uuid.NewV4().String(), "-", ""),
+		collection: collection,
+		filter:     filter,
+		projection: projection,
+		changed:    make(chan struct{}),
+		revisions:  revisions,
+		watchers:   1,
+	}
+	pollers[key] = p
+	go p.run()
+	return p, nil
+}
+
+func (p *poller) release() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.watchers--
+	if p.watchers == 0 {
+		p.idleSince = time.Now()
+	}
+}
+
+func (p *poller) run() {
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pollersMutex.Lock()
+		p.mutex.Lock()
+		idle := p.watchers == 0 && time.Since(p.idleSince) > pollerIdleTimeout
+		p.mutex.Unlock()
+		if idle {
+			delete(pollers, p.key)
+			pollersMutex.Unlock()
+			return
+		}
+		pollersMutex.Unlock()
+		if err := p.poll(context.Background()); err != nil {
+			zap.L().Error("failed to poll watched documents", zap.Error(err), zap.String("collection", p.collection))
+		}
+	}
+}
+
+// poll compares the documents revisions to the last poll and adds events of the inserted, updated and deleted documents
+func (p *poller) poll(c context.Context) error {
+	revisions, err := findRevisions(c, p.collection, p.filter)
+	if err != nil {
+		return err
+	}
+	changedIDs := []string{}
+	for id, revision := range revisions {
+		if oldRevision, ok := p.revisions[id]; !ok || oldRevision != revision {
+			changedIDs = append(changedIDs, id)
+		}
+	}
+	sort.Strings(changedIDs)
+	docs := map[string]bson.Raw{}
+	if len(changedIDs) > 0 {
+		filter := NewFilterBuilder().WithValue("$and", bson.A{p.filter}).WithIn(consts.IdField, changedIDs)
+		cur, err := getReadCollection(p.collection).Find(c, filter.get(), options.Find().SetProjection(p.projection))
+		if err != nil {
+			return err
+		}
+		defer cur.Close(c)
+		for cur.Next(c) {
+			doc := append(bson.Raw{}, cur.Current...)
+			docs[doc.Lookup(consts.IdField).StringValue()] = doc
+		}
+		if err := cur.Err(); err != nil {
+			return err
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	added := false
+	for _, id := range changedIDs {
+		doc, ok := docs[id]
+		if !ok {
+			//changed after the revisions were read, the next poll will find it
+			delete(revisions, id)
+			continue
+		}
+		operation := WatchUpdate
+		if _, ok := p.revisions[id]; !ok {
+			operation = WatchInsert
+		}
+		p.addEvent(polledEvent{operation: operation, docGUID: id, revision: revisions[id], doc: doc})
+		added = true
+	}
+	deletedIDs := []string{}
+	for id := range p.revisions {
+		if _, ok := revisions[id]; !ok {
+			deletedIDs = append(deletedIDs, id)
+		}
+	}
+	sort.Strings(deletedIDs)
+	for _, id := range deletedIDs {
+		p.addEvent(polledEvent{operation: WatchDelete, docGUID: id})
+		added = true
+	}
+	p.revisions = revisions
+	if added {
+		close(p.changed)
+		p.changed = make(chan struct{})
+	}
+	return nil
+}
+
+func (p *poller) addEvent(event polledEvent) {
+	p.lastSeq++
+	event.seq = p.lastSeq
+	p.events = append(p.events, event)
+	if len(p.events) > pollHistorySize {
+		p.events = p.events[len(p.events)-pollHistorySize:]
+	}
+}
+
+// eventsAfter returns the events after seq and a channel that is closed when new events are added
+func (p *poller) eventsAfter(seq int64) ([]polledEvent, <-chan struct{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	events := []polledEvent{}
+	for _, event := range p.events {
+		if event.seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, p.changed
+}
+
+// resumeSeq returns the sequence to resume the events after, false if the watch cannot resume from the event id
+func (p *poller) resumeSeq(lastEventID string) (int64, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if lastEventID == "" {
+		return p.lastSeq, true
+	}
+	seqStr, ok := strings.CutPrefix(lastEventID, pollEventIDPrefix+p.id+"-")
+	if !ok {
+		return p.lastSeq, false
+	}
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil || seq > p.lastSeq || (len(p.events) > 0 && seq < p.events[0].seq-1) || (len(p.events) == 0 && seq != p.lastSeq) {
+		return p.lastSeq, false
+	}
+	return seq, true
+}
+
+func (p *poller) eventID(seq int64) string {
+	return fmt.Sprintf("%s%s-%d", pollEventIDPrefix, p.id, seq)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDB "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionsField     = "collections"
+	eventTypesField      = "eventTypes"
+	statusField          = "status"
+	attemptsField        = "attempts"
+	nextAttemptTimeField = "nextAttemptTime"
+)
+
+// GetWebhookSubscriptions returns the customer subscriptions to the events of the collection documents of the event type
+func GetWebhookSubscriptions(c context.Context, customerGUID, collection, eventType string) ([]*types.WebhookSubscription, error) {
+	defer log.LogNTraceEnterExit("GetWebhookSubscriptions", c)()
+	filter := NewFilterBuilder().
+		WithCustomers([]string{customerGUID}).
+		WithValue(collectionsField, collection).
+		WithValue(eventTypesField, eventType)
+	cur, err := getReadCollection(consts.WebhookSubscriptionsCollection).Find(c, filter.get())
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	subscriptions := []*types.WebhookSubscription{}
+	if err := cur.All(c, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// GetWebhookSubscription returns a subscription by GUID, nil if it does not exist
+func GetWebhookSubscription(c context.Context, guid string) (*types.WebhookSubscription, error) {
+	defer log.LogNTraceEnterExit("GetWebhookSubscription", c)()
+	var subscription types.WebhookSubscription
+	if err := getReadCollection(consts.WebhookSubscriptionsCollection).FindOne(c, NewFilterBuilder().WithID(guid).get()).Decode(&subscription); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// InsertWebhookDeliveries adds deliveries of a customer to the delivery queue
+// deliveries with existing GUIDs are skipped so the same event is delivered once to a subscription
+func InsertWebhookDeliveries(c context.Context, customerGUID string, deliveries []*types.WebhookDelivery) error {
+	defer log.LogNTraceEnterExit("InsertWebhookDeliveries", c)()
+	return insertWebhookDeliveries(c, consts.WebhookDeliveriesCollection, customerGUID, deliveries)
+}
+
+// InsertWebhookDeadLetter copies a delivery that failed all its attempts to the dead letters
+func InsertWebhookDeadLetter(c context.Context, customerGUID string, delivery *types.WebhookDelivery) error {
+	defer log.LogNTraceEnterExit("InsertWebhookDeadLetter", c)()
+	deadLetter := *delivery
+	deadLetter.ExpiryTime = nil
+	return insertWebhookDeliveries(c, consts.WebhookDeadLettersCollection, customerGUID, []*types.WebhookDelivery{&deadLetter})
+}
+
+func insertWebhookDeliveries(c context.Context, collection, customerGUID string, deliveries []*types.WebhookDelivery) error {
+	for _, delivery := range deliveries {
+		doc := types.Document[*types.WebhookDelivery]{
+			ID:        delivery.GUID,
+			Customers: []string{customerGUID},
+			Revision:  1,
+			Content:   delivery,
+		}
+		if _, err := getWriteCollection(collection).InsertOne(c, doc); err != nil && !IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimWebhookDelivery returns the pending delivery with the earliest due attempt and counts the attempt
+// the next attempt of the claimed delivery is postponed by the lease so other workers do not claim it while it is delivered
+func ClaimWebhookDelivery(c context.Context, lease time.Duration) (*types.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	filter := NewFilterBuilder().
+		WithValue(statusField, types.WebhookDeliveryPending).
+		WithLowerThanEqual(nextAttemptTimeField, now)
+	update := withRevisionInc(bson.D{
+		{Key: "$set", Value: bson.D{{Key: nextAttemptTimeField, Value: now.Add(lease)}}},
+		{Key: "$inc", Value: bson.D{{Key: attemptsField, Value: 1}}},
+	})
+	findOpts := options.FindOneAndUpdate().
+		SetSort(NewSortBuilder().AddAscending(nextAttemptTimeField).get()).
+		SetReturnDocument(options.After)
+	var delivery types.WebhookDelivery
+	if err := getWriteCollection(consts.WebhookDeliveriesCollection).FindOneAndUpdate(c, filter.get(), update, findOpts).Decode(&delivery); err != nil {
+		if err == mongoDB.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// UpdateWebhookDelivery saves the result of a delivery attempt
+func UpdateWebhookDelivery(c context.Context, delivery *types.WebhookDelivery) error {
+	update := withRevisionInc(bson.D{{Key: "$set", Value: bson.D{
+		{Key: "url", Value: delivery.URL},
+		{Key: statusField, Value: delivery.Status},
+		{Key: nextAttemptTimeField, Value: delivery.NextAttemptTime},
+		{Key: "lastAttemptTime", Value: delivery.LastAttemptTime},
+		{Key: "lastStatusCode", Value: delivery.LastStatusCode},
+		{Key: "lastError", Value: delivery.LastError},
+		{Key: "deliveredTime", Value: delivery.DeliveredTime},
+	}}})
+	_, err := getWriteCollection(consts.WebhookDeliveriesCollection).UpdateOne(c, NewFilterBuilder().WithID(delivery.GUID).get(), update)
+	return err
+}
+
+// FindExpiredDocs returns the documents of all customers with expiration time in the field after from and up to to
+func FindExpiredDocs(c context.Context, collection, field string, from, to time.Time) ([]bson.M, error) {
+	defer log.LogNTraceEnterExit("FindExpiredDocs", c)()
+	filter := NewFilterBuilder().
+		WithValue(field, bson.D{{Key: "$gt", Value: from}, {Key: "$lte", Value: to}}).
+		withNotDeletedIn(collection)
+	cur, err := getReadCollection(collection).Find(c, filter.get())
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(c)
+	docs := []bson.M{}
+	if err := cur.All(c, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"config-service/types"
+	"config-service/utils/consts"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) TestExport() {
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	newClusters := testBulkPostDocs(suite, consts.ClusterPath, clusters, newClusterCompareFilter)
+	exportPath := consts.ClusterPath + "/export"
+
+	//NDJSON is the default format
+	w := suite.doRequest(http.MethodPost, exportPath, armotypes.V2ListRequest{OrderBy: "name:asc"})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Equal(consts.NDJSONContentType, w.Header().Get("Content-Type"))
+	exported := []*types.Cluster{}
+	scanner := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+	for scanner.Scan() {
+		var cluster *types.Cluster
+		suite.NoError(json.Unmarshal(scanner.Bytes(), &cluster))
+		exported = append(exported, cluster)
+	}
+	suite.Equal(newClusters, exported)
+
+	//CSV columns of the included fields, the page size is ignored
+	pageSize := 1
+	req := armotypes.V2ListRequest{OrderBy: "name:desc", PageSize: &pageSize, FieldsList: []string{"name", "attributes.alias", "attributes.clusterAPIServerInfo.major", "missing"}}
+	w = suite.doRequestWithHeaders(http.MethodPost, exportPath, req, map[string]string{"Accept": consts.CSVContentType})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Equal(consts.CSVContentType, w.Header().Get("Content-Type"))
+	rows, err := csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+	suite.NoError(err)
+	expectedRows := [][]string{req.FieldsList}
+	for i := len(newClusters) - 1; i >= 0; i-- {
+		major := newClusters[i].Attributes["clusterAPIServerInfo"].(map[string]interface{})["major"].(string)
+		expectedRows = append(expectedRows, []string{newClusters[i].Name, newClusters[i].Attributes[consts.ShortNameAttribute].(string), major, ""})
+	}
+	suite.Equal(expectedRows, rows)
+	//objects are JSON cells
+	req = armotypes.V2ListRequest{FieldsList: []string{"attributes.clusterAPIServerInfo"}, InnerFilters: []map[string]string{{"name": newClusters[0].Name}}}
+	w = suite.doRequestWithHeaders(http.MethodPost, exportPath, req, map[string]string{"Accept": consts.CSVContentType})
+	rows, err = csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+	suite.NoError(err)
+	if suite.Len(rows, 2) {
+		suite.JSONEq(mustMarshal(suite, newClusters[0].Attributes["clusterAPIServerInfo"]), rows[1][0])
+	}
+	//no matching documents
+	req = armotypes.V2ListRequest{FieldsList: []string{"name"}, InnerFilters: []map[string]string{{"name": "not-exists"}}}
+	w = suite.doRequestWithHeaders(http.MethodPost, exportPath, req, map[string]string{"Accept": consts.CSVContentType})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal("name\n", w.Body.String())
+
+	//bad requests
+	w = suite.doRequestWithHeaders(http.MethodPost, exportPath, armotypes.V2ListRequest{}, map[string]string{"Accept": consts.CSVContentType})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Equal(`{"error":"includeFields are required for CSV export"}`, w.Body.String())
+	w = suite.doRequestWithHeaders(http.MethodPost, exportPath, armotypes.V2ListRequest{}, map[string]string{"Accept": "application/xml"})
+	suite.Equal(http.StatusNotAcceptable, w.Code)
+
+	testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, []string{newClusters[0].GUID, newClusters[1].GUID, newClusters[2].GUID})
+}
+
+func mustMarshal(suite *MainTestSuite, v interface{}) string {
+	data, err := json.Marshal(v)
+	suite.NoError(err)
+	return string(data)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditDocMutation records a mutation of a customer document with the diff between the old and new document
+// a nil old document is a creation and a nil new document is a deletion
+func AuditDocMutation(c *gin.Context, docGUID string, oldDoc, newDoc interface{}) {
+	event := newAuditEvent(c)
+	event.DocGUID = docGUID
+	diff, err := diffDocs(oldDoc, newDoc)
+	if err != nil {
+		log.LogNTraceError("failed to diff audited document", err, c)
+	}
+	event.Diff = diff
+	saveAuditEvent(c, event, []string{c.GetString(consts.CustomerGUID)})
+}
+
+// AuditBulkMutation records a mutation of the documents matching the query
+// customers are the owners of the mutated documents and empty when documents of all customers can be mutated
+func AuditBulkMutation(c *gin.Context, query interface{}, count int64, customers []string) {
+	event := newAuditEvent(c)
+	event.Count = count
+	if queryJSON, err := json.Marshal(query); err != nil {
+		log.LogNTraceError("failed to marshal audited query", err, c)
+	} else {
+		event.Query = string(queryJSON)
+	}
+	if customers == nil {
+		customers = []string{}
+	}
+	saveAuditEvent(c, event, customers)
+}
+
+// AuditJobMutation records a mutation of the documents matching the query by a background job
+// the event has the actor and path of the request that started the job
+func AuditJobMutation(c context.Context, job *types.Job, collection string, query interface{}, count int64, customers []string) {
+	event := &types.AuditEvent{
+		Actor:        job.Actor,
+		CustomerGUID: job.CustomerGUID,
+		Path:         job.Path,
+		Collection:   collection,
+		Verb:         job.Verb,
+		Count:        count,
+	}
+	event.Attributes = map[string]interface{}{"jobGUID": job.GUID}
+	if queryJSON, err := json.Marshal(query); err != nil {
+		log.LogNTraceError("failed to marshal audited query", err, c)
+	} else {
+		event.Query = string(queryJSON)
+	}
+	if customers == nil {
+		customers = []string{}
+	}
+	if err := db.InsertAuditEvent(c, event, customers); err != nil {
+		log.LogNTraceError("failed to save audit event", err, c)
+	}
+}
+
+// actorFromContext returns the email of the user or the customer GUID when the user email is unknown
+func actorFromContext(c *gin.Context) string {
+	if actor := c.GetString(consts.UserEmail); actor != "" {
+		return actor
+	}
+	return c.GetString(consts.CustomerGUID)
+}
+
+func newAuditEvent(c *gin.Context) *types.AuditEvent {
+	return &types.AuditEvent{
+		Actor:        actorFromContext(c),
+		CustomerGUID: c.GetString(consts.CustomerGUID),
+		Path:         c.Request.URL.Path,
+		Collection:   c.GetString(consts.Collection),
+		Verb:         c.Request.Method,
+	}
+}
+
+// saveAuditEvent writes the event, failures are logged and do not fail the audited request
+func saveAuditEvent(c *gin.Context, event *types.AuditEvent, customers []string) {
+	if err := db.InsertAuditEvent(c, event, customers); err != nil {
+		log.LogNTraceError("failed to save audit event", err, c)
+	}
+}
+
+// diffDocs returns the changed fields between the JSON representations of the documents, a nil document has no fields
+func diffDocs(oldDoc, newDoc interface{}) ([]types.AuditChange, error) {
+	oldFields, err := jsonFields(oldDoc)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := jsonFields(newDoc)
+	if err != nil {
+		return nil, err
+	}
+	changes := []types.AuditChange{}
+	return diffFields("", oldFields, newFields, changes), nil
+}
+
+func jsonFields(doc interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffFields compares objects field by field, other values (including arrays) are compared as a whole
+func diffFields(prefix string, oldFields, newFields map[string]interface{}, changes []types.AuditChange) []types.AuditChange {
+	keys := make([]string, 0, len(oldFields)+len(newFields))
+	for key := range oldFields {
+		keys = append(keys, key)
+	}
+	for key := range newFields {
+		if _, ok := oldFields[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := prefix + key
+		oldValue, inOld := oldFields[key]
+		newValue, inNew := newFields[key]
+		oldObject, oldIsObject := oldValue.(map[string]interface{})
+		newObject, newIsObject := newValue.(map[string]interface{})
+		if oldIsObject && newIsObject {
+			changes = diffFields(path+".", oldObject, newObject, changes)
+			continue
+		}
+		if inOld && inNew && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		change := types.AuditChange{Path: path}
+		if inOld {
+			change.Old, _ = json.Marshal(oldValue)
+		}
+		if inNew {
+			change.New, _ = json.Marshal(newValue)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"config-service/types"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDocs(t *testing.T) {
+	type doc struct {
+		Name       string                 `json:"name"`
+		Tags       []string               `json:"tags,omitempty"`
+		Attributes map[string]interface{} `json:"attributes,omitempty"`
+	}
+	raw := func(v string) json.RawMessage { return json.RawMessage(v) }
+	tests := []struct {
+		name   string
+		oldDoc interface{}
+		newDoc interface{}
+		want   []types.AuditChange
+	}{
+		{
+			name:   "no changes",
+			oldDoc: doc{Name: "a", Tags: []string{"x"}},
+			newDoc: &doc{Name: "a", Tags: []string{"x"}},
+			want:   []types.AuditChange{},
+		},
+		{
+			name:   "creation",
+			newDoc: &doc{Name: "a", Tags: []string{"x"}},
+			want: []types.AuditChange{
+				{Path: "name", New: raw(`"a"`)},
+				{Path: "tags", New: raw(`["x"]`)},
+			},
+		},
+		{
+			name:   "deletion of a nil pointer",
+			oldDoc: &doc{Name: "a"},
+			newDoc: (*doc)(nil),
+			want:   []types.AuditChange{{Path: "name", Old: raw(`"a"`)}},
+		},
+		{
+			name:   "nested fields and arrays",
+			oldDoc: doc{Name: "a", Tags: []string{"x"}, Attributes: map[string]interface{}{"keep": 1, "change": "old", "remove": true}},
+			newDoc: doc{Name: "a", Tags: []string{"x", "y"}, Attributes: map[string]interface{}{"keep": 1, "change": "new", "add": 2}},
+			want: []types.AuditChange{
+				{Path: "attributes.add", New: raw(`2`)},
+				{Path: "attributes.change", Old: raw(`"old"`), New: raw(`"new"`)},
+				{Path: "attributes.remove", Old: raw(`true`)},
+				{Path: "tags", Old: raw(`["x"]`), New: raw(`["x","y"]`)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffDocs(tt.oldDoc, tt.newDoc)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
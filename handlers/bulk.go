@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const invalidDocument = "invalid document"
+
+// HandleBulkPutDocs - updates the documents of the request body, each document is validated by the put validators
+// the valid documents are updated in a transaction when the store supports transactions, otherwise each update is applied on its own
+// the response has the result of each document: updated, not found, invalid or failed (only without a transaction)
+func HandleBulkPutDocs[T types.DocContent](validators ...MutatorValidator[T]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer log.LogNTraceEnterExit("HandleBulkPutDocs", c)()
+		if _, ok := c.Get(consts.IfMatch); ok {
+			ResponseBadRequest(c, "If-Match is not supported in bulk requests")
+			return
+		}
+		//routes with a custom body decoder do not serve bulk put
+		var docs []T
+		if err := c.ShouldBindJSON(&docs); err != nil {
+			ResponseFailedToBindJson(c, err)
+			return
+		}
+		if len(docs) == 0 {
+			ResponseBadRequest(c, "no documents in request")
+			return
+		}
+
+		results := make([]types.BulkUpdateResult, len(docs))
+		updates := make([]bson.D, len(docs))
+		for i := range docs {
+			doc, status, errMsg := validateBulkDoc(c, docs[i], validators)
+			if errMsg == "" && doc.GetGUID() == "" {
+				errMsg = fmt.Sprintf(MissingKey, consts.GUIDField)
+			}
+			if status == http.StatusNotFound {
+				results[i] = types.BulkUpdateResult{GUID: docs[i].GetGUID(), Status: types.BulkStatusNotFound}
+				continue
+			} else if errMsg != "" {
+				results[i] = types.BulkUpdateResult{GUID: docs[i].GetGUID(), Status: types.BulkStatusInvalid, Error: errMsg}
+				continue
+			}
+			docs[i] = doc
+			results[i].GUID = doc.GetGUID()
+			doc.SetUpdatedTime(nil)
+			update, err := db.GetUpdateDocCommand(doc, GetCustomPutFields(c), doc.GetReadOnlyFields()...)
+			if err != nil {
+				if db.IsNoFieldsToUpdateError(err) {
+					results[i].Status, results[i].Error = types.BulkStatusInvalid, "no fields to update"
+					continue
+				}
+				ResponseInternalServerError(c, "failed to generate update command", err)
+				return
+			}
+			updates[i] = update
+		}
+
+		type mutation struct {
+			guid     string
+			oldDoc   T
+			newDoc   T
+			revision int64
+		}
+		var mutations []mutation
+		transactional := db.SupportsTransactions()
+		err := db.WithTransaction(c, func(tc context.Context) error {
+			//the transaction may be retried
+			mutations = mutations[:0]
+			for i, update := range updates {
+				if update == nil {
+					continue
+				}
+				res, revision, err := db.UpdateDocumentWithRevision[T](tc, results[i].GUID, update)
+				switch {
+				case err != nil && transactional:
+					return err
+				case err != nil:
+					log.LogNTraceError("failed to update document", err, c)
+					results[i].Status, results[i].Error = types.BulkStatusFailed, err.Error()
+				case res == nil:
+					results[i].Status, results[i].Revision = types.BulkStatusNotFound, 0
+				default:
+					results[i].Status, results[i].Revision = types.BulkStatusUpdated, revision
+					mutations = append(mutations, mutation{guid: results[i].GUID, oldDoc: res[0], newDoc: res[1], revision: revision})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			ResponseInternalServerError(c, "failed to update documents", err)
+			return
+		}
+		//record the committed updates
+		for _, m := range mutations {
+			saveDocVersion(c, m.guid, m.oldDoc, m.revision-1)
+			AuditDocMutation(c, m.guid, m.oldDoc, m.newDoc)
+			PublishDocMutation(c, m.guid, m.oldDoc, m.newDoc)
+		}
+		c.JSON(http.StatusOK, types.BulkUpdateResponse{Transactional: transactional, Results: results})
+	}
+}
+
+// validateBulkDoc runs the validators on a document of a bulk request and returns the validated document or the status and error of the validation response
+// the validators respond to a copy of the request context so a failed document does not fail the request
+func validateBulkDoc[T types.DocContent](c *gin.Context, doc T, validators []MutatorValidator[T]) (T, int, string) {
+	docContext := c.Copy()
+	writer := &bulkDocResponseWriter{ResponseWriter: c.Writer}
+	docContext.Writer = writer
+	docs := []T{doc}
+	for _, validator := range validators {
+		var ok bool
+		if docs, ok = validator.validate(docContext, docs); !ok || len(docs) != 1 {
+			var invalid T
+			return invalid, writer.Status(), writer.errorMessage()
+		}
+	}
+	return docs[0], http.StatusOK, ""
+}
+
+// bulkDocResponseWriter keeps the response of a document validation instead of writing it
+type bulkDocResponseWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bulkDocResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bulkDocResponseWriter) WriteHeaderNow() {}
+
+func (w *bulkDocResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bulkDocResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bulkDocResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *bulkDocResponseWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bulkDocResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+// errorMessage returns the error of the validation response
+func (w *bulkDocResponseWriter) errorMessage() string {
+	var response struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &response); err == nil && response.Error != "" {
+		return response.Error
+	}
+	return invalidDocument
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"config-service/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBulkDoc(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/bulk", nil)
+	setName := func(c *gin.Context, docs []*types.Cluster) ([]*types.Cluster, bool) {
+		docs[0].Name = "validated"
+		return docs, true
+	}
+	requireGUID := func(c *gin.Context, docs []*types.Cluster) ([]*types.Cluster, bool) {
+		if docs[0].GUID == "" {
+			ResponseMissingGUID(c)
+			return nil, false
+		}
+		return docs, true
+	}
+	notFound := func(c *gin.Context, docs []*types.Cluster) ([]*types.Cluster, bool) {
+		ResponseDocumentNotFound(c)
+		return nil, false
+	}
+	silent := func(c *gin.Context, docs []*types.Cluster) ([]*types.Cluster, bool) {
+		return docs, false
+	}
+
+	doc, status, errMsg := validateBulkDoc(c, &types.Cluster{PortalBase: armotypes.PortalBase{GUID: "1"}}, []MutatorValidator[*types.Cluster]{setName, requireGUID})
+	assert.Equal(t, "validated", doc.Name)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, errMsg)
+
+	doc, status, errMsg = validateBulkDoc(c, &types.Cluster{}, []MutatorValidator[*types.Cluster]{setName, requireGUID})
+	assert.Nil(t, doc)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "guid is required", errMsg)
+
+	_, status, _ = validateBulkDoc(c, &types.Cluster{}, []MutatorValidator[*types.Cluster]{notFound})
+	assert.Equal(t, http.StatusNotFound, status)
+
+	_, _, errMsg = validateBulkDoc(c, &types.Cluster{}, []MutatorValidator[*types.Cluster]{silent})
+	assert.Equal(t, invalidDocument, errMsg)
+
+	//the failed validations do not respond to the request
+	assert.False(t, c.IsAborted())
+	assert.Empty(t, w.Body.String())
+}
+
+func TestServeBulkPutOptions(t *testing.T) {
+	withPutFields := func() *RouterOptionsBuilder[*types.Customer] {
+		return NewRouterOptionsBuilder[*types.Customer]().
+			WithDBCollection("test").
+			WithPath("/test").
+			WithPutFields([]string{"state"})
+	}
+	//bulk put is off by default
+	opts := newRouterOptions[*types.Customer]()
+	opts.apply(withPutFields().Get())
+	assert.NoError(t, opts.validate())
+
+	opts = newRouterOptions[*types.Customer]()
+	opts.apply(withPutFields().WithServeBulkPut(true).Get())
+	assert.EqualError(t, opts.validate(), "serveBulkPut can only be true when bodyDecoder and putFields are not set")
+
+	opts = newRouterOptions[*types.Customer]()
+	opts.apply(NewRouterOptionsBuilder[*types.Customer]().
+		WithDBCollection("test").
+		WithPath("/test").
+		WithBodyDecoder(func(c *gin.Context) ([]*types.Customer, error) { return nil, nil }).
+		WithServeBulkPut(true).Get())
+	assert.Error(t, opts.validate())
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bufio"
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// number of exported documents between flushes of the response
+const exportFlushInterval = 100
+
+// HandleExport - stream all the customer documents matching a V2ListRequest as NDJSON or CSV (by the Accept header)
+// the documents are read from the db cursor without a page size limit
+// CSV columns are the fields of the request includeFields, the must exclude fields of the schema are never exported
+func HandleExport[T types.DocContent](c *gin.Context) {
+	defer log.LogNTraceEnterExit("HandleExport", c)()
+	format := c.NegotiateFormat(consts.NDJSONContentType, consts.CSVContentType)
+	if format == "" {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"error": fmt.Sprintf("accept must be %s or %s", consts.NDJSONContentType, consts.CSVContentType)})
+		return
+	}
+	var req armotypes.V2ListRequest
+	if err := c.BindJSON(&req); err != nil {
+		ResponseFailedToBindJson(c, err)
+		return
+	}
+	req.FieldsList = exportFields(req.FieldsList, db.GetSchemaFromContext(c).GetMustExcludeFields())
+	if format == consts.CSVContentType && len(req.FieldsList) == 0 {
+		ResponseBadRequest(c, "includeFields are required for CSV export")
+		return
+	}
+	findOpts, err := V2List2FindOptionsNotPaginated(c, req)
+	if err != nil {
+		ResponseBadRequest(c, err.Error())
+		return
+	}
+
+	writer := bufio.NewWriter(c.Writer)
+	exported := 0
+	//the response starts with the first document, errors before it are responded with a status code
+	writeHeader := func() {
+		if exported == 0 {
+			c.Header("Content-Type", format)
+			c.Status(http.StatusOK)
+		}
+	}
+	flush := func() error {
+		exported++
+		if exported%exportFlushInterval != 0 {
+			return nil
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+	if format == consts.CSVContentType {
+		csvWriter := csv.NewWriter(writer)
+		err = db.ForEachForCustomer(c, findOpts, func(doc bson.Raw) error {
+			writeHeader()
+			if exported == 0 {
+				if err := csvWriter.Write(req.FieldsList); err != nil {
+					return err
+				}
+			}
+			row := make([]string, len(req.FieldsList))
+			for i, field := range req.FieldsList {
+				row[i] = csvValue(doc, field)
+			}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+			csvWriter.Flush()
+			return flush()
+		})
+		if err == nil && exported == 0 {
+			//only the header line
+			writeHeader()
+			if err = csvWriter.Write(req.FieldsList); err == nil {
+				csvWriter.Flush()
+			}
+		}
+	} else {
+		encoder := json.NewEncoder(writer)
+		err = db.ForEachForCustomer(c, findOpts, func(doc T) error {
+			writeHeader()
+			if err := encoder.Encode(doc); err != nil {
+				return err
+			}
+			return flush()
+		})
+		if err == nil && exported == 0 {
+			writeHeader()
+		}
+	}
+	if err != nil {
+		if exported == 0 {
+			ResponseInternalServerError(c, "failed to export documents", err)
+			return
+		}
+		//the status was sent, the client gets a truncated response
+		log.LogNTraceError("failed to export documents", err, c)
+		c.Abort()
+		return
+	}
+	if err := writer.Flush(); err != nil {
+		log.LogNTraceError("failed to write exported documents", err, c)
+	}
+}
+
+// exportFields returns the requested fields without the must exclude fields and their parents or sub fields
+func exportFields(fields []string, mustExclude []string) []string {
+	exportFields := []string{}
+	for _, field := range fields {
+		excluded := false
+		for _, exclude := range mustExclude {
+			if field == exclude || strings.HasPrefix(field, exclude+".") || strings.HasPrefix(exclude, field+".") {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			exportFields = append(exportFields, field)
+		}
+	}
+	return exportFields
+}
+
+// csvFormulaPrefixes are the first characters of the string cells that spreadsheets evaluate as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvValue returns the CSV cell of a document field, missing and null values are empty and objects and arrays are JSON
+// string cells that start like a formula are prefixed with ' so spreadsheets show them as text
+func csvValue(doc bson.Raw, field string) string {
+	value, err := doc.LookupErr(strings.Split(field, ".")...)
+	if err != nil {
+		return ""
+	}
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return ""
+	case bsontype.String:
+		if str := value.StringValue(); str != "" && strings.ContainsRune(csvFormulaPrefixes, rune(str[0])) {
+			return "'" + str
+		}
+		return value.StringValue()
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bsontype.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	case bsontype.DateTime:
+		return value.Time().UTC().Format(time.RFC3339Nano)
+	}
+	//decode objects to maps for JSON
+	raw, err := bson.Marshal(bson.D{{Key: "value", Value: value}})
+	if err != nil {
+		return value.String()
+	}
+	decoder, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(raw))
+	if err != nil {
+		return value.String()
+	}
+	decoder.DefaultDocumentM()
+	var cell struct {
+		Value interface{} `bson:"value"`
+	}
+	if err := decoder.Decode(&cell); err != nil {
+		return value.String()
+	}
+	data, err := json.Marshal(cell.Value)
+	if err != nil {
+		return value.String()
+	}
+	return string(data)
+}
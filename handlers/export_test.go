@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestExportFields(t *testing.T) {
+	mustExclude := []string{"secret", "spec.token"}
+	fields := []string{"name", "secret", "secret.value", "spec", "spec.token", "spec.url", "secretName"}
+	assert.Equal(t, []string{"name", "spec.url", "secretName"}, exportFields(fields, mustExclude))
+	assert.Equal(t, []string{}, exportFields(nil, mustExclude))
+}
+
+func TestCSVValue(t *testing.T) {
+	date := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	doc, err := bson.Marshal(bson.M{
+		"name":    "a,b",
+		"formula": "=HYPERLINK(\"http://x\")",
+		"signed":  "-1+2",
+		"mention": "@user",
+		"count":   int32(-3),
+		"size":    int64(1 << 40),
+		"score":   7.5,
+		"enabled": true,
+		"date":    date,
+		"null":    nil,
+		"attributes": bson.M{
+			"tags": bson.A{"x", int32(1)},
+			"nested": bson.M{
+				"key": "value",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	tests := map[string]string{
+		"name":                  "a,b",
+		"formula":               `'=HYPERLINK("http://x")`,
+		"signed":                "'-1+2",
+		"mention":               "'@user",
+		"count":                 "-3",
+		"size":                  "1099511627776",
+		"score":                 "7.5",
+		"enabled":               "true",
+		"date":                  "2024-05-01T10:30:00Z",
+		"null":                  "",
+		"missing":               "",
+		"attributes.tags":       `["x",1]`,
+		"attributes.nested":     `{"key":"value"}`,
+		"attributes.nested.key": "value",
+	}
+	for field, expected := range tests {
+		assert.Equal(t, expected, csvValue(doc, field), field)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"config-service/types"
 	"config-service/utils/consts"
 	"config-service/utils/log"
+	"context"
 	"fmt"
 	"net/http"
 
@@ -26,10 +27,11 @@ func HandleGetDocWithGUIDInPath[T types.DocContent](c *gin.Context) {
 		ResponseMissingGUID(c)
 		return
 	}
-	if doc, err := db.GetDocByGUID[T](c, guid); err != nil {
+	if doc, revision, err := db.GetDocByGUIDWithRevision[T](c, guid); err != nil {
 		ResponseInternalServerError(c, "failed to read document", err)
 		return
 	} else {
+		c.Set(consts.DocRevision, revision)
 		docResponse(c, doc)
 	}
 
@@ -162,7 +164,12 @@ func PostDocHandler[T types.DocContent](c *gin.Context, docs []T) {
 			return
 		}
 	} else {
+		for _, doc := range docs {
+			AuditDocMutation(c, doc.GetGUID(), nil, doc)
+			PublishDocMutation(c, doc.GetGUID(), nil, doc)
+		}
 		if len(docs) == 1 {
+			setETag(c, types.NewDocumentRevision)
 			c.JSON(http.StatusCreated, docs[0])
 		} else {
 			c.JSON(http.StatusCreated, docs)
@@ -171,7 +178,7 @@ func PostDocHandler[T types.DocContent](c *gin.Context, docs []T) {
 }
 
 func PostDBDocumentHandler[T types.DocContent](c *gin.Context, dbDoc types.Document[T]) {
-	if _, err := db.InsertDBDocument(c, dbDoc); err != nil {
+	if _, revision, err := db.InsertDBDocumentWithRevision(c, dbDoc); err != nil {
 		if db.IsDuplicateKeyError(err) {
 			ResponseConflict(c, consts.GUIDField)
 			return
@@ -179,6 +186,9 @@ func PostDBDocumentHandler[T types.DocContent](c *gin.Context, dbDoc types.Docum
 		ResponseInternalServerError(c, "failed to create document", err)
 		return
 	} else {
+		AuditDocMutation(c, dbDoc.Content.GetGUID(), nil, dbDoc.Content)
+		PublishDocMutation(c, dbDoc.Content.GetGUID(), nil, dbDoc.Content)
+		setETag(c, revision)
 		c.JSON(http.StatusCreated, dbDoc.Content)
 	}
 }
@@ -313,24 +323,37 @@ func HandlePutDocFromContext[T types.DocContent](c *gin.Context) {
 // PutDoc - helper to put document of type T, custom handler should use this function to do the final PUT handling
 func PutDocHandler[T types.DocContent](c *gin.Context, doc T) {
 	defer log.LogNTraceEnterExit("PutDocHandler", c)()
+	if res, revision, ok := updateDoc(c, doc); ok {
+		c.Set(consts.DocRevision, revision)
+		DocsResponse(c, res)
+	}
+}
+
+// updateDoc updates the document by its GUID, keeps the prior version and audits the update
+// returns the document before and after the update and its revision, on failure it responds with the error and returns false
+func updateDoc[T types.DocContent](c *gin.Context, doc T) ([]T, int64, bool) {
 	doc.SetUpdatedTime(nil)
 	update, err := db.GetUpdateDocCommand(doc, GetCustomPutFields(c), doc.GetReadOnlyFields()...)
 	if err != nil {
 		if db.IsNoFieldsToUpdateError(err) {
 			ResponseBadRequest(c, "no fields to update")
-			return
+			return nil, 0, false
 		}
 		ResponseInternalServerError(c, "failed to generate update command", err)
-		return
+		return nil, 0, false
 	}
-	if res, err := db.UpdateDocument[T](c, doc.GetGUID(), update); err != nil {
+	res, revision, err := db.UpdateDocumentWithRevision[T](c, doc.GetGUID(), update)
+	if err != nil {
 		ResponseInternalServerError(c, "failed to update document", err)
+		return nil, 0, false
 	} else if res == nil {
 		ResponseDocumentNotFound(c)
-		return
-	} else {
-		DocsResponse(c, res)
+		return nil, 0, false
 	}
+	saveDocVersion(c, doc.GetGUID(), res[0], revision-1)
+	AuditDocMutation(c, doc.GetGUID(), res[0], res[1])
+	PublishDocMutation(c, doc.GetGUID(), res[0], res[1])
+	return res, revision, true
 }
 
 // ////////////////////////////////////////DELETE///////////////////////////////////////////////
@@ -359,8 +382,10 @@ func HandleDeleteByQuery[T types.DocContent](c *gin.Context) {
 		return
 	}
 	filter := findOpts.Filter()
-	if deletedCount, err := db.BulkDelete[T](c, *filter); err != nil {
+	if deletedCount, err := bulkDelete[T](c, filter); err != nil {
+		ResponseInternalServerError(c, "failed to delete documents", err)
 	} else {
+		AuditBulkMutation(c, req, deletedCount, []string{c.GetString(consts.CustomerGUID)})
 		c.JSON(http.StatusOK, gin.H{"deletedCount": deletedCount})
 	}
 }
@@ -383,10 +408,12 @@ func HandleBulkDeleteWithGUIDs[T types.DocContent](c *gin.Context) {
 		return
 	}
 	filter := db.NewFilterBuilder().WithIDs(guids)
-	if deletedCount, err := db.BulkDelete[T](c, *filter); err != nil {
+	if deletedCount, err := bulkDelete[T](c, filter); err != nil {
+		ResponseInternalServerError(c, "failed to delete documents", err)
 	} else if deletedCount == 0 {
 		ResponseDocumentNotFound(c)
 	} else {
+		AuditBulkMutation(c, gin.H{consts.GUIDField: guids}, deletedCount, []string{c.GetString(consts.CustomerGUID)})
 		c.JSON(http.StatusOK, gin.H{"deletedCount": deletedCount})
 	}
 }
@@ -424,32 +451,63 @@ func HandleDeleteDocByName[T types.DocContent](nameParam string) gin.HandlerFunc
 
 func BulkDeleteDocByNameHandler[T types.DocContent](c *gin.Context, names []string) {
 	defer log.LogNTraceEnterExit("BulkDeleteDocByNameHandler", c)()
-	if deletedCount, err := db.BulkDeleteByName[T](c, names); err != nil {
+	if deletedCount, err := bulkDelete[T](c, db.NewFilterBuilder().WithIn(consts.NameField, names)); err != nil {
+		ResponseInternalServerError(c, "failed to delete documents", err)
 	} else if deletedCount == 0 {
 		ResponseDocumentNotFound(c)
 	} else {
+		AuditBulkMutation(c, gin.H{consts.NameField: names}, deletedCount, []string{c.GetString(consts.CustomerGUID)})
 		c.JSON(http.StatusOK, gin.H{"deletedCount": deletedCount})
 	}
 }
 
+// bulkDelete deletes the customer documents that match the filter
+// when the route keeps version history the deleted documents are versioned in the same transaction
+func bulkDelete[T types.DocContent](c *gin.Context, filter *db.FilterBuilder) (int64, error) {
+	if !keepsVersions(c) {
+		return db.BulkDelete[T](c, *filter)
+	}
+	var deletedCount int64
+	err := db.WithTransaction(c, func(tc context.Context) error {
+		deleted, err := db.BulkDeleteWithRevisions[T](tc, *filter)
+		if err != nil {
+			return err
+		}
+		for _, d := range deleted {
+			if err := insertDocVersion(tc, c, d.Doc.GetGUID(), d.Doc, d.Revision); err != nil {
+				return err
+			}
+		}
+		deletedCount = int64(len(deleted))
+		return nil
+	})
+	return deletedCount, err
+}
+
 func DeleteDocByGUIDHandler[T types.DocContent](c *gin.Context, guid string) {
 	defer log.LogNTraceEnterExit("DeleteDocByGUIDHandler", c)()
-	if deletedDoc, err := db.DeleteByGUID[T](c, guid); err != nil {
+	if deletedDoc, revision, err := db.DeleteByGUIDWithRevision[T](c, guid); err != nil {
 		ResponseInternalServerError(c, "failed to delete document", err)
 	} else if deletedDoc == nil {
 		ResponseDocumentNotFound(c)
 	} else {
+		saveDocVersion(c, guid, *deletedDoc, revision)
+		AuditDocMutation(c, guid, deletedDoc, nil)
+		PublishDocMutation(c, guid, deletedDoc, nil)
 		c.JSON(http.StatusOK, deletedDoc)
 	}
 }
 
 func DeleteDocByNameHandler[T types.DocContent](c *gin.Context, name string) {
 	defer log.LogNTraceEnterExit("DeleteDocByNameHandler", c)()
-	if deletedDoc, err := db.DeleteByName[T](c, name); err != nil {
+	if deletedDoc, revision, err := db.DeleteByNameWithRevision[T](c, name); err != nil {
 		ResponseInternalServerError(c, "failed to read collection from context", err)
 	} else if deletedDoc == nil {
 		ResponseDocumentNotFound(c)
 	} else {
+		saveDocVersion(c, (*deletedDoc).GetGUID(), *deletedDoc, revision)
+		AuditDocMutation(c, (*deletedDoc).GetGUID(), deletedDoc, nil)
+		PublishDocMutation(c, (*deletedDoc).GetGUID(), deletedDoc, nil)
 		c.JSON(http.StatusOK, deletedDoc)
 	}
 }
@@ -471,7 +529,7 @@ func MustGetDocContentFromContext[T types.DocContent](c *gin.Context) ([]T, erro
 	return docs, nil
 }
 
-func HandlerAddToArray(requestHandler ContainerHandler) func(c *gin.Context) {
+func HandlerAddToArray[T types.DocContent](requestHandler ContainerHandler) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		pathToArray, items, valid := requestHandler(c)
 		if !valid {
@@ -482,17 +540,22 @@ func HandlerAddToArray(requestHandler ContainerHandler) func(c *gin.Context) {
 			ResponseMissingGUID(c)
 			return
 		}
-		if modified, err := db.AddToArray(c, guid, pathToArray, items...); err != nil {
+		if modified, err := updateContainer[T](c, guid, func(tc context.Context) (int64, error) {
+			return db.AddToArray(tc, guid, pathToArray, items...)
+		}); err != nil {
 			ResponseInternalServerError(c, "failed to add to unsubscribedUsers", err)
 			return
 		} else {
+			if modified > 0 {
+				AuditDocMutation(c, guid, nil, gin.H{pathToArray: gin.H{"added": items}})
+			}
 			c.JSON(http.StatusOK, gin.H{"added": modified})
 		}
 	}
 
 }
 
-func HandlerRemoveFromArray(requestHandler ContainerHandler) func(c *gin.Context) {
+func HandlerRemoveFromArray[T types.DocContent](requestHandler ContainerHandler) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		pathToArray, items, valid := requestHandler(c)
 		if !valid {
@@ -503,16 +566,21 @@ func HandlerRemoveFromArray(requestHandler ContainerHandler) func(c *gin.Context
 			ResponseMissingGUID(c)
 			return
 		}
-		if modified, err := db.PullFromArray(c, guid, pathToArray, items...); err != nil {
+		if modified, err := updateContainer[T](c, guid, func(tc context.Context) (int64, error) {
+			return db.PullFromArray(tc, guid, pathToArray, items...)
+		}); err != nil {
 			ResponseInternalServerError(c, "failed to remove from  unsubscribedUsers", err)
 			return
 		} else {
+			if modified > 0 {
+				AuditDocMutation(c, guid, nil, gin.H{pathToArray: gin.H{"removed": items}})
+			}
 			c.JSON(http.StatusOK, gin.H{"removed": modified})
 		}
 	}
 }
 
-func HandlerSetField(requestHandler ContainerHandler, set bool) func(c *gin.Context) {
+func HandlerSetField[T types.DocContent](requestHandler ContainerHandler, set bool) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		pathToField, values, valid := requestHandler(c)
 		if !valid {
@@ -523,21 +591,48 @@ func HandlerSetField(requestHandler ContainerHandler, set bool) func(c *gin.Cont
 			ResponseMissingGUID(c)
 			return
 		}
-		var update interface{}
-		if set {
-			update = db.GetUpdateSetFieldCommand(pathToField, values[0])
-		} else { //unset
-			update = db.GetUpdateUnsetFieldCommand(pathToField)
-		}
-		if modified, err := db.UpdateOne(c, guid, update); err != nil {
+		modified, err := updateContainer[T](c, guid, func(tc context.Context) (int64, error) {
+			if set {
+				return db.SetField(tc, guid, pathToField, values[0])
+			}
+			return db.UnsetField(tc, guid, pathToField)
+		})
+		if err != nil {
 			ResponseInternalServerError(c, "failed to add to unsubscribedUsers", err)
 			return
 		} else {
+			if modified > 0 {
+				if set {
+					AuditDocMutation(c, guid, nil, gin.H{pathToField: values[0]})
+				} else {
+					AuditDocMutation(c, guid, gin.H{pathToField: nil}, nil)
+				}
+			}
 			c.JSON(http.StatusOK, gin.H{"modified": modified})
 		}
 	}
 }
 
+// updateContainer runs an update of a document container and returns the number of modified documents
+// when the route keeps version history the document before a modification is versioned in the same transaction
+func updateContainer[T types.DocContent](c *gin.Context, guid string, update func(tc context.Context) (int64, error)) (int64, error) {
+	if !keepsVersions(c) {
+		return update(c)
+	}
+	var modified int64
+	err := db.WithTransaction(c, func(tc context.Context) error {
+		oldDoc, revision, err := db.GetDocByGUIDWithRevision[T](tc, guid)
+		if err != nil {
+			return err
+		}
+		if modified, err = update(tc); err != nil || modified == 0 {
+			return err
+		}
+		return insertDocVersion(tc, c, guid, *oldDoc, revision)
+	})
+	return modified, err
+}
+
 func GetBulkOrSingleBody[T any](c *gin.Context) ([]T, error) {
 	var doc T
 	var docs []T
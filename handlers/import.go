@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"bufio"
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// max number of records in an import request
+	maxImportRecords = 10000
+	// max size of a NDJSON import line
+	maxImportLineSize = 16 * 1024 * 1024
+)
+
+// importRecord is a document of an import request
+type importRecord[T types.DocContent] struct {
+	line int
+	doc  T
+	err  string //the parse error of the record
+}
+
+// HandleImport - creates the documents of a NDJSON or JSON array body, each record is validated by the POST validators
+// existing documents are matched by the record value of the key query param (default name) and are skipped, overwritten
+// (validated by the PUT validators) or fail the import by the onConflict query param (default fail)
+// with dryRun=true the response reports the result of each record without writing
+func HandleImport[T types.DocContent](opts *routerOptions[T]) gin.HandlerFunc {
+	postValidators, putValidators := opts.getPostValidators(), opts.getPutValidators()
+	return func(c *gin.Context) {
+		defer log.LogNTraceEnterExit("HandleImport", c)()
+		key := c.DefaultQuery(consts.ImportKeyParam, consts.NameField)
+		policy := c.DefaultQuery(consts.OnConflictParam, consts.ConflictFail)
+		switch policy {
+		case consts.ConflictSkip, consts.ConflictFail:
+		case consts.ConflictOverwrite:
+			if !opts.permitted(c, PermissionUpdate) {
+				return
+			}
+		default:
+			ResponseBadRequest(c, fmt.Sprintf("%s must be %s, %s or %s", consts.OnConflictParam, consts.ConflictSkip, consts.ConflictOverwrite, consts.ConflictFail))
+			return
+		}
+		dryRun, _ := strconv.ParseBool(c.Query(consts.DryRunParam))
+		records, err := decodeImportRecords[T](c)
+		if err != nil {
+			ResponseBadRequest(c, err.Error())
+			return
+		}
+		if len(records) == 0 {
+			ResponseBadRequest(c, "no documents in request")
+			return
+		}
+
+		//the keys of the records, records with a duplicate unique value in the request are invalid
+		uniqueFields := []string{key}
+		if opts.validatePostUniqueName && key != consts.NameField {
+			uniqueFields = append(uniqueFields, consts.NameField)
+		}
+		results := make([]types.ImportResult, len(records))
+		keys := make([]string, len(records))
+		seen := map[string]map[string]bool{}
+		for i := range records {
+			results[i].Line = records[i].line
+			if records[i].err != "" {
+				results[i].Status, results[i].Error = types.ImportStatusInvalid, records[i].err
+				continue
+			}
+			for _, field := range uniqueFields {
+				value, err := importKeyValue(records[i].doc, field)
+				if err == nil && seen[field][value] {
+					err = fmt.Errorf("duplicate %s %s in request", field, value)
+				}
+				if err != nil {
+					results[i].Status, results[i].Error = types.ImportStatusInvalid, err.Error()
+					break
+				}
+				if seen[field] == nil {
+					seen[field] = map[string]bool{}
+				}
+				seen[field][value] = true
+				if field == key {
+					keys[i] = value
+				}
+			}
+		}
+		existing, err := findImportedKeys(c, key, keys)
+		if err != nil {
+			ResponseInternalServerError(c, "failed to read documents", err)
+			return
+		}
+		status := http.StatusOK
+		if policy == consts.ConflictFail {
+			for i := range records {
+				if guid, ok := existing[keys[i]]; ok && results[i].Status == "" {
+					results[i].GUID, results[i].Status, results[i].Error = guid, types.ImportStatusConflict, fmt.Sprintf("%s %s already exists", key, keys[i])
+					//nothing is written, the other records are reported as in a dry run
+					status, dryRun = http.StatusConflict, true
+				}
+			}
+		}
+
+		for i, record := range records {
+			if results[i].Status != "" {
+				continue
+			}
+			guid, exists := existing[keys[i]]
+			switch {
+			case !exists:
+				keepGUID := ""
+				if key == consts.GUIDField {
+					keepGUID = keys[i]
+				}
+				results[i] = importCreate(c, record, keepGUID, postValidators, dryRun)
+			case policy == consts.ConflictSkip:
+				results[i].GUID, results[i].Status = guid, types.ImportStatusSkipped
+			default:
+				record.doc.SetGUID(guid)
+				results[i] = importUpdate(c, record, putValidators, dryRun)
+			}
+		}
+
+		summary := map[string]int{}
+		for _, result := range results {
+			summary[result.Status]++
+		}
+		c.JSON(status, types.ImportResponse{DryRun: dryRun, Summary: summary, Results: results})
+	}
+}
+
+// decodeImportRecords returns the records of a NDJSON (by the content type) or JSON array body
+// records that cannot be parsed have the parse error, a malformed JSON array fails the request
+func decodeImportRecords[T types.DocContent](c *gin.Context) ([]importRecord[T], error) {
+	records := []importRecord[T]{}
+	addRecord := func(line int, data []byte) error {
+		if len(records) == maxImportRecords {
+			return fmt.Errorf("import is limited to %d documents", maxImportRecords)
+		}
+		record := importRecord[T]{line: line}
+		if err := json.Unmarshal(data, &record.doc); err != nil {
+			record.err = "invalid JSON: " + err.Error()
+		} else if record.doc == nil {
+			record.err = invalidDocument
+		} else if err := binding.Validator.ValidateStruct(record.doc); err != nil {
+			record.err = err.Error()
+		}
+		records = append(records, record)
+		return nil
+	}
+	if c.ContentType() == consts.NDJSONContentType {
+		scanner := bufio.NewScanner(c.Request.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+		for line := 1; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			if err := addRecord(line, scanner.Bytes()); err != nil {
+				return nil, err
+			}
+		}
+		return records, scanner.Err()
+	}
+	var docs []json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&docs); err != nil {
+		return nil, err
+	}
+	for i := range docs {
+		if err := addRecord(i+1, docs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// importKeyValue returns the string value of a key field (e.g. name or attributes.id) of a document
+func importKeyValue[T types.DocContent](doc T, key string) (string, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	value, err := bson.Raw(data).LookupErr(strings.Split(key, ".")...)
+	if err != nil {
+		return "", fmt.Errorf(MissingKey, key)
+	}
+	str, ok := value.StringValueOK()
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	} else if str == "" {
+		return "", fmt.Errorf(MissingKey, key)
+	}
+	return str, nil
+}
+
+// findImportedKeys returns the GUIDs of the customer documents with the given key values by the key value
+func findImportedKeys(c *gin.Context, key string, values []string) (map[string]string, error) {
+	existing := map[string]string{}
+	keyValues := []string{}
+	for _, value := range values {
+		if value != "" {
+			keyValues = append(keyValues, value)
+		}
+	}
+	if len(keyValues) == 0 {
+		return existing, nil
+	}
+	findOpts := db.NewFindOptions()
+	findOpts.Filter().WithIn(key, keyValues)
+	findOpts.Projection().Include(consts.GUIDField)
+	if key != consts.GUIDField {
+		findOpts.Projection().Include(key)
+	}
+	docs, err := db.FindForCustomer[bson.Raw](c, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		value, _ := doc.Lookup(strings.Split(key, ".")...).StringValueOK()
+		guid, _ := doc.Lookup(consts.GUIDField).StringValueOK()
+		existing[value] = guid
+	}
+	return existing, nil
+}
+
+// importCreate validates and creates the document of an import record, the given GUID (if any) is kept
+func importCreate[T types.DocContent](c *gin.Context, record importRecord[T], guid string, validators []MutatorValidator[T], dryRun bool) types.ImportResult {
+	result := types.ImportResult{Line: record.line, GUID: guid}
+	c.Set(consts.UpsertMethod, http.MethodPost)
+	doc, _, errMsg := validateBulkDoc(c, record.doc, validators)
+	if errMsg != "" {
+		result.Status, result.Error = types.ImportStatusInvalid, errMsg
+		return result
+	}
+	if dryRun {
+		result.Status = types.ImportStatusCreated
+		return result
+	}
+	dbDoc := newDocumentWithGUID(c, doc, guid)
+	if _, err := db.InsertDBDocument(c, dbDoc); err != nil {
+		log.LogNTraceError("failed to create document", err, c)
+		result.Status, result.Error = types.ImportStatusFailed, err.Error()
+		return result
+	}
+	AuditDocMutation(c, dbDoc.ID, nil, dbDoc.Content)
+	PublishDocMutation(c, dbDoc.ID, nil, dbDoc.Content)
+	result.GUID, result.Status = dbDoc.ID, types.ImportStatusCreated
+	return result
+}
+
+// importUpdate validates and updates the existing document of an import record
+func importUpdate[T types.DocContent](c *gin.Context, record importRecord[T], validators []MutatorValidator[T], dryRun bool) types.ImportResult {
+	result := types.ImportResult{Line: record.line, GUID: record.doc.GetGUID()}
+	c.Set(consts.UpsertMethod, http.MethodPut)
+	doc, _, errMsg := validateBulkDoc(c, record.doc, validators)
+	if errMsg != "" {
+		result.Status, result.Error = types.ImportStatusInvalid, errMsg
+		return result
+	}
+	doc.SetUpdatedTime(nil)
+	update, err := db.GetUpdateDocCommand(doc, GetCustomPutFields(c), doc.GetReadOnlyFields()...)
+	if err != nil {
+		result.Status, result.Error = types.ImportStatusInvalid, "no fields to update"
+		if !db.IsNoFieldsToUpdateError(err) {
+			result.Status, result.Error = types.ImportStatusFailed, err.Error()
+		}
+		return result
+	}
+	if dryRun {
+		result.Status = types.ImportStatusUpdated
+		return result
+	}
+	res, revision, err := db.UpdateDocumentWithRevision[T](c, result.GUID, update)
+	switch {
+	case err != nil:
+		log.LogNTraceError("failed to update document", err, c)
+		result.Status, result.Error = types.ImportStatusFailed, err.Error()
+	case res == nil:
+		//deleted after the key lookup
+		result.Status, result.Error = types.ImportStatusFailed, "document not found"
+	default:
+		saveDocVersion(c, result.GUID, res[0], revision-1)
+		AuditDocMutation(c, result.GUID, res[0], res[1])
+		PublishDocMutation(c, result.GUID, res[0], res[1])
+		result.Status = types.ImportStatusUpdated
+	}
+	return result
+}
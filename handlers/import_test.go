@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"config-service/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportKeyValue(t *testing.T) {
+	cluster := &types.Cluster{}
+	cluster.Name = "cluster"
+	cluster.Attributes = map[string]interface{}{"id": "123", "count": 3}
+	tests := []struct {
+		key      string
+		expected string
+		err      string
+	}{
+		{key: "name", expected: "cluster"},
+		{key: "attributes.id", expected: "123"},
+		{key: "guid", err: "guid is required"},
+		{key: "attributes.missing", err: "attributes.missing is required"},
+		{key: "attributes.count", err: "attributes.count must be a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			value, err := importKeyValue(cluster, tt.key)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
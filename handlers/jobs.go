@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"config-service/jobs"
+	"config-service/utils/consts"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsAsyncRequest returns true if the request has async=true, async requests are run as background jobs
+func IsAsyncRequest(c *gin.Context) bool {
+	async, _ := strconv.ParseBool(c.Query(consts.AsyncParam))
+	return async
+}
+
+// EnqueueJob queues a job of the type with the request params and responds with the queued job
+// the job has the actor and path of the request for the audit trail of its mutations
+func EnqueueJob(c *gin.Context, jobType string, params interface{}) {
+	job, err := jobs.New(jobType, params)
+	if err != nil {
+		ResponseInternalServerError(c, "failed to create job", err)
+		return
+	}
+	job.Actor = actorFromContext(c)
+	job.CustomerGUID = c.GetString(consts.CustomerGUID)
+	job.Path = c.Request.URL.Path
+	job.Verb = c.Request.Method
+	if err := jobs.Enqueue(c, job); err != nil {
+		ResponseInternalServerError(c, "failed to queue job", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
@@ -4,9 +4,14 @@ import (
 	"config-service/types"
 	"config-service/utils/consts"
 	"config-service/utils/log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"golang.org/x/exp/slices"
 )
 
 // ////////////////////////////////db handler middleware//////////////////////////////////
@@ -14,6 +19,83 @@ import (
 func DBContextMiddleware(collectionName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Set(consts.Collection, collectionName)
+		c.Set(consts.RoutePath, c.FullPath())
+		c.Next()
+	}
+}
+
+// PermissionMiddleware aborts requests of callers that are not admins and have none of the roles allowed for the permission
+func PermissionMiddleware(path string, permission Permission, roles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasAnyRole(c, roles) {
+			ResponseMissingPermission(c, path, permission)
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasAnyRole returns true if the caller is an admin or has one of the roles
+func hasAnyRole(c *gin.Context, roles []string) bool {
+	if c.GetBool(consts.AdminAccess) {
+		return true
+	}
+	userRoles := c.GetStringSlice(consts.UserRoles)
+	for _, role := range roles {
+		if slices.Contains(userRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeniedReadPath returns the path of a route of the collection that the caller is not permitted to read, empty if the caller can read the documents of all its routes
+func DeniedReadPath(c *gin.Context, collection string) string {
+	paths := types.GetAllPaths()
+	sort.Strings(paths)
+	for _, path := range paths {
+		if apiInfo := types.GetAPIInfo(path); apiInfo.DBCollection == collection && apiInfo.ReadRoles != nil && !hasAnyRole(c, apiInfo.ReadRoles) {
+			return path
+		}
+	}
+	return ""
+}
+
+// IfMatchMiddleware sets in context the document revisions listed in the If-Match header
+// "*" matches any revision, weak and non revision entity tags never match
+func IfMatchMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ifMatch := strings.TrimSpace(c.GetHeader("If-Match")); ifMatch != "" && ifMatch != "*" {
+			revisions := []int64{}
+			for _, tag := range strings.Split(ifMatch, ",") {
+				tag = strings.TrimSpace(tag)
+				if !strings.HasPrefix(tag, `"`) {
+					continue
+				}
+				if value, err := strconv.Unquote(tag); err == nil {
+					if revision, err := strconv.ParseInt(value, 10, 64); err == nil {
+						revisions = append(revisions, revision)
+					}
+				}
+			}
+			c.Set(consts.IfMatch, revisions)
+		}
+		c.Next()
+	}
+}
+
+// VersionHistoryContextMiddleware sets in context the version history retention, handlers keep the prior revisions of the documents they modify
+func VersionHistoryContextMiddleware(retention types.VersionRetention) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(consts.VersionHistory, retention)
+		c.Next()
+	}
+}
+
+// QueryCacheContextMiddleware sets in context the ttl of the cached query results, the db read functions cache their results for the ttl
+func QueryCacheContextMiddleware(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(consts.QueryCacheTTL, ttl)
 		c.Next()
 	}
 }
@@ -89,7 +171,7 @@ func PostValidationMiddleware[T types.DocContent](validators ...MutatorValidator
 
 		for _, validator := range validators {
 			var ok bool
-			if docs, ok = validator(c, docs); !ok {
+			if docs, ok = validator.validate(c, docs); !ok {
 				return
 			}
 		}
@@ -116,7 +198,7 @@ func PutValidationMiddleware[T types.DocContent](validators ...MutatorValidator[
 		}
 		//validate
 		for _, validator := range validators {
-			if docs, ok := validator(c, []T{doc}); !ok {
+			if docs, ok := validator.validate(c, []T{doc}); !ok {
 				return
 			} else {
 				doc = docs[0]
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		roles    []string
+		admin    bool
+		wantCode int
+		wantBody string
+	}{
+		{name: "allowed role", roles: []string{"viewer"}, wantCode: http.StatusOK},
+		{name: "one of the roles allowed", roles: []string{"other", "editor"}, wantCode: http.StatusOK},
+		{name: "admin", admin: true, wantCode: http.StatusOK},
+		{name: "no roles", wantCode: http.StatusForbidden, wantBody: `{"error":"missing update permission for /v1_posture_exception_policy"}`},
+		{name: "not allowed role", roles: []string{"guest"}, wantCode: http.StatusForbidden, wantBody: `{"error":"missing update permission for /v1_posture_exception_policy"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.PUT("/v1_posture_exception_policy", func(c *gin.Context) {
+				if tt.roles != nil {
+					c.Set(consts.UserRoles, tt.roles)
+				}
+				if tt.admin {
+					c.Set(consts.AdminAccess, true)
+				}
+			}, PermissionMiddleware(consts.PostureExceptionPolicyPath, PermissionUpdate, []string{"viewer", "editor"}), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/v1_posture_exception_policy", nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithPermission(t *testing.T) {
+	opts := newRouterOptions[*types.PostureExceptionPolicy]()
+	opts.apply(NewRouterOptionsBuilder[*types.PostureExceptionPolicy]().
+		WithPath("/test").
+		WithPermissions(map[Permission][]string{PermissionRead: {"viewer"}}).Get())
+	handler := func(c *gin.Context) {}
+	assert.Len(t, opts.withPermission(PermissionRead, handler), 2)
+	assert.Len(t, opts.withPermission(PermissionUpdate, handler), 1)
+
+	opts.apply(NewRouterOptionsBuilder[*types.PostureExceptionPolicy]().
+		WithDBCollection("test").
+		WithPermissions(map[Permission][]string{"write": {"editor"}}).Get())
+	assert.Error(t, opts.validate())
+}
+
+func TestIfMatchMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    []int64
+		wantSet bool
+	}{
+		{name: "no header"},
+		{name: "any revision", ifMatch: "*"},
+		{name: "single revision", ifMatch: `"3"`, want: []int64{3}, wantSet: true},
+		{name: "revisions list", ifMatch: `"1", "2"`, want: []int64{1, 2}, wantSet: true},
+		{name: "weak tag never matches", ifMatch: `W/"3"`, want: []int64{}, wantSet: true},
+		{name: "not a revision never matches", ifMatch: `"abc"`, want: []int64{}, wantSet: true},
+		{name: "unquoted never matches", ifMatch: `3`, want: []int64{}, wantSet: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.PUT("/", IfMatchMiddleware(), func(c *gin.Context) {
+				revisions, ok := c.Get(consts.IfMatch)
+				assert.Equal(t, tt.wantSet, ok)
+				if tt.wantSet {
+					assert.Equal(t, tt.want, revisions)
+				}
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
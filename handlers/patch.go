@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// errValidationResponded is returned when a validator rejected the document and responded to the request
+var errValidationResponded = errors.New("validation failed")
+
+// HandlePatchDoc - applies the JSON merge patch or JSON patch in the request body on the document with GUID in path
+// the patched document is validated by the validators and the patch is translated to an update of the changed fields only
+func HandlePatchDoc[T types.DocContent](validators ...MutatorValidator[T]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer log.LogNTraceEnterExit("HandlePatchDoc", c)()
+		guid := c.Param(consts.GUIDField)
+		if guid == "" {
+			ResponseMissingGUID(c)
+			return
+		}
+		body, err := c.GetRawData()
+		if err != nil {
+			ResponseFailedToBindJson(c, err)
+			return
+		}
+		var patch db.Patch
+		switch c.ContentType() {
+		case consts.MergePatchContentType:
+			patch, err = db.NewMergePatch(body)
+		case consts.JSONPatchContentType:
+			patch, err = db.NewJSONPatch(body)
+		default:
+			msg := fmt.Sprintf("content type must be %s or %s", consts.MergePatchContentType, consts.JSONPatchContentType)
+			log.LogNTrace(msg, c)
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": msg})
+			return
+		}
+		if err != nil {
+			ResponseBadRequest(c, err.Error())
+			return
+		}
+
+		validate := func(doc T) (T, error) {
+			docs := []T{doc}
+			for _, validator := range validators {
+				var ok bool
+				if docs, ok = validator.validate(c, docs); !ok {
+					return doc, errValidationResponded
+				}
+			}
+			docs[0].SetUpdatedTime(nil)
+			return docs[0], nil
+		}
+		res, revision, err := db.UpdateDocumentWithFunc(c, guid, func(oldDoc T) (bson.D, error) {
+			_, update, err := db.PatchDocument(oldDoc, patch, validate, oldDoc.GetReadOnlyFields()...)
+			return update, err
+		})
+		switch {
+		case errors.Is(err, errValidationResponded):
+			return
+		case db.IsNoFieldsToUpdateError(err), db.IsReadOnlyFieldError(err):
+			ResponseBadRequest(c, err.Error())
+		case db.IsPatchTestFailedError(err):
+			log.LogNTrace(err.Error(), c)
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case db.IsInvalidPatchError(err):
+			log.LogNTrace(err.Error(), c)
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case err != nil:
+			ResponseInternalServerError(c, "failed to patch document", err)
+		case res == nil:
+			ResponseDocumentNotFound(c)
+		default:
+			saveDocVersion(c, guid, res[0], revision-1)
+			AuditDocMutation(c, guid, res[0], res[1])
+			PublishDocMutation(c, guid, res[0], res[1])
+			c.Set(consts.DocRevision, revision)
+			DocsResponse(c, res)
+		}
+	}
+}
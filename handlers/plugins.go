@@ -4,7 +4,11 @@ import (
 	"config-service/types"
 	"config-service/utils/consts"
 	"config-service/utils/log"
+	"config-service/utils/metrics"
 	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +17,24 @@ import (
 // a MutatorValidator may initialize the doc with required values therefor it returns the docs as well
 type MutatorValidator[T types.DocContent] func(c *gin.Context, docs []T) (verifiedDocs []T, valid bool)
 
+// validate calls the validator and counts the requests it rejected
+func (v MutatorValidator[T]) validate(c *gin.Context, docs []T) (verifiedDocs []T, valid bool) {
+	if verifiedDocs, valid = v(c, docs); !valid {
+		metrics.ValidationRejections.WithLabelValues(v.name()).Inc()
+	}
+	return verifiedDocs, valid
+}
+
+// name returns the function name of the validator without the package path, e.g. handlers.ValidateUniqueValues[...].func1
+func (v MutatorValidator[T]) name() string {
+	fn := runtime.FuncForPC(reflect.ValueOf(v).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
 // BodyDecoder is used for custom decoding of the request body it returns the decoded docs or an error
 type BodyDecoder[T types.DocContent] func(c *gin.Context) ([]T, error)
 
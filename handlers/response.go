@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	plural "github.com/gertd/go-pluralize"
@@ -18,8 +19,12 @@ import (
 
 const (
 	//error messages
-	MissingKey       = "%s is required"
-	DocumentNotFound = "document not found"
+	MissingKey        = "%s is required"
+	DocumentNotFound  = "document not found"
+	MissingPermission = "missing %s permission for %s"
+	RevisionMismatch  = "document revision does not match If-Match header"
+	ConcurrentUpdate  = "document was modified concurrently, retry the request"
+	DocumentInTrash   = "document is in the trash, restore it from the trash first"
 )
 
 var pluralize = plural.NewClient()
@@ -30,6 +35,18 @@ func ResponseInternalServerError(c *gin.Context, msg string, err error) {
 		ResponseConflict(c, consts.GUIDField)
 		return
 	}
+	if db.IsRevisionMismatchError(err) {
+		ResponsePreconditionFailed(c)
+		return
+	}
+	if db.IsConcurrentModificationError(err) {
+		ResponseConcurrentModification(c)
+		return
+	}
+	if db.IsInvalidCursorError(err) {
+		ResponseBadRequest(c, err.Error())
+		return
+	}
 	if errors.Is(err, context.Canceled) {
 		ResponseCanceled(c)
 		return
@@ -48,6 +65,21 @@ func ResponseCanceled(c *gin.Context) {
 	c.AbortWithStatusJSON(http.StatusNoContent, gin.H{"error": "request canceled"})
 }
 
+func ResponsePreconditionFailed(c *gin.Context) {
+	log.LogNTrace(RevisionMismatch, c)
+	c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": RevisionMismatch})
+}
+
+func ResponseConcurrentModification(c *gin.Context) {
+	log.LogNTrace(ConcurrentUpdate, c)
+	c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ConcurrentUpdate})
+}
+
+func ResponseDocumentInTrash(c *gin.Context) {
+	log.LogNTrace(DocumentInTrash, c)
+	c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": DocumentInTrash})
+}
+
 func ResponseDocumentNotFound(c *gin.Context) {
 	log.LogNTrace(DocumentNotFound, c)
 	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": DocumentNotFound})
@@ -121,6 +153,12 @@ func ResponseBadRequest(c *gin.Context, msg string) {
 	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": msg})
 }
 
+func ResponseMissingPermission(c *gin.Context, path string, permission Permission) {
+	msg := fmt.Sprintf(MissingPermission, permission, path)
+	log.LogNTrace(msg, c)
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": msg})
+}
+
 func ResponseFailedToBindJson(c *gin.Context, err error) {
 	log.LogNTraceError("failed to bind json", err, c)
 	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -131,6 +169,7 @@ func docResponse[T types.DocContent](c *gin.Context, doc *T) {
 		ResponseDocumentNotFound(c)
 		return
 	}
+	setDocRevisionETag(c)
 	if sender, _ := GetCustomResponseSender[T](c); sender != nil {
 		sender(c, *doc, nil)
 		return
@@ -144,9 +183,23 @@ func DocsResponse[T types.DocContent](c *gin.Context, docs []T) {
 		ResponseDocumentNotFound(c)
 		return
 	}
+	setDocRevisionETag(c)
 	if sender, _ := GetCustomResponseSender[T](c); sender != nil {
 		sender(c, nil, docs)
 		return
 	}
 	c.JSON(http.StatusOK, docs)
 }
+
+// setDocRevisionETag sets the ETag header to the revision of the document in the response, if set in context by the handler
+func setDocRevisionETag(c *gin.Context) {
+	if revision, ok := c.Get(consts.DocRevision); ok {
+		if revision, ok := revision.(int64); ok {
+			setETag(c, revision)
+		}
+	}
+}
+
+func setETag(c *gin.Context, revision int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(revision, 10)))
+}
@@ -3,8 +3,10 @@ package handlers
 import (
 	"config-service/db"
 	"config-service/types"
+	"config-service/utils"
 	"config-service/utils/consts"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,9 +21,14 @@ type routerOptions[T types.DocContent] struct {
 	serveGetIncludeGlobalDocs bool                      //default false, when true, in GET all the response will include global documents (with customers[""])
 	servePost                 bool                      //default true, serve POST
 	servePostV2ListRequests   bool                      //default false, when true  POST /<path>/query with V2ListRequest is served
+	serveExport               bool                      //default false, when servePostV2ListRequests is true POST /<path>/export with V2ListRequest streams all the matching documents as NDJSON or CSV (not served for nested documents)
 	servePut                  bool                      //default true, serve PUT /<path> to update document by GUID in body and PUT /<path>/<GUID> to update document by GUID in path
 	serveDelete               bool                      //default true, serve DELETE  /<path>/<GUID> to delete document by GUID in path
 	serveBulkDelete           bool                      //default true, serve DELETE /<path>/bulk with list of GUIDs in body or query to delete documents by GUIDs
+	serveBulkPut              bool                      //default false, serve PUT /<path>/bulk with list of documents in body to update the documents in a transaction
+	servePatch                bool                      //default false, serve PATCH /<path>/<GUID> with a JSON merge patch or JSON patch in body to update document by GUID in path
+	serveUpsert               bool                      //default false, when servePost and servePut are true, POST and PUT with upsert=true query param create the document if it does not exist (by GUID or unique name) and update it otherwise
+	serveImport               bool                      //default false, when servePost is true serve POST /<path>/import with NDJSON or a JSON array of documents to create, skip or overwrite the documents by a key (not served with a bodyDecoder)
 	serveDeleteByQuery        bool                      //default true, serve DELETE /<path>/query with V2ListRequest in body - all documents matching the query will be deleted
 	serveDeleteByName         bool                      //default false, when true, DELETE will check for name param and will delete the document by name
 	validatePostUniqueName    bool                      //default true, POST will validate that the name is unique
@@ -38,10 +45,26 @@ type routerOptions[T types.DocContent] struct {
 	putFields                 []string                  //default nil, when set, PUT will update only the specified fields
 	containersHandlers        []containerHandlerOptions //default nil, list of container handlers to put and remove items from document's containers
 	schemaInfo                types.SchemaInfo          //default nil, when set, the schema info will be used for queries (e.g. identify arrays)
+	permissions               map[Permission][]string   //default nil, when set, only the listed roles (or admins) can perform the operations with a declared permission
+	versionHistory            bool                      //default false, when true, PUT and DELETE keep the prior revisions of documents and GET /<path>/<GUID>/versions, GET /<path>/<GUID>/versions/<n> and POST /<path>/<GUID>/versions/<n>/restore are served
+	versionRetention          *types.VersionRetention   //default nil, when set, overrides the version history retention of the configuration
+	softDelete                bool                      //default false, when true, DELETE moves documents to the trash and GET /<path>/trash and POST /<path>/<GUID>/restore are served
+	serveWatch                bool                      //default false, when true, GET /<path>/watch streams the changes of the customer documents as server-sent events
+	queryCacheTTL             time.Duration             //default 0, when set, the results of the list, query, unique values and count requests are cached for the ttl
 }
 
 type ContainerType string
 
+// Permission is an operation on the documents of a route that can be restricted to roles
+type Permission string
+
+const (
+	PermissionRead   Permission = "read"   //GET and query requests
+	PermissionCreate Permission = "create" //POST requests
+	PermissionUpdate Permission = "update" //PUT and PATCH requests, including container items updates
+	PermissionDelete Permission = "delete" //DELETE requests
+)
+
 const (
 	ContainerTypeArray ContainerType = "array"
 	ContainerTypeMap   ContainerType = "map"
@@ -49,10 +72,20 @@ const (
 	countSuffix        = "/count"
 	bulkSuffix         = "/bulk"
 	querySuffix        = "/query"
+	importSuffix       = "/import"
 	uniqueValuesSuffix = "/uniqueValues"
+	exportSuffix       = "/export"
 	// nestedDocSuffixes
 	nestedDocQuerySuffix        = "/:" + consts.GUIDField + querySuffix
 	nestedDocUniqueValuesSuffix = "/:" + consts.GUIDField + uniqueValuesSuffix
+	// version history suffixes
+	versionsSuffix       = "/:" + consts.GUIDField + "/versions"
+	versionSuffix        = versionsSuffix + "/:" + consts.VersionParam
+	restoreVersionSuffix = versionSuffix + "/restore"
+	// soft delete suffixes
+	trashSuffix   = "/trash"
+	restoreSuffix = "/:" + consts.GUIDField + "/restore"
+	watchSuffix   = "/watch"
 )
 
 type containerHandlerOptions struct {
@@ -83,17 +116,21 @@ func newRouterOptions[T types.DocContent]() *routerOptions[T] {
 func AddRoutes[T types.DocContent](g *gin.Engine, options ...RouterOption[T]) *gin.RouterGroup {
 	opts := newRouterOptions[T]()
 	opts.apply(options)
+	if permissions, ok := configuredPermissions[opts.path]; ok {
+		opts.permissions = permissions
+	}
 	if err := opts.validate(); err != nil {
 		panic(err)
 	}
+	//the route info declares the collection indexes so it is added before the collection is indexed
+	addRouteInfo(opts)
 	//validate and initialize collection
 	if err := db.ValidateCollection(opts.dbCollection); err != nil {
 		panic(err)
 	}
-	addRouteInfo(opts)
 	routerGroup := g.Group(opts.path)
 	//add middleware
-	routerGroup.Use(DBContextMiddleware(opts.dbCollection))
+	routerGroup.Use(DBContextMiddleware(opts.dbCollection), IfMatchMiddleware())
 	if opts.responseSender != nil {
 		routerGroup.Use(ResponseSenderContextMiddleware(&opts.responseSender))
 	}
@@ -103,92 +140,113 @@ func AddRoutes[T types.DocContent](g *gin.Engine, options ...RouterOption[T]) *g
 	if opts.putFields != nil {
 		routerGroup.Use(PutFieldsContextMiddleware(opts.putFields))
 	}
+	if opts.versionHistory {
+		if err := db.ValidateCollection(db.VersionsCollection(opts.dbCollection)); err != nil {
+			panic(err)
+		}
+		routerGroup.Use(VersionHistoryContextMiddleware(opts.getVersionRetention()))
+	}
+	if opts.softDelete {
+		db.SetSoftDelete(opts.dbCollection)
+	}
+	if opts.queryCacheTTL > 0 {
+		db.SetQueryCached(opts.dbCollection)
+	}
 
 	//add routes
 	if opts.serveGet {
 		if !opts.serveGetWithGUIDOnly {
-			routerGroup.GET("", SchemaContextMiddleware(opts.schemaInfo), HandleGet(opts))
+			routerGroup.GET("", opts.withPermission(PermissionRead, opts.withQueryCache(SchemaContextMiddleware(opts.schemaInfo), HandleGet(opts))...)...)
 		}
-		routerGroup.GET("/:"+consts.GUIDField, SchemaContextMiddleware(opts.schemaInfo), HandleGetDocWithGUIDInPath[T])
+		routerGroup.GET("/:"+consts.GUIDField, opts.withPermission(PermissionRead, SchemaContextMiddleware(opts.schemaInfo), HandleGetDocWithGUIDInPath[T])...)
+	}
+	//upsert requests are served before the POST and PUT handlers
+	var upsert []gin.HandlerFunc
+	if opts.serveUpsert && opts.servePost && opts.servePut {
+		upsert = append(upsert, HandleUpsert(opts))
 	}
 	if opts.servePost {
-		postValidators := []MutatorValidator[T]{}
-		if opts.validatePostUniqueName {
-			postValidators = append(postValidators, ValidateUniqueValues(NameKeyGetter[T]))
-		}
-		if opts.validatePostMandatoryName {
-			postValidators = append(postValidators, ValidateNameExistence[T])
-		}
-		if opts.uniqueShortName != nil {
-			postValidators = append(postValidators, ValidatePostAttributeShortName(opts.uniqueShortName))
+		postHandlers := append(upsert, HandlePostDocWithValidation(opts.getPostValidators()...)...)
+		routerGroup.POST("", opts.withPermission(PermissionCreate, postHandlers...)...)
+		if opts.serveImport && opts.bodyDecoder == nil {
+			routerGroup.POST(importSuffix, opts.withPermission(PermissionCreate, HandleImport(opts))...)
 		}
-		postValidators = append(postValidators, opts.postValidators...)
-		routerGroup.POST("", HandlePostDocWithValidation(postValidators...)...)
 	}
 	if opts.servePut {
-		putValidators := []MutatorValidator[T]{}
-		if opts.validatePutGUID {
-			putValidators = append(putValidators, ValidateGUIDExistence[T])
+		putValidators := opts.getPutValidators()
+		putHandlers := append(upsert, HandlePutDocWithValidation(putValidators...)...)
+		routerGroup.PUT("", opts.withPermission(PermissionUpdate, putHandlers...)...)
+		routerGroup.PUT("/:"+consts.GUIDField, opts.withPermission(PermissionUpdate, putHandlers...)...)
+		if opts.serveBulkPut {
+			routerGroup.PUT(bulkSuffix, opts.withPermission(PermissionUpdate, HandleBulkPutDocs(putValidators...))...)
 		}
-		if opts.uniqueShortName != nil {
-			putValidators = append(putValidators, ValidatePutAttributerShortName[T])
+		if opts.servePatch {
+			routerGroup.PATCH("/:"+consts.GUIDField, opts.withPermission(PermissionUpdate, HandlePatchDoc(putValidators...))...)
 		}
-		if opts.validatePutUniqueName {
-			putValidators = append(putValidators, ValidateUniqueValues(NameKeyGetter[T]))
-		}
-		putValidators = append(putValidators, opts.putValidators...)
-		routerGroup.PUT("", HandlePutDocWithValidation(putValidators...)...)
-		routerGroup.PUT("/:"+consts.GUIDField, HandlePutDocWithValidation(putValidators...)...)
 	}
 	if opts.serveDelete {
 		if opts.serveDeleteByName {
-			routerGroup.DELETE("", HandleDeleteDocByName[T](opts.nameQueryParam))
+			routerGroup.DELETE("", opts.withPermission(PermissionDelete, HandleDeleteDocByName[T](opts.nameQueryParam))...)
 		}
 		if opts.serveBulkDelete {
-			routerGroup.DELETE(bulkSuffix, HandleBulkDeleteWithGUIDs[T])
+			routerGroup.DELETE(bulkSuffix, opts.withPermission(PermissionDelete, HandleBulkDeleteWithGUIDs[T])...)
 		}
 		if opts.serveDeleteByQuery {
-			routerGroup.DELETE(querySuffix, HandleDeleteByQuery[T])
+			routerGroup.DELETE(querySuffix, opts.withPermission(PermissionDelete, HandleDeleteByQuery[T])...)
 		}
-		routerGroup.DELETE("/:"+consts.GUIDField, HandleDeleteDoc[T])
+		routerGroup.DELETE("/:"+consts.GUIDField, opts.withPermission(PermissionDelete, HandleDeleteDoc[T])...)
 	}
 	if opts.servePostV2ListRequests {
 		putSchemaInContext := SchemaContextMiddleware(opts.schemaInfo)
 		if nestedPath := opts.schemaInfo.GetNestedDocPath(); nestedPath != "" {
-			handlers := []gin.HandlerFunc{putSchemaInContext, NestedDocContextMiddleware(), HandlePostV2ListRequest[T]}
+			handlers := opts.withPermission(PermissionRead, opts.withQueryCache(putSchemaInContext, NestedDocContextMiddleware(), HandlePostV2ListRequest[T])...)
 			routerGroup.POST(nestedDocQuerySuffix, handlers...)
 			routerGroup.POST(nestedDocUniqueValuesSuffix, handlers...)
 		} else {
-			handlers := []gin.HandlerFunc{putSchemaInContext, HandlePostV2ListRequest[T]}
-			routerGroup.POST(querySuffix, handlers...)
-			routerGroup.POST(uniqueValuesSuffix, putSchemaInContext, HandlePostUniqueValuesRequestV2)
-			routerGroup.POST(countSuffix, putSchemaInContext, HandlePostV2CountRequest)
+			routerGroup.POST(querySuffix, opts.withPermission(PermissionRead, opts.withQueryCache(putSchemaInContext, HandlePostV2ListRequest[T])...)...)
+			routerGroup.POST(uniqueValuesSuffix, opts.withPermission(PermissionRead, opts.withQueryCache(putSchemaInContext, HandlePostUniqueValuesRequestV2)...)...)
+			routerGroup.POST(countSuffix, opts.withPermission(PermissionRead, opts.withQueryCache(putSchemaInContext, HandlePostV2CountRequest)...)...)
+			if opts.serveExport {
+				routerGroup.POST(exportSuffix, opts.withPermission(PermissionRead, putSchemaInContext, HandleExport[T])...)
+			}
 		}
 	}
+	if opts.versionHistory {
+		routerGroup.GET(versionsSuffix, opts.withPermission(PermissionRead, HandleGetDocVersions[T])...)
+		routerGroup.GET(versionSuffix, opts.withPermission(PermissionRead, HandleGetDocVersion[T])...)
+		routerGroup.POST(restoreVersionSuffix, opts.withPermission(PermissionUpdate, HandleRestoreDocVersion[T])...)
+	}
+	if opts.softDelete {
+		routerGroup.GET(trashSuffix, opts.withPermission(PermissionRead, HandleGetDeletedDocs[T])...)
+		routerGroup.POST(restoreSuffix, opts.withPermission(PermissionUpdate, HandleRestoreDeletedDoc[T](opts.validatePostUniqueName))...)
+	}
+	if opts.serveWatch {
+		routerGroup.GET(watchSuffix, opts.withPermission(PermissionRead, SchemaContextMiddleware(opts.schemaInfo), HandleWatch[T])...)
+	}
 	//add array handlers
 	for _, containerHandler := range opts.containersHandlers {
 		switch containerHandler.containerType {
 		case ContainerTypeArray:
 			if containerHandler.servePut {
-				routerGroup.PUT(containerHandler.path, HandlerAddToArray(containerHandler.ContainerHandler))
+				routerGroup.PUT(containerHandler.path, opts.withPermission(PermissionUpdate, HandlerAddToArray[T](containerHandler.ContainerHandler))...)
 			}
 			if containerHandler.serveDelete {
-				routerGroup.DELETE(containerHandler.path, HandlerRemoveFromArray(containerHandler.ContainerHandler))
+				routerGroup.DELETE(containerHandler.path, opts.withPermission(PermissionUpdate, HandlerRemoveFromArray[T](containerHandler.ContainerHandler))...)
 			}
 		case ContainerTypeMap:
 			if containerHandler.servePut {
-				routerGroup.PUT(containerHandler.path, HandlerSetField(containerHandler.ContainerHandler, true))
+				routerGroup.PUT(containerHandler.path, opts.withPermission(PermissionUpdate, HandlerSetField[T](containerHandler.ContainerHandler, true))...)
 			}
 			if containerHandler.serveDelete {
-				routerGroup.DELETE(containerHandler.path, HandlerSetField(containerHandler.ContainerHandler, false))
+				routerGroup.DELETE(containerHandler.path, opts.withPermission(PermissionUpdate, HandlerSetField[T](containerHandler.ContainerHandler, false))...)
 			}
 		}
 	}
 	return routerGroup
 }
 
-// Common router config for policies
-func AddPolicyRoutes[T types.DocContent](g *gin.Engine, path, dbCollection string, paramConf *QueryParamsConfig, allowRename bool, schema *types.SchemaInfo) *gin.RouterGroup {
+// Common router config for policies, options are applied after the common config
+func AddPolicyRoutes[T types.DocContent](g *gin.Engine, path, dbCollection string, paramConf *QueryParamsConfig, allowRename bool, schema *types.SchemaInfo, options ...RouterOption[T]) *gin.RouterGroup {
 	routerOptionsBuilder := NewRouterOptionsBuilder[T]().
 		WithPath(path).
 		WithDBCollection(dbCollection).
@@ -206,7 +264,81 @@ func AddPolicyRoutes[T types.DocContent](g *gin.Engine, path, dbCollection strin
 			WithSchemaInfo(*schema)
 	}
 
-	return AddRoutes(g, routerOptionsBuilder.Get()...)
+	return AddRoutes(g, append(routerOptionsBuilder.Get(), options...)...)
+}
+
+// getVersionRetention returns the route version retention or the configuration retention when not set
+func (opts *routerOptions[T]) getVersionRetention() types.VersionRetention {
+	if opts.versionRetention != nil {
+		return *opts.versionRetention
+	}
+	config := utils.GetConfig().VersionHistory
+	return types.VersionRetention{
+		MaxVersions: config.MaxVersions,
+		MaxAge:      time.Duration(config.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// withPermission prepends the permission check to the route handlers when roles are declared for the permission
+func (opts *routerOptions[T]) withPermission(permission Permission, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	roles, ok := opts.permissions[permission]
+	if !ok {
+		return handlers
+	}
+	return append([]gin.HandlerFunc{PermissionMiddleware(opts.path, permission, roles)}, handlers...)
+}
+
+// withQueryCache adds the query cache middleware to the handlers of read requests when the route enables the query cache
+func (opts *routerOptions[T]) withQueryCache(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	if opts.queryCacheTTL <= 0 {
+		return handlers
+	}
+	return append([]gin.HandlerFunc{QueryCacheContextMiddleware(opts.queryCacheTTL)}, handlers...)
+}
+
+// permitted returns true if the caller has the permission, otherwise it responds with 403 and returns false
+func (opts *routerOptions[T]) permitted(c *gin.Context, permission Permission) bool {
+	roles, ok := opts.permissions[permission]
+	if !ok || hasAnyRole(c, roles) {
+		return true
+	}
+	ResponseMissingPermission(c, opts.path, permission)
+	return false
+}
+
+// getPostValidators returns the validators of POST requests
+func (opts *routerOptions[T]) getPostValidators() []MutatorValidator[T] {
+	return opts.buildPostValidators(opts.validatePostUniqueName)
+}
+
+// buildPostValidators returns the validators of POST requests, the names are checked to be unique only when uniqueName is true
+func (opts *routerOptions[T]) buildPostValidators(uniqueName bool) []MutatorValidator[T] {
+	postValidators := []MutatorValidator[T]{}
+	if uniqueName {
+		postValidators = append(postValidators, ValidateUniqueValues(NameKeyGetter[T]))
+	}
+	if opts.validatePostMandatoryName {
+		postValidators = append(postValidators, ValidateNameExistence[T])
+	}
+	if opts.uniqueShortName != nil {
+		postValidators = append(postValidators, ValidatePostAttributeShortName(opts.uniqueShortName))
+	}
+	return append(postValidators, opts.postValidators...)
+}
+
+// getPutValidators returns the validators of PUT, bulk PUT and PATCH requests
+func (opts *routerOptions[T]) getPutValidators() []MutatorValidator[T] {
+	putValidators := []MutatorValidator[T]{}
+	if opts.validatePutGUID {
+		putValidators = append(putValidators, ValidateGUIDExistence[T])
+	}
+	if opts.uniqueShortName != nil {
+		putValidators = append(putValidators, ValidatePutAttributerShortName[T])
+	}
+	if opts.validatePutUniqueName {
+		putValidators = append(putValidators, ValidateUniqueValues(NameKeyGetter[T]))
+	}
+	return append(putValidators, opts.putValidators...)
 }
 
 func (opts *routerOptions[T]) apply(options []RouterOption[T]) {
@@ -231,12 +363,34 @@ func (opts *routerOptions[T]) validate() error {
 	if opts.serveGetWithGUIDOnly && !opts.serveGet {
 		return fmt.Errorf("serveGetWithGUIDOnly can only be true when serveGet is true")
 	}
+	if opts.softDelete && !opts.serveDelete {
+		return fmt.Errorf("softDelete can only be true when serveDelete is true")
+	}
+	if opts.servePut && opts.servePatch && (opts.bodyDecoder != nil || opts.putFields != nil) {
+		return fmt.Errorf("servePatch can only be true when bodyDecoder and putFields are not set")
+	}
+	if opts.servePut && opts.serveBulkPut && (opts.bodyDecoder != nil || opts.putFields != nil) {
+		return fmt.Errorf("serveBulkPut can only be true when bodyDecoder and putFields are not set")
+	}
+	if opts.versionRetention != nil && !opts.versionHistory {
+		return fmt.Errorf("versionRetention can only be set when versionHistory is true")
+	}
+	for permission := range opts.permissions {
+		switch permission {
+		case PermissionRead, PermissionCreate, PermissionUpdate, PermissionDelete:
+		default:
+			return fmt.Errorf("unknown permission %s", permission)
+		}
+	}
 	if opts.schemaInfo.GetNestedDocPath() != "" && !opts.servePostV2ListRequests {
 		return fmt.Errorf("nestedDocPath can only be set when servePostV2ListRequests is true")
 	}
 	if opts.schemaInfo.GetNestedDocPath() != "" && (opts.serveDelete || opts.servePost || opts.serveGet || opts.servePut) {
 		return fmt.Errorf("nestedDocPath can only be set when servePost, serveDelete, serveGet and servePut are false")
 	}
+	if opts.schemaInfo.GetNestedDocPath() != "" && opts.serveWatch {
+		return fmt.Errorf("nestedDocPath can only be set when serveWatch is false")
+	}
 	return nil
 }
 
@@ -261,6 +415,9 @@ func addRouteInfo[T types.DocContent](options *routerOptions[T]) {
 		DBCollection: options.dbCollection,
 		Schema:       options.schemaInfo,
 	}
+	if roles, ok := options.permissions[PermissionRead]; ok {
+		apiInfo.ReadRoles = append([]string{}, roles...)
+	}
 	types.SetAPIInfo(options.path, apiInfo)
 	//keep the admin query handler for this route
 	coll2AdminQueryHandler[options.dbCollection] = HandleAdminPostV2ListRequest[T]
@@ -351,6 +508,13 @@ func (b *RouterOptionsBuilder[T]) WithV2ListSearch(servePostV2ListRequests bool)
 	return b
 }
 
+func (b *RouterOptionsBuilder[T]) WithServeExport(serveExport bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.serveExport = serveExport
+	})
+	return b
+}
+
 func (b *RouterOptionsBuilder[T]) WithServePut(servePut bool) *RouterOptionsBuilder[T] {
 	b.options = append(b.options, func(opts *routerOptions[T]) {
 		opts.servePut = servePut
@@ -365,6 +529,34 @@ func (b *RouterOptionsBuilder[T]) WithServeDelete(serveDelete bool) *RouterOptio
 	return b
 }
 
+func (b *RouterOptionsBuilder[T]) WithServeBulkPut(serveBulkPut bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.serveBulkPut = serveBulkPut
+	})
+	return b
+}
+
+func (b *RouterOptionsBuilder[T]) WithServePatch(servePatch bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.servePatch = servePatch
+	})
+	return b
+}
+
+func (b *RouterOptionsBuilder[T]) WithServeUpsert(serveUpsert bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.serveUpsert = serveUpsert
+	})
+	return b
+}
+
+func (b *RouterOptionsBuilder[T]) WithServeImport(serveImport bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.serveImport = serveImport
+	})
+	return b
+}
+
 func (b *RouterOptionsBuilder[T]) WithServeBulkDelete(serveBulkDelete bool) *RouterOptionsBuilder[T] {
 	b.options = append(b.options, func(opts *routerOptions[T]) {
 		opts.serveBulkDelete = serveBulkDelete
@@ -467,6 +659,72 @@ func (b *RouterOptionsBuilder[T]) WithGetNamesList(serveNameList bool) *RouterOp
 	return b
 }
 
+// WithPermissions declares the roles allowed to perform each operation, operations without declared roles are not restricted
+// e.g. WithPermissions(map[Permission][]string{PermissionRead: {"viewer", "editor"}, PermissionUpdate: {"editor"}})
+func (b *RouterOptionsBuilder[T]) WithPermissions(permissions map[Permission][]string) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.permissions = permissions
+	})
+	return b
+}
+
+// configuredPermissions are the permissions of the routes by path set by the configuration, they replace the permissions declared by the routes
+var configuredPermissions = map[string]map[Permission][]string{}
+
+// SetRoutePermissions sets the configured permissions of the routes by path, it must be called before the routes are added
+// e.g. SetRoutePermissions(map[string]map[string][]string{"/v1_posture_exception_policy": {"read": {"viewer", "editor"}, "update": {"editor"}}})
+func SetRoutePermissions(permissions map[string]map[string][]string) {
+	configuredPermissions = map[string]map[Permission][]string{}
+	for path, routePermissions := range permissions {
+		configuredPermissions[path] = map[Permission][]string{}
+		for permission, roles := range routePermissions {
+			configuredPermissions[path][Permission(permission)] = roles
+		}
+	}
+}
+
+// WithVersionHistory keeps the prior revisions of documents modified by PUT and DELETE and serves the versions and restore routes
+func (b *RouterOptionsBuilder[T]) WithVersionHistory(versionHistory bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.versionHistory = versionHistory
+	})
+	return b
+}
+
+// WithVersionRetention sets the version history retention of the route instead of the configuration retention
+func (b *RouterOptionsBuilder[T]) WithVersionRetention(retention types.VersionRetention) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.versionRetention = &retention
+	})
+	return b
+}
+
+// WithSoftDelete moves deleted documents to the trash instead of removing them and serves the trash and restore routes
+func (b *RouterOptionsBuilder[T]) WithSoftDelete(softDelete bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.softDelete = softDelete
+	})
+	return b
+}
+
+// WithQueryCache caches the results of the list, query, unique values and count requests for the ttl
+// writes to the collection invalidate the cached results of the writing customer, the writes of other instances are applied from the cache invalidations they publish
+// results are stale until the ttl only when an invalidation of another instance is missed
+func (b *RouterOptionsBuilder[T]) WithQueryCache(ttl time.Duration) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.queryCacheTTL = ttl
+	})
+	return b
+}
+
+// WithWatch serves GET /<path>/watch to stream the changes of the customer documents as server-sent events
+func (b *RouterOptionsBuilder[T]) WithWatch(serveWatch bool) *RouterOptionsBuilder[T] {
+	b.options = append(b.options, func(opts *routerOptions[T]) {
+		opts.serveWatch = serveWatch
+	})
+	return b
+}
+
 func (b *RouterOptionsBuilder[T]) WithContainerHandler(path string, containerHandler ContainerHandler, containerType ContainerType, servePut, serveDelete bool) *RouterOptionsBuilder[T] {
 	if path == "" || containerHandler == nil {
 		panic("path and ContainerHandler are mandatory")
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetDeletedDocs - get the customer documents in the trash, latest deleted first
+func HandleGetDeletedDocs[T types.DocContent](c *gin.Context) {
+	defer log.LogNTraceEnterExit("HandleGetDeletedDocs", c)()
+	if docs, err := db.GetDeletedForCustomer[T](c); err != nil {
+		ResponseInternalServerError(c, "failed to read deleted documents", err)
+	} else {
+		c.JSON(http.StatusOK, docs)
+	}
+}
+
+// HandleRestoreDeletedDoc - restore a document by id in path from the trash
+// when validateUniqueName is set a document is not restored if another document with the same name exists
+func HandleRestoreDeletedDoc[T types.DocContent](validateUniqueName bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer log.LogNTraceEnterExit("HandleRestoreDeletedDoc", c)()
+		guid := c.Param(consts.GUIDField)
+		if guid == "" {
+			ResponseMissingGUID(c)
+			return
+		}
+		if validateUniqueName {
+			deleted, err := db.GetDoc[T](c, db.NewFilterBuilder().WithCustomer(c).WithDeleted().WithID(guid))
+			if err != nil {
+				ResponseInternalServerError(c, "failed to read deleted document", err)
+				return
+			} else if deleted == nil {
+				ResponseDocumentNotFound(c)
+				return
+			}
+			if name := (*deleted).GetName(); name != "" {
+				if exist, err := db.DocWithNameExist(c, name); err != nil {
+					ResponseInternalServerError(c, "failed to validate unique name", err)
+					return
+				} else if exist {
+					ResponseDuplicateNames(c, name)
+					return
+				}
+			}
+		}
+		if restored, revision, err := db.RestoreDeleted[T](c, guid); err != nil {
+			ResponseInternalServerError(c, "failed to restore document", err)
+		} else if restored == nil {
+			ResponseDocumentNotFound(c)
+		} else {
+			AuditDocMutation(c, guid, nil, *restored)
+			PublishDocMutation(c, guid, nil, *restored)
+			c.Set(consts.DocRevision, revision)
+			docResponse(c, restored)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+)
+
+// HandleUpsert - serves POST and PUT requests with upsert=true query param, other requests are passed to the next handlers
+// the document is updated if it exists (by GUID or by name when names are unique) and created otherwise
+// created documents are validated by the POST validators and updated documents by the PUT validators
+// upsert requests need both the create and update permissions
+// a document created by a concurrent upsert of the same GUID or name is updated
+// a GUID of the request that is not found is not used as is, the created document gets a GUID derived from the customer and the GUID
+func HandleUpsert[T types.DocContent](opts *routerOptions[T]) gin.HandlerFunc {
+	postValidators, putValidators := opts.getPostValidators(), opts.getPutValidators()
+	//creates by name skip the unique name validation, the name was not found and concurrent upserts of the name create the same GUID
+	upsertByNameValidators := opts.buildPostValidators(false)
+	return func(c *gin.Context) {
+		if upsert, _ := strconv.ParseBool(c.Query(consts.UpsertParam)); !upsert {
+			c.Next()
+			return
+		}
+		defer log.LogNTraceEnterExit("HandleUpsert", c)()
+		//the request is served here, skip the POST and PUT handlers
+		c.Abort()
+		if !opts.permitted(c, PermissionCreate) || !opts.permitted(c, PermissionUpdate) {
+			return
+		}
+		doc, ok := decodeUpsertDoc[T](c)
+		if !ok {
+			return
+		}
+		if guid := c.Param(consts.GUIDField); guid != "" {
+			doc.SetGUID(guid)
+		}
+		existing, err := findUpsertDoc(c, doc, opts.validatePostUniqueName)
+		if err != nil {
+			ResponseInternalServerError(c, "failed to read document", err)
+			return
+		}
+		if existing == nil {
+			validators, guid, byName := postValidators, doc.GetGUID(), false
+			if guid != "" {
+				guid = upsertGUID(c, guid)
+			} else if opts.validatePostUniqueName && doc.GetName() != "" {
+				validators, guid, byName = upsertByNameValidators, upsertNameGUID(c, doc.GetName()), true
+			}
+			if responded := upsertCreate(c, doc, validators, guid); responded {
+				return
+			}
+			//the GUID was created by a concurrent upsert
+			if existing, err = db.GetDocByGUID[T](c, guid); err != nil {
+				ResponseInternalServerError(c, "failed to read document", err)
+				return
+			}
+			if existing == nil && byName {
+				//the document of the name GUID is in the trash or was deleted, the name is free
+				if responded := upsertCreate(c, doc, postValidators, ""); !responded {
+					ResponseConflict(c, consts.GUIDField, doc.GetGUID())
+				}
+				return
+			}
+			if existing == nil {
+				//the document of the derived GUID is in the trash
+				ResponseConflict(c, consts.GUIDField, guid)
+				return
+			}
+		}
+		doc.SetGUID((*existing).GetGUID())
+		//the PUT validators take the GUID in path, it is the GUID of the existing document (which may be derived from it)
+		for i := range c.Params {
+			if c.Params[i].Key == consts.GUIDField {
+				c.Params[i].Value = doc.GetGUID()
+			}
+		}
+		upsertUpdate(c, doc, putValidators)
+	}
+}
+
+// decodeUpsertDoc returns the document of an upsert request body, upsert of a list of documents is not supported
+func decodeUpsertDoc[T types.DocContent](c *gin.Context) (T, bool) {
+	var docs []T
+	var err error
+	if customDecoder, _ := GetCustomBodyDecoder[T](c); customDecoder != nil {
+		docs, err = customDecoder(c)
+	} else {
+		docs, err = GetBulkOrSingleBody[T](c)
+	}
+	if err != nil {
+		ResponseFailedToBindJson(c, err)
+		return nil, false
+	}
+	if len(docs) != 1 || docs[0] == nil {
+		ResponseBadRequest(c, "upsert request must have a single document")
+		return nil, false
+	}
+	return docs[0], true
+}
+
+// findUpsertDoc returns the existing document of an upsert request by the document GUID (or the GUID derived from it)
+// or by the document name when the names are unique and the document has no GUID, nil if not found
+func findUpsertDoc[T types.DocContent](c *gin.Context, doc T, uniqueName bool) (*T, error) {
+	if guid := doc.GetGUID(); guid != "" {
+		existing, err := db.GetDocByGUID[T](c, guid)
+		if existing != nil || err != nil {
+			return existing, err
+		}
+		return db.GetDocByGUID[T](c, upsertGUID(c, guid))
+	}
+	if uniqueName && doc.GetName() != "" {
+		return db.GetDocByName[T](c, doc.GetName())
+	}
+	return nil, nil
+}
+
+// upsertNameGUID returns the GUID of a document created by an upsert by name, concurrent upserts of a name create the same GUID
+func upsertNameGUID(c *gin.Context, name string) string {
+	return uuid.NewV5(uuid.NamespaceOID, c.GetString(consts.CustomerGUID)+"/"+name).String()
+}
+
+// upsertGUID returns the GUID of a document created by an upsert of a GUID that was not found
+// a client GUID is never used as is, it may be the GUID of a document of another customer
+func upsertGUID(c *gin.Context, guid string) string {
+	//a different namespace than the names, a name and a GUID of the same value do not collide
+	return uuid.NewV5(uuid.NamespaceURL, c.GetString(consts.CustomerGUID)+"/"+guid).String()
+}
+
+// upsertCreate validates and creates the document of an upsert request with the GUID, an empty GUID is generated
+// returns false without responding if a document with the GUID exists, the caller updates it
+func upsertCreate[T types.DocContent](c *gin.Context, doc T, validators []MutatorValidator[T], guid string) (responded bool) {
+	if _, ok := c.Get(consts.IfMatch); ok {
+		//there is no revision to match
+		ResponsePreconditionFailed(c)
+		return true
+	}
+	c.Set(consts.UpsertMethod, http.MethodPost)
+	docs, ok := validateUpsertDoc(c, doc, validators)
+	if !ok {
+		return true
+	}
+	dbDoc := newDocumentWithGUID(c, docs[0], guid)
+	if _, err := db.InsertDBDocument(c, dbDoc); db.IsDuplicateKeyError(err) {
+		return false
+	} else if err != nil {
+		ResponseInternalServerError(c, "failed to create document", err)
+		return true
+	}
+	AuditDocMutation(c, dbDoc.ID, nil, dbDoc.Content)
+	PublishDocMutation(c, dbDoc.ID, nil, dbDoc.Content)
+	setETag(c, dbDoc.Revision)
+	c.JSON(http.StatusCreated, types.UpsertResponse[T]{Status: types.UpsertStatusCreated, Revision: dbDoc.Revision, Document: dbDoc.Content})
+	return true
+}
+
+// newDocumentWithGUID returns a new db document of the customer with the content, a non empty GUID replaces the generated one
+func newDocumentWithGUID[T types.DocContent](c *gin.Context, content T, guid string) types.Document[T] {
+	dbDoc := types.NewDocument(content, c.GetString(consts.CustomerGUID))
+	if guid != "" {
+		dbDoc.ID = guid
+		dbDoc.Content.SetGUID(guid)
+	}
+	return dbDoc
+}
+
+// upsertUpdate validates and updates the existing document of an upsert request
+func upsertUpdate[T types.DocContent](c *gin.Context, doc T, validators []MutatorValidator[T]) {
+	c.Set(consts.UpsertMethod, http.MethodPut)
+	docs, ok := validateUpsertDoc(c, doc, validators)
+	if !ok {
+		return
+	}
+	res, revision, ok := updateDoc(c, docs[0])
+	if !ok {
+		return
+	}
+	setETag(c, revision)
+	c.JSON(http.StatusOK, types.UpsertResponse[T]{Status: types.UpsertStatusUpdated, Revision: revision, Document: res[1]})
+}
+
+// validateUpsertDoc runs the validators on the document of an upsert request, the validators respond on failure
+func validateUpsertDoc[T types.DocContent](c *gin.Context, doc T, validators []MutatorValidator[T]) ([]T, bool) {
+	docs := []T{doc}
+	for _, validator := range validators {
+		var ok bool
+		if docs, ok = validator.validate(c, docs); !ok {
+			return nil, false
+		}
+	}
+	return docs, true
+}
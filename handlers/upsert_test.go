@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleUpsertPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	opts := newRouterOptions[*types.PostureExceptionPolicy]()
+	opts.apply(NewRouterOptionsBuilder[*types.PostureExceptionPolicy]().
+		WithPath(consts.PostureExceptionPolicyPath).
+		WithPermissions(map[Permission][]string{PermissionCreate: {"creator", "editor"}, PermissionUpdate: {"editor"}}).Get())
+	tests := []struct {
+		name     string
+		query    string
+		roles    []string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "not an upsert", query: "", roles: []string{"creator"}, wantCode: http.StatusOK},
+		{name: "upsert false", query: "?upsert=false", roles: []string{"creator"}, wantCode: http.StatusOK},
+		{name: "upsert without update permission", query: "?upsert=true", roles: []string{"creator"}, wantCode: http.StatusForbidden, wantBody: `{"error":"missing update permission for /v1_posture_exception_policy"}`},
+		{name: "upsert of a list", query: "?upsert=true", roles: []string{"editor"}, body: `[{"name":"a"},{"name":"b"}]`, wantCode: http.StatusBadRequest, wantBody: `{"error":"upsert request must have a single document"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/v1_posture_exception_policy", func(c *gin.Context) {
+				c.Set(consts.UserRoles, tt.roles)
+			}, HandleUpsert(opts), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/v1_posture_exception_policy"+tt.query, strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"config-service/db"
 	"config-service/utils"
 	"config-service/utils/consts"
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -16,15 +17,15 @@ import (
 
 const maxV2PageSize = 150
 
-func V2List2FindOptionsPaginated(ctx *gin.Context, request armotypes.V2ListRequest) (*db.FindOptions, error) {
+func V2List2FindOptionsPaginated(ctx context.Context, request armotypes.V2ListRequest) (*db.FindOptions, error) {
 	return v2List2FindOptions(ctx, request, true)
 }
 
-func V2List2FindOptionsNotPaginated(ctx *gin.Context, request armotypes.V2ListRequest) (*db.FindOptions, error) {
+func V2List2FindOptionsNotPaginated(ctx context.Context, request armotypes.V2ListRequest) (*db.FindOptions, error) {
 	return v2List2FindOptions(ctx, request, false)
 }
 
-func v2List2FindOptions(ctx *gin.Context, request armotypes.V2ListRequest, withPagination bool) (*db.FindOptions, error) {
+func v2List2FindOptions(ctx context.Context, request armotypes.V2ListRequest, withPagination bool) (*db.FindOptions, error) {
 	if withPagination {
 		request.ValidatePageProperties(maxV2PageSize)
 	}
@@ -42,7 +43,12 @@ func v2List2FindOptions(ctx *gin.Context, request armotypes.V2ListRequest, withP
 		if request.PageNum != nil {
 			page = *request.PageNum
 		}
+		//the cursor replaces the page number
+		if request.CursorDepracated != "" && page > 0 {
+			return nil, fmt.Errorf("cursor cannot be used with pageNum")
+		}
 		findOptions.SetPagination(int64(page), int64(perPage))
+		findOptions.WithCursor(request.CursorDepracated)
 	}
 	//sort
 	tsField := db.GetSchemaFromContext(ctx).GetTimestampFieldName()
@@ -165,7 +171,7 @@ func uniqueValuesRequest2FindOptions(ctx *gin.Context, request armotypes.UniqueV
 
 // buildInnerFilter builds a filter from a map of key value pairs
 // if it calls itself recursively (e.g. for element match operator) the rootField must be the array field path
-func buildInnerFilter(ctx *gin.Context, innerFilter map[string]string, rootField string) (*db.FilterBuilder, error) {
+func buildInnerFilter(ctx context.Context, innerFilter map[string]string, rootField string) (*db.FilterBuilder, error) {
 	filterBuilder := db.NewFilterBuilder()
 	schemaInfo := db.GetSchemaFromContext(ctx)
 	var elemMatches map[string]map[string]string
@@ -296,7 +302,7 @@ func buildInnerFilter(ctx *gin.Context, innerFilter map[string]string, rootField
 	return filterBuilder, nil
 }
 
-func getTypedValue(ctx *gin.Context, field, value string) (interface{}, error) {
+func getTypedValue(ctx context.Context, field, value string) (interface{}, error) {
 	schemaInfo := db.GetSchemaFromContext(ctx)
 	if schemaInfo.IsString(field) {
 		return value, nil
@@ -34,12 +34,12 @@ func ValidateUniqueValues[T types.DocContent](uniqueKeyValues ...UniqueKeyValueI
 	return func(c *gin.Context, docs []T) ([]T, bool) {
 		findOpts := db.NewFindOptions()
 		keys2Values := map[string][]string{}
-		switch c.Request.Method {
+		switch validationMethod(c) {
 		case "POST":
 			if !buildValidateUniqueValuesPostQuery[T](c, docs, findOpts, keys2Values, uniqueKeyValues...) {
 				return nil, false
 			}
-		case "PUT":
+		case "PUT", "PATCH":
 			if !buildValidateUniqueValuesPutQuery[T](c, docs, findOpts, keys2Values, uniqueKeyValues...) {
 				return nil, false
 			}
@@ -69,6 +69,15 @@ func ValidateUniqueValues[T types.DocContent](uniqueKeyValues ...UniqueKeyValueI
 	}
 }
 
+// validationMethod returns the method the request documents are validated as
+// upsert requests are validated as POST when the document is created and as PUT when it is updated
+func validationMethod(c *gin.Context) string {
+	if method := c.GetString(consts.UpsertMethod); method != "" {
+		return method
+	}
+	return c.Request.Method
+}
+
 func buildValidateUniqueValuesPostQuery[T types.DocContent](c *gin.Context, docs []T, findOpts *db.FindOptions, keys2Values map[string][]string, uniqueKeyValues ...UniqueKeyValueInfo[T]) bool {
 	for _, uniqueKeyValue := range uniqueKeyValues {
 		key, mandatory, valueGetter := uniqueKeyValue()
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveDocVersion stores a prior revision of a document when the route keeps version history
+// failures are logged and do not fail the request
+func saveDocVersion[T types.DocContent](c *gin.Context, docGUID string, doc T, revision int64) {
+	if err := insertDocVersion(c, c, docGUID, doc, revision); err != nil {
+		log.LogNTraceError("failed to save document version", err, c)
+	}
+}
+
+// insertDocVersion stores a prior revision of a document of the request c with the db context tc (e.g. of a transaction) when the route keeps version history
+func insertDocVersion[T types.DocContent](tc context.Context, c *gin.Context, docGUID string, doc T, revision int64) error {
+	iRetention, ok := c.Get(consts.VersionHistory)
+	if !ok {
+		return nil
+	}
+	retention, _ := iRetention.(types.VersionRetention)
+	version := &types.DocVersion[T]{
+		DocGUID: docGUID,
+		Version: revision,
+		Verb:    c.Request.Method,
+		Actor:   actorFromContext(c),
+		Content: doc,
+	}
+	return db.InsertDocVersion(tc, version, retention)
+}
+
+// keepsVersions returns true if the route of the request keeps version history
+func keepsVersions(c *gin.Context) bool {
+	_, ok := c.Get(consts.VersionHistory)
+	return ok
+}
+
+// HandleGetDocVersions - get the stored versions of a document by id in path, newest first
+func HandleGetDocVersions[T types.DocContent](c *gin.Context) {
+	defer log.LogNTraceEnterExit("HandleGetDocVersions", c)()
+	guid := c.Param(consts.GUIDField)
+	if guid == "" {
+		ResponseMissingGUID(c)
+		return
+	}
+	if versions, err := db.GetDocVersions[T](c, guid); err != nil {
+		ResponseInternalServerError(c, "failed to read document versions", err)
+	} else {
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// HandleGetDocVersion - get a stored version of a document by id and version in path
+func HandleGetDocVersion[T types.DocContent](c *gin.Context) {
+	defer log.LogNTraceEnterExit("HandleGetDocVersion", c)()
+	guid, version, ok := versionParams(c)
+	if !ok {
+		return
+	}
+	if docVersion, err := db.GetDocVersion[T](c, guid, version); err != nil {
+		ResponseInternalServerError(c, "failed to read document version", err)
+	} else if docVersion == nil {
+		ResponseDocumentNotFound(c)
+	} else {
+		c.JSON(http.StatusOK, docVersion)
+	}
+}
+
+// HandleRestoreDocVersion - restore a document by id in path to the version in path, a deleted document is recreated
+func HandleRestoreDocVersion[T types.DocContent](c *gin.Context) {
+	defer log.LogNTraceEnterExit("HandleRestoreDocVersion", c)()
+	guid, version, ok := versionParams(c)
+	if !ok {
+		return
+	}
+	docVersion, err := db.GetDocVersion[T](c, guid, version)
+	if err != nil {
+		ResponseInternalServerError(c, "failed to read document version", err)
+		return
+	} else if docVersion == nil {
+		ResponseDocumentNotFound(c)
+		return
+	}
+	restored := docVersion.Content
+	restored.SetUpdatedTime(nil)
+
+	current, err := db.GetDocByGUID[T](c, guid)
+	if err != nil {
+		ResponseInternalServerError(c, "failed to read document", err)
+		return
+	} else if current == nil {
+		recreateDocVersion(c, guid, restored)
+		return
+	}
+	update, err := db.GetReplaceDocCommand(*current, restored)
+	if err != nil {
+		ResponseInternalServerError(c, "failed to generate update command", err)
+		return
+	}
+	if res, revision, err := db.UpdateDocumentWithRevision[T](c, guid, update); err != nil {
+		ResponseInternalServerError(c, "failed to restore document", err)
+	} else if res == nil {
+		ResponseDocumentNotFound(c)
+	} else {
+		saveDocVersion(c, guid, res[0], revision-1)
+		AuditDocMutation(c, guid, res[0], res[1])
+		PublishDocMutation(c, guid, res[0], res[1])
+		c.Set(consts.DocRevision, revision)
+		docResponse(c, &res[1])
+	}
+}
+
+// recreateDocVersion inserts a deleted document with a revision following its latest version
+// a document in the trash of a soft delete route is not recreated, it must be restored from the trash first
+func recreateDocVersion[T types.DocContent](c *gin.Context, guid string, restored T) {
+	if _, ok := db.ExpectedRevisionsFromContext(c); ok {
+		//the document does not exist so no revision can match
+		ResponsePreconditionFailed(c)
+		return
+	}
+	versions, err := db.GetDocVersions[T](c, guid)
+	if err != nil {
+		ResponseInternalServerError(c, "failed to read document versions", err)
+		return
+	}
+	dbDoc := types.Document[T]{
+		ID:        guid,
+		Customers: []string{c.GetString(consts.CustomerGUID)},
+		Revision:  types.NewDocumentRevision,
+		Content:   restored,
+	}
+	if len(versions) > 0 {
+		dbDoc.Revision = versions[0].Version + 1
+	}
+	if _, err := db.InsertDBDocument(c, dbDoc); db.IsDuplicateKeyError(err) {
+		respondRecreateConflict[T](c, guid)
+		return
+	} else if err != nil {
+		ResponseInternalServerError(c, "failed to restore document", err)
+		return
+	}
+	AuditDocMutation(c, guid, nil, restored)
+	PublishDocMutation(c, guid, nil, restored)
+	c.Set(consts.DocRevision, dbDoc.Revision)
+	docResponse(c, &restored)
+}
+
+// respondRecreateConflict responds to a recreated document whose GUID exists, the document is in the trash or was recreated concurrently
+func respondRecreateConflict[T types.DocContent](c *gin.Context, guid string) {
+	deleted, err := db.GetDoc[T](c, db.NewFilterBuilder().WithCustomer(c).WithDeleted().WithID(guid))
+	if err != nil {
+		ResponseInternalServerError(c, "failed to read deleted document", err)
+	} else if deleted != nil {
+		ResponseDocumentInTrash(c)
+	} else {
+		ResponseConcurrentModification(c)
+	}
+}
+
+func versionParams(c *gin.Context) (guid string, version int64, ok bool) {
+	guid = c.Param(consts.GUIDField)
+	if guid == "" {
+		ResponseMissingGUID(c)
+		return "", 0, false
+	}
+	version, err := strconv.ParseInt(c.Param(consts.VersionParam), 10, 64)
+	if err != nil || version < 0 {
+		ResponseBadRequest(c, consts.VersionParam+" must be a number")
+		return "", 0, false
+	}
+	return guid, version, true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreVersionOfTrashedDoc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db.SetStore(memory.NewStore())
+	defer db.GetStore().Disconnect()
+	const collection = "versionsTrashTest"
+	db.SetSoftDelete(collection)
+	c := context.WithValue(context.WithValue(context.Background(), consts.Collection, collection), consts.CustomerGUID, "customer-guid")
+	repo := &types.Repository{}
+	repo.Name = "repo"
+	docs, err := db.InsertDocuments(c, []*types.Repository{repo})
+	require.NoError(t, err)
+	guid := docs[0].GUID
+	require.NoError(t, db.InsertDocVersion(c, &types.DocVersion[*types.Repository]{DocGUID: guid, Version: 1, Content: docs[0]}, types.VersionRetention{}))
+	_, _, err = db.DeleteByGUIDWithRevision[*types.Repository](c, guid)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST(fmt.Sprintf("/:%s/versions/:%s/restore", consts.GUIDField, consts.VersionParam), func(c *gin.Context) {
+		c.Set(consts.Collection, collection)
+		c.Set(consts.CustomerGUID, "customer-guid")
+		c.Set(consts.VersionHistory, types.VersionRetention{})
+	}, HandleRestoreDocVersion[*types.Repository])
+	restore := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/"+guid+"/versions/1/restore", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	//a document in the trash is not recreated
+	w := restore()
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error":"`+DocumentInTrash+`"}`, w.Body.String())
+
+	//a document restored from the trash is restored to the version
+	_, _, err = db.RestoreDeleted[*types.Repository](c, guid)
+	require.NoError(t, err)
+	w = restore()
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/gin-gonic/gin"
+)
+
+// interval of keep alive comments sent to watchers when there are no changes
+const watchKeepAliveInterval = 15 * time.Second
+
+// HandleWatch - stream the insert, update and delete events of the customer documents as server-sent events
+// the documents are filtered by the inner filters of an optional V2ListRequest in the "filter" query param or in the body
+// the Last-Event-ID header resumes after the last event the client received
+func HandleWatch[T types.DocContent](c *gin.Context) {
+	defer log.LogNTraceEnterExit("HandleWatch", c)()
+	var req armotypes.V2ListRequest
+	if filter := c.Query(consts.FilterParam); filter != "" {
+		if err := json.Unmarshal([]byte(filter), &req); err != nil {
+			ResponseBadRequest(c, fmt.Sprintf("invalid %s param: %s", consts.FilterParam, err.Error()))
+			return
+		}
+	} else if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			ResponseFailedToBindJson(c, err)
+			return
+		}
+	}
+	findOpts, err := V2List2FindOptionsNotPaginated(c, req)
+	if err != nil {
+		ResponseBadRequest(c, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	events := make(chan types.ChangeEvent[T])
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- db.WatchForCustomer(c, findOpts.Filter(), c.GetHeader("Last-Event-ID"), events)
+	}()
+	keepAlive := time.NewTicker(watchKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-events:
+			writeServerSentEvent(c, event.ID, event.Operation, event)
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case err := <-watchErr:
+			if err != nil && c.Err() == nil {
+				log.LogNTraceError("failed to watch documents", err, c)
+				writeServerSentEvent(c, "", "error", gin.H{"error": err.Error()})
+			}
+			return
+		}
+	}
+}
+
+func writeServerSentEvent(c *gin.Context, id, event string, data interface{}) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		log.LogNTraceError("failed to marshal server-sent event", err, c)
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(c.Writer, "id: %s\n", id)
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, dataJSON)
+	c.Writer.Flush()
+}
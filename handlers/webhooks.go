@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"config-service/webhooks"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PublishDocMutation publishes the created, updated or deleted event of a mutated document to the customer webhook subscriptions
+// a nil old document is a creation and a nil new document is a deletion, mutations of container items have partial documents and are not published
+func PublishDocMutation(c *gin.Context, docGUID string, oldDoc, newDoc interface{}) {
+	oldDoc, newDoc = fullDoc(oldDoc), fullDoc(newDoc)
+	event := types.WebhookEvent{
+		CustomerGUID: c.GetString(consts.CustomerGUID),
+		Collection:   c.GetString(consts.Collection),
+		DocGUID:      docGUID,
+	}
+	doc := newDoc
+	switch {
+	case oldDoc == nil && newDoc != nil:
+		event.Type = types.WebhookEventCreated
+	case oldDoc != nil && newDoc != nil:
+		event.Type = types.WebhookEventUpdated
+	case oldDoc != nil:
+		event.Type = types.WebhookEventDeleted
+		doc = oldDoc
+	default:
+		return
+	}
+	if err := webhooks.Publish(c, event, doc); err != nil {
+		log.LogNTraceError("failed to publish webhook event", err, c)
+	}
+}
+
+// fullDoc returns the document content of a published document (or a pointer to it), nil for partial documents
+func fullDoc(doc interface{}) interface{} {
+	v := reflect.ValueOf(doc)
+	for v.IsValid() && v.Kind() == reflect.Ptr && !v.IsNil() {
+		if _, ok := v.Interface().(interface{ GetGUID() string }); ok {
+			return v.Interface()
+		}
+		v = v.Elem()
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package health
+
+import (
+	"config-service/types"
+	"context"
+	"sync"
+	"time"
+)
+
+// Check returns an error if the checked dependency is not ready
+type Check func(ctx context.Context) error
+
+// Config configures the readiness checks
+type Config struct {
+	CacheTTL time.Duration //a report is reused for the cache ttl, 0 runs the checks on every report
+	Timeout  time.Duration //timeout of each check, 0 waits for the checks to return
+}
+
+var config = Config{
+	CacheTTL: 2 * time.Second,
+	Timeout:  5 * time.Second,
+}
+
+// registeredCheck is a registered readiness check, an informational check is reported without failing the report
+type registeredCheck struct {
+	check         Check
+	informational bool
+}
+
+var (
+	checksLock sync.RWMutex
+	checks     = map[string]registeredCheck{}
+
+	reportLock sync.Mutex
+	lastReport *types.HealthReport
+)
+
+// Configure sets the readiness checks configuration and removes the registered checks
+func Configure(conf Config) {
+	checksLock.Lock()
+	defer checksLock.Unlock()
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	config = conf
+	checks = map[string]registeredCheck{}
+	lastReport = nil
+}
+
+// Register adds a readiness check, a check with the same name is replaced
+func Register(name string, check Check) {
+	register(name, registeredCheck{check: check})
+}
+
+// RegisterInformational adds a readiness check that is reported without failing the readiness, a check with the same name is replaced
+func RegisterInformational(name string, check Check) {
+	register(name, registeredCheck{check: check, informational: true})
+}
+
+func register(name string, check registeredCheck) {
+	checksLock.Lock()
+	defer checksLock.Unlock()
+	checks[name] = check
+}
+
+// Report runs all the registered checks in parallel and returns their results
+// a report is reused for the cache ttl so frequent probes do not load the checked dependencies
+func Report(ctx context.Context) types.HealthReport {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	if lastReport != nil && time.Since(lastReport.CheckedAt) < config.CacheTTL {
+		return *lastReport
+	}
+	//a cancelled probe request does not fail the cached report
+	report := runChecks(context.WithoutCancel(ctx))
+	lastReport = &report
+	return report
+}
+
+func runChecks(ctx context.Context) types.HealthReport {
+	checksLock.RLock()
+	defer checksLock.RUnlock()
+	report := types.HealthReport{
+		Status:    types.HealthOK,
+		CheckedAt: time.Now().UTC(),
+		Checks:    make(map[string]types.HealthCheckResult, len(checks)),
+	}
+	resultsLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check registeredCheck) {
+			defer wg.Done()
+			result := runCheck(ctx, check.check)
+			result.Informational = check.informational
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+			report.Checks[name] = result
+			if result.Status != types.HealthOK && !check.informational {
+				report.Status = types.HealthFailed
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+func runCheck(ctx context.Context, check Check) types.HealthCheckResult {
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	//a check that does not return on timeout does not block the report
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := types.HealthCheckResult{Status: types.HealthOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status, result.Error = types.HealthFailed, err.Error()
+	}
+	return result
+}
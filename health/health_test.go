@@ -0,0 +1,75 @@
+package health
+
+import (
+	"config-service/types"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	Configure(Config{CacheTTL: time.Hour, Timeout: 50 * time.Millisecond})
+	var calls atomic.Int32
+	Register("ok", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	report := Report(context.Background())
+	assert.Equal(t, types.HealthOK, report.Status)
+	assert.Equal(t, types.HealthOK, report.Checks["ok"].Status)
+	assert.Empty(t, report.Checks["ok"].Error)
+
+	//the report is cached
+	Register("failing", func(ctx context.Context) error {
+		return errors.New("not ready")
+	})
+	assert.Equal(t, report, Report(context.Background()))
+	assert.Equal(t, int32(1), calls.Load())
+
+	//failing and timed out checks fail the report
+	Configure(Config{Timeout: 50 * time.Millisecond})
+	Register("ok", func(ctx context.Context) error {
+		return nil
+	})
+	Register("failing", func(ctx context.Context) error {
+		return errors.New("not ready")
+	})
+	Register("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	report = Report(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, types.HealthFailed, report.Status)
+	assert.Len(t, report.Checks, 3)
+	assert.Equal(t, types.HealthOK, report.Checks["ok"].Status)
+	assert.Equal(t, types.HealthCheckResult{Status: types.HealthFailed, Error: "not ready", DurationMs: report.Checks["failing"].DurationMs}, report.Checks["failing"])
+	assert.Equal(t, types.HealthFailed, report.Checks["stuck"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+
+	//a cancelled request does not fail the checks
+	Configure(Config{})
+	Register("ok", func(ctx context.Context) error {
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, types.HealthOK, Report(ctx).Status)
+
+	//a failed informational check is reported without failing the readiness
+	Configure(Config{})
+	Register("ok", func(ctx context.Context) error {
+		return nil
+	})
+	RegisterInformational("secondary", func(ctx context.Context) error {
+		return errors.New("no secondary")
+	})
+	report = Report(context.Background())
+	assert.Equal(t, types.HealthOK, report.Status)
+	assert.Equal(t, types.HealthCheckResult{Status: types.HealthFailed, Error: "no secondary", DurationMs: report.Checks["secondary"].DurationMs, Informational: true}, report.Checks["secondary"])
+}
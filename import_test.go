@@ -0,0 +1,138 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func (suite *MainTestSuite) TestImport() {
+	policies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	existing := testPostDoc(suite, consts.PostureExceptionPolicyPath, policies[0], commonCmpFilter)
+	importPath := consts.PostureExceptionPolicyPath + "/import"
+	doImport := func(query string, body []byte, contentType string, expectedCode int) types.ImportResponse {
+		w := suite.doRequestWithHeaders(http.MethodPost, importPath+query, body, map[string]string{"Content-Type": contentType})
+		suite.Equal(expectedCode, w.Code, w.Body.String())
+		return decode[types.ImportResponse](suite, w.Body.Bytes())
+	}
+	ndjson := func(lines ...interface{}) []byte {
+		var sb strings.Builder
+		for _, line := range lines {
+			if s, ok := line.(string); ok {
+				sb.WriteString(s + "\n")
+				continue
+			}
+			data, err := json.Marshal(line)
+			suite.NoError(err)
+			sb.Write(data)
+			sb.WriteString("\n")
+		}
+		return []byte(sb.String())
+	}
+	array := func(docs ...*types.PostureExceptionPolicy) []byte {
+		data, err := json.Marshal(docs)
+		suite.NoError(err)
+		return data
+	}
+	statuses := func(response types.ImportResponse) []string {
+		s := []string{}
+		for _, result := range response.Results {
+			s = append(s, result.Status)
+		}
+		return s
+	}
+	getPolicies := func() map[string]*types.PostureExceptionPolicy {
+		w := suite.doRequest(http.MethodGet, consts.PostureExceptionPolicyPath, nil)
+		suite.Equal(http.StatusOK, w.Code)
+		byName := map[string]*types.PostureExceptionPolicy{}
+		for _, policy := range decode[[]*types.PostureExceptionPolicy](suite, w.Body.Bytes()) {
+			byName[policy.Name] = policy
+		}
+		return byName
+	}
+	updated := *policies[0]
+	updated.Attributes = map[string]interface{}{"source": "import"}
+	noName := *policies[3]
+	noName.Name = ""
+
+	//dry run report of NDJSON with invalid lines
+	report := doImport("?dryRun=true&onConflict=overwrite", ndjson(&updated, policies[1], "", "{bad json", policies[1], &noName, policies[2]), consts.NDJSONContentType, http.StatusOK)
+	suite.True(report.DryRun)
+	suite.Equal([]string{types.ImportStatusUpdated, types.ImportStatusCreated, types.ImportStatusInvalid, types.ImportStatusInvalid, types.ImportStatusInvalid, types.ImportStatusCreated}, statuses(report))
+	suite.Equal([]int{1, 2, 4, 5, 6, 7}, []int{report.Results[0].Line, report.Results[1].Line, report.Results[2].Line, report.Results[3].Line, report.Results[4].Line, report.Results[5].Line})
+	suite.Equal(existing.GUID, report.Results[0].GUID)
+	suite.Contains(report.Results[2].Error, "invalid JSON")
+	suite.Equal("duplicate name "+policies[1].Name+" in request", report.Results[3].Error)
+	suite.Equal("name is required", report.Results[4].Error)
+	suite.Equal(map[string]int{types.ImportStatusUpdated: 1, types.ImportStatusCreated: 2, types.ImportStatusInvalid: 3}, report.Summary)
+	suite.Len(getPolicies(), 1)
+
+	//the default conflict policy fails the import without writing
+	report = doImport("", array(&updated, policies[1]), "application/json", http.StatusConflict)
+	suite.True(report.DryRun)
+	suite.Equal([]string{types.ImportStatusConflict, types.ImportStatusCreated}, statuses(report))
+	suite.Equal("name "+policies[0].Name+" already exists", report.Results[0].Error)
+	suite.Len(getPolicies(), 1)
+
+	//skip existing documents
+	report = doImport("?onConflict=skip", array(&updated, policies[1], policies[2]), "application/json", http.StatusOK)
+	suite.False(report.DryRun)
+	suite.Equal([]string{types.ImportStatusSkipped, types.ImportStatusCreated, types.ImportStatusCreated}, statuses(report))
+	stored := getPolicies()
+	suite.Len(stored, 3)
+	suite.Nil(stored[policies[0].Name].Attributes["source"])
+	suite.Equal(report.Results[1].GUID, stored[policies[1].Name].GUID)
+	events := suite.getAuditEvents(map[string]string{"docGUID": report.Results[2].GUID, "verb": http.MethodPost})
+	suite.Len(events, 1)
+
+	//overwrite existing documents
+	report = doImport("?onConflict=overwrite", ndjson(&updated), consts.NDJSONContentType, http.StatusOK)
+	suite.Equal([]string{types.ImportStatusUpdated}, statuses(report))
+	stored = getPolicies()
+	suite.Equal("import", stored[policies[0].Name].Attributes["source"])
+	w := suite.doRequest(http.MethodGet, consts.PostureExceptionPolicyPath+"/"+existing.GUID, nil)
+	suite.Equal(`"2"`, w.Header().Get("ETag"))
+
+	//match by guid keeps the record guid of new documents
+	byGUID := *policies[3]
+	byGUID.GUID = "5e0c3b54-7b1d-4c8e-9a6f-2d4e8b1c0f3a"
+	renamed := *stored[policies[1].Name]
+	renamed.Attributes = map[string]interface{}{"source": "guid"}
+	report = doImport("?key=guid&onConflict=overwrite", array(&byGUID, &renamed), "application/json", http.StatusOK)
+	suite.Equal([]string{types.ImportStatusCreated, types.ImportStatusUpdated}, statuses(report))
+	suite.Equal(byGUID.GUID, report.Results[0].GUID)
+	stored = getPolicies()
+	suite.Equal(byGUID.GUID, stored[policies[3].Name].GUID)
+	suite.Equal("guid", stored[policies[1].Name].Attributes["source"])
+
+	//bad requests
+	testBadRequest(suite, http.MethodPost, importPath+"?onConflict=replace", `{"error":"onConflict must be skip, overwrite or fail"}`, []*types.PostureExceptionPolicy{policies[1]}, http.StatusBadRequest)
+	testBadRequest(suite, http.MethodPost, importPath, `{"error":"no documents in request"}`, []*types.PostureExceptionPolicy{}, http.StatusBadRequest)
+
+	for _, policy := range stored {
+		testDeleteDocByGUID(suite, consts.PostureExceptionPolicyPath, policy, commonCmpFilter)
+	}
+
+	//short names of imported clusters are unique
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	for _, cluster := range clusters {
+		delete(cluster.Attributes, consts.ShortNameAttribute)
+	}
+	w = suite.doRequest(http.MethodPost, consts.ClusterPath+"/import", clusters)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	report = decode[types.ImportResponse](suite, w.Body.Bytes())
+	suite.Equal(map[string]int{types.ImportStatusCreated: len(clusters)}, report.Summary)
+	shortNames := map[interface{}]bool{}
+	guids := []string{}
+	for _, result := range report.Results {
+		w = suite.doRequest(http.MethodGet, consts.ClusterPath+"/"+result.GUID, nil)
+		cluster := decode[*types.Cluster](suite, w.Body.Bytes())
+		suite.NotEmpty(cluster.Attributes[consts.ShortNameAttribute])
+		shortNames[cluster.Attributes[consts.ShortNameAttribute]] = true
+		guids = append(guids, result.GUID)
+	}
+	suite.Len(shortNames, len(clusters))
+	testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, guids)
+}
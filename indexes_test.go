@@ -0,0 +1,51 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+)
+
+func (suite *MainTestSuite) TestIndexes() {
+	//only admin can manage the indexes
+	testBadRequest(suite, http.MethodGet, consts.AdminPath+"/indexes", errorNotAdminUser, nil, http.StatusUnauthorized)
+	suite.loginAsAdmin("indexes-admin-guid")
+
+	w := suite.doRequest(http.MethodPost, consts.AdminPath+"/indexes/reconcile", nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	reports := decode[[]types.IndexesReport](suite, w.Body.Bytes())
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/indexes/usage?collection="+consts.IntegrationReferenceCollection, nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	usage := decode[[]types.IndexUsage](suite, w.Body.Bytes())
+	if suite.inMemoryStore {
+		//the in memory store has no indexes
+		suite.Empty(reports)
+		suite.Empty(usage)
+		return
+	}
+	var integrationReferences *types.IndexesReport
+	for i := range reports {
+		suite.Empty(reports[i].Error, reports[i].Collection)
+		if reports[i].Collection == consts.IntegrationReferenceCollection {
+			integrationReferences = &reports[i]
+		}
+	}
+	//indexes declared by the route schema
+	suite.Require().NotNil(integrationReferences)
+	suite.Contains(integrationReferences.Declared, "relatedObjects.cveID_1")
+	suite.Empty(integrationReferences.Created)
+	suite.Empty(integrationReferences.Extra)
+	//last reconciliation reports
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/indexes", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Contains(decode[[]types.IndexesReport](suite, w.Body.Bytes()), *integrationReferences)
+
+	names := map[string]bool{}
+	for _, index := range usage {
+		suite.Equal(consts.IntegrationReferenceCollection, index.Collection)
+		suite.True(index.Declared, index.Name)
+		names[index.Name] = true
+	}
+	suite.True(names["_id_"])
+	suite.True(names["relatedObjects.cveID_1"])
+}
@@ -5,11 +5,21 @@ package main
 
 import (
 	"config-service/db"
+	"config-service/db/memory"
 	"config-service/db/mongo"
+	"config-service/db/store"
+	"config-service/handlers"
+	"config-service/health"
+	"config-service/jobs"
+	"config-service/ratelimit"
+	"config-service/retention"
 	"config-service/utils"
+	"config-service/webhooks"
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -28,19 +38,66 @@ var zapLogger *zap.Logger
 var zapInfoLevelLogger *zap.Logger
 
 func initialize() (shutdown func()) {
-	conf := utils.GetConfig()
+	return initializeWithConfig(utils.GetConfig())
+}
+
+func initializeWithConfig(conf utils.Configuration) (shutdown func()) {
 	//init logger
 	initLogger(conf.LoggerConfig)
 	//init tracer
 	tracer := initTracer(conf.Telemetry)
-	//connect db
-	mongo.MustConnect(conf.Mongo)
+	//init db store
+	db.SetStore(mustCreateStore(conf))
 	//init db library
 	db.Init()
+	db.SetWatchPollInterval(time.Duration(conf.Watch.PollIntervalMillis) * time.Millisecond)
+	db.SetSlowQueries(db.SlowQueriesConfig{
+		Threshold: time.Duration(conf.SlowQueries.ThresholdMillis) * time.Millisecond,
+		Explain:   conf.SlowQueries.Explain,
+		Retention: time.Duration(conf.SlowQueries.RetentionDays) * 24 * time.Hour,
+	})
+	db.SetQueryCache(db.QueryCacheConfig{
+		MaxEntries: conf.QueryCache.MaxEntries,
+		MaxBytes:   int64(conf.QueryCache.MaxSizeMB) << 20,
+	})
+	//roles of the restricted routes, set before the routes are added
+	handlers.SetRoutePermissions(conf.Auth.RoutePermissions)
+	//readiness checks
+	registerHealthChecks(conf)
+	//apply the cache invalidations of the other instances in the background
+	stopCacheInvalidations := startCacheInvalidations(conf.CacheInvalidation)
+	//purge the trash of soft delete routes in the background
+	stopTrashPurge := startTrashPurge(conf.Trash)
+	//deliver the webhook subscriptions events in the background
+	stopWebhooks := webhooks.Start(webhooksConfig(conf.Webhooks))
+	//run the async admin jobs in the background
+	stopJobs := jobs.Start(jobs.Config{
+		Workers:      conf.Jobs.Workers,
+		Lease:        time.Duration(conf.Jobs.LeaseSeconds) * time.Second,
+		PollInterval: time.Duration(conf.Jobs.PollIntervalMillis) * time.Millisecond,
+	})
+	//purge old documents by the retention policies in the background
+	stopRetention := retention.Start(retention.Config{
+		Interval: time.Duration(conf.Retention.IntervalHours) * time.Hour,
+		DryRun:   conf.Retention.DryRun,
+		Policies: conf.Retention.Policies,
+	})
+	//per customer rate limits
+	stopRateLimits := ratelimit.Start(ratelimit.Config{
+		Limits:          conf.RateLimits.Limits,
+		LicenseTypes:    conf.RateLimits.LicenseTypes,
+		LicenseCacheTTL: time.Duration(conf.RateLimits.LicenseCacheSeconds) * time.Second,
+	})
 
 	//shutdown function
 	shutdown = func() {
-		mongo.Disconnect()
+		stopRateLimits()
+		stopTrashPurge()
+		stopWebhooks()
+		stopJobs()
+		stopRetention()
+		stopCacheInvalidations()
+		db.GetStore().Disconnect()
 		if err := tracer.Shutdown(context.Background()); err != nil {
 			log.Printf("Error shutting down tracer provider: %v", err)
 		}
@@ -49,6 +106,96 @@ func initialize() (shutdown func()) {
 	return shutdown
 }
 
+// mustCreateStore creates the configured storage backend, connecting to mongo if needed
+func mustCreateStore(conf utils.Configuration) store.Store {
+	switch conf.Store {
+	case utils.MemoryStore:
+		zapLogger.Info("using in memory store")
+		return memory.NewStore()
+	case utils.MongoStore, "":
+		mongo.MustConnect(conf.Mongo)
+		return mongo.NewStore()
+	default:
+		panic(fmt.Sprintf("unknown store type %s", conf.Store))
+	}
+}
+
+// registerHealthChecks configures the readiness checks and registers the checks of the configuration and the store
+func registerHealthChecks(conf utils.Configuration) {
+	health.Configure(health.Config{
+		CacheTTL: time.Duration(conf.Readiness.CacheMillis) * time.Millisecond,
+		Timeout:  time.Duration(conf.Readiness.TimeoutMillis) * time.Millisecond,
+	})
+	health.Register("config", func(ctx context.Context) error {
+		return conf.Validate()
+	})
+	if conf.Store == utils.MongoStore || conf.Store == "" {
+		health.Register("mongoPrimary", mongo.PingPrimary)
+		//reads that prefer a secondary fall back to the primary, a lost secondary does not fail the readiness
+		health.RegisterInformational("mongoSecondary", mongo.PingSecondary)
+		health.Register("mongoIndexes", mongo.IndexesStatus)
+	}
+}
+
+// reconcileIndexes reconciles the indexes of all the collections, it must be called after the routes are added
+// failures are reported by the mongoIndexes readiness check
+func reconcileIndexes() {
+	if _, err := db.ReconcileIndexes(context.Background()); err != nil {
+		zapLogger.Error("failed to reconcile indexes", zap.Error(err))
+	}
+}
+
+// startTrashPurge runs the trash purge job when configured
+// the returned function stops it and waits for the running purge to end, so the store can be disconnected
+func startTrashPurge(conf utils.Trash) (stop func()) {
+	if conf.RetentionDays <= 0 || conf.PurgeIntervalHours <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		db.RunTrashPurge(ctx, time.Duration(conf.RetentionDays)*24*time.Hour, time.Duration(conf.PurgeIntervalHours)*time.Hour)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startCacheInvalidations applies the cache invalidations of the other instances when configured
+// the returned function stops it and waits for the running invalidation to end, so the store can be disconnected
+func startCacheInvalidations(conf utils.CacheInvalidation) (stop func()) {
+	if conf.PollIntervalMillis <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		db.RunCacheInvalidations(ctx, time.Duration(conf.PollIntervalMillis)*time.Millisecond)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// webhooksConfig converts the webhooks configuration to the webhooks delivery configuration
+func webhooksConfig(conf utils.Webhooks) webhooks.Config {
+	return webhooks.Config{
+		Workers:             conf.Workers,
+		MaxAttempts:         conf.MaxAttempts,
+		InitialBackoff:      time.Duration(conf.InitialBackoffMillis) * time.Millisecond,
+		MaxBackoff:          time.Duration(conf.MaxBackoffMillis) * time.Millisecond,
+		Timeout:             time.Duration(conf.TimeoutSeconds) * time.Second,
+		PollInterval:        time.Duration(conf.PollIntervalMillis) * time.Millisecond,
+		ExpirySweepInterval: time.Duration(conf.ExpirySweepIntervalMillis) * time.Millisecond,
+		DeliveryRetention:   time.Duration(conf.DeliveryRetentionDays) * 24 * time.Hour,
+		AllowPrivateTargets: conf.AllowPrivateTargets,
+	}
+}
+
 func initLogger(config utils.LoggerConfig) {
 	var err error
 	lvl := zap.NewAtomicLevel()
@@ -0,0 +1,234 @@
+package jobs
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config configures the job workers
+type Config struct {
+	Workers      int           //number of concurrent jobs, 0 disables the job workers
+	Lease        time.Duration //a running job is claimed again by another worker if its lease is not renewed
+	PollInterval time.Duration //interval of checking for jobs queued by other instances and expired leases
+}
+
+var config = Config{
+	Workers:      2,
+	Lease:        time.Minute,
+	PollInterval: time.Second,
+}
+
+// RunFunc runs a job and sets its result, a job that was claimed again resumes from its progress and result
+// the context is cancelled when the job is cancelled or the worker stops, checkpoint saves the job progress and result
+type RunFunc func(ctx context.Context, job *types.Job, checkpoint func() error) error
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]RunFunc{}
+)
+
+// Register sets the run function of a job type, workers claim jobs of the registered types
+func Register(jobType string, run RunFunc) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[jobType] = run
+}
+
+func registeredTypes() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	jobTypes := make([]string, 0, len(registry))
+	for jobType := range registry {
+		jobTypes = append(jobTypes, jobType)
+	}
+	sort.Strings(jobTypes)
+	return jobTypes
+}
+
+func getRunFunc(jobType string) RunFunc {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return registry[jobType]
+}
+
+// wakeup notifies idle workers on new jobs
+var wakeup = make(chan struct{}, 1)
+
+func notifyWorkers() {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start starts the job workers, the returned function stops them and returns their running jobs to the queue
+func Start(conf Config) (stop func()) {
+	config = conf
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(ctx)
+		}()
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// New returns a job of the type with the params, params are kept as JSON
+func New(jobType string, params interface{}) (*types.Job, error) {
+	job := &types.Job{Type: jobType}
+	job.Name = jobType
+	var err error
+	if job.Params, err = json.Marshal(params); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// DecodeParams decodes the params of a job to v
+func DecodeParams(job *types.Job, v interface{}) error {
+	return json.Unmarshal(job.Params, v)
+}
+
+// ResultCount returns a count in the result of a job, 0 if it is not set
+func ResultCount(job *types.Job, key string) int64 {
+	switch count := job.Result[key].(type) {
+	case int64:
+		return count
+	case int32:
+		return int64(count)
+	case int:
+		return int64(count)
+	case float64:
+		return int64(count)
+	}
+	return 0
+}
+
+// Enqueue adds a job to the queue, the job gets a GUID (unless it has one) and is run by the first idle worker
+func Enqueue(c context.Context, job *types.Job) error {
+	defer log.LogNTraceEnterExit("jobs.Enqueue", c)()
+	if getRunFunc(job.Type) == nil {
+		return fmt.Errorf("unknown job type %s", job.Type)
+	}
+	if err := db.InsertJob(c, job); err != nil {
+		return err
+	}
+	notifyWorkers()
+	return nil
+}
+
+// runWorker runs the queued jobs until the context is done
+func runWorker(ctx context.Context) {
+	for ctx.Err() == nil {
+		var job *types.Job
+		if jobTypes := registeredTypes(); len(jobTypes) > 0 {
+			var err error
+			job, err = db.ClaimJob(ctx, jobTypes, config.Lease)
+			if err != nil && ctx.Err() == nil {
+				zap.L().Error("failed to claim job", zap.Error(err))
+			}
+		}
+		if job != nil {
+			//more jobs may be queued, let idle workers claim them
+			notifyWorkers()
+			run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wakeup:
+		case <-time.After(config.PollInterval):
+		}
+	}
+}
+
+// errLeaseLost is the cancel cause of a job that is no longer leased by the worker
+var errLeaseLost = errors.New("the job lease expired")
+
+// run runs a claimed job while renewing its lease and saves its final state
+func run(ctx context.Context, job *types.Job) {
+	logger := zap.L().With(zap.String("job", job.GUID), zap.String("type", job.Type))
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if job.CancelRequested {
+		cancel(context.Canceled)
+	}
+	//renew the lease and check for cancel requests while the job runs
+	done := make(chan struct{})
+	renewed := sync.WaitGroup{}
+	renewed.Add(1)
+	go func() {
+		defer renewed.Done()
+		ticker := time.NewTicker(config.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			leased, err := db.RenewJobLease(ctx, job, config.Lease)
+			switch {
+			case err != nil:
+				logger.Error("failed to renew job lease", zap.Error(err))
+			case leased == nil:
+				cancel(errLeaseLost)
+			case leased.CancelRequested:
+				cancel(context.Canceled)
+			}
+		}
+	}()
+	checkpoint := func() error {
+		err := db.CheckpointJob(ctx, job)
+		if db.IsJobLeaseLostError(err) {
+			cancel(errLeaseLost)
+		}
+		return err
+	}
+	err := getRunFunc(job.Type)(jobCtx, job, checkpoint)
+	close(done)
+	renewed.Wait()
+
+	//the final state is saved after the worker stopped
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelSave()
+	switch {
+	case ctx.Err() != nil:
+		//the worker stopped, another worker resumes the job
+		if err := db.ReleaseJob(saveCtx, job); err != nil && !db.IsJobLeaseLostError(err) {
+			logger.Error("failed to release job", zap.Error(err))
+		}
+		return
+	case errors.Is(context.Cause(jobCtx), errLeaseLost):
+		logger.Warn("job lease expired, the job was claimed by another worker")
+		return
+	case err == nil:
+		job.State = types.JobSucceeded
+	case errors.Is(context.Cause(jobCtx), context.Canceled):
+		job.State, job.Error = types.JobCancelled, "cancelled"
+	default:
+		job.State, job.Error = types.JobFailed, err.Error()
+	}
+	if err := db.FinishJob(saveCtx, job); db.IsJobLeaseLostError(err) {
+		logger.Warn("job lease expired before the job finished, the job was claimed by another worker")
+	} else if err != nil {
+		logger.Error("failed to save job", zap.Error(err))
+	}
+}
@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"config-service/db"
+	"config-service/db/memory"
+	"config-service/types"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{Workers: 2, Lease: 150 * time.Millisecond, PollInterval: 20 * time.Millisecond}
+
+func waitForState(t *testing.T, guid, state string) *types.Job {
+	var job *types.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = db.GetJob(context.Background(), guid)
+		return err == nil && job != nil && job.State == state
+	}, 5*time.Second, 10*time.Millisecond, "job %s is not %s", guid, state)
+	return job
+}
+
+func TestJobs(t *testing.T) {
+	db.SetStore(memory.NewStore())
+	defer db.GetStore().Disconnect()
+	ctx := context.Background()
+	//counts the items of the params, a resumed job continues from its progress
+	type countParams struct {
+		Items []string `json:"items"`
+	}
+	Register("count", func(ctx context.Context, job *types.Job, checkpoint func() error) error {
+		var params countParams
+		if err := DecodeParams(job, &params); err != nil {
+			return err
+		}
+		counted := ResultCount(job, "counted")
+		for i := job.Progress.Done; i < int64(len(params.Items)); i++ {
+			counted++
+			job.Progress = types.JobProgress{Done: i + 1, Total: int64(len(params.Items))}
+			job.Result = map[string]interface{}{"counted": counted}
+			if err := checkpoint(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	//blocks until cancelled
+	Register("block", func(ctx context.Context, job *types.Job, checkpoint func() error) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	defer func() {
+		registry = map[string]RunFunc{}
+	}()
+
+	//a job of an instance that stopped after counting one item
+	resumed, err := New("count", countParams{Items: []string{"a", "b", "c"}})
+	require.NoError(t, err)
+	require.NoError(t, Enqueue(ctx, resumed))
+	claimed, err := db.ClaimJob(ctx, []string{"count"}, time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	claimed.Progress, claimed.Result = types.JobProgress{Done: 1, Total: 3}, map[string]interface{}{"counted": int64(1)}
+	require.NoError(t, db.CheckpointJob(ctx, claimed))
+
+	stop := Start(testConfig)
+	job := waitForState(t, resumed.GUID, types.JobSucceeded)
+	assert.Equal(t, types.JobProgress{Done: 3, Total: 3}, job.Progress)
+	assert.Equal(t, int64(3), ResultCount(job, "counted"))
+	assert.Equal(t, 2, job.Attempts)
+	assert.NotNil(t, job.FinishedTime)
+
+	_, err = New("unknown", nil)
+	require.NoError(t, err)
+	assert.Error(t, Enqueue(ctx, &types.Job{Type: "unknown"}))
+
+	//cancel a running job
+	blocking, err := New("block", nil)
+	require.NoError(t, err)
+	require.NoError(t, Enqueue(ctx, blocking))
+	waitForState(t, blocking.GUID, types.JobRunning)
+	cancelled, err := db.CancelJob(ctx, blocking.GUID)
+	require.NoError(t, err)
+	assert.True(t, cancelled.CancelRequested)
+	job = waitForState(t, blocking.GUID, types.JobCancelled)
+	assert.Equal(t, "cancelled", job.Error)
+
+	//a running job is returned to the queue when the workers stop
+	blocking, err = New("block", nil)
+	require.NoError(t, err)
+	require.NoError(t, Enqueue(ctx, blocking))
+	waitForState(t, blocking.GUID, types.JobRunning)
+	stop()
+	job = waitForState(t, blocking.GUID, types.JobPending)
+	assert.Nil(t, job.LeaseExpiryTime)
+
+	//a pending job is cancelled right away
+	cancelled, err = db.CancelJob(ctx, blocking.GUID)
+	require.NoError(t, err)
+	assert.Equal(t, types.JobCancelled, cancelled.State)
+	assert.True(t, cancelled.Finished())
+
+	//a worker whose lease expired cannot write the job claimed again by another worker
+	require.NoError(t, db.InsertJob(ctx, &types.Job{Type: "stale"}))
+	stale, err := db.ClaimJob(ctx, []string{"stale"}, time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, stale)
+	time.Sleep(2 * time.Millisecond)
+	reclaimed, err := db.ClaimJob(ctx, []string{"stale"}, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, reclaimed)
+	assert.True(t, db.IsJobLeaseLostError(db.CheckpointJob(ctx, stale)))
+	leased, err := db.RenewJobLease(ctx, stale, time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, leased)
+	stale.State = types.JobFailed
+	assert.True(t, db.IsJobLeaseLostError(db.FinishJob(ctx, stale)))
+	assert.True(t, db.IsJobLeaseLostError(db.ReleaseJob(ctx, stale)))
+	assert.NoError(t, db.CheckpointJob(ctx, reclaimed))
+	reclaimed.State = types.JobSucceeded
+	assert.NoError(t, db.FinishJob(ctx, reclaimed))
+	job, err = db.GetJob(ctx, reclaimed.GUID)
+	require.NoError(t, err)
+	assert.Equal(t, types.JobSucceeded, job.State)
+}
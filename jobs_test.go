@@ -0,0 +1,86 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var testJobsConfig = utils.Jobs{
+	Workers:            2,
+	LeaseSeconds:       1,
+	PollIntervalMillis: 50,
+}
+
+// waitForJob polls the job until it is finished and returns it
+func (suite *MainTestSuite) waitForJob(guid string) *types.Job {
+	var job *types.Job
+	suite.Eventually(func() bool {
+		w := suite.doRequest(http.MethodGet, fmt.Sprintf("%s/jobs/%s", consts.AdminPath, guid), nil)
+		suite.Equal(http.StatusOK, w.Code)
+		job = decode[*types.Job](suite, w.Body.Bytes())
+		return job.Finished()
+	}, 5*time.Second, 20*time.Millisecond, "job %s did not finish", guid)
+	return job
+}
+
+func (suite *MainTestSuite) TestAsyncAdminJobs() {
+	const user = "async-delete-user-guid"
+	clusters, clustersNames := loadJson[*types.Cluster](clustersJson)
+	suite.login(user)
+	testBulkPostDocs(suite, consts.ClusterPath, clusters, newClusterCompareFilter)
+	testGetNameList(suite, consts.ClusterPath, clustersNames)
+
+	suite.loginAsAdmin("async-admin-guid")
+	//async delete of the customer data
+	w := suite.doRequest(http.MethodDelete, fmt.Sprintf("%s/customers?%s=%s&async=true", consts.AdminPath, consts.CustomersParam, user), nil)
+	suite.Equal(http.StatusAccepted, w.Code, w.Body.String())
+	job := decode[*types.Job](suite, w.Body.Bytes())
+	suite.NotEmpty(job.GUID)
+	suite.Equal("deleteCustomersData", job.Type)
+	suite.Equal(types.JobPending, job.State)
+	suite.JSONEq(fmt.Sprintf(`{"customers":["%s"]}`, user), string(job.Params))
+
+	job = suite.waitForJob(job.GUID)
+	suite.Equal(types.JobSucceeded, job.State, job.Error)
+	suite.Equal(types.JobProgress{Done: 1, Total: 1}, job.Progress)
+	suite.Equal(float64(len(clusters)), job.Result["deleted"])
+	suite.Equal(1, job.Attempts)
+	suite.NotNil(job.FinishedTime)
+	suite.login(user)
+	testGetNameList(suite, consts.ClusterPath, nil)
+
+	//async update with a request body
+	suite.loginAsAdmin("async-admin-guid")
+	w = suite.doRequest(http.MethodPut, consts.AdminPath+"/updateVulnerabilityExceptionsSeverity?async=true", types.VulnerabilityExceptionsSeverityUpdate{Cves: []string{"CVE-0000-0000"}, SeverityScore: 100})
+	suite.Equal(http.StatusAccepted, w.Code, w.Body.String())
+	updateJob := suite.waitForJob(decode[*types.Job](suite, w.Body.Bytes()).GUID)
+	suite.Equal(types.JobSucceeded, updateJob.State, updateJob.Error)
+	suite.Equal(float64(0), updateJob.Result["updatedCount"])
+
+	//invalid requests are not queued
+	testBadRequest(suite, http.MethodPut, consts.AdminPath+"/markRuntimeIncidentsAsResolved?async=true",
+		`{"error":"Key: 'BulkResolveRuntimeIncidents.CustomerGUID' Error:Field validation for 'CustomerGUID' failed on the 'required' tag\nKey: 'BulkResolveRuntimeIncidents.UserEmail' Error:Field validation for 'UserEmail' failed on the 'required' tag"}`,
+		types.BulkResolveRuntimeIncidents{}, http.StatusBadRequest)
+
+	//list the latest jobs
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/jobs?state=succeeded&limit=2", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	jobsList := decode[[]*types.Job](suite, w.Body.Bytes())
+	if suite.Len(jobsList, 2) {
+		suite.Equal(updateJob.GUID, jobsList[0].GUID)
+		suite.Equal(job.GUID, jobsList[1].GUID)
+	}
+
+	//finished jobs cannot be cancelled
+	testBadRequest(suite, http.MethodPost, fmt.Sprintf("%s/jobs/%s/cancel", consts.AdminPath, job.GUID), `{"error":"job is succeeded"}`, nil, http.StatusConflict)
+	testBadRequest(suite, http.MethodGet, consts.AdminPath+"/jobs/no-such-job", errorDocumentNotFound, nil, http.StatusNotFound)
+	testBadRequest(suite, http.MethodPost, consts.AdminPath+"/jobs/no-such-job/cancel", errorDocumentNotFound, nil, http.StatusNotFound)
+
+	//regular users cannot see jobs
+	suite.login(user)
+	testBadRequest(suite, http.MethodGet, fmt.Sprintf("%s/jobs/%s", consts.AdminPath, job.GUID), errorNotAdminUser, nil, http.StatusUnauthorized)
+}
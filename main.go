@@ -5,6 +5,7 @@ import (
 	"config-service/routes/prob"
 	"config-service/routes/v1/admin"
 	"config-service/routes/v1/attack_chains"
+	"config-service/routes/v1/audit"
 	"config-service/routes/v1/cloud_credentials"
 	"config-service/routes/v1/cluster"
 	"config-service/routes/v1/collaboration_config"
@@ -24,7 +25,9 @@ import (
 
 	"config-service/routes/v1/users_notifications_cache"
 	"config-service/routes/v1/vulnerability_exception"
+	"config-service/routes/v1/webhook_subscriptions"
 	"config-service/utils"
+	"config-service/utils/metrics"
 	"context"
 	"log"
 	"net/http"
@@ -44,6 +47,8 @@ func main() {
 	defer initialize()()
 	//Create routes
 	router := setupRouter()
+	//reconcile the collections indexes with the indexes declared by the routes
+	reconcileIndexes()
 	//Start server (blocking)
 	startServer(router)
 }
@@ -54,6 +59,8 @@ func setupRouter() *gin.Engine {
 	router.ContextWithFallback = true
 	//readiness and liveness probes
 	prob.AddRoutes(router)
+	//prometheus metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	//general middlewares
 	//open telemetry middleware
@@ -64,18 +71,24 @@ func setupRouter() *gin.Engine {
 	router.Use(requestLoggerWithFields)
 	//log request summary after served
 	router.Use(requestSummary())
+	//record requests latency and status metrics
+	router.Use(requestMetrics)
 	//recover from panics with 500 response
 	router.Use(ginzap.RecoveryWithZap(zapLogger, true))
 
 	//Public routes
-
-	//login routes
-	login.AddRoutes(router)
+	authConf := utils.GetConfig().Auth
+	//login routes - minting unverified cookies is allowed only in insecure dev mode
+	if authConf.InsecureDevMode {
+		login.AddRoutes(router)
+	}
 	//public (not authenticate routes
 	customer.AddPublicRoutes(router)
 
 	//auth middleware
-	router.Use(authenticate)
+	router.Use(newAuthenticateMiddleware(authConf))
+	//per customer rate limits
+	router.Use(rateLimit)
 
 	//add protected routes
 	admin.AddRoutes(router)
@@ -98,6 +111,8 @@ func setupRouter() *gin.Engine {
 	cloud_credentials.AddRoutes(router)
 	workflows.AddRoutes(router)
 	registry.AddRoutes(router)
+	audit.AddRoutes(router)
+	webhook_subscriptions.AddRoutes(router)
 
 	return router
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func (suite *MainTestSuite) TestMetrics() {
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	cluster := testPostDoc(suite, consts.ClusterPath, clusters[0], newClusterCompareFilter)
+	testGetDoc(suite, consts.ClusterPath+"/"+cluster.GUID, cluster, newClusterCompareFilter)
+	//rejected by the unique name validator
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath, fmt.Sprintf(`{"error":"name %s already exists"}`, cluster.Name), clusters[0], http.StatusBadRequest)
+
+	//cached documents refreshes
+	db.AddCachedDocument[*types.Cluster]("metricsTestCluster", consts.ClustersCollection, db.NewFilterBuilder().WithID(cluster.GUID), time.Minute)
+	_, err := db.GetCachedDocument[*types.Cluster]("metricsTestCluster")
+	suite.NoError(err)
+	db.AddCachedDocument[*types.Cluster]("metricsTestMissing", consts.ClustersCollection, db.NewFilterBuilder().WithID("missing"), time.Minute)
+	_, err = db.GetCachedDocument[*types.Cluster]("metricsTestMissing")
+	suite.Error(err)
+
+	//metrics are public
+	suite.authCookie = ""
+	w := suite.doRequest(http.MethodGet, "/metrics", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	//requests are labeled by the route path template
+	suite.Contains(body, fmt.Sprintf(`config_service_http_requests_total{method="GET",path="%s/:guid",status="200"}`, consts.ClusterPath))
+	suite.Contains(body, fmt.Sprintf(`config_service_http_requests_total{method="POST",path="%s",status="400"}`, consts.ClusterPath))
+	suite.Contains(body, fmt.Sprintf(`config_service_http_request_duration_seconds_count{method="GET",path="%s/:guid"}`, consts.ClusterPath))
+	suite.NotContains(body, cluster.GUID)
+	suite.Contains(body, `config_service_validation_rejections_total{validator="handlers.ValidateUniqueValues[...].func1"}`)
+	suite.Contains(body, `config_service_cache_refreshes_total{cache="metricsTestCluster",result="success"}`)
+	suite.Contains(body, `config_service_cache_refreshes_total{cache="metricsTestMissing",result="failure"}`)
+	suite.Contains(body, `config_service_cache_staleness_seconds{cache="metricsTestCluster"}`)
+	suite.NotContains(body, `config_service_cache_staleness_seconds{cache="metricsTestMissing"}`)
+	if !suite.inMemoryStore {
+		suite.Contains(body, fmt.Sprintf(`config_service_mongo_operation_duration_seconds_count{collection="%s",operation="find"}`, consts.ClustersCollection))
+		suite.Contains(body, "config_service_mongo_pool_open_connections")
+	}
+}
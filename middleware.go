@@ -1,8 +1,15 @@
 package main
 
 import (
+	"config-service/auth"
+	"config-service/ratelimit"
+	"config-service/utils"
 	"config-service/utils/consts"
+	"config-service/utils/metrics"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,7 +26,49 @@ import (
 
 //////////////////////////////////////////middleware handlers//////////////////////////////////////////
 
-// authenticate middleware for request authentication
+// newAuthenticateMiddleware returns the authentication middleware for the configuration
+// JWT bearer tokens are required unless insecure dev mode is explicitly enabled
+func newAuthenticateMiddleware(conf utils.AuthConfig) gin.HandlerFunc {
+	if conf.InsecureDevMode {
+		zapLogger.Warn("INSECURE DEV MODE IS ENABLED - customerGUID cookie and query param are trusted without verification and /login mints unverified cookies, do not use in production")
+		return authenticate
+	}
+	verifier, err := auth.NewJWTVerifier(conf.JWT)
+	if err != nil {
+		zapLogger.Fatal("failed to create jwt verifier", zap.Error(err))
+	}
+	return authenticateJWT(verifier)
+}
+
+// authenticateJWT middleware authenticates requests with a signed JWT bearer token
+func authenticateJWT(verifier *auth.JWTVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		token, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		identity, err := verifier.Verify(token)
+		if err != nil {
+			zapLogger.Debug("invalid bearer token", zap.Error(err), zap.String("path", c.Request.URL.Path))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Set(consts.CustomerGUID, identity.CustomerGUID)
+		if identity.Email != "" {
+			c.Set(consts.UserEmail, identity.Email)
+		}
+		c.Set(consts.UserRoles, identity.Roles)
+		if identity.Admin {
+			c.Set(consts.AdminAccess, true)
+		}
+		c.Next()
+	}
+}
+
+// authenticate middleware for insecure dev mode request authentication, trusts the customerGUID cookie or query param
+// the cookie values after the customer GUID are used as the user roles
 func authenticate(c *gin.Context) {
 	cookieVal, err := c.Cookie(consts.CustomerGUID)
 	customerValues := strings.Split(cookieVal, ";")
@@ -33,8 +82,11 @@ func authenticate(c *gin.Context) {
 		}
 	}
 	c.Set(consts.CustomerGUID, customerGuid)
-	if len(customerValues) > 1 && slices.Contains(customerValues[1:], consts.AdminAccess) {
-		c.Set(consts.AdminAccess, true)
+	if len(customerValues) > 1 {
+		c.Set(consts.UserRoles, customerValues[1:])
+		if slices.Contains(customerValues[1:], consts.AdminAccess) {
+			c.Set(consts.AdminAccess, true)
+		}
 	}
 	c.Next()
 }
@@ -70,6 +122,31 @@ func requestSummary() func(c *gin.Context) {
 	})
 }
 
+// requestMetrics records the latency and status of the served requests by the route path template
+func requestMetrics(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	path := c.FullPath()
+	if path == "" {
+		//not found paths are not reported separately to keep the number of labels bounded
+		path = "unmatched"
+	}
+	metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, path).Observe(time.Since(start).Seconds())
+	metrics.HTTPRequests.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Inc()
+}
+
+// rateLimit aborts with 429 the requests of customers that exceeded the rate limit of the route class
+func rateLimit(c *gin.Context) {
+	class := ratelimit.RouteClass(c.Request.Method, c.FullPath())
+	if allowed, retryAfter := ratelimit.Allow(c, c.GetString(consts.CustomerGUID), class); !allowed {
+		metrics.RateLimitedRequests.WithLabelValues(class).Inc()
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("%s requests rate limit exceeded", class)})
+		return
+	}
+	c.Next()
+}
+
 /////////////////////////////////////helper functions/////////////////////////////////////
 
 // telemetryLogFields returns telemetry and customer id fields for  logging
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"config-service/auth"
+	"config-service/utils"
+	"config-service/utils/consts"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAuthenticateJWT(t *testing.T) {
+	if zapLogger == nil {
+		zapLogger = zap.NewNop()
+	}
+	verifier, err := auth.NewJWTVerifier(utils.JWTConfig{Issuer: "test-issuer", Audience: "test-audience", HMACKey: "test-key"})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authenticateJWT(verifier))
+	router.GET("/whoami", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			consts.CustomerGUID: c.GetString(consts.CustomerGUID),
+			consts.UserEmail:    c.GetString(consts.UserEmail),
+			consts.AdminAccess:  c.GetBool(consts.AdminAccess),
+			consts.UserRoles:    c.GetStringSlice(consts.UserRoles),
+		})
+	})
+
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-key"))
+		assert.NoError(t, err)
+		return token
+	}
+	claims := func(roles ...string) jwt.MapClaims {
+		return jwt.MapClaims{"iss": "test-issuer", "aud": "test-audience", "exp": time.Now().Add(time.Minute).Unix(),
+			"customerGUID": "jwt-customer", "email": "user@example.com", "roles": roles}
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		cookie     string
+		wantCode   int
+		wantBody   string
+	}{
+		{name: "no token", wantCode: http.StatusUnauthorized},
+		{name: "customerGUID cookie is not trusted", cookie: "customerGUID=some-guid;adminAccess", wantCode: http.StatusUnauthorized},
+		{name: "invalid token", authHeader: "Bearer invalid", wantCode: http.StatusUnauthorized},
+		{name: "not bearer", authHeader: "Basic " + sign(claims()), wantCode: http.StatusUnauthorized},
+		{
+			name:       "valid token",
+			authHeader: "Bearer " + sign(claims()),
+			wantCode:   http.StatusOK,
+			wantBody:   `{"adminAccess":false,"customerGUID":"jwt-customer","userEmail":"user@example.com","userRoles":null}`,
+		},
+		{
+			name:       "admin token",
+			authHeader: "Bearer " + sign(claims("admin")),
+			wantCode:   http.StatusOK,
+			wantBody:   `{"adminAccess":true,"customerGUID":"jwt-customer","userEmail":"user@example.com","userRoles":["admin"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/whoami?customerGUID=query-guid", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			if tt.cookie != "" {
+				req.Header.Set("Cookie", tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) TestCursorPagination() {
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	newClusters := testBulkPostDocs(suite, consts.ClusterPath, clusters, newClusterCompareFilter)
+	query := func(req armotypes.V2ListRequest) types.SearchResult[*types.Cluster] {
+		w := suite.doRequest(http.MethodPost, consts.ClusterPath+"/query", req)
+		suite.Equal(http.StatusOK, w.Code, w.Body.String())
+		return decode[types.SearchResult[*types.Cluster]](suite, w.Body.Bytes())
+	}
+	pageSize := 2
+	all := query(armotypes.V2ListRequest{OrderBy: "name:desc"})
+	suite.Empty(all.Cursor)
+
+	//pages continue after the cursor of the previous page
+	first := query(armotypes.V2ListRequest{OrderBy: "name:desc", PageSize: &pageSize})
+	suite.Len(first.Response, 2)
+	suite.NotEmpty(first.Cursor)
+	second := query(armotypes.V2ListRequest{OrderBy: "name:desc", PageSize: &pageSize, CursorDepracated: first.Cursor})
+	suite.Equal(all.Total, second.Total)
+	suite.Equal(all.Response, append(first.Response, second.Response...))
+	suite.Equal(len(all.Response) > 2*pageSize, second.Cursor != "")
+
+	//bad cursors
+	pageNum := 2
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath+"/query", `{"error":"cursor cannot be used with pageNum"}`,
+		armotypes.V2ListRequest{OrderBy: "name:desc", PageSize: &pageSize, PageNum: &pageNum, CursorDepracated: first.Cursor}, http.StatusBadRequest)
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath+"/query", `{"error":"invalid cursor: the cursor belongs to a query with another order"}`,
+		armotypes.V2ListRequest{OrderBy: "name:asc", PageSize: &pageSize, CursorDepracated: first.Cursor}, http.StatusBadRequest)
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath+"/query", `{"error":"invalid cursor: malformed token"}`,
+		armotypes.V2ListRequest{PageSize: &pageSize, CursorDepracated: "not-a-cursor!"}, http.StatusBadRequest)
+
+	testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, []string{newClusters[0].GUID, newClusters[1].GUID, newClusters[2].GUID})
+}
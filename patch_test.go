@@ -0,0 +1,91 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/identifiers"
+)
+
+func (suite *MainTestSuite) TestPatch() {
+	policies, _ := loadJson[*types.VulnerabilityExceptionPolicy](vulnerabilityPoliciesJson)
+	policy := testPostDoc(suite, consts.VulnerabilityExceptionPolicyPath, policies[0], commonCmpFilter)
+	path := consts.VulnerabilityExceptionPolicyPath + "/" + policy.GUID
+	patch := func(contentType, body string, headers map[string]string) *types.VulnerabilityExceptionPolicy {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers["Content-Type"] = contentType
+		w := suite.doRequestWithHeaders(http.MethodPatch, path, []byte(body), headers)
+		if !suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+			return nil
+		}
+		docs := decode[[]*types.VulnerabilityExceptionPolicy](suite, w.Body.Bytes())
+		suite.Len(docs, 2)
+		return docs[1]
+	}
+
+	//JSON patch removes an array item, adds an array item and checks the name
+	patched := patch(consts.JSONPatchContentType, `[
+		{"op":"test","path":"/name","value":"`+policy.Name+`"},
+		{"op":"remove","path":"/vulnerabilities/1"},
+		{"op":"add","path":"/designators/-","value":{"designatorType":"Attributes","attributes":{"cluster":"patched"}}}
+	]`, nil)
+	suite.Equal([]string{"CVE-2005-2541", "CVE-2009-5155", "CVE-2010-4756"}, vulnerabilityNames(patched))
+	suite.Len(patched.Designatores, 2)
+	suite.Equal(identifiers.PortalDesignator{DesignatorType: identifiers.DesignatorAttributes, Attributes: map[string]string{"cluster": "patched"}}, patched.Designatores[1])
+	suite.Equal(policy.Designatores[0], patched.Designatores[0])
+	suite.Equal(policy.Actions, patched.Actions)
+
+	//merge patch with a revision precondition
+	w := suite.doRequestWithHeaders(http.MethodPatch, path, []byte(`{"reason":"stale"}`), map[string]string{"Content-Type": consts.MergePatchContentType, "If-Match": `"1"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	patched = patch(consts.MergePatchContentType, `{"attributes":{"owner":"team"},"reason":"false positive","vulnerabilities":null}`, map[string]string{"If-Match": `"2"`})
+	suite.Equal("team", patched.Attributes["owner"])
+	suite.Equal("false positive", patched.Reason)
+	suite.Empty(patched.VulnerabilityPolicies)
+	suite.Len(patched.Designatores, 2)
+
+	w = suite.doRequest(http.MethodGet, path, nil)
+	suite.Equal(`"3"`, w.Header().Get("ETag"))
+	stored := decode[*types.VulnerabilityExceptionPolicy](suite, w.Body.Bytes())
+	suite.Equal(patched, stored)
+	events := suite.getAuditEvents(map[string]string{"docGUID": policy.GUID, "verb": http.MethodPatch})
+	suite.Len(events, 2)
+
+	//bad patches
+	badPatch := func(contentType, body string, code int, expectedBody string) {
+		w := suite.doRequestWithHeaders(http.MethodPatch, path, []byte(body), map[string]string{"Content-Type": contentType})
+		suite.Equal(code, w.Code)
+		suite.Equal(expectedBody, w.Body.String())
+	}
+	badPatch(consts.MergePatchContentType, `{"name":"renamed"}`, http.StatusBadRequest, `{"error":"name is read only"}`)
+	badPatch(consts.MergePatchContentType, `{"reason":"false positive"}`, http.StatusBadRequest, `{"error":"no fields to update"}`)
+	badPatch(consts.MergePatchContentType, `[]`, http.StatusBadRequest, `{"error":"invalid patch: merge patch must be a JSON object"}`)
+	badPatch(consts.JSONPatchContentType, `[{"op":"test","path":"/reason","value":"other"}]`, http.StatusConflict, `{"error":"test of /reason failed"}`)
+	badPatch(consts.JSONPatchContentType, `[{"op":"remove","path":"/attributes/missing"}]`, http.StatusUnprocessableEntity, `{"error":"invalid patch: operation 0: /attributes/missing does not exist"}`)
+	badPatch("application/json", `{"reason":"other"}`, http.StatusUnsupportedMediaType, `{"error":"content type must be application/merge-patch+json or application/json-patch+json"}`)
+	w = suite.doRequestWithHeaders(http.MethodPatch, consts.VulnerabilityExceptionPolicyPath+"/not-exists", []byte(`{"reason":"other"}`), map[string]string{"Content-Type": consts.MergePatchContentType})
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	//the put validators keep the cluster alias
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	cluster := testPostDoc(suite, consts.ClusterPath, clusters[0], newClusterCompareFilter)
+	w = suite.doRequestWithHeaders(http.MethodPatch, consts.ClusterPath+"/"+cluster.GUID, []byte(`{"attributes":{"test":"patched","`+consts.ShortNameAttribute+`":null}}`), map[string]string{"Content-Type": consts.MergePatchContentType})
+	suite.Equal(http.StatusOK, w.Code)
+	patchedCluster := decode[[]*types.Cluster](suite, w.Body.Bytes())[1]
+	suite.Equal("patched", patchedCluster.Attributes["test"])
+	suite.Equal(cluster.Attributes[consts.ShortNameAttribute], patchedCluster.Attributes[consts.ShortNameAttribute])
+
+	testDeleteDocByGUID(suite, consts.VulnerabilityExceptionPolicyPath, stored, commonCmpFilter)
+	testDeleteDocByGUID(suite, consts.ClusterPath, patchedCluster, newClusterCompareFilter)
+}
+
+func vulnerabilityNames(policy *types.VulnerabilityExceptionPolicy) []string {
+	names := []string{}
+	for _, vulnerability := range policy.VulnerabilityPolicies {
+		names = append(names, vulnerability.Name)
+	}
+	return names
+}
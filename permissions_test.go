@@ -0,0 +1,60 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+)
+
+// testRoutePermissions restrict the exception policies routes to viewers and editors in the tests
+var testRoutePermissions = map[string]map[string][]string{
+	consts.PostureExceptionPolicyPath:       viewerEditorPermissions,
+	consts.VulnerabilityExceptionPolicyPath: viewerEditorPermissions,
+}
+
+var viewerEditorPermissions = map[string][]string{
+	"read":   {consts.ViewerRole, consts.EditorRole},
+	"create": {consts.EditorRole},
+	"update": {consts.EditorRole},
+	"delete": {consts.EditorRole},
+}
+
+func (suite *MainTestSuite) TestExceptionPoliciesPermissions() {
+	posturePolicies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	vulnerabilityPolicies, _ := loadJson[*types.VulnerabilityExceptionPolicy](vulnerabilityPoliciesJson)
+	testPermissions(suite, consts.PostureExceptionPolicyPath, posturePolicies[0])
+	testPermissions(suite, consts.VulnerabilityExceptionPolicyPath, vulnerabilityPolicies[0])
+
+	//routes without configured permissions are not restricted
+	suite.loginWithRoles("permissions-guid")
+	w := suite.doRequest(http.MethodGet, consts.ClusterPath, nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+}
+
+// testPermissions checks that viewers can read the documents of the path and only editors can modify them
+func testPermissions[T types.DocContent](suite *MainTestSuite, path string, doc T) {
+	const customerGUID = "permissions-guid"
+	suite.login(customerGUID)
+	doc = testPostDoc(suite, path, doc, commonCmpFilter)
+
+	suite.loginWithRoles(customerGUID, consts.ViewerRole)
+	missingPermission := func(permission string) string {
+		return fmt.Sprintf(`{"error":"missing %s permission for %s"}`, permission, path)
+	}
+	w := suite.doRequest(http.MethodGet, path, nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Len(decode[[]T](suite, w.Body.Bytes()), 1)
+	w = suite.doRequest(http.MethodGet, path+"/"+doc.GetGUID(), nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	testBadRequest(suite, http.MethodPost, path, missingPermission("create"), doc, http.StatusForbidden)
+	testBadRequest(suite, http.MethodPut, path, missingPermission("update"), doc, http.StatusForbidden)
+	testBadRequest(suite, http.MethodDelete, path+"/"+doc.GetGUID(), missingPermission("delete"), nil, http.StatusForbidden)
+
+	//users without a role cannot read the documents
+	suite.loginWithRoles(customerGUID)
+	testBadRequest(suite, http.MethodGet, path, missingPermission("read"), nil, http.StatusForbidden)
+
+	suite.login(customerGUID)
+	testDeleteDocByGUID(suite, path, doc, commonCmpFilter)
+}
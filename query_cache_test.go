@@ -0,0 +1,46 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+func (suite *MainTestSuite) TestQueryCache() {
+	cacheRequests := func(result string) int {
+		w := suite.doRequest(http.MethodGet, "/metrics", nil)
+		suite.Equal(http.StatusOK, w.Code)
+		match := regexp.MustCompile(fmt.Sprintf(`config_service_query_cache_requests_total{collection="%s",result="%s"} (\d+)`, consts.ClustersCollection, result)).FindStringSubmatch(w.Body.String())
+		if match == nil {
+			return 0
+		}
+		count, _ := strconv.Atoi(match[1])
+		return count
+	}
+	hits, misses := cacheRequests("hit"), cacheRequests("miss")
+
+	suite.login("query-cache-guid")
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	cluster := testPostDoc(suite, consts.ClusterPath, clusters[0], newClusterCompareFilter)
+	testGetDocs(suite, consts.ClusterPath, []*types.Cluster{cluster}, newClusterCompareFilter)
+	testGetDocs(suite, consts.ClusterPath, []*types.Cluster{cluster}, newClusterCompareFilter)
+	suite.Equal(misses+1, cacheRequests("miss"))
+	suite.Equal(hits+1, cacheRequests("hit"))
+
+	//writes invalidate the cached lists
+	updated := Clone(cluster)
+	updated.Attributes = map[string]interface{}{"cached": "no"}
+	updated = testPutDoc(suite, consts.ClusterPath, cluster, updated, newClusterCompareFilter)
+	testGetDocs(suite, consts.ClusterPath, []*types.Cluster{updated}, newClusterCompareFilter)
+	suite.Equal(misses+2, cacheRequests("miss"))
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath, fmt.Sprintf(`{"error":"name %s already exists"}`, cluster.Name), clusters[0], http.StatusBadRequest)
+	testGetDocs(suite, consts.ClusterPath, []*types.Cluster{updated}, newClusterCompareFilter)
+	suite.Equal(hits+2, cacheRequests("hit"))
+
+	//other customers do not see the cached lists
+	suite.login("query-cache-other-guid")
+	testGetDocs(suite, consts.ClusterPath, []*types.Cluster{}, newClusterCompareFilter)
+}
@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// route classes, each class has a separate bucket per customer
+const (
+	ClassRead      = "read"      //GET requests and document queries
+	ClassWrite     = "write"     //requests that create, update or delete documents
+	ClassAggregate = "aggregate" //unique values and count aggregations
+	ClassAdmin     = "admin"     //admin routes
+)
+
+// sweepInterval is the interval of removing the full buckets of idle customers
+const sweepInterval = time.Minute
+
+// Config configures the per customer rate limits
+type Config struct {
+	Limits          map[string]types.RateLimit            //limits by route class, classes without a limit are not limited
+	LicenseTypes    map[string]map[string]types.RateLimit //overrides of the limits by license type and route class
+	LicenseCacheTTL time.Duration                         //license type of a customer is cached for the ttl
+}
+
+var config Config
+
+type bucket struct {
+	limiter *rate.Limiter
+	limit   types.RateLimit
+}
+
+type licenseEntry struct {
+	licenseType string
+	expires     time.Time
+}
+
+var (
+	bucketsLock sync.Mutex
+	buckets     = map[string]*bucket{}
+
+	licensesLock sync.Mutex
+	licenses     = map[string]licenseEntry{}
+)
+
+// Start sets the rate limits and starts removing the buckets of idle customers, the returned function stops it
+func Start(conf Config) (stop func()) {
+	bucketsLock.Lock()
+	config = conf
+	buckets = map[string]*bucket{}
+	bucketsLock.Unlock()
+	licensesLock.Lock()
+	licenses = map[string]licenseEntry{}
+	licensesLock.Unlock()
+	if !enabled() {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweep(time.Now())
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// RouteClass returns the rate limit class of a request by its method and route path template
+func RouteClass(method, path string) string {
+	switch {
+	case strings.HasPrefix(path, consts.AdminPath):
+		return ClassAdmin
+	case strings.HasSuffix(path, "/uniqueValues"), strings.HasSuffix(path, "/count"):
+		return ClassAggregate
+	case method == "GET", method == "HEAD", strings.HasSuffix(path, "/query") && method == "POST", strings.HasSuffix(path, "/export"):
+		return ClassRead
+	default:
+		return ClassWrite
+	}
+}
+
+// Allow takes a token from the bucket of the customer and route class
+// when the bucket is empty it returns false and the time until a token is available
+func Allow(c context.Context, customerGUID, class string) (bool, time.Duration) {
+	if customerGUID == "" || !enabled() {
+		return true, 0
+	}
+	limit, ok := customerLimit(c, customerGUID, class)
+	if !ok {
+		return true, 0
+	}
+	b := getBucket(customerGUID+"/"+class, limit)
+	now := time.Now()
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		//do not consume the token of a rejected request
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func enabled() bool {
+	return len(config.Limits) > 0 || len(config.LicenseTypes) > 0
+}
+
+// customerLimit returns the limit of the route class for the license type of the customer
+func customerLimit(c context.Context, customerGUID, class string) (types.RateLimit, bool) {
+	limit, ok := config.Limits[class]
+	if len(config.LicenseTypes) > 0 {
+		licenseType := customerLicenseType(c, customerGUID)
+		for overrideLicense, limits := range config.LicenseTypes {
+			if strings.EqualFold(overrideLicense, licenseType) {
+				if override, found := limits[class]; found {
+					limit, ok = override, true
+				}
+				break
+			}
+		}
+	}
+	if !ok || limit.RequestsPerSecond <= 0 {
+		return types.RateLimit{}, false
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.RequestsPerSecond))
+	}
+	return limit, true
+}
+
+// customerLicenseType returns the cached license type of the customer, customers without a subscription have the free license type
+func customerLicenseType(c context.Context, customerGUID string) string {
+	licensesLock.Lock()
+	entry, ok := licenses[customerGUID]
+	licensesLock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.licenseType
+	}
+	licenseType, err := db.GetCustomerLicenseType(c, customerGUID)
+	if err != nil {
+		//keep the last known license type and retry on the next request
+		zap.L().Warn("failed to get customer license type for rate limits", zap.String("customerGUID", customerGUID), zap.Error(err))
+		return entry.licenseType
+	}
+	if licenseType == "" {
+		licenseType = string(armotypes.LicenseTypeFree)
+	}
+	licensesLock.Lock()
+	licenses[customerGUID] = licenseEntry{licenseType: licenseType, expires: time.Now().Add(config.LicenseCacheTTL)}
+	licensesLock.Unlock()
+	return licenseType
+}
+
+// getBucket returns the bucket of the key, the bucket is replaced when the limit changed (e.g. a new license type)
+func getBucket(key string, limit types.RateLimit) *bucket {
+	bucketsLock.Lock()
+	defer bucketsLock.Unlock()
+	b, ok := buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), limit.Burst), limit: limit}
+		buckets[key] = b
+	}
+	return b
+}
+
+// sweep removes the full buckets, a new bucket of the same key is full so no state is lost
+func sweep(now time.Time) {
+	bucketsLock.Lock()
+	defer bucketsLock.Unlock()
+	for key, b := range buckets {
+		if b.limiter.TokensAt(now) >= float64(b.limit.Burst) {
+			delete(buckets, key)
+		}
+	}
+}
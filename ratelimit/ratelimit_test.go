@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"config-service/db"
+	"config-service/db/memory"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRouteClass(t *testing.T) {
+	tests := []struct {
+		method, path, class string
+	}{
+		{"GET", "/cluster", ClassRead},
+		{"GET", "/cluster/:guid", ClassRead},
+		{"POST", "/cluster/query", ClassRead},
+		{"POST", "/cluster/export", ClassRead},
+		{"POST", "/cluster/uniqueValues", ClassAggregate},
+		{"POST", "/cluster/count", ClassAggregate},
+		{"POST", "/cluster", ClassWrite},
+		{"PUT", "/cluster/:guid", ClassWrite},
+		{"DELETE", "/cluster/query", ClassWrite},
+		{"GET", consts.AdminPath + "/jobs", ClassAdmin},
+		{"POST", consts.AdminPath + "/:path/uniqueValues", ClassAdmin},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.class, RouteClass(test.method, test.path), "%s %s", test.method, test.path)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	db.SetStore(memory.NewStore())
+	defer db.GetStore().Disconnect()
+	ctx := context.Background()
+	_, err := db.GetStore().GetWriteCollection(consts.CustomersCollection).InsertOne(ctx,
+		bson.M{consts.IdField: "enterprise", "activeSubscription": bson.M{"licenseType": "Enterprise"}})
+	assert.NoError(t, err)
+
+	//disabled without limits
+	Start(Config{})
+	for i := 0; i < 10; i++ {
+		allowed, _ := Allow(ctx, "free", ClassRead)
+		assert.True(t, allowed)
+	}
+
+	stop := Start(Config{
+		Limits:          map[string]types.RateLimit{ClassAggregate: {RequestsPerSecond: 0.5, Burst: 2}},
+		LicenseTypes:    map[string]map[string]types.RateLimit{"enterprise": {ClassAggregate: {RequestsPerSecond: 100}, ClassWrite: {RequestsPerSecond: 1}}},
+		LicenseCacheTTL: time.Minute,
+	})
+	defer stop()
+	//burst then rejected with the time until the next token
+	for i := 0; i < 2; i++ {
+		allowed, _ := Allow(ctx, "free", ClassAggregate)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := Allow(ctx, "free", ClassAggregate)
+	assert.False(t, allowed)
+	assert.Greater(t, retryAfter, time.Second)
+	assert.LessOrEqual(t, retryAfter, 2*time.Second)
+	//buckets are per customer and class
+	allowed, _ = Allow(ctx, "other", ClassAggregate)
+	assert.True(t, allowed)
+	for i := 0; i < 10; i++ {
+		allowed, _ = Allow(ctx, "free", ClassRead)
+		assert.True(t, allowed)
+	}
+	//license type overrides, burst defaults to the rate rounded up
+	for i := 0; i < 100; i++ {
+		allowed, _ = Allow(ctx, "enterprise", ClassAggregate)
+		assert.True(t, allowed)
+	}
+	allowed, _ = Allow(ctx, "enterprise", ClassAggregate)
+	assert.False(t, allowed)
+	allowed, _ = Allow(ctx, "enterprise", ClassWrite)
+	assert.True(t, allowed)
+	allowed, _ = Allow(ctx, "enterprise", ClassWrite)
+	assert.False(t, allowed)
+
+	//full buckets are removed
+	sweep(time.Now().Add(time.Hour))
+	bucketsLock.Lock()
+	assert.Empty(t, buckets)
+	bucketsLock.Unlock()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"config-service/ratelimit"
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) TestRateLimits() {
+	const enterpriseUser = "rate-limit-enterprise-guid"
+	suite.authCookie = ""
+	testPostDoc(suite, consts.TenantPath, &types.Customer{PortalBase: armotypes.PortalBase{Name: "rate limit enterprise", GUID: enterpriseUser}}, customerCompareFilter)
+	suite.login(enterpriseUser)
+	w := suite.doRequest(http.MethodPut, consts.ActiveSubscriptionPath+"/"+enterpriseUser, armotypes.Subscription{LicenseType: armotypes.LicenseTypeEnterprise})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	stop := ratelimit.Start(ratelimit.Config{
+		Limits:          map[string]types.RateLimit{ratelimit.ClassAggregate: {RequestsPerSecond: 0.1, Burst: 2}},
+		LicenseTypes:    map[string]map[string]types.RateLimit{string(armotypes.LicenseTypeEnterprise): {ratelimit.ClassAggregate: {RequestsPerSecond: 0.1, Burst: 4}}},
+		LicenseCacheTTL: time.Minute,
+	})
+	defer func() {
+		stop()
+		ratelimit.Start(ratelimit.Config{})
+	}()
+	uniqueValues := func() int {
+		return suite.doRequest(http.MethodPost, consts.ClusterPath+"/uniqueValues", armotypes.UniqueValuesRequestV2{Fields: map[string]string{"name": ""}}).Code
+	}
+
+	suite.login("rate-limit-free-guid")
+	for i := 0; i < 2; i++ {
+		suite.Equal(http.StatusOK, uniqueValues())
+	}
+	w = suite.doRequest(http.MethodPost, consts.ClusterPath+"/uniqueValues", armotypes.UniqueValuesRequestV2{Fields: map[string]string{"name": ""}})
+	suite.Equal(http.StatusTooManyRequests, w.Code)
+	suite.JSONEq(`{"error":"aggregate requests rate limit exceeded"}`, w.Body.String())
+	suite.Equal("10", w.Header().Get("Retry-After"))
+	//other route classes are not limited
+	suite.Equal(http.StatusOK, suite.doRequest(http.MethodGet, consts.ClusterPath, nil).Code)
+
+	//license type override
+	suite.login(enterpriseUser)
+	for i := 0; i < 4; i++ {
+		suite.Equal(http.StatusOK, uniqueValues())
+	}
+	suite.Equal(http.StatusTooManyRequests, uniqueValues())
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"config-service/types"
+	"net/http"
+)
+
+func (suite *MainTestSuite) TestReadiness() {
+	//probes are public
+	suite.authCookie = ""
+	w := suite.doRequest(http.MethodGet, "/healthz", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.JSONEq(`{"status":"ok"}`, w.Body.String())
+
+	w = suite.doRequest(http.MethodGet, "/readiness", nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	report := decode[types.HealthReport](suite, w.Body.Bytes())
+	suite.Equal(types.HealthOK, report.Status)
+	suite.False(report.CheckedAt.IsZero())
+	checks := []string{"config", "defaultCustomerConfig"}
+	if !suite.inMemoryStore {
+		checks = append(checks, "mongoPrimary", "mongoSecondary", "mongoIndexes")
+	}
+	suite.Len(report.Checks, len(checks))
+	for _, check := range checks {
+		suite.Equal(types.HealthOK, report.Checks[check].Status, check)
+	}
+	if !suite.inMemoryStore {
+		suite.True(report.Checks["mongoSecondary"].Informational)
+	}
+}
@@ -0,0 +1,259 @@
+package retention
+
+import (
+	"config-service/db"
+	"config-service/jobs"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/utils/metrics"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/hashicorp/go-multierror"
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// JobType is the type of the jobs of the scheduled purge
+const JobType = "retentionPurge"
+
+// maxPurgeCustomers is the max number of customers in the filter of a purge, larger groups of customers are purged in batches
+const maxPurgeCustomers = 1000
+
+// Config configures the scheduled purge of the retention policies
+type Config struct {
+	Interval time.Duration //interval of the scheduled purge, 0 disables the scheduler
+	DryRun   bool          //scheduled runs only report the documents that would be purged
+	Policies []types.RetentionPolicy
+}
+
+var config = Config{
+	Interval: 24 * time.Hour,
+}
+
+var (
+	statsLock sync.Mutex
+	stats     = types.RetentionStats{Purged: map[string]int64{}}
+)
+
+// jobParams are the params of a scheduled purge job
+type jobParams struct {
+	DryRun bool `json:"dryRun"`
+}
+
+// Start sets the retention policies and starts the scheduled purge, the returned function stops it
+// the scheduler of each instance enqueues the purge job of the current interval, the job is enqueued once and run by a single job worker
+func Start(conf Config) (stop func()) {
+	config = conf
+	jobs.Register(JobType, runJob)
+	if conf.Interval <= 0 || len(conf.Policies) == 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runScheduler(ctx)
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Policies returns the configured retention policies
+func Policies() []types.RetentionPolicy {
+	return config.Policies
+}
+
+// Stats returns the purge counters of the service instance
+func Stats() types.RetentionStats {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	snapshot := stats
+	snapshot.Purged = make(map[string]int64, len(stats.Purged))
+	for collection, count := range stats.Purged {
+		snapshot.Purged[collection] = count
+	}
+	return snapshot
+}
+
+func runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := enqueueScheduledRun(ctx, now); err != nil {
+				zap.L().Error("failed to enqueue retention run", zap.Error(err))
+			}
+		}
+	}
+}
+
+// enqueueScheduledRun enqueues the purge job of the interval of the given time
+// the job GUID is derived from the interval start, the instances that enqueue the job of the same interval after the first one are ignored
+func enqueueScheduledRun(ctx context.Context, now time.Time) error {
+	job, err := jobs.New(JobType, jobParams{DryRun: config.DryRun})
+	if err != nil {
+		return err
+	}
+	intervalStart := now.UTC().Truncate(config.Interval)
+	job.GUID = uuid.NewV5(uuid.NamespaceOID, JobType+"/"+intervalStart.Format(time.RFC3339)).String()
+	if err := jobs.Enqueue(ctx, job); err != nil && !db.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
+// runJob runs a scheduled purge job, the result has the purged (or counted in dry run) documents by collection
+func runJob(ctx context.Context, job *types.Job, checkpoint func() error) error {
+	var params jobParams
+	if err := jobs.DecodeParams(job, &params); err != nil {
+		return err
+	}
+	report, err := Run(ctx, params.DryRun)
+	if err != nil {
+		zap.L().Error("retention run completed with errors", zap.Error(err))
+	}
+	zap.L().Info("retention run", zap.Bool("dryRun", report.DryRun), zap.Any("collections", report.Collections))
+	job.Result = map[string]interface{}{"dryRun": report.DryRun, "collections": report.Collections}
+	return err
+}
+
+// Run purges the documents of all the retention policies and returns the report, in dry run the documents are only counted
+// a failed policy does not stop the run, the returned error has the errors of all the failed policies
+func Run(ctx context.Context, dryRun bool) (*types.RetentionReport, error) {
+	now := time.Now().UTC()
+	report := &types.RetentionReport{DryRun: dryRun, StartTime: now, Collections: map[string]int64{}}
+	//customers license types are read once for all the policies with license types retention
+	var licenseTypes map[string]string
+	var licenseTypesErr error
+	var runErrs error
+	for _, policy := range config.Policies {
+		if len(policy.LicenseTypes) > 0 && licenseTypes == nil && licenseTypesErr == nil {
+			licenseTypes, licenseTypesErr = db.GetCustomersLicenseTypes(ctx)
+		}
+		if len(policy.LicenseTypes) > 0 && licenseTypesErr != nil {
+			//the default retention must not be applied to customers with a license type retention
+			runErrs = multierror.Append(runErrs, fmt.Errorf("failed to purge collection %s: failed to get customers license types: %w", policy.Collection, licenseTypesErr))
+			continue
+		}
+		purged, err := runPolicy(ctx, policy, now, licenseTypes, dryRun)
+		report.Collections[policy.Collection] += purged
+		if err != nil {
+			runErrs = multierror.Append(runErrs, fmt.Errorf("failed to purge collection %s: %w", policy.Collection, err))
+		}
+	}
+	if runErrs != nil {
+		for _, err := range runErrs.(*multierror.Error).Errors {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	report.EndTime = time.Now().UTC()
+	recordRun(report, runErrs != nil)
+	return report, runErrs
+}
+
+// runPolicy purges the documents of the collection customers grouped by their retention days (customer override, license type or default)
+// each group is purged by the customers in the group, the documents without customers have the default retention
+func runPolicy(ctx context.Context, policy types.RetentionPolicy, now time.Time, licenseTypes map[string]string, dryRun bool) (int64, error) {
+	if policy.Collection == "" {
+		return 0, fmt.Errorf("missing collection")
+	}
+	timestampField := types.SchemaInfo{}.GetTimestampFieldName()
+	if schema := types.GetCollectionSchema(policy.Collection); schema != nil {
+		timestampField = schema.GetTimestampFieldName()
+	}
+	customers, err := db.GetCollectionCustomers(ctx, policy.Collection)
+	if err != nil {
+		return 0, err
+	}
+	customersByDays := map[int][]string{}
+	for _, customerGUID := range customers {
+		days := customerDays(policy, customerGUID, licenseTypes)
+		customersByDays[days] = append(customersByDays[days], customerGUID)
+	}
+	allDays := make([]int, 0, len(customersByDays))
+	for days := range customersByDays {
+		allDays = append(allDays, days)
+	}
+	sort.Ints(allDays)
+
+	var purged int64
+	for _, days := range allDays {
+		if days <= 0 {
+			continue
+		}
+		group := customersByDays[days]
+		sort.Strings(group)
+		for start := 0; start < len(group); start += maxPurgeCustomers {
+			batch := group[start:min(start+maxPurgeCustomers, len(group))]
+			count, err := db.PurgeOlderThan(ctx, policy.Collection, timestampField, now.AddDate(0, 0, -days), db.NewFilterBuilder().WithCustomers(batch), dryRun)
+			purged += count
+			if err != nil {
+				return purged, err
+			}
+		}
+	}
+	if policy.Days <= 0 {
+		return purged, nil
+	}
+	withoutCustomers := db.NewFilterBuilder().AddOr(
+		db.NewFilterBuilder().WithValue(consts.CustomersField, bson.A{}),
+		db.NewFilterBuilder().AddExists(consts.CustomersField, false))
+	count, err := db.PurgeOlderThan(ctx, policy.Collection, timestampField, now.AddDate(0, 0, -policy.Days), withoutCustomers, dryRun)
+	return purged + count, err
+}
+
+// customerDays returns the retention days of a customer, the customer override or else the days of its license type or else the default days
+// customers without a customer document have the default days
+func customerDays(policy types.RetentionPolicy, customerGUID string, licenseTypes map[string]string) int {
+	if days, ok := policy.Customers[customerGUID]; ok {
+		return days
+	}
+	if licenseType, ok := licenseTypes[customerGUID]; ok && len(policy.LicenseTypes) > 0 {
+		if days, ok := licenseTypeDays(policy, licenseType); ok {
+			return days
+		}
+	}
+	return policy.Days
+}
+
+// licenseTypeDays returns the retention days of a license type, customers without an active subscription have the free license
+func licenseTypeDays(policy types.RetentionPolicy, licenseType string) (int, bool) {
+	if licenseType == "" {
+		licenseType = string(armotypes.LicenseTypeFree)
+	}
+	for policyLicenseType, days := range policy.LicenseTypes {
+		if strings.EqualFold(policyLicenseType, licenseType) {
+			return days, true
+		}
+	}
+	return 0, false
+}
+
+func recordRun(report *types.RetentionReport, failed bool) {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	stats.Runs++
+	if failed {
+		stats.FailedRuns++
+	}
+	if !report.DryRun {
+		for collection, count := range report.Collections {
+			stats.Purged[collection] += count
+			metrics.RetentionPurged.WithLabelValues(collection).Add(float64(count))
+		}
+	}
+	stats.LastReport = report
+}
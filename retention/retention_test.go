@@ -0,0 +1,67 @@
+package retention
+
+import (
+	"config-service/db"
+	"config-service/db/memory"
+	"config-service/jobs"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testCollection = "retentionTest"
+
+func TestScheduledRun(t *testing.T) {
+	db.SetStore(memory.NewStore())
+	defer db.GetStore().Disconnect()
+	ctx := context.Background()
+	config = Config{
+		Interval: time.Hour,
+		Policies: []types.RetentionPolicy{{Collection: testCollection, Days: 30, Customers: map[string]int{"keep": 0, "short": 10}}},
+	}
+	jobs.Register(JobType, runJob)
+
+	//the instances enqueue the job of an interval once
+	intervalStart := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, now := range []time.Time{intervalStart, intervalStart.Add(time.Minute), intervalStart.Add(59 * time.Minute), intervalStart.Add(time.Hour)} {
+		require.NoError(t, enqueueScheduledRun(ctx, now))
+	}
+	queued, err := db.FindJobs(ctx, types.JobPending, 10)
+	require.NoError(t, err)
+	assert.Len(t, queued, 2)
+
+	//the customers are purged by their retention days, documents without customers have the default retention
+	old, recent := time.Now().UTC().AddDate(0, 0, -60), time.Now().UTC().AddDate(0, 0, -20)
+	collection := db.GetStore().GetWriteCollection(testCollection)
+	docs := []struct {
+		customers []string
+		time      time.Time
+	}{
+		{[]string{"default"}, old}, {[]string{"default"}, recent},
+		{[]string{"keep"}, old},
+		{[]string{"short"}, recent},
+		{[]string{}, old}, {nil, old},
+	}
+	for i, doc := range docs {
+		inserted := bson.M{consts.IdField: fmt.Sprint(i), "creationTime": doc.time}
+		if doc.customers != nil {
+			inserted[consts.CustomersField] = doc.customers
+		}
+		_, err := collection.InsertOne(ctx, inserted)
+		require.NoError(t, err)
+	}
+	job, err := jobs.New(JobType, jobParams{})
+	require.NoError(t, err)
+	require.NoError(t, runJob(ctx, job, func() error { return nil }))
+	assert.Equal(t, map[string]int64{testCollection: 4}, job.Result["collections"])
+	left, err := collection.CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), left)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils"
+	"config-service/utils/consts"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/armosec/armosec-infra/kdr"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	retentionEnterpriseUser = "retention-enterprise-guid"
+	retentionKeepUser       = "retention-keep-guid"
+)
+
+var testRetentionConfig = utils.Retention{
+	Policies: []types.RetentionPolicy{
+		{
+			Collection:   consts.RuntimeIncidentCollection,
+			Days:         30,
+			LicenseTypes: map[string]int{"enterprise": 365},
+			Customers:    map[string]int{retentionKeepUser: 0},
+		},
+	},
+}
+
+type retentionStatus struct {
+	Policies []types.RetentionPolicy `json:"policies"`
+	Stats    types.RetentionStats    `json:"stats"`
+}
+
+// postAgedIncidents posts incidents of the logged in customer created the given number of days ago
+func (suite *MainTestSuite) postAgedIncidents(ages ...int) {
+	for _, age := range ages {
+		w := suite.doRequest(http.MethodPost, consts.RuntimeIncidentPath, &types.RuntimeIncident{RuntimeIncident: kdr.RuntimeIncident{PortalBase: armotypes.PortalBase{Name: "incident"}}})
+		suite.Equal(http.StatusCreated, w.Code)
+		incident := decode[*types.RuntimeIncident](suite, w.Body.Bytes())
+		_, err := db.GetStore().GetWriteCollection(consts.RuntimeIncidentCollection).UpdateOne(context.Background(),
+			bson.M{consts.IdField: incident.GUID},
+			bson.M{"$set": bson.M{"creationTimestamp": time.Now().UTC().AddDate(0, 0, -age)}})
+		suite.NoError(err)
+	}
+}
+
+func (suite *MainTestSuite) TestRetention() {
+	const freeUser = "retention-free-guid"
+	//enterprise customer
+	suite.authCookie = ""
+	testPostDoc(suite, consts.TenantPath, &types.Customer{PortalBase: armotypes.PortalBase{Name: "retention enterprise", GUID: retentionEnterpriseUser}}, customerCompareFilter)
+	suite.login(retentionEnterpriseUser)
+	w := suite.doRequest(http.MethodPut, consts.ActiveSubscriptionPath+"/"+retentionEnterpriseUser, armotypes.Subscription{LicenseType: armotypes.LicenseTypeEnterprise})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.postAgedIncidents(0, 60, 400)
+	//customer without subscription has the default retention
+	suite.login(freeUser)
+	suite.postAgedIncidents(0, 60, 400)
+	//customer that keeps its documents
+	suite.login(retentionKeepUser)
+	suite.postAgedIncidents(0, 60, 400)
+
+	//only admin can run the retention
+	testBadRequest(suite, http.MethodPost, consts.AdminPath+"/retention/run", errorNotAdminUser, nil, http.StatusUnauthorized)
+	suite.loginAsAdmin("retention-admin-guid")
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/retention", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	status := decode[retentionStatus](suite, w.Body.Bytes())
+	suite.Equal(testRetentionConfig.Policies, status.Policies)
+	purgedBefore := status.Stats.Purged[consts.RuntimeIncidentCollection]
+
+	//dry run reports the documents that would be purged
+	w = suite.doRequest(http.MethodPost, consts.AdminPath+"/retention/run?dryRun=true", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	report := decode[types.RetentionReport](suite, w.Body.Bytes())
+	suite.True(report.DryRun)
+	suite.Equal(map[string]int64{consts.RuntimeIncidentCollection: 3}, report.Collections)
+	suite.Empty(report.Errors)
+
+	w = suite.doRequest(http.MethodPost, consts.AdminPath+"/retention/run", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	report = decode[types.RetentionReport](suite, w.Body.Bytes())
+	suite.False(report.DryRun)
+	suite.Equal(map[string]int64{consts.RuntimeIncidentCollection: 3}, report.Collections)
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/retention", nil)
+	status = decode[retentionStatus](suite, w.Body.Bytes())
+	suite.Equal(purgedBefore+3, status.Stats.Purged[consts.RuntimeIncidentCollection])
+	suite.Equal(&report, status.Stats.LastReport)
+
+	//nothing left to purge
+	w = suite.doRequest(http.MethodPost, consts.AdminPath+"/retention/run", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(map[string]int64{consts.RuntimeIncidentCollection: 0}, decode[types.RetentionReport](suite, w.Body.Bytes()).Collections)
+
+	for user, left := range map[string]int{freeUser: 1, retentionEnterpriseUser: 2, retentionKeepUser: 3} {
+		suite.login(user)
+		suite.Len(suite.getRuntimeIncidentsByQuery(nil), left, user)
+	}
+}
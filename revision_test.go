@@ -0,0 +1,122 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/armosec/armoapi-go/notifications"
+)
+
+func (suite *MainTestSuite) TestDocumentRevision() {
+	posturePolicies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	w := suite.doRequest(http.MethodPost, consts.PostureExceptionPolicyPath, posturePolicies[0])
+	suite.Equal(http.StatusCreated, w.Code)
+	suite.Equal(`"1"`, w.Header().Get("ETag"))
+	policy, err := decodeResponse[*types.PostureExceptionPolicy](w)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	docPath := consts.PostureExceptionPolicyPath + "/" + policy.GUID
+
+	w = suite.doRequest(http.MethodGet, docPath, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"1"`, w.Header().Get("ETag"))
+
+	//put with the current revision
+	policy.Attributes = map[string]interface{}{"test": "revision"}
+	w = suite.doRequestWithHeaders(http.MethodPut, consts.PostureExceptionPolicyPath, policy, map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"2"`, w.Header().Get("ETag"))
+	//put with a stale revision
+	policy.Attributes = map[string]interface{}{"test": "stale"}
+	w = suite.doRequestWithHeaders(http.MethodPut, consts.PostureExceptionPolicyPath, policy, map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	//weak and non revision tags never match
+	for _, ifMatch := range []string{`W/"2"`, `"abc"`, `2`} {
+		w = suite.doRequestWithHeaders(http.MethodPut, consts.PostureExceptionPolicyPath, policy, map[string]string{"If-Match": ifMatch})
+		suite.Equal(http.StatusPreconditionFailed, w.Code, ifMatch)
+	}
+	w = suite.doRequest(http.MethodGet, docPath, nil)
+	suite.Equal(`"2"`, w.Header().Get("ETag"))
+	stored, err := decodeResponse[*types.PostureExceptionPolicy](w)
+	suite.NoError(err)
+	suite.Equal("revision", stored.Attributes["test"])
+	//any revision and no precondition
+	w = suite.doRequestWithHeaders(http.MethodPut, consts.PostureExceptionPolicyPath, policy, map[string]string{"If-Match": "*"})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"3"`, w.Header().Get("ETag"))
+	w = suite.doRequest(http.MethodPut, consts.PostureExceptionPolicyPath, policy)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"4"`, w.Header().Get("ETag"))
+
+	//delete with a stale revision
+	w = suite.doRequestWithHeaders(http.MethodDelete, docPath, nil, map[string]string{"If-Match": `"3"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	w = suite.doRequest(http.MethodGet, docPath, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	//delete with one of the listed revisions
+	w = suite.doRequestWithHeaders(http.MethodDelete, docPath, nil, map[string]string{"If-Match": `"3", "4"`})
+	suite.Equal(http.StatusOK, w.Code)
+	//missing document is not found
+	w = suite.doRequestWithHeaders(http.MethodDelete, docPath, nil, map[string]string{"If-Match": `"4"`})
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *MainTestSuite) TestContainerRevision() {
+	testCustomerGUID := "test-revision-customer-guid"
+	customer := &types.Customer{
+		PortalBase: armotypes.PortalBase{
+			Name: "customer-test-revision",
+			GUID: testCustomerGUID,
+		},
+	}
+	//create customer is public so - remove auth cookie
+	suite.authCookie = ""
+	testPostDoc(suite, "/customer_tenant", customer, customerCompareFilter)
+	suite.login(testCustomerGUID)
+	configPath := consts.NotificationConfigPath + "/" + testCustomerGUID
+	w := suite.doRequest(http.MethodGet, configPath, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"1"`, w.Header().Get("ETag"))
+
+	notify := notifications.NotificationConfigIdentifier{NotificationType: notifications.NotificationTypeWeekly}
+	unsubscribePath := fmt.Sprintf("%s/%s/%s", consts.NotificationConfigPath, "unsubscribe", "user1")
+	w = suite.doRequestWithHeaders(http.MethodPut, unsubscribePath, notify, map[string]string{"If-Match": `"2"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	w = suite.doRequestWithHeaders(http.MethodPut, unsubscribePath, notify, map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusOK, w.Code)
+	res, err := decodeResponse[map[string]int](w)
+	suite.NoError(err)
+	suite.Equal(1, res["added"])
+	w = suite.doRequest(http.MethodGet, configPath, nil)
+	suite.Equal(`"2"`, w.Header().Get("ETag"))
+
+	//unchanged documents keep their revision
+	w = suite.doRequestWithHeaders(http.MethodPut, unsubscribePath, notify, map[string]string{"If-Match": `"2"`})
+	suite.Equal(http.StatusOK, w.Code)
+	res, err = decodeResponse[map[string]int](w)
+	suite.NoError(err)
+	suite.Equal(0, res["added"])
+	w = suite.doRequest(http.MethodGet, configPath, nil)
+	suite.Equal(`"2"`, w.Header().Get("ETag"))
+
+	w = suite.doRequestWithHeaders(http.MethodDelete, unsubscribePath, notify, map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	w = suite.doRequestWithHeaders(http.MethodDelete, unsubscribePath, notify, map[string]string{"If-Match": `"2"`})
+	suite.Equal(http.StatusOK, w.Code)
+	res, err = decodeResponse[map[string]int](w)
+	suite.NoError(err)
+	suite.Equal(1, res["removed"])
+
+	pushReportPath := fmt.Sprintf("%s/%s/%s", consts.NotificationConfigPath, "latestPushReport", "cluster1")
+	w = suite.doRequestWithHeaders(http.MethodPut, pushReportPath, notifications.PushReport{ReportGUID: "push-guid"}, map[string]string{"If-Match": `"2"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	w = suite.doRequestWithHeaders(http.MethodDelete, pushReportPath, nil, map[string]string{"If-Match": `"3"`})
+	suite.Equal(http.StatusOK, w.Code)
+	res, err = decodeResponse[map[string]int](w)
+	suite.NoError(err)
+	suite.Equal(0, res["modified"])
+}
@@ -16,6 +16,7 @@ func AddRoutes(g *gin.Engine) {
 		loginDetails := struct {
 			CustomerGUID string                 `json:"customerGUID" binding:"required"`
 			Attributes   map[string]interface{} `json:"attributes,omitempty"`
+			Roles        []string               `json:"roles,omitempty"`
 		}{
 			CustomerGUID: "",
 		}
@@ -35,6 +36,10 @@ func AddRoutes(g *gin.Engine) {
 				cookieValue += ";" + consts.AdminAccess
 			}
 		}
+		//the cookie values after the customer GUID are the user roles
+		for _, role := range loginDetails.Roles {
+			cookieValue += ";" + role
+		}
 		c.SetCookie(consts.CustomerGUID, cookieValue, 2*60*60*24, "/", "", false, true)
 		c.JSON(http.StatusOK, nil)
 	})
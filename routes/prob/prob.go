@@ -1,6 +1,8 @@
 package prob
 
 import (
+	"config-service/health"
+	"config-service/types"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -13,9 +15,19 @@ func AddRoutes(g *gin.Engine) {
 		c.JSON(http.StatusOK, nil)
 	})
 
+	//liveness does not check the dependencies so an outage of mongo does not restart the service
+	prob.GET("healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": types.HealthOK})
+	})
+
+	//readiness runs the registered health checks and reports the result of each check
 	prob.GET("readiness", func(c *gin.Context) {
-		//TODO check number of open mongo sessions
-		c.JSON(http.StatusOK, nil)
+		report := health.Report(c)
+		if report.Status != types.HealthOK {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
 	})
 
 }
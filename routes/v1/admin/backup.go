@@ -0,0 +1,346 @@
+package admin
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"config-service/db"
+	"config-service/handlers"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	backupManifestFile = "manifest.json"
+	backupFileSuffix   = ".ndjson"
+	backupVersion      = 1
+	// max size of a backed up document line
+	maxBackupLineSize = 16 * 1024 * 1024
+)
+
+// backupManifest is the first file of a backup archive
+type backupManifest struct {
+	Version      int              `json:"version"`
+	CustomerGUID string           `json:"customerGUID"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	Collections  map[string]int64 `json:"collections"` //number of documents by collection
+}
+
+// backupCustomer streams a tar.gz archive of all the customer documents, a manifest followed by a NDJSON file per collection
+// documents are written as canonical extended JSON so they are restored with their exact BSON types
+func backupCustomer(c *gin.Context) {
+	defer log.LogNTraceEnterExit("backupCustomer", c)()
+	customerGUID := c.Param("guid")
+	collections, err := db.BackupCollections(c)
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to list collections", err)
+		return
+	}
+	//collections are staged in temp files to know their size for the tar headers
+	tmpDir, err := os.MkdirTemp("", "backup")
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to create backup", err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+	manifest := backupManifest{Version: backupVersion, CustomerGUID: customerGUID, CreatedAt: time.Now().UTC(), Collections: map[string]int64{}}
+	for _, collection := range collections {
+		count, err := stageBackupCollection(c, tmpDir, collection, customerGUID)
+		if err != nil {
+			handlers.ResponseInternalServerError(c, fmt.Sprintf("failed to backup collection %s", collection), err)
+			return
+		}
+		if count > 0 {
+			manifest.Collections[collection] = count
+		}
+	}
+	if len(manifest.Collections) == 0 {
+		handlers.ResponseDocumentNotFound(c)
+		return
+	}
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar.gz"`, customerGUID))
+	c.Status(http.StatusOK)
+	if err := writeBackupArchive(c.Writer, tmpDir, manifest, collections); err != nil {
+		//the status was sent, the client gets a truncated archive
+		log.LogNTraceError("failed to write backup archive", err, c)
+		c.Abort()
+	}
+}
+
+// stageBackupCollection writes the customer documents of a collection to a NDJSON file in dir, returns the number of documents
+func stageBackupCollection(c *gin.Context, dir, collection, customerGUID string) (int64, error) {
+	file, err := os.Create(backupFilePath(dir, collection))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	var count int64
+	err = db.AdminForEachCustomerDoc(c, collection, customerGUID, func(doc bson.Raw) error {
+		line, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, writer.Flush()
+}
+
+func writeBackupArchive(w io.Writer, dir string, manifest backupManifest, collections []string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: backupManifestFile, Mode: 0644, Size: int64(len(manifestData)), ModTime: manifest.CreatedAt}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tarWriter.Write(manifestData); err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		if manifest.Collections[collection] == 0 {
+			continue
+		}
+		if err := writeBackupFile(tarWriter, backupFilePath(dir, collection), collection+backupFileSuffix, manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func writeBackupFile(tarWriter *tar.Writer, path, name string, modTime time.Time) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
+
+func backupFilePath(dir, collection string) string {
+	return filepath.Join(dir, collection+backupFileSuffix)
+}
+
+// restoreCustomer loads a backup archive of the customer, documents that already exist are skipped
+// with remap=true the archive of another customer is restored as the path customer, all the documents get new ids
+// the new ids are derived from the customer and the original ids so restoring an archive again skips the restored documents
+func restoreCustomer(c *gin.Context) {
+	defer log.LogNTraceEnterExit("restoreCustomer", c)()
+	customerGUID := c.Param("guid")
+	remap, _ := strconv.ParseBool(c.Query(consts.RemapParam))
+	//the archive is read twice when remapping
+	archive, err := os.CreateTemp("", "restore")
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to read archive", err)
+		return
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	if _, err := io.Copy(archive, c.Request.Body); err != nil {
+		handlers.ResponseBadRequest(c, "failed to read archive: "+err.Error())
+		return
+	}
+
+	ids := map[string]string{}
+	manifest, err := readBackupArchive(archive, func(collection string, doc bson.D) error {
+		if id, ok := docID(doc).(string); ok && remap {
+			ids[id] = remappedID(customerGUID, id).String()
+		}
+		return nil
+	})
+	if err != nil {
+		handlers.ResponseBadRequest(c, "invalid archive: "+err.Error())
+		return
+	}
+	if manifest.CustomerGUID != customerGUID {
+		if !remap {
+			handlers.ResponseBadRequest(c, fmt.Sprintf("the archive belongs to customer %s, use %s=true to restore it as customer %s", manifest.CustomerGUID, consts.RemapParam, customerGUID))
+			return
+		}
+	} else {
+		//restore the documents as they are
+		remap, ids = false, nil
+	}
+	if remap {
+		ids[manifest.CustomerGUID] = customerGUID
+	}
+
+	restored, skipped := map[string]int64{}, map[string]int64{}
+	_, err = readBackupArchive(archive, func(collection string, doc bson.D) error {
+		if remap {
+			doc = remapValue(doc, ids).(bson.D)
+			if id, ok := docID(doc).(primitive.ObjectID); ok {
+				var remapped primitive.ObjectID
+				copy(remapped[:], remappedID(customerGUID, id.Hex()).Bytes())
+				doc = setDocID(doc, remapped)
+			}
+		}
+		inserted, err := db.AdminRestoreDoc(c, collection, doc)
+		if err != nil {
+			return fmt.Errorf("failed to restore document in %s: %w", collection, err)
+		}
+		if inserted {
+			restored[collection]++
+		} else {
+			skipped[collection]++
+		}
+		return nil
+	})
+	var total int64
+	for _, count := range restored {
+		total += count
+	}
+	handlers.AuditBulkMutation(c, gin.H{"restore": manifest.CustomerGUID, consts.RemapParam: remap}, total, []string{customerGUID})
+	if err != nil {
+		handlers.ResponseInternalServerError(c, fmt.Sprintf("restored: %d, errors: %v", total, err), err)
+		return
+	}
+	log.LogNTrace(fmt.Sprintf("restoreCustomer completed successfully. %d documents of %s restored by admin %s", total, manifest.CustomerGUID, c.GetString(consts.CustomerGUID)), c)
+	c.JSON(http.StatusOK, gin.H{"customerGUID": customerGUID, "restored": restored, "skipped": skipped})
+}
+
+// readBackupArchive reads the manifest of the archive file and calls fn with each document of the archive collections
+func readBackupArchive(archive *os.File, fn func(collection string, doc bson.D) error) (*backupManifest, error) {
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	var manifest *backupManifest
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if manifest == nil {
+			if header.Name != backupManifestFile {
+				return nil, fmt.Errorf("%s must be the first file", backupManifestFile)
+			}
+			manifest = &backupManifest{}
+			if err := json.NewDecoder(tarReader).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", backupManifestFile, err)
+			}
+			if manifest.CustomerGUID == "" {
+				return nil, fmt.Errorf("missing customerGUID in %s", backupManifestFile)
+			}
+			continue
+		}
+		collection, ok := strings.CutSuffix(header.Name, backupFileSuffix)
+		if !ok || !validBackupCollection(collection) {
+			return nil, fmt.Errorf("unknown collection file %s", header.Name)
+		}
+		scanner := bufio.NewScanner(tarReader)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBackupLineSize)
+		for line := 1; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			doc := bson.D{}
+			if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+				return nil, fmt.Errorf("invalid document in %s line %d: %w", header.Name, line, err)
+			}
+			if err := fn(collection, doc); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("missing %s", backupManifestFile)
+	}
+	return manifest, nil
+}
+
+// validBackupCollection returns false for names that are not collections of customer documents
+func validBackupCollection(collection string) bool {
+	return collection != "" && collection != consts.AuditCollection &&
+		!strings.HasPrefix(collection, "system.") && !strings.ContainsAny(collection, "$/\\")
+}
+
+// remappedID returns the id of a document restored as the given customer
+func remappedID(customerGUID, id string) uuid.UUID {
+	return uuid.NewV5(uuid.NamespaceOID, customerGUID+"/"+id)
+}
+
+func docID(doc bson.D) interface{} {
+	for _, e := range doc {
+		if e.Key == consts.IdField {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+func setDocID(doc bson.D, id interface{}) bson.D {
+	for i := range doc {
+		if doc[i].Key == consts.IdField {
+			doc[i].Value = id
+		}
+	}
+	return doc
+}
+
+// remapValue returns the value with the string values in the ids map (in any depth) replaced by their mapped value
+func remapValue(value interface{}, ids map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if mapped, ok := ids[v]; ok {
+			return mapped
+		}
+	case bson.D:
+		for i := range v {
+			v[i].Value = remapValue(v[i].Value, ids)
+		}
+	case bson.A:
+		for i := range v {
+			v[i] = remapValue(v[i], ids)
+		}
+	}
+	return value
+}
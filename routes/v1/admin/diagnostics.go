@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"config-service/db"
+	"config-service/handlers"
+	"config-service/utils/consts"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// default number of slow queries in the slow queries list
+const defaultSlowQueriesLimit = 100
+
+// getSlowQueries returns the latest slow queries, optionally of a route path template and a collection
+func getSlowQueries(c *gin.Context) {
+	limit := defaultSlowQueriesLimit
+	if limitStr := c.Query(consts.LimitParam); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 {
+			handlers.ResponseBadRequest(c, consts.LimitParam+" must be a positive number")
+			return
+		}
+	}
+	slowQueries, err := db.FindSlowQueries(c, c.Query(consts.RouteParam), c.Query(consts.CollectionParam), int64(limit))
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to get slow queries", err)
+		return
+	}
+	c.JSON(http.StatusOK, slowQueries)
+}
+
+// getSlowQueriesRoutes returns the slow queries summary of each route with the plans of its slow queries
+func getSlowQueriesRoutes(c *gin.Context) {
+	routes, err := db.GetSlowQueriesRoutes(c)
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to get slow queries routes", err)
+		return
+	}
+	c.JSON(http.StatusOK, routes)
+}
@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"config-service/db"
+	"config-service/handlers"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getIndexes returns the last reconciliation report of the indexes of each collection
+func getIndexes(c *gin.Context) {
+	c.JSON(http.StatusOK, db.GetIndexesReports())
+}
+
+// reconcileIndexes creates the missing declared indexes of all the collections and drops the extra indexes when configured
+func reconcileIndexes(c *gin.Context) {
+	reports, err := db.ReconcileIndexes(c)
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to reconcile indexes", err)
+		return
+	}
+	log.LogNTrace(fmt.Sprintf("reconcileIndexes completed by admin %s", c.GetString(consts.CustomerGUID)), c)
+	c.JSON(http.StatusOK, reports)
+}
+
+// getIndexesUsage returns the $indexStats usage of the indexes of the collection query param, of all the collections when not set
+func getIndexesUsage(c *gin.Context) {
+	usage, err := db.GetIndexesUsage(c, c.Query(consts.CollectionParam))
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to get indexes usage", err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
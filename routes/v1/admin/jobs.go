@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"config-service/db"
+	"config-service/handlers"
+	"config-service/jobs"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admin job types
+const (
+	deleteCustomersDataJob                   = "deleteCustomersData"
+	updateVulnerabilityExceptionsSeverityJob = "updateVulnerabilityExceptionsSeverity"
+	updatePostureExceptionsSeverityJob       = "updatePostureExceptionsSeverity"
+	markRuntimeIncidentsAsResolvedJob        = "markRuntimeIncidentsAsResolved"
+
+	//default number of jobs in the jobs list
+	defaultJobsLimit = 100
+)
+
+type deleteCustomersDataParams struct {
+	Customers []string `json:"customers"`
+}
+
+func registerJobs() {
+	jobs.Register(deleteCustomersDataJob, runDeleteCustomersData)
+	jobs.Register(updateVulnerabilityExceptionsSeverityJob, func(ctx context.Context, job *types.Job, checkpoint func() error) error {
+		var updateReq types.VulnerabilityExceptionsSeverityUpdate
+		if err := jobs.DecodeParams(job, &updateReq); err != nil {
+			return err
+		}
+		ctx = context.WithValue(ctx, consts.Collection, consts.VulnerabilityExceptionPolicyCollection)
+		updatedCount, err := updateVulnerabilityExceptionsSeverityScore(ctx, updateReq)
+		if err != nil {
+			return err
+		}
+		handlers.AuditJobMutation(ctx, job, consts.VulnerabilityExceptionPolicyCollection, updateReq, updatedCount, nil)
+		job.Progress, job.Result = types.JobProgress{Done: 1, Total: 1}, map[string]interface{}{"updatedCount": updatedCount}
+		return nil
+	})
+	jobs.Register(updatePostureExceptionsSeverityJob, func(ctx context.Context, job *types.Job, checkpoint func() error) error {
+		var updateReq types.PostureExceptionsSeverityUpdate
+		if err := jobs.DecodeParams(job, &updateReq); err != nil {
+			return err
+		}
+		ctx = context.WithValue(ctx, consts.Collection, consts.PostureExceptionPolicyCollection)
+		updatedCount, err := updatePostureExceptionsSeverityScore(ctx, updateReq)
+		if err != nil {
+			return err
+		}
+		handlers.AuditJobMutation(ctx, job, consts.PostureExceptionPolicyCollection, updateReq, updatedCount, nil)
+		job.Progress, job.Result = types.JobProgress{Done: 1, Total: 1}, map[string]interface{}{"updatedCount": updatedCount}
+		return nil
+	})
+	jobs.Register(markRuntimeIncidentsAsResolvedJob, func(ctx context.Context, job *types.Job, checkpoint func() error) error {
+		var updateReq types.BulkResolveRuntimeIncidents
+		if err := jobs.DecodeParams(job, &updateReq); err != nil {
+			return err
+		}
+		ctx = context.WithValue(ctx, consts.Collection, consts.RuntimeIncidentCollection)
+		filter, err := runtimeIncidentsFilter(ctx, updateReq)
+		if err != nil {
+			return err
+		}
+		updatedCount, err := resolveRuntimeIncidents(ctx, filter, updateReq.UserEmail)
+		if err != nil {
+			return err
+		}
+		handlers.AuditJobMutation(ctx, job, consts.RuntimeIncidentCollection, updateReq, updatedCount, []string{updateReq.CustomerGUID})
+		job.Progress, job.Result = types.JobProgress{Done: 1, Total: 1}, map[string]interface{}{"updatedCount": updatedCount}
+		return nil
+	})
+}
+
+// runDeleteCustomersData deletes the data of the customers one by one, a resumed job continues from the first customer that was not deleted
+func runDeleteCustomersData(ctx context.Context, job *types.Job, checkpoint func() error) error {
+	var params deleteCustomersDataParams
+	if err := jobs.DecodeParams(job, &params); err != nil {
+		return err
+	}
+	deleted := jobs.ResultCount(job, "deleted")
+	for i := job.Progress.Done; i < int64(len(params.Customers)); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		customerGUID := params.Customers[i]
+		count, err := db.AdminDeleteCustomersDocs(ctx, customerGUID)
+		deleted += count
+		handlers.AuditJobMutation(ctx, job, "", gin.H{consts.CustomersParam: []string{customerGUID}}, count, nil)
+		job.Result = map[string]interface{}{"deleted": deleted}
+		if err != nil {
+			return err
+		}
+		job.Progress = types.JobProgress{Done: i + 1, Total: int64(len(params.Customers))}
+		if err := checkpoint(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getJobs returns the latest jobs, optionally of a state
+func getJobs(c *gin.Context) {
+	limit := defaultJobsLimit
+	if limitStr := c.Query(consts.LimitParam); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 {
+			handlers.ResponseBadRequest(c, consts.LimitParam+" must be a positive number")
+			return
+		}
+	}
+	jobsList, err := db.FindJobs(c, c.Query("state"), int64(limit))
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to get jobs", err)
+		return
+	}
+	c.JSON(http.StatusOK, jobsList)
+}
+
+func getJob(c *gin.Context) {
+	job, err := db.GetJob(c, c.Param("id"))
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to get job", err)
+		return
+	} else if job == nil {
+		handlers.ResponseDocumentNotFound(c)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// cancelJob cancels a pending job or requests the cancel of a running job, the worker cancels it on its next lease renewal
+func cancelJob(c *gin.Context) {
+	job, err := db.CancelJob(c, c.Param("id"))
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to cancel job", err)
+		return
+	} else if job == nil {
+		handlers.ResponseDocumentNotFound(c)
+		return
+	} else if job.Finished() && job.State != types.JobCancelled {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "job is " + job.State})
+		return
+	}
+	handlers.AuditBulkMutation(c, gin.H{"cancelJob": job.GUID}, 1, nil)
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"config-service/handlers"
+	"config-service/retention"
+	"config-service/utils/consts"
+	"config-service/utils/log"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getRetention returns the retention policies and the purge counters of the instance
+func getRetention(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"policies": retention.Policies(), "stats": retention.Stats()})
+}
+
+// runRetention purges the documents of the retention policies now, with dryRun=true the documents that would be purged are only counted
+func runRetention(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query(consts.DryRunParam))
+	report, err := retention.Run(c, dryRun)
+	if !dryRun {
+		var purged int64
+		for _, count := range report.Collections {
+			purged += count
+		}
+		handlers.AuditBulkMutation(c, gin.H{"retention": report.Collections}, purged, nil)
+		log.LogNTrace(fmt.Sprintf("runRetention completed. %d documents purged by admin %s", purged, c.GetString(consts.CustomerGUID)), c)
+	}
+	if err != nil {
+		handlers.ResponseInternalServerError(c, fmt.Sprintf("retention run completed with errors: %v", err), err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -7,6 +7,7 @@ import (
 	"config-service/utils"
 	"config-service/utils/consts"
 	"config-service/utils/log"
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -28,6 +29,8 @@ func AddRoutes(g *gin.Engine) {
 		if c.GetBool(consts.AdminAccess) {
 			c.Next()
 		} else if slices.Contains(adminUsers, c.GetString(consts.CustomerGUID)) {
+			//the admin routes handlers and db functions see the configured admins as admins
+			c.Set(consts.AdminAccess, true)
 			c.Next()
 		} else {
 			//not admin
@@ -43,6 +46,21 @@ func AddRoutes(g *gin.Engine) {
 	admin.GET("/customers", handlers.DBContextMiddleware(consts.CustomersCollection), getCustomers)
 	//add delete customers data route
 	admin.DELETE("/customers", deleteAllCustomerData)
+	//backup all the documents of a customer and restore them (optionally as another customer)
+	admin.GET("/customers/:guid/backup", backupCustomer)
+	admin.POST("/customers/:guid/restore", restoreCustomer)
+	//purge documents from the trash of soft delete routes
+	admin.DELETE("/trash", purgeTrash)
+	//retention policies and purge of old documents
+	admin.GET("/retention", getRetention)
+	admin.POST("/retention/run", runRetention)
+	//declared indexes reconciliation and indexes usage
+	admin.GET("/indexes", getIndexes)
+	admin.POST("/indexes/reconcile", reconcileIndexes)
+	admin.GET("/indexes/usage", getIndexesUsage)
+	//slow queries diagnostics
+	admin.GET("/slowQueries", getSlowQueries)
+	admin.GET("/slowQueries/routes", getSlowQueriesRoutes)
 
 	admin.PUT("/updateVulnerabilityExceptionsSeverity",
 		handlers.DBContextMiddleware(consts.VulnerabilityExceptionPolicyCollection),
@@ -62,6 +80,12 @@ func AddRoutes(g *gin.Engine) {
 	admin.DELETE("/:path/query", adminDeleteCollection)
 	//uniqueValues
 	admin.POST("/:path/uniqueValues", adminAggregateCollection)
+
+	//background jobs of async admin requests
+	registerJobs()
+	admin.GET("/jobs", getJobs)
+	admin.GET("/jobs/:id", getJob)
+	admin.POST("/jobs/:id/cancel", cancelJob)
 }
 
 func updateVulnerabilityExceptionsSeverity(c *gin.Context) {
@@ -71,16 +95,26 @@ func updateVulnerabilityExceptionsSeverity(c *gin.Context) {
 		return
 	}
 
-	filter := db.NewFilterBuilder().WithIn("vulnerabilities.name", updateReq.Cves)
-	update := db.GetUpdateSetFieldCommand("vulnerabilities.$.severityScore", updateReq.SeverityScore)
-	updatedCount, err := db.AdminUpdateMany(c, filter, update)
+	if handlers.IsAsyncRequest(c) {
+		handlers.EnqueueJob(c, updateVulnerabilityExceptionsSeverityJob, updateReq)
+		return
+	}
+	updatedCount, err := updateVulnerabilityExceptionsSeverityScore(c, updateReq)
 	if err != nil {
 		handlers.ResponseInternalServerError(c, "failed to update vulnerability exceptions severity", err)
 		return
 	}
+	handlers.AuditBulkMutation(c, updateReq, updatedCount, nil)
 	c.JSON(http.StatusOK, gin.H{"updatedCount": updatedCount})
 }
 
+// updateVulnerabilityExceptionsSeverityScore sets the severity score of the CVEs in the vulnerability exceptions of all customers
+func updateVulnerabilityExceptionsSeverityScore(c context.Context, updateReq types.VulnerabilityExceptionsSeverityUpdate) (int64, error) {
+	filter := db.NewFilterBuilder().WithIn("vulnerabilities.name", updateReq.Cves)
+	update := db.GetUpdateSetFieldCommand("vulnerabilities.$.severityScore", updateReq.SeverityScore)
+	return db.AdminUpdateMany(c, filter, update)
+}
+
 func updatePostureExceptionsSeverity(c *gin.Context) {
 	var updateReq types.PostureExceptionsSeverityUpdate
 	if err := c.ShouldBindJSON(&updateReq); err != nil {
@@ -88,16 +122,26 @@ func updatePostureExceptionsSeverity(c *gin.Context) {
 		return
 	}
 
-	filter := db.NewFilterBuilder().WithIn("posturePolicies.controlID", updateReq.ControlIDS)
-	update := db.GetUpdateSetFieldCommand("posturePolicies.$.severityScore", updateReq.SeverityScore)
-	updatedCount, err := db.AdminUpdateMany(c, filter, update)
+	if handlers.IsAsyncRequest(c) {
+		handlers.EnqueueJob(c, updatePostureExceptionsSeverityJob, updateReq)
+		return
+	}
+	updatedCount, err := updatePostureExceptionsSeverityScore(c, updateReq)
 	if err != nil {
 		handlers.ResponseInternalServerError(c, "failed to update posture exceptions severity", err)
 		return
 	}
+	handlers.AuditBulkMutation(c, updateReq, updatedCount, nil)
 	c.JSON(http.StatusOK, gin.H{"updatedCount": updatedCount})
 }
 
+// updatePostureExceptionsSeverityScore sets the severity score of the controls in the posture exceptions of all customers
+func updatePostureExceptionsSeverityScore(c context.Context, updateReq types.PostureExceptionsSeverityUpdate) (int64, error) {
+	filter := db.NewFilterBuilder().WithIn("posturePolicies.controlID", updateReq.ControlIDS)
+	update := db.GetUpdateSetFieldCommand("posturePolicies.$.severityScore", updateReq.SeverityScore)
+	return db.AdminUpdateMany(c, filter, update)
+}
+
 func adminDeleteCollection(c *gin.Context) {
 	path := "/" + c.Param("path")
 	apiInfo := types.GetAPIInfo(path)
@@ -171,7 +215,12 @@ func deleteAllCustomerData(c *gin.Context) {
 		handlers.ResponseMissingQueryParam(c, consts.CustomersParam)
 		return
 	}
+	if handlers.IsAsyncRequest(c) {
+		handlers.EnqueueJob(c, deleteCustomersDataJob, deleteCustomersDataParams{Customers: customersGUIDs})
+		return
+	}
 	deleted, err := db.AdminDeleteCustomersDocs(c, customersGUIDs...)
+	handlers.AuditBulkMutation(c, gin.H{consts.CustomersParam: customersGUIDs}, deleted, nil)
 	if err != nil {
 		log.LogNTraceError(fmt.Sprintf("deleteAllCustomerData completed with errors. %d documents deleted", deleted), err, c)
 		handlers.ResponseInternalServerError(c, fmt.Sprintf("deleted: %d, errors: %v", deleted, err), err)
@@ -182,21 +231,29 @@ func deleteAllCustomerData(c *gin.Context) {
 
 }
 
-func deleteOldRuntimeIncidents(c *gin.Context) {
-	customersGUIDs := c.QueryArray(consts.CustomersParam)
-	if len(customersGUIDs) == 0 {
-		handlers.ResponseMissingQueryParam(c, consts.CustomersParam)
-		return
+// purgeTrash removes the documents in the trash longer than the olderThanDays param (default is the trash retention configuration)
+func purgeTrash(c *gin.Context) {
+	olderThanDays := utils.GetConfig().Trash.RetentionDays
+	if daysStr := c.Query(consts.OlderThanDaysParam); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			handlers.ResponseBadRequest(c, consts.OlderThanDaysParam+" must be a positive number")
+			return
+		}
+		olderThanDays = days
 	}
-	deleted, err := db.AdminDeleteCustomersDocs(c, customersGUIDs...)
+	purged, err := db.PurgeTrash(c, time.Now().UTC().AddDate(0, 0, -olderThanDays))
+	var deleted int64
+	for _, count := range purged {
+		deleted += count
+	}
+	handlers.AuditBulkMutation(c, gin.H{consts.OlderThanDaysParam: olderThanDays}, deleted, nil)
 	if err != nil {
-		log.LogNTraceError(fmt.Sprintf("deleteAllCustomerData completed with errors. %d documents deleted", deleted), err, c)
 		handlers.ResponseInternalServerError(c, fmt.Sprintf("deleted: %d, errors: %v", deleted, err), err)
 		return
 	}
-	log.LogNTrace(fmt.Sprintf("deleteAllCustomerData completed successfully. %d documents of %d users deleted by admin %s ", deleted, len(customersGUIDs), c.GetString(consts.CustomerGUID)), c)
-	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
-
+	log.LogNTrace(fmt.Sprintf("purgeTrash completed successfully. %d documents deleted by admin %s", deleted, c.GetString(consts.CustomerGUID)), c)
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted, "collections": purged})
 }
 
 func getActiveCustomers(c *gin.Context) {
@@ -260,28 +317,43 @@ func markRuntimeIncidentsAsResolved(c *gin.Context) {
 		return
 	}
 
-	req := armotypes.V2ListRequest{InnerFilters: updateReq.InnerFilters}
-	findOpts, err := handlers.V2List2FindOptionsNotPaginated(c, req)
+	filter, err := runtimeIncidentsFilter(c, updateReq)
 	if err != nil {
 		handlers.ResponseBadRequest(c, err.Error())
 		return
 	}
-	filter := findOpts.Filter().WithCustomers([]string{updateReq.CustomerGUID})
-	nowTime := time.Now().UTC()
+	if handlers.IsAsyncRequest(c) {
+		handlers.EnqueueJob(c, markRuntimeIncidentsAsResolvedJob, updateReq)
+		return
+	}
+	updatedCount, err := resolveRuntimeIncidents(c, filter, updateReq.UserEmail)
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to update runtime incidents", err)
+		return
+	}
+	handlers.AuditBulkMutation(c, updateReq, updatedCount, []string{updateReq.CustomerGUID})
+	c.JSON(http.StatusOK, gin.H{"updatedCount": updatedCount})
+}
 
-	// mark customer incidents as resolved
+// runtimeIncidentsFilter returns the filter of the customer incidents matching the inner filters of the request
+func runtimeIncidentsFilter(c context.Context, updateReq types.BulkResolveRuntimeIncidents) (*db.FilterBuilder, error) {
+	req := armotypes.V2ListRequest{InnerFilters: updateReq.InnerFilters}
+	findOpts, err := handlers.V2List2FindOptionsNotPaginated(c, req)
+	if err != nil {
+		return nil, err
+	}
+	return findOpts.Filter().WithCustomers([]string{updateReq.CustomerGUID}), nil
+}
+
+// resolveRuntimeIncidents marks the incidents matching the filter as resolved by the user
+func resolveRuntimeIncidents(c context.Context, filter *db.FilterBuilder, userEmail string) (int64, error) {
+	nowTime := time.Now().UTC()
 	update := db.GetMultipleUpdateSetFieldCommand(map[string]interface{}{
 		"isDismissed": true,
 		"seenAt":      &nowTime,
-		"seenBy":      updateReq.UserEmail,
+		"seenBy":      userEmail,
 		"resolvedAt":  &nowTime,
-		"resolvedBy":  updateReq.UserEmail,
+		"resolvedBy":  userEmail,
 	})
-
-	updatedCount, err := db.AdminUpdateMany(c, filter, update)
-	if err != nil {
-		handlers.ResponseInternalServerError(c, "failed to update runtime incidents", err)
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"updatedCount": updatedCount})
+	return db.AdminUpdateMany(c, filter, update)
 }
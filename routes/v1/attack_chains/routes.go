@@ -16,5 +16,17 @@ func AddRoutes(g *gin.Engine) {
 		WithGetNamesList(false).
 		WithValidatePostUniqueName(true).
 		WithValidatePostMandatoryName(true).
+		WithWatch(true).
+		WithSchemaInfo(types.SchemaInfo{
+			Indexes: []types.IndexInfo{
+				types.NewIndex("guid"),
+				types.NewIndex("name"),
+				types.NewIndex("customers"),
+				types.NewIndex("clusterName"),
+				types.NewIndex("lastPostureScanTriggered"),
+				types.NewIndex("lastTimeEngineCompleted"),
+				types.NewIndex("-creationTime"),
+			},
+		}).
 		Get()...)
 }
@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"config-service/handlers"
+	"config-service/types"
+	"config-service/utils/consts"
+
+	"github.com/aws/smithy-go/ptr"
+	"github.com/gin-gonic/gin"
+)
+
+// AddRoutes adds the read only audit trail query routes, the audit events are written by the generic handlers on every mutation
+// the events hold the diffs of the documents of all the collections so only auditors (and admins) can search them
+func AddRoutes(g *gin.Engine) {
+	schemaInfo := types.SchemaInfo{
+		ArrayPaths: []string{"diff"},
+		FieldsType: map[string]types.FieldType{
+			"timestamp": "date",
+		},
+		TimestampFieldName: ptr.String("timestamp"),
+		Indexes: []types.IndexInfo{
+			types.NewIndex("customers", "-timestamp"),
+			types.NewIndex("docGUID", "-timestamp"),
+			types.NewIndex("actor"),
+		},
+	}
+
+	handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.AuditEvent]().
+		WithPath(consts.AuditPath).
+		WithDBCollection(consts.AuditCollection).
+		WithSchemaInfo(schemaInfo).
+		WithV2ListSearch(true).
+		WithServeGet(false).
+		WithServePost(false).
+		WithServePut(false).
+		WithServeDelete(false).
+		WithPermissions(map[handlers.Permission][]string{handlers.PermissionRead: {consts.AuditorRole}}).
+		Get()...)
+}
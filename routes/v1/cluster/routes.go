@@ -4,6 +4,7 @@ import (
 	"config-service/handlers"
 	"config-service/types"
 	"config-service/utils/consts"
+	"time"
 
 	"github.com/aws/smithy-go/ptr"
 	"github.com/gin-gonic/gin"
@@ -12,6 +13,10 @@ import (
 func AddRoutes(g *gin.Engine) {
 	schemaInfo := types.SchemaInfo{
 		TimestampFieldName: ptr.String("subscription_date"),
+		Indexes: []types.IndexInfo{
+			types.NewIndex("_id", "customers"),
+			types.NewIndex("customers"),
+		},
 	}
 	handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.Cluster]().
 		WithPath(consts.ClusterPath).
@@ -23,5 +28,11 @@ func AddRoutes(g *gin.Engine) {
 		WithV2ListSearch(true).
 		WithNameQuery(consts.NameField).
 		WithSchemaInfo(schemaInfo).
+		WithQueryCache(time.Minute).
+		WithServeBulkPut(true).
+		WithServePatch(true).
+		WithServeUpsert(true).
+		WithServeExport(true).
+		WithServeImport(true).
 		Get()...)
 }
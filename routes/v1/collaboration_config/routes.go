@@ -11,5 +11,13 @@ import (
 func AddRoutes(g *gin.Engine) {
 	handlers.AddPolicyRoutes[*types.CollaborationConfig](g,
 		consts.CollaborationConfigPath,
-		consts.CollaborationConfigCollection, handlers.FlatQueryConfig(), true, nil)
+		consts.CollaborationConfigCollection, handlers.FlatQueryConfig(), true,
+		&types.SchemaInfo{
+			Indexes: []types.IndexInfo{
+				types.NewIndex("guid"),
+				types.NewIndex("name"),
+				types.NewIndex("provider"),
+				types.NewIndex("customers"),
+			},
+		})
 }
@@ -28,7 +28,13 @@ func AddRoutes(g *gin.Engine) {
 	handlers.AddRouteInfo[*types.Customer](types.APIInfo{
 		BasePath:     consts.CustomerPath,
 		DBCollection: consts.CustomersCollection,
-		// Schema:       nil,
+		//the indexes of the customers collection are declared by the customer route, the other routes of the collection are added after it
+		Schema: types.SchemaInfo{
+			Indexes: []types.IndexInfo{
+				types.NewIndex("guid"),
+				types.NewIndex("activeSubscription.licenseType", "activeSubscription.subscriptionStatus", "subscription_date"),
+			},
+		},
 	})
 
 	//add customer's inner files routes
@@ -3,9 +3,11 @@ package customer_config
 import (
 	"config-service/db"
 	"config-service/handlers"
+	"config-service/health"
 	"config-service/types"
 	"config-service/utils"
 	"config-service/utils/consts"
+	"context"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,9 +24,12 @@ func AddRoutes(g *gin.Engine) {
 		WithServeDelete(false).                       // customer config needs custom delete handler
 		WithValidatePutGUID(false).                   // customer config needs custom put validator
 		WithPutValidators(validatePutCustomerConfig). //customer config custom put validator
+		WithVersionHistory(true).                     //keep prior revisions to undo edits
 		Get()...)
 
-	customerConfigRouter.GET("", getCustomerConfigHandler)
+	//customer configs are read by every scan and rarely change
+	db.SetQueryCached(consts.CustomerConfigCollection)
+	customerConfigRouter.GET("", handlers.QueryCacheContextMiddleware(time.Minute), getCustomerConfigHandler)
 	customerConfigRouter.DELETE("", deleteCustomerConfig)
 
 	// load default customer config from config file
@@ -38,6 +43,10 @@ func AddRoutes(g *gin.Engine) {
 			consts.CustomerConfigCollection,
 			db.NewFilterBuilder().WithGlobal().WithName(consts.GlobalConfigName),
 			time.Minute*5)
+		//requests of customer configs fail without the default config
+		health.Register("defaultCustomerConfig", func(ctx context.Context) error {
+			return db.CheckCachedDocument(consts.DefaultCustomerConfigKey)
+		})
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"config-service/handlers"
 	"config-service/types"
 	"config-service/utils/consts"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,5 +15,6 @@ func AddRoutes(g *gin.Engine) {
 		WithDBCollection(consts.FrameworkCollection).
 		WithNameQuery(consts.FrameworkNameParam).
 		WithDeleteByName(true).
+		WithQueryCache(5*time.Minute). //frameworks are read by every scan and rarely change
 		Get()...)
 }
@@ -19,6 +19,29 @@ func AddRoutes(g *gin.Engine) {
 			"owner.repoHash":       types.String,
 			"relatedObjects.cveID": types.String,
 		},
+		Indexes: []types.IndexInfo{
+			types.NewIndex("guid"),
+			types.NewIndex("provider"),
+			types.NewIndex("type"),
+			types.NewIndex("customers"),
+			types.NewIndex("owner.name"),
+			types.NewIndex("owner.kind"),
+			types.NewIndex("owner.namespace"),
+			types.NewIndex("owner.cluster"),
+			types.NewIndex("owner.repoHash"),
+			types.NewIndex("owner.resourceID"),
+			types.NewIndex("owner.resourceHash"),
+			types.NewIndex("relatedObjects.layerHash"),
+			types.NewIndex("relatedObjects.controlID"),
+			types.NewIndex("relatedObjects.baseScore"),
+			types.NewIndex("relatedObjects.cveName"),
+			types.NewIndex("relatedObjects.cveID"),
+			types.NewIndex("relatedObjects.severity"),
+			types.NewIndex("relatedObjects.severityScore"),
+			types.NewIndex("relatedObjects.component"),
+			types.NewIndex("relatedObjects.componentVersion"),
+			types.NewIndex("relatedObjects.imageRepository"),
+		},
 	}
 
 	handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.IntegrationReference]().
@@ -31,8 +31,13 @@ func AddRoutes(g *gin.Engine) {
 		queryParamsConfig,
 		false,
 		&types.SchemaInfo{
-			ArrayPaths: []string{"posturePolicies", "resources"},
-			FieldsType: map[string]types.FieldType{"expirationDate": types.Date},
+			ArrayPaths:          []string{"posturePolicies", "resources"},
+			FieldsType:          map[string]types.FieldType{"expirationDate": types.Date},
+			ExpirationFieldName: "expirationDate",
 		},
+		handlers.NewRouterOptionsBuilder[*types.PostureExceptionPolicy]().
+			WithVersionHistory(true).
+			WithServeImport(true).
+			Get()...,
 	)
 }
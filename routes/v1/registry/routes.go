@@ -16,6 +16,10 @@ func AddRoutes(g *gin.Engine) {
 			"updatedTime":  "date",
 		},
 		TimestampFieldName: ptr.String("updatedTime"),
+		Indexes: []types.IndexInfo{
+			types.NewIndex("guid"),
+			types.NewIndex("customers"),
+		},
 	}
 
 	routerOptionsBuilder := handlers.NewRouterOptionsBuilder[*types.ContainerImageRegistry]().
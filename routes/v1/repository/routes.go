@@ -28,5 +28,6 @@ func AddRoutes(g *gin.Engine) {
 		WithNameQuery(consts.NameField).
 		WithSchemaInfo(schemaInfo).
 		WithV2ListSearch(true).
+		WithSoftDelete(true).
 		Get()...)
 }
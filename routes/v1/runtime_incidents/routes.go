@@ -24,6 +24,23 @@ func AddRoutes(g *gin.Engine) {
 		},
 		TimestampFieldName: ptr.String("creationTimestamp"),
 		MustExcludeFields:  []string{"relatedAlerts", "creationDayDate", "resolveDayDate"},
+		Indexes: []types.IndexInfo{
+			types.NewIndex("guid"),
+			types.NewIndex("_id", "customers"),
+			types.NewIndex("name"),
+			types.NewIndex("customers"),
+			types.NewIndex("relatedAlerts.ruleID"),
+			types.NewIndex("relatedAlerts.timestamp"),
+			// can't have customers and relatedAlerts in the same index, they are different arrays
+			types.NewIndex("guid", "relatedAlerts.timestamp"),
+			types.NewIndex("customers", "incidentSeverity"),
+			types.NewIndex("isDismissed"),
+			types.NewIndex("-creationTimestamp"),
+			types.NewIndex("customers", "workloadKind", "workloadName"),
+			types.NewIndex("customers", "isDismissed"),
+			types.NewIndex("customers", "severityScore"),
+			types.NewIndex("customers", "timestamp"),
+		},
 	}
 
 	handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.RuntimeIncident]().
@@ -35,6 +52,7 @@ func AddRoutes(g *gin.Engine) {
 		WithPutValidators(incidentUpdateResolveDayDate).
 		WithSchemaInfo(schemaInfo).
 		WithV2ListSearch(true).
+		WithWatch(true).
 		Get()...)
 }
 
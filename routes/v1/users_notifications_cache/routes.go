@@ -25,6 +25,14 @@ func AddRoutes(g *gin.Engine) {
 			FieldsType: map[string]types.FieldType{
 				"expiryTime": types.Date,
 			},
+			Indexes: []types.IndexInfo{
+				types.NewIndex("guid"),
+				types.NewIndex("name"),
+				types.NewIndex("customers"),
+				types.NewIndex("dataType"),
+				//documents are removed when they expire
+				types.NewIndex("expiryTime").WithTTL(0),
+			},
 		}).
 		Get()...)
 }
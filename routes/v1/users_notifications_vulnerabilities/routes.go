@@ -10,6 +10,13 @@ import (
 
 var schema = types.SchemaInfo{
 	ArrayPaths: []string{"workloads", "images", "wlids"},
+	Indexes: []types.IndexInfo{
+		types.NewIndex("cveID"),
+		types.NewIndex("cluster"),
+		types.NewIndex("namespace"),
+		types.NewIndex("notificationType"),
+		types.NewIndex("customers"),
+	},
 }
 
 func AddRoutes(g *gin.Engine) {
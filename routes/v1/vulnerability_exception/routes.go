@@ -33,7 +33,19 @@ func AddRoutes(g *gin.Engine) {
 		queryParamsConfig,
 		false,
 		&types.SchemaInfo{
-			ArrayPaths: []string{"vulnerabilities", "designators"},
-			FieldsType: map[string]types.FieldType{"expirationDate": types.Date},
-		})
+			ArrayPaths:          []string{"vulnerabilities", "designators"},
+			FieldsType:          map[string]types.FieldType{"expirationDate": types.Date},
+			ExpirationFieldName: "expirationDate",
+			Indexes: []types.IndexInfo{
+				types.NewIndex("guid"),
+				types.NewIndex("name"),
+				types.NewIndex("customers"),
+				// can't index on both customer and designators, as they are in different arrays
+				types.NewIndex("attributes.namespaceOnly", "designators.attributes.cluster", "designators.attributes.namespace"),
+			},
+		},
+		handlers.NewRouterOptionsBuilder[*types.VulnerabilityExceptionPolicy]().
+			WithVersionHistory(true).
+			WithServePatch(true).
+			Get()...)
 }
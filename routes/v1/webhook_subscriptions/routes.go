@@ -0,0 +1,208 @@
+package webhook_subscriptions
+
+import (
+	"config-service/db"
+	"config-service/handlers"
+	"config-service/types"
+	"config-service/utils/consts"
+	"config-service/webhooks"
+	"fmt"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+	"github.com/aws/smithy-go/ptr"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slices"
+)
+
+const redeliverPath = "/:" + consts.GUIDField + "/redeliver"
+
+var eventTypes = []string{types.WebhookEventCreated, types.WebhookEventUpdated, types.WebhookEventDeleted, types.WebhookEventExpired}
+
+// webhook collections are not subscribable, the deliveries would publish events of themselves
+var webhookCollections = []string{consts.WebhookSubscriptionsCollection, consts.WebhookDeliveriesCollection, consts.WebhookDeadLettersCollection}
+
+// AddRoutes adds the webhook subscriptions routes, the read only delivery log routes and the dead letters routes
+func AddRoutes(g *gin.Engine) {
+	handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.WebhookSubscription]().
+		WithPath(consts.WebhookSubscriptionPath).
+		WithDBCollection(consts.WebhookSubscriptionsCollection).
+		WithSchemaInfo(types.SchemaInfo{
+			ArrayPaths:        []string{"eventTypes", "collections"},
+			MustExcludeFields: []string{"secret", "matchQuery"},
+			Indexes: []types.IndexInfo{
+				types.NewIndex("guid"),
+				types.NewIndex("customers", "collections"),
+			},
+		}).
+		WithV2ListSearch(true).
+		WithGetNamesList(false).
+		WithValidatePostUniqueName(false).
+		WithPostValidators(validateSubscriptions(true)).
+		WithPutValidators(validateSubscriptions(false)).
+		Get()...)
+
+	deliverySchemaInfo := types.SchemaInfo{
+		FieldsType: map[string]types.FieldType{
+			"creationTime":    "date",
+			"nextAttemptTime": "date",
+			"lastAttemptTime": "date",
+			"deliveredTime":   "date",
+			"event.timestamp": "date",
+		},
+		TimestampFieldName: ptr.String("creationTime"),
+	}
+	deliveryIndexes := []types.IndexInfo{
+		types.NewIndex("customers", "subscriptionGUID"),
+		types.NewIndex("status", "nextAttemptTime"),
+		//deliveries are removed from the delivery log when they expire
+		types.NewIndex("expiryTime").WithTTL(0),
+	}
+	deadLetterIndexes := []types.IndexInfo{
+		types.NewIndex("customers", "subscriptionGUID"),
+	}
+
+	//delivery log
+	handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.WebhookDelivery]().
+		WithPath(consts.WebhookDeliveryPath).
+		WithDBCollection(consts.WebhookDeliveriesCollection).
+		WithSchemaInfo(withIndexes(deliverySchemaInfo, deliveryIndexes)).
+		WithV2ListSearch(true).
+		WithServeGetWithGUIDOnly(true).
+		WithServePost(false).
+		WithServePut(false).
+		WithServeDelete(false).
+		Get()...)
+
+	//dead letters
+	deadLettersRouter := handlers.AddRoutes(g, handlers.NewRouterOptionsBuilder[*types.WebhookDelivery]().
+		WithPath(consts.WebhookDeadLetterPath).
+		WithDBCollection(consts.WebhookDeadLettersCollection).
+		WithSchemaInfo(withIndexes(deliverySchemaInfo, deadLetterIndexes)).
+		WithV2ListSearch(true).
+		WithServeGetWithGUIDOnly(true).
+		WithServePost(false).
+		WithServePut(false).
+		Get()...)
+
+	deadLettersRouter.POST(redeliverPath, redeliverDeadLetter)
+}
+
+// withIndexes returns a copy of the schema info with the indexes
+func withIndexes(schemaInfo types.SchemaInfo, indexes []types.IndexInfo) types.SchemaInfo {
+	schemaInfo.Indexes = indexes
+	return schemaInfo
+}
+
+// validateSubscriptions validates the subscriptions and compiles their inner filters to the match query of the documents
+// the caller must be permitted to read the subscribed collections since the events hold the documents
+func validateSubscriptions(isPost bool) handlers.MutatorValidator[*types.WebhookSubscription] {
+	return func(c *gin.Context, docs []*types.WebhookSubscription) ([]*types.WebhookSubscription, bool) {
+		for _, doc := range docs {
+			for _, collection := range doc.Collections {
+				if path := handlers.DeniedReadPath(c, collection); path != "" {
+					handlers.ResponseMissingPermission(c, path, handlers.PermissionRead)
+					return nil, false
+				}
+			}
+			if err := validateSubscription(c, doc, isPost); err != nil {
+				handlers.ResponseBadRequest(c, err.Error())
+				return nil, false
+			}
+		}
+		return docs, true
+	}
+}
+
+func validateSubscription(c *gin.Context, doc *types.WebhookSubscription, isPost bool) error {
+	if isPost {
+		switch {
+		case len(doc.EventTypes) == 0:
+			return fmt.Errorf("eventTypes is required")
+		case len(doc.Collections) == 0:
+			return fmt.Errorf("collections is required")
+		case doc.URL == "":
+			return fmt.Errorf("url is required")
+		case doc.Secret == "":
+			return fmt.Errorf("secret is required")
+		}
+	}
+	for _, eventType := range doc.EventTypes {
+		if !slices.Contains(eventTypes, eventType) {
+			return fmt.Errorf("invalid event type %s, valid event types are %v", eventType, eventTypes)
+		}
+	}
+	for _, collection := range doc.Collections {
+		if _, ok := collectionSchema(collection); !ok {
+			return fmt.Errorf("invalid collection %s", collection)
+		}
+	}
+	if doc.URL != "" {
+		if err := webhooks.ValidateTargetURL(doc.URL); err != nil {
+			return err
+		}
+	}
+	//the match query is set only by the inner filters
+	doc.MatchQuery = ""
+	if len(doc.InnerFilters) > 0 {
+		matchQuery, err := compileInnerFilters(c, doc)
+		if err != nil {
+			return err
+		}
+		doc.MatchQuery = matchQuery
+	}
+	return nil
+}
+
+// compileInnerFilters returns the extended JSON query of the subscription inner filters
+// the filter values are typed by the schema of the collection so inner filters require a single collection
+func compileInnerFilters(c *gin.Context, doc *types.WebhookSubscription) (string, error) {
+	if len(doc.Collections) != 1 {
+		return "", fmt.Errorf("innerFilters require a single collection")
+	}
+	schema, _ := collectionSchema(doc.Collections[0])
+	ctx := c.Copy()
+	ctx.Set(consts.SchemaInfo, schema)
+	findOptions, err := handlers.V2List2FindOptionsNotPaginated(ctx, armotypes.V2ListRequest{InnerFilters: doc.InnerFilters})
+	if err != nil {
+		return "", fmt.Errorf("invalid innerFilters: %w", err)
+	}
+	return findOptions.Filter().ExtJSON()
+}
+
+// collectionSchema returns the schema of a subscribable collection
+func collectionSchema(collection string) (types.SchemaInfo, bool) {
+	if slices.Contains(webhookCollections, collection) {
+		return types.SchemaInfo{}, false
+	}
+	for _, path := range types.GetAllPaths() {
+		if apiInfo := types.GetAPIInfo(path); apiInfo.DBCollection == collection {
+			return apiInfo.Schema, true
+		}
+	}
+	return types.SchemaInfo{}, false
+}
+
+// redeliverDeadLetter queues a dead letter as a new delivery and removes it from the dead letters
+func redeliverDeadLetter(c *gin.Context) {
+	guid := c.Param(consts.GUIDField)
+	deadLetter, err := db.GetDocByGUID[types.WebhookDelivery](c, guid)
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to read dead letter", err)
+		return
+	}
+	if deadLetter == nil {
+		handlers.ResponseDocumentNotFound(c)
+		return
+	}
+	delivery, err := webhooks.Redeliver(c, deadLetter)
+	if err != nil {
+		handlers.ResponseInternalServerError(c, "failed to queue delivery", err)
+		return
+	}
+	if _, err := db.DeleteByGUID[*types.WebhookDelivery](c, guid); err != nil {
+		handlers.ResponseInternalServerError(c, "failed to delete dead letter", err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
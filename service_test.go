@@ -1,7 +1,7 @@
 package main
 
 import (
-	"config-service/db/mongo"
+	"config-service/db"
 	"config-service/routes/v1/customer_config"
 	"config-service/types"
 	"config-service/utils"
@@ -507,7 +507,7 @@ func (suite *MainTestSuite) TestPostureException() {
 			},
 		},
 	}
-	collection := mongo.GetWriteCollection(consts.PostureExceptionPolicyCollection)
+	collection := db.GetStore().GetWriteCollection(consts.PostureExceptionPolicyCollection)
 	if _, err := collection.InsertOne(context.Background(), oldException); err != nil {
 		suite.FailNow("Failed to insert posturePolicyException", err.Error())
 	}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) TestSlowQueries() {
+	const customerGUID = "slow-queries-guid"
+	//every query is slow
+	db.SetSlowQueries(db.SlowQueriesConfig{Threshold: time.Nanosecond, Explain: true, Retention: time.Hour})
+	defer db.SetSlowQueries(db.SlowQueriesConfig{})
+
+	suite.login(customerGUID)
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	testPostDoc(suite, consts.ClusterPath, clusters[0], newClusterCompareFilter)
+	w := suite.doRequest(http.MethodPost, consts.ClusterPath+"/query", armotypes.V2ListRequest{
+		OrderBy:      "name:desc",
+		InnerFilters: []map[string]string{{"name": clusters[0].Name}},
+	})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = suite.doRequest(http.MethodPost, consts.ClusterPath+"/uniqueValues", armotypes.UniqueValuesRequestV2{Fields: map[string]string{"name": ""}})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	//only admin can get the slow queries
+	testBadRequest(suite, http.MethodGet, consts.AdminPath+"/slowQueries", errorNotAdminUser, nil, http.StatusUnauthorized)
+	suite.loginAsAdmin("slow-queries-admin-guid")
+	getSlowQueries := func(route string) []types.SlowQuery {
+		w := suite.doRequest(http.MethodGet, consts.AdminPath+"/slowQueries?collection="+consts.ClustersCollection+"&route="+url.QueryEscape(route), nil)
+		suite.Equal(http.StatusOK, w.Code, w.Body.String())
+		return decode[[]types.SlowQuery](suite, w.Body.Bytes())
+	}
+	//slow queries are recorded in the background
+	var slowQueries []types.SlowQuery
+	suite.Eventually(func() bool {
+		slowQueries = getSlowQueries(consts.ClusterPath + "/query")
+		return len(slowQueries) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	suite.Require().NotEmpty(slowQueries)
+	slowQuery := slowQueries[0]
+	suite.Equal(consts.ClusterPath+"/query", slowQuery.Route)
+	suite.Equal(consts.ClustersCollection, slowQuery.Collection)
+	suite.Equal(types.SlowQueryFind, slowQuery.Operation)
+	suite.Equal(customerGUID, slowQuery.CustomerGUID)
+	suite.Contains(slowQuery.Filter, customerGUID)
+	suite.Contains(slowQuery.Filter, clusters[0].Name)
+	suite.Equal(`{"name":-1}`, slowQuery.Sort)
+	suite.Contains(slowQuery.Pipeline, "$facet")
+	suite.NotNil(slowQuery.ExpiryTime)
+	if suite.inMemoryStore {
+		//the in memory store does not explain queries
+		suite.Empty(slowQuery.WinningPlan)
+	} else {
+		suite.Empty(slowQuery.ExplainError)
+		suite.NotEmpty(slowQuery.WinningPlan)
+		suite.NotEmpty(slowQuery.PlanSummary)
+	}
+	suite.Eventually(func() bool {
+		slowQueries = getSlowQueries(consts.ClusterPath + "/uniqueValues")
+		return len(slowQueries) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	suite.Require().NotEmpty(slowQueries)
+	suite.Equal(types.SlowQueryUniqueValues, slowQueries[0].Operation)
+	suite.Empty(slowQueries[0].Sort)
+
+	//plans by route
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/slowQueries/routes", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	routes := map[string]types.SlowQueriesRoute{}
+	for _, route := range decode[[]types.SlowQueriesRoute](suite, w.Body.Bytes()) {
+		routes[route.Route] = route
+	}
+	queryRoute, ok := routes[consts.ClusterPath+"/query"]
+	suite.Require().True(ok)
+	suite.GreaterOrEqual(queryRoute.Count, 1)
+	suite.NotEmpty(queryRoute.Plans)
+	suite.Equal(consts.ClusterPath+"/query", queryRoute.Plans[0].Route)
+
+	w = suite.doRequest(http.MethodGet, consts.AdminPath+"/slowQueries?limit=0", nil)
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"bytes"
-	"config-service/db/mongo"
+	"config-service/db"
 	"config-service/types"
+	"config-service/utils"
 	"config-service/utils/consts"
 	"context"
 	_ "embed"
@@ -12,6 +13,7 @@ import (
 
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"os/exec"
 	"testing"
 	"time"
@@ -20,6 +22,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -43,8 +46,13 @@ func TestConfigServiceWithMongoImage(t *testing.T) {
 	suite.Run(t, new(MainTestSuite))
 }
 
+func TestConfigServiceInMemory(t *testing.T) {
+	suite.Run(t, &MainTestSuite{inMemoryStore: true})
+}
+
 type MainTestSuite struct {
 	suite.Suite
+	inMemoryStore    bool
 	router           *gin.Engine
 	shutdownFunc     func()
 	authCookie       string
@@ -52,35 +60,54 @@ type MainTestSuite struct {
 }
 
 func (suite *MainTestSuite) SetupSuite() {
-	//start mongo
-	exec.Command("/bin/sh", "-c", mongoStopCommand).Run()
-	out, err := exec.Command("/bin/sh", "-c", mongoDockerCommand).Output()
-	if err != nil {
-		suite.FailNow("failed to start mongo", err.Error(), string(out))
+	//the tests login with unverified cookies
+	os.Setenv(utils.InsecureDevModeEnvVar, "true")
+	conf := utils.GetConfig()
+	conf.Watch.PollIntervalMillis = int(watchPollInterval / time.Millisecond)
+	conf.CacheInvalidation.PollIntervalMillis = int(cacheInvalidationPollInterval / time.Millisecond)
+	conf.Webhooks = testWebhooksConfig
+	conf.Jobs = testJobsConfig
+	conf.Retention = testRetentionConfig
+	conf.Auth.RoutePermissions = testRoutePermissions
+	//the tests expect documents with equal sort values in creation order, time ordered GUIDs keep it after the _id tie breaker
+	types.NewGUID = func() string {
+		return uuid.Must(uuid.NewV7()).String()
+	}
+	//do not cache the readiness reports so the readiness wait sees the inserted documents
+	conf.Readiness.CacheMillis = 0
+	if suite.inMemoryStore {
+		//initialize service with in memory store
+		conf.Store = utils.MemoryStore
+	} else {
+		//start mongo
+		exec.Command("/bin/sh", "-c", mongoStopCommand).Run()
+		out, err := exec.Command("/bin/sh", "-c", mongoDockerCommand).Output()
+		if err != nil {
+			suite.FailNow("failed to start mongo", err.Error(), string(out))
+		}
 	}
-
 	//initialize service
-	suite.shutdownFunc = initialize()
+	suite.shutdownFunc = initializeWithConfig(conf)
 	//Create routes
 	suite.router = setupRouter()
+	reconcileIndexes()
+	//addGlobal documents to mong db, readiness checks the default customer config
+	defaultCustomerConfig := decode[interface{}](suite, defaultCustomerConfigJson)
+	if _, err := db.GetStore().GetWriteCollection(consts.CustomerConfigCollection).InsertOne(context.Background(), defaultCustomerConfig); err != nil {
+		suite.FailNow("failed to insert defaultCustomerConfigJson", err.Error())
+	}
 	//wait for service to be ready
-	checkReadiness := func() error { 
+	checkReadiness := func() error {
 		w := suite.doRequest(http.MethodGet, "/readiness", nil)
 		if w.Code != http.StatusOK {
-			return fmt.Errorf("failed to get readiness")
+			return fmt.Errorf("failed to get readiness: %s", w.Body.String())
 		}
 		return nil
 	}
-	err = retry(10, time.Microsecond*10, checkReadiness)
+	err := retry(10, time.Microsecond*10, checkReadiness)
 	if err != nil {
 		suite.FailNow("service is not ready readiness", err.Error())
 	}
-
-	//addGlobal documents to mong db
-	defaultCustomerConfig := decode[interface{}](suite, defaultCustomerConfigJson)
-	if _, err := mongo.GetWriteCollection(consts.CustomerConfigCollection).InsertOne(context.Background(), defaultCustomerConfig); err != nil {
-		suite.FailNow("failed to insert defaultCustomerConfigJson", err.Error())
-	}
 }
 
 func (suite *MainTestSuite) SetupTest() {
@@ -88,11 +115,18 @@ func (suite *MainTestSuite) SetupTest() {
 	suite.login(defaultUserGUID)
 }
 
+// login logs in as an editor and auditor of the customer, editors can read and modify the documents of routes with permissions
 func (suite *MainTestSuite) login(customerGUID string) {
+	suite.loginWithRoles(customerGUID, consts.EditorRole, consts.AuditorRole)
+}
+
+func (suite *MainTestSuite) loginWithRoles(customerGUID string, roles ...string) {
 	loginDetails := struct {
-		CustomerGUID string `json:"customerGUID"`
+		CustomerGUID string   `json:"customerGUID"`
+		Roles        []string `json:"roles"`
 	}{
 		CustomerGUID: customerGUID,
+		Roles:        roles,
 	}
 	w := suite.doRequest(http.MethodPost, "/login", loginDetails)
 	if w.Code != http.StatusOK {
@@ -122,23 +156,31 @@ func (suite *MainTestSuite) loginAsAdmin(customerGUID string) {
 
 func (suite *MainTestSuite) TearDownTest() {
 	//drop all collections except customer config
-	collections, err := mongo.ListCollectionNames(context.Background())
+	collections, err := db.GetStore().ListCollectionNames(context.Background())
 	if err != nil {
 		suite.FailNow("failed to list collections", err.Error())
 	}
 	for _, collection := range collections {
 		if collection != consts.CustomerConfigCollection {
-			mongo.GetWriteCollection(collection).Drop(context.Background())
-			mongo.IndexCollection(collection)
+			db.GetStore().GetWriteCollection(collection).Drop(context.Background())
+			db.GetStore().IndexCollection(collection)
 		}
 	}
+	//the collections are dropped through the store, without invalidating the cached query results
+	db.ClearQueryCache()
 }
 func (suite *MainTestSuite) TearDownSuite() {
 	suite.shutdownFunc()
-	exec.Command("/bin/sh", "-c", mongoStopCommand).Run()
+	if !suite.inMemoryStore {
+		exec.Command("/bin/sh", "-c", mongoStopCommand).Run()
+	}
 }
 
 func (suite *MainTestSuite) doRequest(method, path string, body interface{}) *httptest.ResponseRecorder {
+	return suite.doRequestWithHeaders(method, path, body, nil)
+}
+
+func (suite *MainTestSuite) doRequestWithHeaders(method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	var req *http.Request
 	var reqErr error
@@ -165,6 +207,9 @@ func (suite *MainTestSuite) doRequest(method, path string, body interface{}) *ht
 	if suite.authCookie != "" {
 		req.Header.Set("Cookie", suite.authCookie)
 	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 	suite.router.ServeHTTP(w, req)
 
 	return w
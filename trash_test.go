@@ -0,0 +1,83 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) getTrash(path string) []*types.Repository {
+	w := suite.doRequest(http.MethodGet, path+"/trash", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	docs, err := decodeResponseArray[*types.Repository](w)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	return docs
+}
+
+func (suite *MainTestSuite) TestSoftDelete() {
+	repositories, _ := loadJson[*types.Repository](repositoriesJson)
+	for i := range repositories {
+		repositories[i] = testPostDoc(suite, consts.RepositoryPath, repositories[i], repoCompareFilter)
+	}
+	repo := repositories[0]
+	repoPath := consts.RepositoryPath + "/" + repo.GUID
+
+	//deleted document is moved to the trash
+	w := suite.doRequest(http.MethodDelete, repoPath, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	testBadRequest(suite, http.MethodGet, repoPath, errorDocumentNotFound, nil, http.StatusNotFound)
+	testBadRequest(suite, http.MethodDelete, repoPath, errorDocumentNotFound, nil, http.StatusNotFound)
+	testGetDocs(suite, consts.RepositoryPath, repositories[1:], repoCompareFilter)
+	trash := suite.getTrash(consts.RepositoryPath)
+	if suite.Len(trash, 1) {
+		suite.Equal(repo.GUID, trash[0].GUID)
+	}
+
+	//delete by a loose query moves all the documents to the trash
+	w = suite.doRequest(http.MethodDelete, consts.RepositoryPath+"/query", armotypes.V2ListRequest{})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`{"deletedCount":2}`, w.Body.String())
+	testGetDocs(suite, consts.RepositoryPath, []*types.Repository{}, repoCompareFilter)
+	suite.Len(suite.getTrash(consts.RepositoryPath), 3)
+
+	//other customers do not see the trash
+	suite.login("other-customer-guid")
+	suite.Empty(suite.getTrash(consts.RepositoryPath))
+	testBadRequest(suite, http.MethodPost, repoPath+"/restore", errorDocumentNotFound, nil, http.StatusNotFound)
+	suite.login(defaultUserGUID)
+
+	//restore from the trash
+	w = suite.doRequestWithHeaders(http.MethodPost, repoPath+"/restore", nil, map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	w = suite.doRequestWithHeaders(http.MethodPost, repoPath+"/restore", nil, map[string]string{"If-Match": `"2"`})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"3"`, w.Header().Get("ETag"))
+	testGetDoc(suite, repoPath, repo, repoCompareFilter)
+	testBadRequest(suite, http.MethodPost, repoPath+"/restore", errorDocumentNotFound, nil, http.StatusNotFound)
+	suite.Len(suite.getTrash(consts.RepositoryPath), 2)
+
+	//names of documents in the trash can be reused, restoring a document with a used name fails
+	sameName := Clone(repositories[1])
+	sameName.GUID = ""
+	testPostDoc(suite, consts.RepositoryPath, sameName, repoCompareFilter)
+	testBadRequest(suite, http.MethodPost, consts.RepositoryPath+"/"+repositories[1].GUID+"/restore",
+		fmt.Sprintf(`{"error":"name %s already exists"}`, sameName.Name), nil, http.StatusBadRequest)
+
+	//admin purges the trash
+	suite.loginAsAdmin("a-admin-guid")
+	testBadRequest(suite, http.MethodDelete, consts.AdminPath+"/trash?olderThanDays=abc", `{"error":"olderThanDays must be a positive number"}`, nil, http.StatusBadRequest)
+	w = suite.doRequest(http.MethodDelete, consts.AdminPath+"/trash?olderThanDays=1", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(fmt.Sprintf(`{"collections":{"%s":0},"deleted":0}`, consts.RepositoryCollection), w.Body.String())
+	w = suite.doRequest(http.MethodDelete, consts.AdminPath+"/trash?olderThanDays=0", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(fmt.Sprintf(`{"collections":{"%s":2},"deleted":2}`, consts.RepositoryCollection), w.Body.String())
+	suite.login(defaultUserGUID)
+	suite.Empty(suite.getTrash(consts.RepositoryPath))
+	testBadRequest(suite, http.MethodPost, consts.RepositoryPath+"/"+repositories[2].GUID+"/restore", errorDocumentNotFound, nil, http.StatusNotFound)
+}
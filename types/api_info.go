@@ -1,6 +1,7 @@
 package types
 
 import (
+	"sort"
 	"strings"
 
 	"k8s.io/utils/ptr"
@@ -12,6 +13,7 @@ type APIInfo struct {
 	BasePath     string     `json:"basePath"`
 	DBCollection string     `json:"dbCollection"`
 	Schema       SchemaInfo `json:"schema"`
+	ReadRoles    []string   `json:"readRoles,omitempty"` //nil when the documents can be read by all the users, otherwise only the roles (or admins) can read them
 }
 
 type FieldType string
@@ -29,6 +31,8 @@ type SchemaInfo struct {
 	MustExcludeFields             []string             `json:"mustExcludeFields,omitempty"`             // fields that must be excluded from the response
 	NestedDocPath                 string               `json:"nestedDocPath,omitempty"`                 // path to nested document
 	NanosecondsTimestampFieldName *string              `json:"nanosecondsTimestampFieldName,omitempty"` // pointer so empty string can be distinguished from nil
+	ExpirationFieldName           string               `json:"expirationFieldName,omitempty"`           // date field of the document expiration, webhook subscriptions get expired events when it passes
+	Indexes                       []IndexInfo          `json:"indexes,omitempty"`                       // indexes of the collection, when not declared by any route of the collection the default indexes are used
 }
 
 func SetAPIInfo(path string, apiInfo APIInfo) {
@@ -88,3 +92,36 @@ func (s SchemaInfo) GetMustExcludeFields() []string {
 func (s SchemaInfo) GetNestedDocPath() string {
 	return s.NestedDocPath
 }
+
+// GetCollectionSchema returns the schema of the route that serves the documents of the collection, nil if there is none
+// routes of nested documents are skipped since their schema describes the nested documents
+func GetCollectionSchema(collection string) *SchemaInfo {
+	for _, apiInfo := range path2apiInfo {
+		if apiInfo.DBCollection == collection && apiInfo.Schema.NestedDocPath == "" {
+			return ptr.To(apiInfo.Schema)
+		}
+	}
+	return nil
+}
+
+// GetCollectionIndexes returns the indexes declared by the routes of the collection, nil if no route declared indexes
+// routes of nested documents are skipped, indexes declared by more than one route are returned once
+func GetCollectionIndexes(collection string) []IndexInfo {
+	var indexes []IndexInfo
+	names := map[string]bool{}
+	paths := GetAllPaths()
+	sort.Strings(paths)
+	for _, path := range paths {
+		apiInfo := path2apiInfo[path]
+		if apiInfo.DBCollection != collection || apiInfo.Schema.NestedDocPath != "" {
+			continue
+		}
+		for _, index := range apiInfo.Schema.Indexes {
+			if !names[index.GetName()] {
+				names[index.GetName()] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+// AuditEvent is a record of a mutation made through the service
+type AuditEvent struct {
+	armotypes.PortalBase `json:",inline" bson:",inline"`
+	Actor                string        `json:"actor" bson:"actor"`                         // email of the user or the customer GUID when the user email is unknown
+	CustomerGUID         string        `json:"customerGUID" bson:"customerGUID"`           // customer of the actor
+	Path                 string        `json:"path" bson:"path"`                           // request path
+	Collection           string        `json:"collection" bson:"collection"`               // db collection of the mutated documents
+	Verb                 string        `json:"verb" bson:"verb"`                           // request http method
+	DocGUID              string        `json:"docGUID,omitempty" bson:"docGUID,omitempty"` // the mutated document, empty for bulk mutations
+	Query                string        `json:"query,omitempty" bson:"query,omitempty"`     // the bulk mutation query
+	Count                int64         `json:"count,omitempty" bson:"count,omitempty"`     // number of documents mutated by the bulk mutation
+	Diff                 []AuditChange `json:"diff,omitempty" bson:"diff,omitempty"`       // changes of the mutated document
+	Timestamp            time.Time     `json:"timestamp" bson:"timestamp"`
+}
+
+// AuditChange is a change of a single field, the values are JSON encoded and missing on field creation or removal
+type AuditChange struct {
+	Path string          `json:"path" bson:"path"`
+	Old  json.RawMessage `json:"old,omitempty" bson:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty" bson:"new,omitempty"`
+}
+
+func (a *AuditEvent) GetReadOnlyFields() []string {
+	return append([]string{"timestamp"}, commonReadOnlyFields...)
+}
+
+func (a *AuditEvent) InitNew() {
+	a.Timestamp = time.Now().UTC()
+}
+
+func (a *AuditEvent) GetCreationTime() *time.Time {
+	return &a.Timestamp
+}
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// slow query operations
+const (
+	SlowQueryFind         = "find"         //V2 list query
+	SlowQueryFindNested   = "findNested"   //V2 list query of nested documents
+	SlowQueryUniqueValues = "uniqueValues" //unique values aggregation of a field
+)
+
+// SlowQuery is a query that took longer than the slow query threshold, kept in the diagnostics collection
+// the filter, sort, pipeline and plan are kept as extended JSON since their keys are mongo operators
+type SlowQuery struct {
+	GUID         string     `json:"guid" bson:"_id"`
+	Route        string     `json:"route" bson:"route"` // route path template of the request
+	Collection   string     `json:"collection" bson:"collection"`
+	Operation    string     `json:"operation" bson:"operation"`
+	CustomerGUID string     `json:"customerGUID,omitempty" bson:"customerGUID,omitempty"`
+	DurationMs   int64      `json:"durationMs" bson:"durationMs"`
+	Filter       string     `json:"filter" bson:"filter"`
+	Sort         string     `json:"sort,omitempty" bson:"sort,omitempty"`
+	Pipeline     string     `json:"pipeline" bson:"pipeline"`
+	PlanSummary  string     `json:"planSummary,omitempty" bson:"planSummary,omitempty"` // stages of the winning plan, e.g. "IXSCAN customers_1, FETCH"
+	WinningPlan  string     `json:"winningPlan,omitempty" bson:"winningPlan,omitempty"`
+	ExplainError string     `json:"explainError,omitempty" bson:"explainError,omitempty"`
+	Timestamp    time.Time  `json:"timestamp" bson:"timestamp"`
+	ExpiryTime   *time.Time `json:"expiryTime,omitempty" bson:"expiryTime,omitempty"` // removed by a ttl index, kept when empty
+}
+
+// SlowQueriesRoute is the summary of the slow queries of a route
+type SlowQueriesRoute struct {
+	Route         string      `json:"route"`
+	Count         int         `json:"count"`
+	MaxDurationMs int64       `json:"maxDurationMs"`
+	LastTimestamp time.Time   `json:"lastTimestamp"`
+	Plans         []SlowQuery `json:"plans"` // the latest slow query of each distinct plan summary
+}
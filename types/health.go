@@ -0,0 +1,24 @@
+package types
+
+import "time"
+
+// health check statuses
+const (
+	HealthOK     = "ok"
+	HealthFailed = "failed"
+)
+
+// HealthReport is the result of the readiness checks, the status is failed if any of the checks that are not informational failed
+type HealthReport struct {
+	Status    string                       `json:"status"`
+	CheckedAt time.Time                    `json:"checkedAt"`
+	Checks    map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the result of a single readiness check
+type HealthCheckResult struct {
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	DurationMs    int64  `json:"durationMs"`
+	Informational bool   `json:"informational,omitempty"` //a failed informational check does not fail the readiness
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// index key types
+const (
+	IndexAscending  = 1
+	IndexDescending = -1
+)
+
+// IndexKey is a key of an index, the ascending and descending keys are ordered and text keys are searched by $text
+type IndexKey struct {
+	Field string `json:"field"`
+	Order int    `json:"order,omitempty"` //1 ascending (default) or -1 descending
+	Text  bool   `json:"text,omitempty"`  //text index key, the order is ignored
+}
+
+// IndexInfo declares an index of the collection of a route, the indexes are reconciled when the routes are added
+type IndexInfo struct {
+	Name               string                 `json:"name,omitempty"`               //default is the mongo generated name of the keys (e.g. customers_1_timestamp_-1)
+	Keys               []IndexKey             `json:"keys"`                         //mandatory, compound indexes have more than one key
+	Unique             bool                   `json:"unique,omitempty"`             //reject documents with duplicate keys
+	ExpireAfterSeconds *int32                 `json:"expireAfterSeconds,omitempty"` //TTL index, documents are removed when the date field passes by the seconds
+	PartialFilter      map[string]interface{} `json:"partialFilter,omitempty"`      //only the documents matching the filter are indexed
+}
+
+// NewIndex returns an index of the fields, a field with a "-" prefix is descending
+func NewIndex(fields ...string) IndexInfo {
+	index := IndexInfo{}
+	for _, field := range fields {
+		key := IndexKey{Field: field, Order: IndexAscending}
+		if strings.HasPrefix(field, "-") {
+			key = IndexKey{Field: strings.TrimPrefix(field, "-"), Order: IndexDescending}
+		}
+		index.Keys = append(index.Keys, key)
+	}
+	return index
+}
+
+// NewTextIndex returns a text index of the fields
+func NewTextIndex(fields ...string) IndexInfo {
+	index := IndexInfo{}
+	for _, field := range fields {
+		index.Keys = append(index.Keys, IndexKey{Field: field, Text: true})
+	}
+	return index
+}
+
+// WithName sets the index name
+func (i IndexInfo) WithName(name string) IndexInfo {
+	i.Name = name
+	return i
+}
+
+// WithUnique makes the index unique
+func (i IndexInfo) WithUnique() IndexInfo {
+	i.Unique = true
+	return i
+}
+
+// WithTTL makes the index a TTL index, the indexed date field must be the only key
+func (i IndexInfo) WithTTL(expireAfter time.Duration) IndexInfo {
+	seconds := int32(expireAfter.Seconds())
+	i.ExpireAfterSeconds = &seconds
+	return i
+}
+
+// WithPartialFilter indexes only the documents matching the filter
+func (i IndexInfo) WithPartialFilter(filter map[string]interface{}) IndexInfo {
+	i.PartialFilter = filter
+	return i
+}
+
+// GetName returns the index name, the mongo generated name when not set
+func (i IndexInfo) GetName() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	parts := make([]string, 0, len(i.Keys))
+	for _, key := range i.Keys {
+		if key.Text {
+			parts = append(parts, key.Field+"_text")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s_%d", key.Field, key.GetOrder()))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// GetOrder returns the key order, ascending when not set
+func (k IndexKey) GetOrder() int {
+	if k.Order == 0 {
+		return IndexAscending
+	}
+	return k.Order
+}
+
+// IndexesReport is the result of the reconciliation of the indexes of a collection with the declared indexes
+type IndexesReport struct {
+	Collection   string    `json:"collection"`
+	ReconciledAt time.Time `json:"reconciledAt"`
+	Declared     []string  `json:"declared"`
+	Created      []string  `json:"created,omitempty"`
+	Extra        []string  `json:"extra,omitempty"`   //existing indexes that are not declared
+	Changed      []string  `json:"changed,omitempty"` //existing indexes with the name of a declared index but other options
+	Dropped      []string  `json:"dropped,omitempty"` //extra and changed indexes dropped when dropping extra indexes is configured
+	Error        string    `json:"error,omitempty"`
+}
+
+// IndexUsage is the usage of an index reported by $indexStats since the index was created or the mongo server restarted
+type IndexUsage struct {
+	Collection string    `json:"collection"`
+	Name       string    `json:"name"`
+	Keys       string    `json:"keys"`
+	Declared   bool      `json:"declared"`
+	Ops        int64     `json:"ops"`
+	Since      time.Time `json:"since"`
+	Host       string    `json:"host,omitempty"`
+}
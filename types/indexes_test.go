@@ -0,0 +1,62 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexName(t *testing.T) {
+	tests := []struct {
+		name  string
+		index IndexInfo
+		want  string
+	}{
+		{
+			name:  "single key",
+			index: NewIndex("guid"),
+			want:  "guid_1",
+		},
+		{
+			name:  "compound with descending key",
+			index: NewIndex("customers", "-timestamp"),
+			want:  "customers_1_timestamp_-1",
+		},
+		{
+			name:  "text",
+			index: NewTextIndex("name", "description"),
+			want:  "name_text_description_text",
+		},
+		{
+			name:  "explicit name",
+			index: NewIndex("guid").WithName("guid_unique").WithUnique(),
+			want:  "guid_unique",
+		},
+		{
+			name:  "key without order",
+			index: IndexInfo{Keys: []IndexKey{{Field: "name"}}},
+			want:  "name_1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.index.GetName())
+		})
+	}
+	assert.Equal(t, int32(3600), *NewIndex("expiryTime").WithTTL(time.Hour).ExpireAfterSeconds)
+}
+
+func TestGetCollectionIndexes(t *testing.T) {
+	SetAPIInfo("/indexesTestA", APIInfo{DBCollection: "indexesTest", Schema: SchemaInfo{Indexes: []IndexInfo{NewIndex("guid"), NewIndex("name")}}})
+	SetAPIInfo("/indexesTestB", APIInfo{DBCollection: "indexesTest", Schema: SchemaInfo{Indexes: []IndexInfo{NewIndex("name"), NewIndex("-creationTime")}}})
+	//nested documents routes are skipped
+	SetAPIInfo("/indexesTestNested", APIInfo{DBCollection: "indexesTest", Schema: SchemaInfo{NestedDocPath: "items", Indexes: []IndexInfo{NewIndex("items.name")}}})
+	defer func() {
+		delete(path2apiInfo, "/indexesTestA")
+		delete(path2apiInfo, "/indexesTestB")
+		delete(path2apiInfo, "/indexesTestNested")
+	}()
+	assert.Equal(t, []IndexInfo{NewIndex("guid"), NewIndex("name"), NewIndex("-creationTime")}, GetCollectionIndexes("indexesTest"))
+	assert.Nil(t, GetCollectionIndexes("noIndexes"))
+}
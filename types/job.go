@@ -0,0 +1,62 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+// job states
+const (
+	JobPending   = "pending"   //waiting for a worker
+	JobRunning   = "running"   //claimed by a worker, the worker renews the lease while the job runs
+	JobSucceeded = "succeeded" //finished with a result
+	JobFailed    = "failed"    //finished with an error
+	JobCancelled = "cancelled" //cancelled by an admin before it finished
+)
+
+// Job is an admin operation that runs in the background by the job workers of the service instances
+// a running job whose lease expired (e.g. the instance was restarted) is claimed again and resumes from its progress
+type Job struct {
+	armotypes.PortalBase `json:",inline" bson:",inline"`
+	Type                 string                 `json:"type" bson:"type"`
+	Params               json.RawMessage        `json:"params,omitempty" bson:"params,omitempty"` // the request of the job
+	State                string                 `json:"state" bson:"state"`
+	Progress             JobProgress            `json:"progress" bson:"progress"`
+	Result               map[string]interface{} `json:"result,omitempty" bson:"result,omitempty"`
+	Error                string                 `json:"error,omitempty" bson:"error,omitempty"`
+	Actor                string                 `json:"actor" bson:"actor"`               // the user that started the job
+	CustomerGUID         string                 `json:"customerGUID" bson:"customerGUID"` // the customer of the actor
+	Path                 string                 `json:"path" bson:"path"`                 // the path of the request that started the job
+	Verb                 string                 `json:"verb" bson:"verb"`                 // the http method of the request that started the job
+	Attempts             int                    `json:"attempts" bson:"attempts"`         // the number of times the job was claimed by a worker
+	CancelRequested      bool                   `json:"cancelRequested,omitempty" bson:"cancelRequested,omitempty"`
+	LeaseExpiryTime      *time.Time             `json:"leaseExpiryTime,omitempty" bson:"leaseExpiryTime,omitempty"`
+	CreationTime         time.Time              `json:"creationTime" bson:"creationTime"`
+	StartedTime          *time.Time             `json:"startedTime,omitempty" bson:"startedTime,omitempty"`
+	FinishedTime         *time.Time             `json:"finishedTime,omitempty" bson:"finishedTime,omitempty"`
+}
+
+// JobProgress is the number of processed items of a job out of the total (if known)
+type JobProgress struct {
+	Done  int64 `json:"done" bson:"done"`
+	Total int64 `json:"total,omitempty" bson:"total,omitempty"`
+}
+
+func (j *Job) GetReadOnlyFields() []string {
+	return commonReadOnlyFieldsAllowRename
+}
+
+func (j *Job) InitNew() {
+	j.CreationTime = time.Now().UTC()
+}
+
+func (j *Job) GetCreationTime() *time.Time {
+	return &j.CreationTime
+}
+
+// Finished returns true if the job is in a final state
+func (j *Job) Finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobCancelled
+}
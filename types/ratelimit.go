@@ -0,0 +1,7 @@
+package types
+
+// RateLimit is a token bucket limit of the requests of a customer
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"` //bucket refill rate, 0 does not limit the requests
+	Burst             int     `json:"burst"`             //bucket size, 0 defaults to the requests per second rounded up
+}
@@ -5,6 +5,7 @@ import "github.com/armosec/armoapi-go/armotypes"
 type SearchResult[T any] struct {
 	Total    armotypes.RespTotal `json:"total"`
 	Response []T                 `json:"response"`
+	Cursor   string              `json:"cursor,omitempty"` //the cursor of the next page, empty when there are no more results
 }
 
 func (s *SearchResult[T]) SetCount(count int64) {
@@ -25,3 +26,63 @@ func (s *CountResult) SetCount(count int64) {
 	s.Total.Relation = "eq"
 	s.Total.Value = int(count)
 }
+
+// statuses of the documents of bulk update requests
+const (
+	BulkStatusUpdated  = "updated"
+	BulkStatusNotFound = "notFound"
+	BulkStatusInvalid  = "invalid" //the document failed the route validation
+	BulkStatusFailed   = "failed"  //the update failed, only when the updates are not transactional
+)
+
+// BulkUpdateResult is the result of a document of a bulk update request
+type BulkUpdateResult struct {
+	GUID     string `json:"guid,omitempty"`
+	Status   string `json:"status"`
+	Revision int64  `json:"revision,omitempty"` //the revision of the updated document
+	Error    string `json:"error,omitempty"`
+}
+
+// BulkUpdateResponse is the response of a bulk update request with the results in the order of the request documents
+type BulkUpdateResponse struct {
+	Transactional bool               `json:"transactional"` //true if the updates were applied in a transaction (all or none)
+	Results       []BulkUpdateResult `json:"results"`
+}
+
+// statuses of upsert requests
+const (
+	UpsertStatusCreated = "created"
+	UpsertStatusUpdated = "updated"
+)
+
+// UpsertResponse is the response of an upsert request with the created or updated document
+type UpsertResponse[T any] struct {
+	Status   string `json:"status"`   //created or updated
+	Revision int64  `json:"revision"` //the revision of the document after the upsert
+	Document T      `json:"document"`
+}
+
+// statuses of the records of import requests, dry runs report the statuses without writing
+const (
+	ImportStatusCreated  = "created"
+	ImportStatusUpdated  = "updated"
+	ImportStatusSkipped  = "skipped"  //a document with the record key exists and the conflict policy is skip
+	ImportStatusConflict = "conflict" //a document with the record key exists and the conflict policy is fail
+	ImportStatusInvalid  = "invalid"  //the record cannot be parsed or failed the route validation
+	ImportStatusFailed   = "failed"   //the write of a valid record failed
+)
+
+// ImportResult is the result of a record of an import request
+type ImportResult struct {
+	Line   int    `json:"line"` //the line of the record in NDJSON or the position of the record in a JSON array, starting at 1
+	GUID   string `json:"guid,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportResponse is the report of an import request with the results in the order of the records
+type ImportResponse struct {
+	DryRun  bool           `json:"dryRun"`  //true if no document was written
+	Summary map[string]int `json:"summary"` //the number of records of each status
+	Results []ImportResult `json:"results"`
+}
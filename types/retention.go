@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// RetentionPolicy purges the documents of a collection that are older than the retention days by the schema timestamp field
+// the retention of customers and license types overrides the default days, 0 days keep the documents
+type RetentionPolicy struct {
+	Collection   string         `json:"collection"`
+	Days         int            `json:"days"`
+	LicenseTypes map[string]int `json:"licenseTypes,omitempty"` //days by the license type of the customer active subscription
+	Customers    map[string]int `json:"customers,omitempty"`    //days by customer GUID
+}
+
+// RetentionReport is the result of a retention run, dry runs report the documents that would be purged
+type RetentionReport struct {
+	DryRun      bool             `json:"dryRun"`
+	StartTime   time.Time        `json:"startTime"`
+	EndTime     time.Time        `json:"endTime"`
+	Collections map[string]int64 `json:"collections"` //purged documents per collection
+	Errors      []string         `json:"errors,omitempty"`
+}
+
+// RetentionStats are the purge counters of the service instance since it started
+type RetentionStats struct {
+	Runs       int64            `json:"runs"`
+	FailedRuns int64            `json:"failedRuns"`
+	Purged     map[string]int64 `json:"purged"` //purged documents per collection
+	LastReport *RetentionReport `json:"lastReport,omitempty"`
+}
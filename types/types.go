@@ -19,17 +19,27 @@ import (
 type Document[T DocContent] struct {
 	ID        string   `json:"_id" bson:"_id"`
 	Customers []string `json:"customers" bson:"customers"`
+	Revision  int64    `json:"revision" bson:"revision"` //incremented on every write, documents created before revisions were maintained have no revision
 	Content   T        `json:",inline" bson:"inline"`
 }
 
+// NewGUID returns the GUID of a new document
+var NewGUID = func() string {
+	return uuid.NewV4().String()
+}
+
+// NewDocumentRevision is the revision of created documents
+const NewDocumentRevision int64 = 1
+
 // NewDocument - create new document per doc content T
 func NewDocument[T DocContent](content T, customerGUID string) Document[T] {
 	content.InitNew()
-	content.SetGUID(uuid.NewV4().String())
+	content.SetGUID(NewGUID())
 	content.SetUpdatedTime(nil)
 	doc := Document[T]{
-		ID:      content.GetGUID(),
-		Content: content,
+		ID:       content.GetGUID(),
+		Revision: NewDocumentRevision,
+		Content:  content,
 	}
 	if customerGUID != "" {
 		doc.Customers = append(doc.Customers, customerGUID)
@@ -41,7 +51,8 @@ func NewDocument[T DocContent](content T, customerGUID string) Document[T] {
 type DocContent interface {
 	*CustomerConfig | *Cluster | *PostureExceptionPolicy | *VulnerabilityExceptionPolicy | *Customer |
 		*Framework | *Repository | *RegistryCronJob | *CollaborationConfig | *Cache | *ClusterAttackChainState | *AggregatedVulnerability |
-		*RuntimeIncident | *RuntimeAlert | *IntegrationReference | *IncidentPolicy | *CloudAccount | *Workflow | *ContainerImageRegistry
+		*RuntimeIncident | *RuntimeAlert | *IntegrationReference | *IncidentPolicy | *CloudAccount | *Workflow | *ContainerImageRegistry |
+		*AuditEvent | *WebhookSubscription | *WebhookDelivery | *Job
 	InitNew()
 	GetReadOnlyFields() []string
 	//default implementation exist in portal base
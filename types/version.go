@@ -0,0 +1,23 @@
+package types
+
+import (
+	"time"
+)
+
+// DocVersion is a prior revision of a document, kept in the version history collection of the document collection
+type DocVersion[T DocContent] struct {
+	ID        string    `json:"-" bson:"_id"`
+	Customers []string  `json:"-" bson:"customers"`
+	DocGUID   string    `json:"docGUID" bson:"docGUID"`
+	Version   int64     `json:"version" bson:"version"`     // the document revision
+	Verb      string    `json:"verb" bson:"verb"`           // http method of the request that replaced or deleted the revision
+	Actor     string    `json:"actor" bson:"actor"`         // email of the user or the customer GUID when the user email is unknown
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"` // time the revision was replaced or deleted
+	Content   T         `json:"content" bson:"content"`
+}
+
+// VersionRetention limits the versions kept per document, zero values are not limited
+type VersionRetention struct {
+	MaxVersions int           `json:"maxVersions"` // number of latest versions to keep
+	MaxAge      time.Duration `json:"maxAge"`      // versions older than max age are removed
+}
@@ -0,0 +1,10 @@
+package types
+
+// ChangeEvent is a change of a customer document sent to the watchers of a collection
+type ChangeEvent[T DocContent] struct {
+	ID        string `json:"-"`                  // id of the event, watchers resume after it
+	Operation string `json:"operation"`          // insert, update, delete or reset when the watch could not resume and the documents should be reloaded
+	DocGUID   string `json:"docGUID,omitempty"`  // GUID of the changed document
+	Revision  int64  `json:"revision,omitempty"` // revision of the inserted or updated document
+	Document  T      `json:"document,omitempty"` // the inserted or updated document
+}
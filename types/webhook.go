@@ -0,0 +1,97 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+// webhook event types
+const (
+	WebhookEventCreated = "created"
+	WebhookEventUpdated = "updated"
+	WebhookEventDeleted = "deleted"
+	WebhookEventExpired = "expired" //the expiration date of a document with an expiration field passed
+)
+
+// webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"   //waiting for the first attempt or a retry
+	WebhookDeliveryDelivered = "delivered" //the target responded with 2xx
+	WebhookDeliveryDead      = "dead"      //all the attempts failed, the delivery was moved to the dead letters
+)
+
+// redacted JSON value of write only strings
+const redactedValue = "********"
+
+// WriteOnlyString is a string that is set in requests and redacted in responses, audit events and watch events
+type WriteOnlyString string
+
+func (s WriteOnlyString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redactedValue)
+}
+
+// WebhookSubscription is a customer subscription to the lifecycle events of documents, the events are posted to the URL
+type WebhookSubscription struct {
+	armotypes.PortalBase `json:",inline" bson:",inline"`
+	EventTypes           []string            `json:"eventTypes" bson:"eventTypes"`                         // created, updated, deleted or expired
+	Collections          []string            `json:"collections" bson:"collections"`                       // db collections of the documents
+	InnerFilters         []map[string]string `json:"innerFilters,omitempty" bson:"innerFilters,omitempty"` // optional V2ListRequest inner filters the document must match
+	URL                  string              `json:"url" bson:"url"`                                       // http or https URL the events are posted to
+	Secret               WriteOnlyString     `json:"secret,omitempty" bson:"secret,omitempty"`             // HMAC key of the events signature
+	MatchQuery           string              `json:"-" bson:"matchQuery,omitempty"`                        // the inner filters as an extended JSON query
+	CreationTime         time.Time           `json:"creationTime" bson:"creationTime"`
+}
+
+func (w *WebhookSubscription) GetReadOnlyFields() []string {
+	return commonReadOnlyFieldsAllowRename
+}
+
+func (w *WebhookSubscription) InitNew() {
+	w.CreationTime = time.Now().UTC()
+}
+
+func (w *WebhookSubscription) GetCreationTime() *time.Time {
+	return &w.CreationTime
+}
+
+// WebhookEvent is the payload posted to the webhook subscriptions
+type WebhookEvent struct {
+	ID           string          `json:"id" bson:"id"` // unique id of the event, the same in the deliveries to all subscriptions
+	Type         string          `json:"type" bson:"type"`
+	CustomerGUID string          `json:"customerGUID" bson:"customerGUID"`
+	Collection   string          `json:"collection" bson:"collection"`
+	DocGUID      string          `json:"docGUID" bson:"docGUID"`
+	Timestamp    time.Time       `json:"timestamp" bson:"timestamp"`
+	Document     json.RawMessage `json:"document,omitempty" bson:"document,omitempty"` // the document after the event, the deleted document on deletion
+}
+
+// WebhookDelivery is the delivery of an event to a subscription, deliveries are kept as the delivery log and failed deliveries are copied to the dead letters
+type WebhookDelivery struct {
+	armotypes.PortalBase `json:",inline" bson:",inline"`
+	SubscriptionGUID     string       `json:"subscriptionGUID" bson:"subscriptionGUID"`
+	URL                  string       `json:"url" bson:"url"`
+	Event                WebhookEvent `json:"event" bson:"event"`
+	Status               string       `json:"status" bson:"status"`
+	Attempts             int          `json:"attempts" bson:"attempts"`
+	NextAttemptTime      *time.Time   `json:"nextAttemptTime,omitempty" bson:"nextAttemptTime,omitempty"`
+	LastAttemptTime      *time.Time   `json:"lastAttemptTime,omitempty" bson:"lastAttemptTime,omitempty"`
+	LastStatusCode       int          `json:"lastStatusCode,omitempty" bson:"lastStatusCode,omitempty"`
+	LastError            string       `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	DeliveredTime        *time.Time   `json:"deliveredTime,omitempty" bson:"deliveredTime,omitempty"`
+	CreationTime         time.Time    `json:"creationTime" bson:"creationTime"`
+	ExpiryTime           *time.Time   `json:"expiryTime,omitempty" bson:"expiryTime,omitempty"` // the delivery log is removed after the expiry time
+}
+
+func (w *WebhookDelivery) GetReadOnlyFields() []string {
+	return commonReadOnlyFieldsAllowRename
+}
+
+func (w *WebhookDelivery) InitNew() {
+	w.CreationTime = time.Now().UTC()
+}
+
+func (w *WebhookDelivery) GetCreationTime() *time.Time {
+	return &w.CreationTime
+}
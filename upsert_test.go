@@ -0,0 +1,205 @@
+package main
+
+import (
+	"config-service/db"
+	"config-service/db/store"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (suite *MainTestSuite) TestUpsert() {
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	upsert := func(method, path string, cluster *types.Cluster, expectedCode int, expectedStatus string, expectedRevision int64) *types.Cluster {
+		w := suite.doRequest(method, path, cluster)
+		if !suite.Equal(expectedCode, w.Code, w.Body.String()) {
+			return nil
+		}
+		response := decode[types.UpsertResponse[*types.Cluster]](suite, w.Body.Bytes())
+		suite.Equal(expectedStatus, response.Status)
+		suite.Equal(expectedRevision, response.Revision)
+		suite.Equal(fmt.Sprintf(`"%d"`, expectedRevision), w.Header().Get("ETag"))
+		return response.Document
+	}
+
+	//POST creates a document by name and then updates it
+	cluster := clusters[0]
+	cluster.GUID = ""
+	created := upsert(http.MethodPost, consts.ClusterPath+"?upsert=true", cluster, http.StatusCreated, types.UpsertStatusCreated, 1)
+	suite.NotEmpty(created.GUID)
+	clusterGUID := created.GUID
+	suite.NotEmpty(created.Attributes[consts.ShortNameAttribute])
+	cluster.Attributes = map[string]interface{}{"test": "upsert"}
+	updated := upsert(http.MethodPost, consts.ClusterPath+"?upsert=true", cluster, http.StatusOK, types.UpsertStatusUpdated, 2)
+	suite.Equal(created.GUID, updated.GUID)
+	suite.Equal("upsert", updated.Attributes["test"])
+	//the put validators kept the alias
+	suite.Equal(created.Attributes[consts.ShortNameAttribute], updated.Attributes[consts.ShortNameAttribute])
+	events := suite.getAuditEvents(map[string]string{"docGUID": created.GUID, "verb": http.MethodPost})
+	suite.Len(events, 2)
+
+	//PUT creates a document with a GUID derived from the GUID in path and then updates it by both GUIDs
+	clientGUID := "9d6a7f0a-3b1e-4a55-8e9e-3c8a0f2d1b7e"
+	other := clusters[1]
+	other.GUID = ""
+	path := consts.ClusterPath + "/" + clientGUID + "?upsert=true"
+	created = upsert(http.MethodPut, path, other, http.StatusCreated, types.UpsertStatusCreated, 1)
+	suite.NotEqual(clientGUID, created.GUID)
+	guid := created.GUID
+	updated = upsert(http.MethodPut, path, other, http.StatusOK, types.UpsertStatusUpdated, 2)
+	suite.Equal(guid, updated.GUID)
+	updated = upsert(http.MethodPut, consts.ClusterPath+"/"+guid+"?upsert=true", other, http.StatusOK, types.UpsertStatusUpdated, 3)
+	suite.Equal(guid, updated.GUID)
+	w := suite.doRequest(http.MethodGet, consts.ClusterPath+"/"+guid, nil)
+	suite.Equal(`"3"`, w.Header().Get("ETag"))
+	w = suite.doRequest(http.MethodGet, consts.ClusterPath+"/"+clientGUID, nil)
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	//an upsert of the GUID of another customer document creates a document of the customer
+	suite.login("other-customer-guid")
+	otherCustomerDoc := upsert(http.MethodPut, consts.ClusterPath+"/"+guid+"?upsert=true", other, http.StatusCreated, types.UpsertStatusCreated, 1)
+	suite.NotEqual(guid, otherCustomerDoc.GUID)
+	testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, []string{otherCustomerDoc.GUID})
+	suite.login(defaultUserGUID)
+	w = suite.doRequest(http.MethodGet, consts.ClusterPath+"/"+guid, nil)
+	suite.Equal(`"3"`, w.Header().Get("ETag"))
+
+	//the post validators check the name of a created document
+	duplicate := clusters[0]
+	duplicate.GUID = "c2b8e1d4-6f3a-4e27-9b0c-5d7e8f9a1b2c"
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath+"?upsert=true", `{"error":"name `+duplicate.Name+` already exists"}`, duplicate, http.StatusBadRequest)
+	//If-Match of a document that does not exist
+	w = suite.doRequestWithHeaders(http.MethodPut, consts.ClusterPath+"/not-exists?upsert=true", clusters[2], map[string]string{"If-Match": `"1"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath+"?upsert=true", `{"error":"upsert request must have a single document"}`, clusters[1:3], http.StatusBadRequest)
+	//without upsert a POST of an existing name fails
+	testBadRequest(suite, http.MethodPost, consts.ClusterPath+"?upsert=false", `{"error":"name `+other.Name+` already exists"}`, other, http.StatusBadRequest)
+
+	testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, []string{clusterGUID, guid})
+}
+
+func (suite *MainTestSuite) TestConcurrentUpserts() {
+	clusters, _ := loadJson[*types.Cluster](clustersJson)
+	const requests = 10
+	upsertConcurrently := func(method, path string, cluster *types.Cluster) (codes map[int]int, guids map[string]bool) {
+		codes, guids = map[int]int{}, map[string]bool{}
+		mutex := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := suite.doRequest(method, path, cluster)
+				mutex.Lock()
+				defer mutex.Unlock()
+				codes[w.Code]++
+				if w.Code == http.StatusCreated || w.Code == http.StatusOK {
+					guids[decode[types.UpsertResponse[*types.Cluster]](suite, w.Body.Bytes()).Document.GUID] = true
+				}
+			}()
+		}
+		wg.Wait()
+		return codes, guids
+	}
+
+	//concurrent upserts of a GUID create the document once and update it
+	cluster := clusters[0]
+	cluster.GUID = ""
+	codes, guids := upsertConcurrently(http.MethodPut, consts.ClusterPath+"/5f0c2a9e-8d4b-4c1e-a7f3-2b6d9e0c4a18?upsert=true", cluster)
+	suite.Equal(map[int]int{http.StatusCreated: 1, http.StatusOK: requests - 1}, codes)
+	suite.Len(guids, 1)
+	guid := ""
+	for g := range guids {
+		guid = g
+	}
+	w := suite.doRequest(http.MethodGet, consts.ClusterPath+"/"+guid, nil)
+	suite.Equal(fmt.Sprintf(`"%d"`, requests), w.Header().Get("ETag"))
+
+	//concurrent upserts of a name create a single document
+	other := clusters[1]
+	other.GUID = ""
+	codes, guids = upsertConcurrently(http.MethodPost, consts.ClusterPath+"?upsert=true", other)
+	suite.Equal(map[int]int{http.StatusCreated: 1, http.StatusOK: requests - 1}, codes)
+	suite.Len(guids, 1)
+	w = suite.doRequest(http.MethodGet, consts.ClusterPath, nil)
+	named := 0
+	for _, doc := range decodeArray[*types.Cluster](suite, w.Body.Bytes()) {
+		if doc.Name == other.Name {
+			named++
+		}
+	}
+	suite.Equal(1, named)
+
+	otherGUID := ""
+	for g := range guids {
+		otherGUID = g
+	}
+
+	//an upsert that did not find the document is updating it when a concurrent upsert created it before its insert
+	racing := &racingStore{Store: db.GetStore(), collection: consts.ClustersCollection}
+	db.SetStore(racing)
+	defer db.SetStore(racing.Store)
+	raced := func(method, path string, cluster *types.Cluster) (raceCode int, code int, response types.UpsertResponse[*types.Cluster]) {
+		racing.race = func() {
+			raceCode = suite.doRequest(method, path, cluster).Code
+		}
+		w := suite.doRequest(method, path, cluster)
+		suite.Nil(racing.race, "the request did not insert a document")
+		return raceCode, w.Code, decode[types.UpsertResponse[*types.Cluster]](suite, w.Body.Bytes())
+	}
+	third := clusters[2]
+	third.GUID = ""
+	raceCode, code, response := raced(http.MethodPut, consts.ClusterPath+"/0e4f6b1a-7c2d-4f38-9a5e-6d1b3c8f2e47?upsert=true", third)
+	suite.Equal(http.StatusCreated, raceCode)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(types.UpsertStatusUpdated, response.Status)
+	suite.Equal(int64(2), response.Revision)
+	racedGUID := response.Document.GUID
+
+	byName := Clone(third)
+	byName.Name = "raced-upsert-by-name"
+	byName.GUID = ""
+	raceCode, code, response = raced(http.MethodPost, consts.ClusterPath+"?upsert=true", byName)
+	suite.Equal(http.StatusCreated, raceCode)
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(types.UpsertStatusUpdated, response.Status)
+	suite.Equal(int64(2), response.Revision)
+	racedByNameGUID := response.Document.GUID
+
+	testBulkDeleteByGUIDWithBody(suite, consts.ClusterPath, []string{guid, otherGUID, racedGUID, racedByNameGUID})
+}
+
+// racingStore runs the race function before the next insert of a document to the collection
+// the race function runs a request that creates the document first, like a concurrent request
+type racingStore struct {
+	store.Store
+	collection string
+	race       func()
+}
+
+func (s *racingStore) GetWriteCollection(collectionName string) store.Collection {
+	collection := s.Store.GetWriteCollection(collectionName)
+	if collectionName != s.collection {
+		return collection
+	}
+	return racingCollection{Collection: collection, store: s}
+}
+
+type racingCollection struct {
+	store.Collection
+	store *racingStore
+}
+
+func (col racingCollection) InsertOne(c context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	if race := col.store.race; race != nil {
+		col.store.race = nil
+		race()
+	}
+	return col.Collection.InsertOne(c, document, opts...)
+}
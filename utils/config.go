@@ -6,6 +6,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/tkanos/gonfig"
 )
 
@@ -13,6 +14,16 @@ const (
 	ConfigPathEnvVar      = "CONFIG_PATH"
 	MongoDbPasswordEnvVar = "MONGODB_PASSWORD"
 	MongoDbUserEnvVar     = "MONGODB_USER"
+	StoreTypeEnvVar       = "STORE_TYPE"
+	JWTHMACKeyEnvVar      = "JWT_HMAC_KEY"
+	InsecureDevModeEnvVar = "INSECURE_DEV_MODE" //set to true to enable insecure dev mode authentication, for development and tests only
+)
+
+const (
+	// MongoStore stores documents in mongo (default)
+	MongoStore = "mongo"
+	// MemoryStore stores documents in memory, used for tests and running without mongo
+	MemoryStore = "memory"
 )
 
 type DefaultConfigs struct {
@@ -20,12 +31,100 @@ type DefaultConfigs struct {
 }
 
 type Configuration struct {
-	Port           string          `json:"port"`
-	Telemetry      TelemetryConfig `json:"telemetry"`
-	Mongo          MongoConfig     `json:"mongo"`
-	LoggerConfig   LoggerConfig    `json:"logger"`
-	AdminUsers     []string        `json:"admins"`
-	DefaultConfigs *DefaultConfigs `json:"defaultConfigs"`
+	Port              string            `json:"port"`
+	Telemetry         TelemetryConfig   `json:"telemetry"`
+	Store             string            `json:"store"`
+	Mongo             MongoConfig       `json:"mongo"`
+	LoggerConfig      LoggerConfig      `json:"logger"`
+	Auth              AuthConfig        `json:"auth"`
+	AdminUsers        []string          `json:"admins"`
+	DefaultConfigs    *DefaultConfigs   `json:"defaultConfigs"`
+	VersionHistory    VersionHistory    `json:"versionHistory"`
+	Trash             Trash             `json:"trash"`
+	Watch             Watch             `json:"watch"`
+	Webhooks          Webhooks          `json:"webhooks"`
+	Jobs              Jobs              `json:"jobs"`
+	Retention         Retention         `json:"retention"`
+	Readiness         Readiness         `json:"readiness"`
+	RateLimits        RateLimits        `json:"rateLimits"`
+	SlowQueries       SlowQueries       `json:"slowQueries"`
+	QueryCache        QueryCache        `json:"queryCache"`
+	CacheInvalidation CacheInvalidation `json:"cacheInvalidation"`
+}
+
+// VersionHistory is the default retention of routes with version history
+type VersionHistory struct {
+	MaxVersions int `json:"maxVersions"` //number of latest versions to keep per document, 0 keeps all versions
+	MaxAgeDays  int `json:"maxAgeDays"`  //versions older than max age are removed, 0 keeps all versions
+}
+
+// Trash configures the purge of documents deleted from soft delete routes
+type Trash struct {
+	RetentionDays      int `json:"retentionDays"`      //documents are purged from the trash after retention days, 0 keeps them until purged by an admin
+	PurgeIntervalHours int `json:"purgeIntervalHours"` //interval of the purge job, 0 disables the job
+}
+
+// Watch configures the watch routes of collections without change streams
+type Watch struct {
+	PollIntervalMillis int `json:"pollIntervalMillis"` //interval of polling the watched documents when change streams are not supported (in memory store or standalone mongo)
+}
+
+// Webhooks configures the delivery of the webhook subscriptions events
+type Webhooks struct {
+	Workers                   int  `json:"workers"`                   //number of concurrent deliveries, 0 disables the deliveries of this instance
+	MaxAttempts               int  `json:"maxAttempts"`               //deliveries are moved to the dead letters after max attempts
+	InitialBackoffMillis      int  `json:"initialBackoffMillis"`      //delay of the first retry, doubled on each retry
+	MaxBackoffMillis          int  `json:"maxBackoffMillis"`          //max delay between retries
+	TimeoutSeconds            int  `json:"timeoutSeconds"`            //timeout of a delivery request
+	PollIntervalMillis        int  `json:"pollIntervalMillis"`        //interval of checking for due retries and deliveries queued by other instances
+	ExpirySweepIntervalMillis int  `json:"expirySweepIntervalMillis"` //interval of checking for expired documents, 0 disables expired events
+	DeliveryRetentionDays     int  `json:"deliveryRetentionDays"`     //deliveries are kept in the delivery log for the retention
+	AllowPrivateTargets       bool `json:"allowPrivateTargets"`       //allow subscriptions and deliveries to loopback, private and link local addresses
+}
+
+// Jobs configures the workers of the async admin jobs
+type Jobs struct {
+	Workers            int `json:"workers"`            //number of concurrent jobs, 0 disables the jobs of this instance
+	LeaseSeconds       int `json:"leaseSeconds"`       //a running job is resumed by another instance if its lease is not renewed
+	PollIntervalMillis int `json:"pollIntervalMillis"` //interval of checking for jobs queued by other instances and expired leases
+}
+
+// Retention configures the scheduled purge of old documents by the retention policies
+type Retention struct {
+	IntervalHours int                     `json:"intervalHours"` //interval of the scheduled purge, 0 disables the scheduler
+	DryRun        bool                    `json:"dryRun"`        //scheduled runs only report the documents that would be purged
+	Policies      []types.RetentionPolicy `json:"policies"`
+}
+
+// Readiness configures the readiness checks
+type Readiness struct {
+	CacheMillis   int `json:"cacheMillis"`   //a readiness report is reused for the cache duration, 0 runs the checks on every probe
+	TimeoutMillis int `json:"timeoutMillis"` //timeout of each check
+}
+
+// RateLimits configures the per customer token bucket limits of the route classes (read, write, aggregate and admin)
+type RateLimits struct {
+	Limits              map[string]types.RateLimit            `json:"limits"`              //limits by route class, classes without a limit are not limited
+	LicenseTypes        map[string]map[string]types.RateLimit `json:"licenseTypes"`        //overrides of the limits by license type and route class
+	LicenseCacheSeconds int                                   `json:"licenseCacheSeconds"` //license type of a customer is cached for this duration
+}
+
+// SlowQueries configures the logging and recording of the list and unique values queries that exceed the threshold
+type SlowQueries struct {
+	ThresholdMillis int  `json:"thresholdMillis"` //queries that take longer are logged and recorded in the diagnostics collection, 0 disables the diagnostics
+	Explain         bool `json:"explain"`         //explain the recorded queries and keep their winning plan
+	RetentionDays   int  `json:"retentionDays"`   //recorded queries are removed after the retention, 0 keeps them
+}
+
+// QueryCache configures the size limits of the cached query results of the routes with the query cache
+type QueryCache struct {
+	MaxEntries int `json:"maxEntries"` //max number of cached results, 0 disables the cache
+	MaxSizeMB  int `json:"maxSizeMB"`  //max size of the cached results, the least recently used results are evicted, 0 does not limit the size
+}
+
+// CacheInvalidation configures the fan out of the writes of collections with cached documents to the other instances
+type CacheInvalidation struct {
+	PollIntervalMillis int `json:"pollIntervalMillis"` //interval of polling the invalidations of the other instances when change streams are not supported, 0 disables applying them
 }
 
 type TelemetryConfig struct {
@@ -38,6 +137,29 @@ type LoggerConfig struct {
 	LogFileName string `json:"logFileName"`
 }
 
+type AuthConfig struct {
+	// InsecureDevMode trusts the customerGUID cookie or query param without verification, for development and tests only
+	InsecureDevMode bool      `json:"insecureDevMode"`
+	JWT             JWTConfig `json:"jwt"`
+	// RoutePermissions are the roles allowed to perform each operation (read, create, update, delete) by route path, routes not listed are not restricted
+	RoutePermissions map[string]map[string][]string `json:"routePermissions,omitempty"`
+}
+
+// JWTConfig configures the verification of bearer tokens, one of JWKSFile or HMACKey is required
+type JWTConfig struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// JWKSFile is a path to a static JWKS file with the RSA/EC public keys
+	JWKSFile string `json:"jwksFile,omitempty"`
+	// HMACKey is a shared secret for HS256/HS384/HS512 tokens
+	HMACKey string `json:"hmacKey,omitempty"`
+	// claims mapping
+	CustomerGUIDClaim string `json:"customerGUIDClaim,omitempty"` //default "customerGUID"
+	EmailClaim        string `json:"emailClaim,omitempty"`        //default "email"
+	RolesClaim        string `json:"rolesClaim,omitempty"`        //default "roles"
+	AdminRole         string `json:"adminRole,omitempty"`         //default "admin"
+}
+
 type MongoConfig struct {
 	Host        string `json:"host,omitempty"`
 	Port        string `json:"port,omitempty"`
@@ -46,16 +168,65 @@ type MongoConfig struct {
 	DB          string `json:"db,omitempty"`
 	ReplicaSet  string `json:"replicaSet"`
 	MaxPoolSize int    `json:"maxPoolSize"`
+	//indexes that are not declared are dropped on reconciliation, otherwise they are only reported
+	DropExtraIndexes bool `json:"dropExtraIndexes"`
 }
 
 // globalConfig with defaults
 var globalConfig = Configuration{
+	Store: MongoStore,
 	Mongo: MongoConfig{
 		Host:        "localhost",
 		Port:        "27017",
 		DB:          "caportalbe_db",
 		MaxPoolSize: 200,
 	},
+	VersionHistory: VersionHistory{
+		MaxVersions: 50,
+	},
+	Trash: Trash{
+		RetentionDays:      30,
+		PurgeIntervalHours: 24,
+	},
+	Watch: Watch{
+		PollIntervalMillis: 2000,
+	},
+	Webhooks: Webhooks{
+		Workers:                   4,
+		MaxAttempts:               8,
+		InitialBackoffMillis:      10000,
+		MaxBackoffMillis:          3600000,
+		TimeoutSeconds:            10,
+		PollIntervalMillis:        1000,
+		ExpirySweepIntervalMillis: 60000,
+		DeliveryRetentionDays:     7,
+	},
+	Jobs: Jobs{
+		Workers:            2,
+		LeaseSeconds:       60,
+		PollIntervalMillis: 1000,
+	},
+	Retention: Retention{
+		IntervalHours: 24,
+	},
+	Readiness: Readiness{
+		CacheMillis:   2000,
+		TimeoutMillis: 5000,
+	},
+	RateLimits: RateLimits{
+		LicenseCacheSeconds: 300,
+	},
+	SlowQueries: SlowQueries{
+		ThresholdMillis: 2000,
+		RetentionDays:   7,
+	},
+	QueryCache: QueryCache{
+		MaxEntries: 10000,
+		MaxSizeMB:  64,
+	},
+	CacheInvalidation: CacheInvalidation{
+		PollIntervalMillis: 1000,
+	},
 }
 var initOnce sync.Once
 
@@ -77,6 +248,24 @@ func GetConfig() Configuration {
 	return globalConfig
 }
 
+// Validate returns an error if the configuration is not usable
+func (conf Configuration) Validate() error {
+	var err error
+	switch conf.Store {
+	case MongoStore, "":
+		if conf.Mongo.Host == "" || conf.Mongo.DB == "" {
+			err = multierror.Append(err, fmt.Errorf("mongo host and db are required"))
+		}
+	case MemoryStore:
+	default:
+		err = multierror.Append(err, fmt.Errorf("unknown store type %s", conf.Store))
+	}
+	if !conf.Auth.InsecureDevMode && conf.Auth.JWT.JWKSFile == "" && conf.Auth.JWT.HMACKey == "" {
+		err = multierror.Append(err, fmt.Errorf("jwt jwksFile or hmacKey is required when insecure dev mode is disabled"))
+	}
+	return err
+}
+
 func OverrideConfigFromEnvVars(config *Configuration) {
 	if user := os.Getenv(MongoDbUserEnvVar); user != "" {
 		fmt.Println("overriding mongo db user from env var")
@@ -87,4 +276,19 @@ func OverrideConfigFromEnvVars(config *Configuration) {
 		fmt.Println("overriding mongo db password from env var")
 		config.Mongo.Password = password
 	}
+
+	if hmacKey := os.Getenv(JWTHMACKeyEnvVar); hmacKey != "" {
+		fmt.Println("overriding jwt hmac key from env var")
+		config.Auth.JWT.HMACKey = hmacKey
+	}
+
+	if insecureDevMode := os.Getenv(InsecureDevModeEnvVar); insecureDevMode != "" {
+		fmt.Println("overriding insecure dev mode from env var")
+		config.Auth.InsecureDevMode = insecureDevMode == "true"
+	}
+
+	if storeType := os.Getenv(StoreTypeEnvVar); storeType != "" {
+		fmt.Println("overriding store type from env var")
+		config.Store = storeType
+	}
 }
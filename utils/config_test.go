@@ -17,20 +17,27 @@ func TestOverrideConfigFromEnvVars(t *testing.T) {
 	config := GetConfig()
 	assert.Equal(t, "admin", config.Mongo.User)
 	assert.Equal(t, "admin", config.Mongo.Password)
+	assert.False(t, config.Auth.InsecureDevMode)
 
 	// override config from env vars
 	expectedUser := "override-user"
 	expectedPassword := "override-password"
 	os.Setenv(MongoDbUserEnvVar, expectedUser)
 	os.Setenv(MongoDbPasswordEnvVar, expectedPassword)
+	os.Setenv(JWTHMACKeyEnvVar, "override-hmac-key")
+	os.Setenv(InsecureDevModeEnvVar, "true")
 	defer os.Unsetenv(MongoDbUserEnvVar)
 	defer os.Unsetenv(MongoDbPasswordEnvVar)
+	defer os.Unsetenv(JWTHMACKeyEnvVar)
+	defer os.Unsetenv(InsecureDevModeEnvVar)
 
 	// test config read from env vars
 	initOnce = sync.Once{}
 	config = GetConfig()
 	assert.Equal(t, expectedUser, config.Mongo.User)
 	assert.Equal(t, expectedPassword, config.Mongo.Password)
+	assert.Equal(t, "override-hmac-key", config.Auth.JWT.HMACKey)
+	assert.True(t, config.Auth.InsecureDevMode)
 
 	// reset singleton
 	initOnce = sync.Once{}
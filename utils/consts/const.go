@@ -8,11 +8,22 @@ const (
 	Collection     = "collection"           //key for db collection name of the request
 	ReqLogger      = "reqLogger"            //key for request logger
 	AdminAccess    = "adminAccess"          //key for admin access flag
+	UserEmail      = "userEmail"            //key for the authenticated user email
+	UserRoles      = "userRoles"            //key for the authenticated user roles
+	ViewerRole     = "viewer"               //role of users that can read the documents of the exception policies routes when restricted by the configuration
+	EditorRole     = "editor"               //role of users that can read and modify the documents of the exception policies routes when restricted by the configuration
+	AuditorRole    = "auditor"              //role of users that can search the audit trail
 	BodyDecoder    = "customBodyDecoder"    //key for custom body decoder
 	ResponseSender = "customResponseSender" //key for custom response sender
 	PutDocFields   = "customPutDocFields"   //key for string list of fields name to update in PUT requests, only these fields will be updated
 	SchemaInfo     = "schemaInfo"           //key for schema info
 	BaseDocID      = "baseDocID"            //key for base document ID, for pagination over nested documents
+	IfMatch        = "ifMatchRevisions"     //key for the document revisions listed in the If-Match header of the request
+	DocRevision    = "docRevision"          //key for the revision of the document in the response, sent as ETag header
+	VersionHistory = "versionHistory"       //key for the version history retention of the collection, set when prior revisions are kept
+	UpsertMethod   = "upsertMethod"         //key for the method (POST or PUT) the document of an upsert request is validated as
+	RoutePath      = "routePath"            //key for the route path template of the request, for the slow queries diagnostics
+	QueryCacheTTL  = "queryCacheTTL"        //key for the ttl of the cached query results of the route, set when the route enables the query cache
 
 	//PATHS
 	ClusterPath                           = "/cluster"
@@ -39,6 +50,10 @@ const (
 	CloudAccountPath                      = "/v1_cloud_account"
 	WorkflowPath                          = "/v1_workflow"
 	ContainerImageRegistriesPath          = "/v1_container_image_registries"
+	AuditPath                             = "/v1_audit"
+	WebhookSubscriptionPath               = "/v1_webhook_subscription"
+	WebhookDeliveryPath                   = "/v1_webhook_delivery"
+	WebhookDeadLetterPath                 = "/v1_webhook_dead_letter"
 
 	//DB collections
 	ClustersCollection                          = "clusters"
@@ -60,6 +75,14 @@ const (
 	CloudAccountsCollection                     = "v1_cloud_accounts"
 	WorkflowCollection                          = "v1_workflows"
 	ContainerImageRegistriesCollection          = "v1_container_image_registries"
+	AuditCollection                             = "v1_audit_events"
+	WebhookSubscriptionsCollection              = "v1_webhook_subscriptions"
+	WebhookDeliveriesCollection                 = "v1_webhook_deliveries"
+	WebhookDeadLettersCollection                = "v1_webhook_dead_letters"
+	JobsCollection                              = "jobs"
+	DiagnosticsCollection                       = "diagnostics"        //slow queries and their explain plans
+	CacheInvalidationsCollection                = "cacheInvalidations" //writes fanned out to the cached documents of the other instances
+	VersionsCollectionSuffix                    = "_versions"          //suffix of the version history collection of a collection
 
 	//Common document fields
 	IdField          = "_id"
@@ -68,6 +91,8 @@ const (
 	AttributesField  = "attributes"
 	CustomersField   = "customers"
 	UpdatedTimeField = "updatedTime"
+	RevisionField    = "revision"
+	DeletedAtField   = "deletedAt"
 	//cluster fields
 	ShortNameAttribute = "alias"
 	ShortNameField     = AttributesField + "." + ShortNameAttribute
@@ -82,6 +107,30 @@ const (
 	FromDateParam      = "fromDate"
 	ToDateParam        = "toDate"
 	ProjectionParam    = "projection"
+	VersionParam       = "version"
+	OlderThanDaysParam = "olderThanDays"
+	FilterParam        = "filter"
+	UpsertParam        = "upsert"
+	DryRunParam        = "dryRun"
+	ImportKeyParam     = "key"
+	OnConflictParam    = "onConflict"
+	RemapParam         = "remap"
+	AsyncParam         = "async"
+	CollectionParam    = "collection"
+	RouteParam         = "route"
+
+	//PATCH content types
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+
+	//import conflict policies
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+
+	//export and import content types
+	NDJSONContentType = "application/x-ndjson"
+	CSVContentType    = "text/csv"
 
 	//Cached documents keys
 	DefaultCustomerConfigKey = "defaultCustomerConfig"
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "config_service"
+
+var (
+	// HTTPRequestDuration is the latency of the served requests by method and route path template
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the served requests by method and route path template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	// HTTPRequests counts the served requests by method, route path template and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of served requests by method, route path template and status code.",
+	}, []string{"method", "path", "status"})
+
+	// MongoOperationDuration is the latency of the mongo commands by collection and operation
+	MongoOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the mongo commands by collection and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"collection", "operation"})
+
+	// MongoOperationErrors counts the failed mongo commands by collection and operation
+	MongoOperationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "operation_errors_total",
+		Help:      "Number of failed mongo commands by collection and operation.",
+	}, []string{"collection", "operation"})
+
+	// MongoPoolOpenConnections is the number of open connections of the mongo connection pools
+	MongoPoolOpenConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "pool_open_connections",
+		Help:      "Number of open connections of the mongo connection pools.",
+	})
+
+	// MongoPoolInUseConnections is the number of connections checked out of the mongo connection pools
+	MongoPoolInUseConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "pool_in_use_connections",
+		Help:      "Number of connections checked out of the mongo connection pools.",
+	})
+
+	// MongoPoolCheckoutFailures counts the failed connection checkouts of the mongo connection pools
+	MongoPoolCheckoutFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "pool_checkout_failures_total",
+		Help:      "Number of failed connection checkouts of the mongo connection pools.",
+	})
+
+	// CacheRefreshes counts the refreshes of the cached documents by cache key and result (success or failure)
+	CacheRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "refreshes_total",
+		Help:      "Number of refreshes of the cached documents by cache key and result.",
+	}, []string{"cache", "result"})
+
+	// QueryCacheRequests counts the reads of the routes with the query cache by collection and result (hit or miss)
+	QueryCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "query_cache",
+		Name:      "requests_total",
+		Help:      "Number of reads of the routes with the query cache by collection and result.",
+	}, []string{"collection", "result"})
+
+	// QueryCacheEvictions counts the cached query results evicted by the cache size limits
+	QueryCacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "query_cache",
+		Name:      "evictions_total",
+		Help:      "Number of cached query results evicted by the cache size limits.",
+	})
+
+	// QueryCacheBytes is the size of the cached query results
+	QueryCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "query_cache",
+		Name:      "size_bytes",
+		Help:      "Size of the cached query results.",
+	})
+
+	// ValidationRejections counts the requests rejected by each mutator validator
+	ValidationRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "validation",
+		Name:      "rejections_total",
+		Help:      "Number of requests rejected by each mutator validator.",
+	}, []string{"validator"})
+
+	// RateLimitedRequests counts the requests rejected by the per customer rate limits by route class
+	RateLimitedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_requests_total",
+		Help:      "Number of requests rejected by the per customer rate limits by route class.",
+	}, []string{"class"})
+
+	// RetentionPurged counts the documents purged by the retention policies by collection
+	RetentionPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "purged_documents_total",
+		Help:      "Number of documents purged by the retention policies by collection.",
+	}, []string{"collection"})
+)
+
+// cache refresh results
+const (
+	RefreshSuccess = "success"
+	RefreshFailure = "failure"
+)
+
+// query cache results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Handler serves the metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// cachesLastRefresh keeps the function returning the last successful refresh time of each cached document
+var cachesLastRefresh = sync.Map{}
+
+// SetCacheLastRefreshFunc sets the function returning the last successful refresh time of a cached document for its staleness age
+func SetCacheLastRefreshFunc(cacheKey string, lastRefresh func() time.Time) {
+	cachesLastRefresh.Store(cacheKey, lastRefresh)
+}
+
+// cacheStalenessCollector reports the time since the last successful refresh of the cached documents when scraped
+type cacheStalenessCollector struct {
+	desc *prometheus.Desc
+}
+
+func (s cacheStalenessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+func (s cacheStalenessCollector) Collect(ch chan<- prometheus.Metric) {
+	cachesLastRefresh.Range(func(key, value interface{}) bool {
+		//not refreshed yet
+		if lastRefresh := value.(func() time.Time)(); !lastRefresh.IsZero() {
+			ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, time.Since(lastRefresh).Seconds(), key.(string))
+		}
+		return true
+	})
+}
+
+func init() {
+	prometheus.MustRegister(cacheStalenessCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "staleness_seconds"),
+			"Time since the last successful refresh of the cached documents by cache key.", []string{"cache"}, nil),
+	})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils/consts"
+	"fmt"
+	"net/http"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+func (suite *MainTestSuite) getDocVersions(docPath string) []types.DocVersion[*types.PostureExceptionPolicy] {
+	w := suite.doRequest(http.MethodGet, docPath+"/versions", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	versions, err := decodeResponse[[]types.DocVersion[*types.PostureExceptionPolicy]](w)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	return versions
+}
+
+func (suite *MainTestSuite) TestVersionHistory() {
+	posturePolicies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	policy := testPostDoc(suite, consts.PostureExceptionPolicyPath, posturePolicies[0], commonCmpFilter)
+	docPath := consts.PostureExceptionPolicyPath + "/" + policy.GUID
+	suite.Empty(suite.getDocVersions(docPath))
+
+	original := Clone(policy)
+	policy.Attributes = map[string]interface{}{"edit": "first"}
+	policy = testPutDoc(suite, consts.PostureExceptionPolicyPath, original, policy, commonCmpFilter)
+	edited := Clone(policy)
+	policy.Attributes = map[string]interface{}{"edit": "second"}
+	testPutDoc(suite, consts.PostureExceptionPolicyPath, edited, policy, commonCmpFilter)
+
+	versions := suite.getDocVersions(docPath)
+	if suite.Len(versions, 2) {
+		suite.Equal(int64(2), versions[0].Version)
+		suite.Equal(int64(1), versions[1].Version)
+		suite.Equal(http.MethodPut, versions[0].Verb)
+		suite.Equal(defaultUserGUID, versions[0].Actor)
+		suite.Equal(policy.GUID, versions[0].DocGUID)
+	}
+	w := suite.doRequest(http.MethodGet, docPath+"/versions/1", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	version, err := decodeResponse[types.DocVersion[*types.PostureExceptionPolicy]](w)
+	suite.NoError(err)
+	suite.Nil(version.Content.Attributes)
+	suite.Equal(original.PosturePolicies, version.Content.PosturePolicies)
+	testBadRequest(suite, http.MethodGet, docPath+"/versions/10", errorDocumentNotFound, nil, http.StatusNotFound)
+	testBadRequest(suite, http.MethodGet, docPath+"/versions/latest", `{"error":"version must be a number"}`, nil, http.StatusBadRequest)
+	//other customers do not see the versions
+	suite.login("other-customer-guid")
+	suite.Empty(suite.getDocVersions(docPath))
+	testBadRequest(suite, http.MethodPost, docPath+"/versions/1/restore", errorDocumentNotFound, nil, http.StatusNotFound)
+	suite.login(defaultUserGUID)
+
+	//restore the original document, the replaced revision is kept
+	w = suite.doRequestWithHeaders(http.MethodPost, docPath+"/versions/1/restore", nil, map[string]string{"If-Match": `"2"`})
+	suite.Equal(http.StatusPreconditionFailed, w.Code)
+	w = suite.doRequestWithHeaders(http.MethodPost, docPath+"/versions/1/restore", nil, map[string]string{"If-Match": `"3"`})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"4"`, w.Header().Get("ETag"))
+	restored, err := decodeResponse[*types.PostureExceptionPolicy](w)
+	suite.NoError(err)
+	suite.Nil(restored.Attributes)
+	testGetDoc(suite, docPath, restored, commonCmpFilter)
+	versions = suite.getDocVersions(docPath)
+	if suite.Len(versions, 3) {
+		suite.Equal(int64(3), versions[0].Version)
+		suite.Equal(http.MethodPost, versions[0].Verb)
+		suite.Equal("second", versions[0].Content.Attributes["edit"])
+	}
+
+	//restore a deleted document
+	testDeleteDocByGUID(suite, consts.PostureExceptionPolicyPath, restored, commonCmpFilter)
+	versions = suite.getDocVersions(docPath)
+	if suite.Len(versions, 4) {
+		suite.Equal(int64(4), versions[0].Version)
+		suite.Equal(http.MethodDelete, versions[0].Verb)
+	}
+	w = suite.doRequest(http.MethodPost, fmt.Sprintf("%s/versions/%d/restore", docPath, 3), nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"5"`, w.Header().Get("ETag"))
+	w = suite.doRequest(http.MethodGet, docPath, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal(`"5"`, w.Header().Get("ETag"))
+	recreated, err := decodeResponse[*types.PostureExceptionPolicy](w)
+	suite.NoError(err)
+	suite.Equal("second", recreated.Attributes["edit"])
+
+	//restore is audited
+	events := suite.getAuditEvents(map[string]string{"docGUID": policy.GUID, "verb": http.MethodPost})
+	suite.Len(events, 3)
+}
+
+func (suite *MainTestSuite) TestCustomerConfigVersionHistory() {
+	config := &types.CustomerConfig{}
+	config.Name = "versioned-config"
+	w := suite.doRequest(http.MethodPost, consts.CustomerConfigPath, config)
+	suite.Equal(http.StatusCreated, w.Code)
+	config, err := decodeResponse[*types.CustomerConfig](w)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	docPath := consts.CustomerConfigPath + "/" + config.GUID
+	config.Settings.PostureScanConfig.ScanFrequency = "12h"
+	w = suite.doRequest(http.MethodPut, consts.CustomerConfigPath+"?configName=versioned-config", config)
+	suite.Equal(http.StatusOK, w.Code)
+
+	w = suite.doRequest(http.MethodGet, docPath+"/versions/1", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	version, err := decodeResponse[types.DocVersion[*types.CustomerConfig]](w)
+	suite.NoError(err)
+	suite.Empty(version.Content.Settings.PostureScanConfig.ScanFrequency)
+
+	w = suite.doRequest(http.MethodDelete, consts.CustomerConfigPath+"?configName=versioned-config", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	w = suite.doRequest(http.MethodPost, docPath+"/versions/2/restore", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	w = suite.doRequest(http.MethodGet, consts.CustomerConfigPath+"?configName=versioned-config", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	restored, err := decodeResponse[*types.CustomerConfig](w)
+	suite.NoError(err)
+	suite.Equal("12h", string(restored.Settings.PostureScanConfig.ScanFrequency))
+	w = suite.doRequest(http.MethodDelete, consts.CustomerConfigPath+"?configName=versioned-config", nil)
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+func (suite *MainTestSuite) TestBulkDeleteVersionHistory() {
+	posturePolicies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	policies := []*types.PostureExceptionPolicy{}
+	for _, policy := range posturePolicies[:3] {
+		policies = append(policies, testPostDoc(suite, consts.PostureExceptionPolicyPath, policy, commonCmpFilter))
+	}
+
+	//bulk delete by guids, by name and by query keep the deleted revisions
+	w := suite.doRequest(http.MethodDelete, fmt.Sprintf("%s/bulk?guid=%s", consts.PostureExceptionPolicyPath, policies[0].GUID), nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = suite.doRequest(http.MethodDelete, fmt.Sprintf("%s?%s=%s&%s=missing", consts.PostureExceptionPolicyPath, consts.PolicyNameParam, policies[1].Name, consts.PolicyNameParam), nil)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = suite.doRequest(http.MethodDelete, consts.PostureExceptionPolicyPath+"/query", armotypes.V2ListRequest{InnerFilters: []map[string]string{{"name": policies[2].Name}}})
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Equal(`{"deletedCount":1}`, w.Body.String())
+	testGetDocs(suite, consts.PostureExceptionPolicyPath, []*types.PostureExceptionPolicy{}, commonCmpFilter)
+
+	for _, policy := range policies {
+		docPath := consts.PostureExceptionPolicyPath + "/" + policy.GUID
+		versions := suite.getDocVersions(docPath)
+		if suite.Len(versions, 1) {
+			suite.Equal(int64(1), versions[0].Version)
+			suite.Equal(http.MethodDelete, versions[0].Verb)
+		}
+		w = suite.doRequest(http.MethodPost, docPath+"/versions/1/restore", nil)
+		suite.Equal(http.StatusOK, w.Code, w.Body.String())
+		testGetDoc(suite, docPath, policy, commonCmpFilter)
+	}
+}
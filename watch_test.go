@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+// poll interval of the watch routes in tests
+const watchPollInterval = 50 * time.Millisecond
+
+type serverSentEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// watch streams the server-sent events of a watch route while making the changes until count events are received
+func (suite *MainTestSuite) watch(path, lastEventID string, count int, changes func()) []serverSentEvent {
+	server := httptest.NewServer(suite.router)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	req.Header.Set("Cookie", suite.authCookie)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	//let the watch read the documents before changing them
+	time.Sleep(4 * watchPollInterval)
+	changes()
+
+	events := []serverSentEvent{}
+	event := serverSentEvent{}
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < count && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.event != "" {
+				events = append(events, event)
+			}
+			event = serverSentEvent{}
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if len(events) < count {
+		suite.FailNow("missing watch events", "expected %d events, got %v", count, events)
+	}
+	return events
+}
+
+func (suite *MainTestSuite) assertChangeEvent(event serverSentEvent, operation, guid string) {
+	suite.Equal(operation, event.event)
+	changeEvent := decode[types.ChangeEvent[*types.RuntimeIncident]](suite, []byte(event.data))
+	suite.Equal(operation, changeEvent.Operation)
+	suite.Equal(guid, changeEvent.DocGUID)
+	if operation == "delete" || operation == "reset" {
+		suite.Nil(changeEvent.Document)
+	} else if suite.NotNil(changeEvent.Document) {
+		suite.Equal(guid, changeEvent.Document.GUID)
+	}
+}
+
+func (suite *MainTestSuite) TestWatch() {
+	filter, _ := json.Marshal(armotypes.V2ListRequest{InnerFilters: []map[string]string{{"name": "incident1,incident2"}}})
+	watchPath := consts.RuntimeIncidentPath + "/watch?filter=" + url.QueryEscape(string(filter))
+	incidents := getRuntimeIncidentsMocks()
+	incident1, incident2, incident3 := incidents[0], incidents[1], incidents[2]
+
+	testBadRequest(suite, http.MethodGet, consts.RuntimeIncidentPath+"/watch?filter=abc",
+		`{"error":"invalid filter param: invalid character 'a' looking for beginning of value"}`, nil, http.StatusBadRequest)
+
+	//inserts of documents that match the filter are sent
+	events := suite.watch(watchPath, "", 2, func() {
+		w := suite.doRequest(http.MethodPost, consts.RuntimeIncidentPath, incidents)
+		suite.Equal(http.StatusCreated, w.Code)
+	})
+	suite.assertChangeEvent(events[0], "insert", incident1.GUID)
+	suite.assertChangeEvent(events[1], "insert", incident2.GUID)
+
+	//resume after the first event
+	events = suite.watch(watchPath, events[0].id, 3, func() {
+		update := Clone(incident1)
+		update.IsDismissed = true
+		w := suite.doRequest(http.MethodPut, consts.RuntimeIncidentPath, update)
+		suite.Equal(http.StatusOK, w.Code)
+		w = suite.doRequest(http.MethodDelete, consts.RuntimeIncidentPath+"/"+incident2.GUID, nil)
+		suite.Equal(http.StatusOK, w.Code)
+	})
+	suite.assertChangeEvent(events[0], "insert", incident2.GUID)
+	suite.assertChangeEvent(events[1], "update", incident1.GUID)
+	suite.assertChangeEvent(events[2], "delete", incident2.GUID)
+
+	//an unknown event id resets the watch, changes of documents that do not match the filter are not sent
+	events = suite.watch(watchPath, "poll-unknown-1", 2, func() {
+		w := suite.doRequest(http.MethodDelete, consts.RuntimeIncidentPath+"/"+incident3.GUID, nil)
+		suite.Equal(http.StatusOK, w.Code)
+		time.Sleep(4 * watchPollInterval)
+		w = suite.doRequest(http.MethodDelete, consts.RuntimeIncidentPath+"/"+incident1.GUID, nil)
+		suite.Equal(http.StatusOK, w.Code)
+	})
+	suite.assertChangeEvent(events[0], "reset", "")
+	suite.assertChangeEvent(events[1], "delete", incident1.GUID)
+
+	//other customers do not see the changes
+	suite.login("other-customer-guid")
+	events = suite.watch(watchPath, "", 1, func() {
+		suite.login(defaultUserGUID)
+		w := suite.doRequest(http.MethodPost, consts.RuntimeIncidentPath, incident1)
+		suite.Equal(http.StatusCreated, w.Code)
+		suite.login("other-customer-guid")
+		time.Sleep(4 * watchPollInterval)
+		w = suite.doRequest(http.MethodPost, consts.RuntimeIncidentPath, incident2)
+		suite.Equal(http.StatusCreated, w.Code)
+	})
+	suite.assertChangeEvent(events[0], "insert", incident2.GUID)
+}
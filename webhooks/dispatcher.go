@@ -0,0 +1,144 @@
+package webhooks
+
+import (
+	"bytes"
+	"config-service/db"
+	"config-service/types"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// headers of the delivery requests
+const (
+	EventHeader     = "X-Webhook-Event"     //the event type
+	EventIDHeader   = "X-Webhook-Id"        //the event id, the same in retries and in the deliveries to other subscriptions
+	DeliveryHeader  = "X-Webhook-Delivery"  //the delivery GUID in the delivery log
+	TimestampHeader = "X-Webhook-Timestamp" //unix time of the request
+	SignatureHeader = "X-Webhook-Signature" //sha256=<hex of the HMAC-SHA256 of "<timestamp>.<body>" with the subscription secret>
+
+	signaturePrefix = "sha256="
+	//max bytes of the target response read to reuse the connection
+	maxResponseBodyRead = 4096
+)
+
+// Sign returns the signature header value of a request body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// runWorker delivers the due deliveries until the context is done
+func runWorker(ctx context.Context) {
+	for {
+		delivery, err := db.ClaimWebhookDelivery(ctx, 2*config.Timeout)
+		if err != nil && ctx.Err() == nil {
+			zap.L().Error("failed to claim webhook delivery", zap.Error(err))
+		}
+		if delivery != nil {
+			//more deliveries may be due, let idle workers claim them
+			notifyWorkers()
+			deliver(ctx, delivery)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wakeup:
+		case <-time.After(config.PollInterval):
+		}
+	}
+}
+
+// deliver makes a delivery attempt and saves its result, a failed delivery is retried with backoff or moved to the dead letters
+func deliver(ctx context.Context, delivery *types.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.LastAttemptTime = &now
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	retry := true
+	subscription, err := db.GetWebhookSubscription(ctx, delivery.SubscriptionGUID)
+	if err == nil && subscription == nil {
+		err = fmt.Errorf("subscription %s was deleted", delivery.SubscriptionGUID)
+		retry = false
+	}
+	if err == nil {
+		delivery.URL = subscription.URL
+		delivery.LastStatusCode, err = send(ctx, subscription, delivery)
+	}
+	switch {
+	case err == nil:
+		delivery.Status = types.WebhookDeliveryDelivered
+		delivery.DeliveredTime = &now
+		delivery.NextAttemptTime = nil
+	case retry && delivery.Attempts < config.MaxAttempts:
+		delivery.LastError = err.Error()
+		nextAttemptTime := now.Add(backoff(delivery.Attempts))
+		delivery.NextAttemptTime = &nextAttemptTime
+	default:
+		delivery.LastError = err.Error()
+		delivery.Status = types.WebhookDeliveryDead
+		delivery.NextAttemptTime = nil
+		if err := db.InsertWebhookDeadLetter(ctx, delivery.Event.CustomerGUID, delivery); err != nil {
+			zap.L().Error("failed to save webhook dead letter", zap.Error(err), zap.String("delivery", delivery.GUID))
+		}
+	}
+	if err := db.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		zap.L().Error("failed to save webhook delivery", zap.Error(err), zap.String("delivery", delivery.GUID))
+	}
+}
+
+// send posts the event to the subscription URL and returns the response status code
+func send(ctx context.Context, subscription *types.WebhookSubscription, delivery *types.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event.Type)
+	req.Header.Set(EventIDHeader, delivery.Event.ID)
+	req.Header.Set(DeliveryHeader, delivery.GUID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(string(subscription.Secret), timestamp, body))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBodyRead))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the retry of a failed attempt, the delay is doubled on each attempt up to the max backoff
+func backoff(attempt int) time.Duration {
+	delay := config.InitialBackoff
+	for i := 1; i < attempt && delay < config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > config.MaxBackoff {
+		delay = config.MaxBackoff
+	}
+	return delay
+}
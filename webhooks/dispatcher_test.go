@@ -0,0 +1,38 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	defer func(conf Config) { config = conf }(config)
+	config.InitialBackoff = 10 * time.Second
+	config.MaxBackoff = time.Minute
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 10 * time.Second},
+		{attempt: 2, want: 20 * time.Second},
+		{attempt: 3, want: 40 * time.Second},
+		{attempt: 4, want: time.Minute},
+		{attempt: 100, want: time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, backoff(tt.attempt), "attempt %d", tt.attempt)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signature := Sign("secret", "1700000000", body)
+	assert.Equal(t, "sha256=", signature[:len(signaturePrefix)])
+	assert.Len(t, signature, len(signaturePrefix)+64)
+	assert.Equal(t, signature, Sign("secret", "1700000000", body))
+	assert.NotEqual(t, signature, Sign("other", "1700000000", body))
+	assert.NotEqual(t, signature, Sign("secret", "1700000001", body))
+	assert.NotEqual(t, signature, Sign("secret", "1700000000", []byte(`{"id":"2"}`)))
+}
@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"config-service/db"
+	"config-service/types"
+	"config-service/utils/consts"
+	"context"
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// on start the sweep publishes the expirations of the lookback that were missed while the service was down
+const expiredEventsLookback = 24 * time.Hour
+
+// internal fields of the stored documents that are not sent in the events
+var internalFields = []string{consts.IdField, consts.CustomersField, consts.RevisionField, consts.DeletedAtField}
+
+// runExpirySweep publishes expired events of the documents of routes with an expiration field until the context is done
+func runExpirySweep(ctx context.Context) {
+	from := time.Now().UTC().Add(-expiredEventsLookback)
+	ticker := time.NewTicker(config.ExpirySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			to := time.Now().UTC()
+			SweepExpired(ctx, from, to)
+			//overlap the sweeps, events that were already published are not queued again
+			from = to.Add(-config.ExpirySweepInterval)
+		}
+	}
+}
+
+// SweepExpired publishes expired events of the documents that expired after from and up to to
+func SweepExpired(ctx context.Context, from, to time.Time) {
+	swept := map[string]bool{}
+	for _, path := range types.GetAllPaths() {
+		apiInfo := types.GetAPIInfo(path)
+		field := apiInfo.Schema.ExpirationFieldName
+		if field == "" || swept[apiInfo.DBCollection] {
+			continue
+		}
+		swept[apiInfo.DBCollection] = true
+		docs, err := db.FindExpiredDocs(ctx, apiInfo.DBCollection, field, from, to)
+		if err != nil {
+			zap.L().Error("failed to find expired documents", zap.Error(err), zap.String("collection", apiInfo.DBCollection))
+			continue
+		}
+		for _, doc := range docs {
+			if err := publishExpired(ctx, apiInfo.DBCollection, field, doc); err != nil {
+				zap.L().Error("failed to publish expired event", zap.Error(err), zap.String("collection", apiInfo.DBCollection))
+			}
+		}
+	}
+}
+
+func publishExpired(ctx context.Context, collection, field string, doc bson.M) error {
+	expiration, ok := doc[field].(primitive.DateTime)
+	if !ok {
+		return fmt.Errorf("expiration field %s is not a date", field)
+	}
+	guid, _ := doc[consts.GUIDField].(string)
+	customers, _ := doc[consts.CustomersField].(primitive.A)
+	for _, internalField := range internalFields {
+		delete(doc, internalField)
+	}
+	content := jsonValue(doc)
+	for _, customer := range customers {
+		customerGUID, ok := customer.(string)
+		if !ok || customerGUID == "" {
+			continue
+		}
+		event := types.WebhookEvent{
+			//the same id in all the sweeps so the expiration is published once
+			ID:           uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("%s/%s/%s/%d", customerGUID, collection, guid, expiration)).String(),
+			Type:         types.WebhookEventExpired,
+			CustomerGUID: customerGUID,
+			Collection:   collection,
+			DocGUID:      guid,
+			Timestamp:    expiration.Time().UTC(),
+		}
+		if err := Publish(ctx, event, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonValue converts the values decoded from bson to values with the JSON representation of the documents
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case bson.M:
+		m := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case bson.D:
+		m := make(map[string]interface{}, len(value))
+		for _, e := range value {
+			m[e.Key] = jsonValue(e.Value)
+		}
+		return m
+	case primitive.A:
+		a := make([]interface{}, len(value))
+		for i := range value {
+			a[i] = jsonValue(value[i])
+		}
+		return a
+	case primitive.DateTime:
+		return value.Time().UTC()
+	}
+	return v
+}
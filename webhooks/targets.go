@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// httpClient delivers the events, connections to private addresses are refused when dialed so a public host name
+// that is resolved to a private address after the subscription was validated (DNS rebinding) is not reached
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   checkDialedAddress,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+// ValidateTargetURL returns an error if the url is not an http or https url of a public host
+// host names are resolved only when the events are delivered, the dialed addresses are checked then
+func ValidateTargetURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %s, an http or https url is required", rawURL)
+	}
+	if config.AllowPrivateTargets {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("invalid url %s, the host is not a public address", rawURL)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("invalid url %s, the host is not a public address", rawURL)
+	}
+	return nil
+}
+
+// checkDialedAddress refuses connections to the resolved addresses that are not public
+func checkDialedAddress(_, address string, _ syscall.RawConn) error {
+	if config.AllowPrivateTargets {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("webhook target address %s is not a public address", host)
+	}
+	return nil
+}
+
+// isPublicIP returns false for loopback, private, link local, multicast and unspecified addresses
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTargetURL(t *testing.T) {
+	defer func(conf Config) { config = conf }(config)
+	config.AllowPrivateTargets = false
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{url: "https://hooks.example.com/incidents", valid: true},
+		{url: "http://93.184.216.34:8080/hook", valid: true},
+		{url: "ftp://example.com"},
+		{url: "https://"},
+		{url: "http://localhost:8080/hook"},
+		{url: "http://api.localhost./hook"},
+		{url: "http://127.0.0.1/hook"},
+		{url: "http://[::1]/hook"},
+		{url: "http://10.0.0.1/hook"},
+		{url: "http://192.168.1.1/hook"},
+		{url: "http://[fd00::1]/hook"},
+		{url: "http://169.254.169.254/latest/meta-data"},
+		{url: "http://[fe80::1]/hook"},
+		{url: "http://0.0.0.0/hook"},
+		{url: "http://[::]/hook"},
+	}
+	for _, tt := range tests {
+		err := ValidateTargetURL(tt.url)
+		if tt.valid {
+			assert.NoError(t, err, tt.url)
+		} else {
+			assert.Error(t, err, tt.url)
+		}
+	}
+	config.AllowPrivateTargets = true
+	assert.NoError(t, ValidateTargetURL("http://127.0.0.1/hook"))
+	assert.Error(t, ValidateTargetURL("ftp://127.0.0.1/hook"))
+}
+
+func TestDeliveryToPrivateAddress(t *testing.T) {
+	defer func(conf Config) { config = conf }(config)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	post := func() error {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, target.URL, nil)
+		assert.NoError(t, err)
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	//the dialed address is checked, so host names resolved to private addresses are refused too
+	config.AllowPrivateTargets = false
+	assert.ErrorContains(t, post(), "is not a public address")
+	config.AllowPrivateTargets = true
+	assert.NoError(t, post())
+}
@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"config-service/db"
+	"config-service/db/bsonquery"
+	"config-service/types"
+	"config-service/utils/log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Config configures the delivery of the webhook events
+type Config struct {
+	Workers             int           //number of concurrent deliveries, 0 disables the delivery workers
+	MaxAttempts         int           //deliveries are moved to the dead letters after max attempts
+	InitialBackoff      time.Duration //delay of the first retry, doubled on each retry
+	MaxBackoff          time.Duration //max delay between retries
+	Timeout             time.Duration //timeout of a delivery request
+	PollInterval        time.Duration //interval of checking for due retries and deliveries queued by other instances
+	ExpirySweepInterval time.Duration //interval of checking for expired documents, 0 disables expired events
+	DeliveryRetention   time.Duration //deliveries are kept in the delivery log for the retention
+	AllowPrivateTargets bool          //allow subscriptions and deliveries to loopback, private and link local addresses
+}
+
+var config = Config{
+	Workers:             4,
+	MaxAttempts:         8,
+	InitialBackoff:      10 * time.Second,
+	MaxBackoff:          time.Hour,
+	Timeout:             10 * time.Second,
+	PollInterval:        time.Second,
+	ExpirySweepInterval: time.Minute,
+	DeliveryRetention:   7 * 24 * time.Hour,
+}
+
+// wakeup notifies idle workers on new deliveries
+var wakeup = make(chan struct{}, 1)
+
+func notifyWorkers() {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start starts the delivery workers and the expired documents sweep, the returned function stops them
+func Start(conf Config) (stop func()) {
+	config = conf
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(ctx)
+		}()
+	}
+	if conf.ExpirySweepInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runExpirySweep(ctx)
+		}()
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Publish queues deliveries of the event to the customer subscriptions of the event type and collection
+// doc is the document of the event, subscriptions with inner filters get the event only if the document matches them
+// an event without id gets a new id, deliveries of an event id to a subscription are queued once
+func Publish(c context.Context, event types.WebhookEvent, doc interface{}) error {
+	defer log.LogNTraceEnterExit("webhooks.Publish", c)()
+	subscriptions, err := db.GetWebhookSubscriptions(c, event.CustomerGUID, event.Collection, event.Type)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+	if event.ID == "" {
+		event.ID = uuid.NewV4().String()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if doc != nil {
+		if event.Document, err = documentPayload(event.Collection, doc); err != nil {
+			return err
+		}
+	}
+	now := time.Now().UTC()
+	expiryTime := now.Add(config.DeliveryRetention)
+	deliveries := []*types.WebhookDelivery{}
+	for _, subscription := range subscriptions {
+		//subscriptions get the events that happened after they were created
+		if subscription.CreationTime.After(event.Timestamp) {
+			continue
+		}
+		if matched, err := matchSubscription(subscription, doc); err != nil {
+			log.LogNTraceError(fmt.Sprintf("failed to match document to webhook subscription %s", subscription.GUID), err, c)
+			continue
+		} else if !matched {
+			continue
+		}
+		delivery := &types.WebhookDelivery{
+			SubscriptionGUID: subscription.GUID,
+			URL:              subscription.URL,
+			Event:            event,
+			Status:           types.WebhookDeliveryPending,
+			NextAttemptTime:  &now,
+			ExpiryTime:       &expiryTime,
+		}
+		delivery.InitNew()
+		delivery.GUID = deliveryGUID(event.ID, subscription.GUID)
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := db.InsertWebhookDeliveries(c, event.CustomerGUID, deliveries); err != nil {
+		return err
+	}
+	notifyWorkers()
+	return nil
+}
+
+// documentPayload returns the JSON of the event document without the fields that the routes of the collection must exclude from the responses
+func documentPayload(collection string, doc interface{}) (json.RawMessage, error) {
+	payload, err := json.Marshal(doc)
+	schema := types.GetCollectionSchema(collection)
+	if err != nil || schema == nil || len(schema.GetMustExcludeFields()) == 0 {
+		return payload, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return nil, err
+	}
+	for _, field := range schema.GetMustExcludeFields() {
+		removeField(document, strings.Split(field, "."))
+	}
+	return json.Marshal(document)
+}
+
+// removeField removes the field of the path parts from a decoded JSON document, the field is removed from each element of the arrays in the path
+func removeField(v interface{}, parts []string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(parts) == 1 {
+			delete(value, parts[0])
+			return
+		}
+		removeField(value[parts[0]], parts[1:])
+	case []interface{}:
+		for _, element := range value {
+			removeField(element, parts)
+		}
+	}
+}
+
+// Redeliver queues a dead letter as a new delivery with new attempts
+func Redeliver(c context.Context, deadLetter *types.WebhookDelivery) (*types.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	expiryTime := now.Add(config.DeliveryRetention)
+	delivery := &types.WebhookDelivery{
+		SubscriptionGUID: deadLetter.SubscriptionGUID,
+		URL:              deadLetter.URL,
+		Event:            deadLetter.Event,
+		Status:           types.WebhookDeliveryPending,
+		NextAttemptTime:  &now,
+		ExpiryTime:       &expiryTime,
+	}
+	delivery.InitNew()
+	delivery.GUID = uuid.NewV4().String()
+	if err := db.InsertWebhookDeliveries(c, deadLetter.Event.CustomerGUID, []*types.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	notifyWorkers()
+	return delivery, nil
+}
+
+// deliveryGUID returns the same delivery GUID for an event and subscription so an event is queued once
+func deliveryGUID(eventID, subscriptionGUID string) string {
+	return uuid.NewV5(uuid.NamespaceOID, eventID+"/"+subscriptionGUID).String()
+}
+
+func matchSubscription(subscription *types.WebhookSubscription, doc interface{}) (bool, error) {
+	if subscription.MatchQuery == "" {
+		return true, nil
+	}
+	if doc == nil {
+		return false, nil
+	}
+	var query bson.D
+	if err := bson.UnmarshalExtJSON([]byte(subscription.MatchQuery), true, &query); err != nil {
+		return false, err
+	}
+	return bsonquery.Match(doc, query)
+}
@@ -0,0 +1,28 @@
+package webhooks
+
+import (
+	"config-service/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentPayload(t *testing.T) {
+	types.SetAPIInfo("/webhooksPayloadTest", types.APIInfo{
+		DBCollection: "webhooksPayloadTest",
+		Schema:       types.SchemaInfo{MustExcludeFields: []string{"relatedAlerts", "items.secret"}},
+	})
+	doc := map[string]interface{}{
+		"name":          "incident",
+		"relatedAlerts": []interface{}{map[string]interface{}{"name": "alert"}},
+		"items":         []interface{}{map[string]interface{}{"name": "a", "secret": "s"}, map[string]interface{}{"name": "b"}},
+	}
+	payload, err := documentPayload("webhooksPayloadTest", doc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"incident","items":[{"name":"a"},{"name":"b"}]}`, string(payload))
+
+	//collections without excluded fields get the whole document
+	payload, err = documentPayload("webhooksPayloadTestOther", doc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(payload), "relatedAlerts")
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"config-service/types"
+	"config-service/utils"
+	"config-service/utils/consts"
+	"config-service/webhooks"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/armosec/armoapi-go/armotypes"
+)
+
+// fast webhooks deliveries in tests, expired events are published by the tests
+var testWebhooksConfig = utils.Webhooks{
+	Workers:               2,
+	MaxAttempts:           3,
+	InitialBackoffMillis:  50,
+	MaxBackoffMillis:      200,
+	TimeoutSeconds:        2,
+	PollIntervalMillis:    50,
+	DeliveryRetentionDays: 1,
+	//the test target listens on the loopback address
+	AllowPrivateTargets: true,
+}
+
+const webhookSecret = "webhook-secret"
+
+type webhookRequest struct {
+	event          types.WebhookEvent
+	deliveryGUID   string
+	validSignature bool
+}
+
+// webhookTarget records the events delivered to its paths
+// the first attempt of every delivery to /flaky fails and deliveries to /down fail until it is up
+type webhookTarget struct {
+	*httptest.Server
+	mu       sync.Mutex
+	up       bool
+	attempts map[string]int
+	requests map[string][]webhookRequest
+}
+
+func newWebhookTarget() *webhookTarget {
+	target := &webhookTarget{attempts: map[string]int{}, requests: map[string][]webhookRequest{}}
+	target.Server = httptest.NewServer(target)
+	return target
+}
+
+func (t *webhookTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	request := webhookRequest{
+		deliveryGUID:   r.Header.Get(webhooks.DeliveryHeader),
+		validSignature: webhooks.Sign(webhookSecret, r.Header.Get(webhooks.TimestampHeader), body) == r.Header.Get(webhooks.SignatureHeader),
+	}
+	json.Unmarshal(body, &request.event)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts[request.deliveryGUID]++
+	if (r.URL.Path == "/flaky" && t.attempts[request.deliveryGUID] == 1) || (r.URL.Path == "/down" && !t.up) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	t.requests[r.URL.Path] = append(t.requests[r.URL.Path], request)
+}
+
+func (t *webhookTarget) setUp(up bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.up = up
+}
+
+func (t *webhookTarget) received(path string) []webhookRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]webhookRequest{}, t.requests[path]...)
+}
+
+// waitForRequests waits until the target path received count requests
+func (suite *MainTestSuite) waitForRequests(target *webhookTarget, path string, count int) []webhookRequest {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(target.received(path)) < count && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	requests := target.received(path)
+	suite.Len(requests, count, "requests to %s", path)
+	for _, request := range requests {
+		suite.True(request.validSignature)
+	}
+	return requests
+}
+
+// subscriptionBody returns the request body of a subscription, the secret is redacted when the subscription is marshaled
+func (suite *MainTestSuite) subscriptionBody(subscription *types.WebhookSubscription) map[string]interface{} {
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	body := decode[map[string]interface{}](suite, data)
+	if subscription.Secret != "" {
+		body["secret"] = string(subscription.Secret)
+	}
+	return body
+}
+
+func (suite *MainTestSuite) postSubscription(subscription *types.WebhookSubscription) *types.WebhookSubscription {
+	w := suite.doRequest(http.MethodPost, consts.WebhookSubscriptionPath, suite.subscriptionBody(subscription))
+	suite.Equal(http.StatusCreated, w.Code)
+	newSubscription := decode[*types.WebhookSubscription](suite, w.Body.Bytes())
+	//the secret is write only
+	suite.Equal(types.WriteOnlyString("********"), newSubscription.Secret)
+	return newSubscription
+}
+
+func (suite *MainTestSuite) queryDeliveries(path string, filters map[string]string) []*types.WebhookDelivery {
+	w := suite.doRequest(http.MethodPost, path+"/query", armotypes.V2ListRequest{
+		OrderBy:      "creationTime:asc",
+		InnerFilters: []map[string]string{filters},
+	})
+	suite.Equal(http.StatusOK, w.Code)
+	result, err := decodeResponse[types.SearchResult[*types.WebhookDelivery]](w)
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	return result.Response
+}
+
+func (suite *MainTestSuite) TestWebhookSubscriptionsValidation() {
+	valid := func() *types.WebhookSubscription {
+		return &types.WebhookSubscription{
+			EventTypes:  []string{types.WebhookEventCreated},
+			Collections: []string{consts.RuntimeIncidentCollection},
+			URL:         "https://example.com/hook",
+			Secret:      webhookSecret,
+		}
+	}
+	subscription := valid()
+	subscription.Secret = ""
+	testBadRequest(suite, http.MethodPost, consts.WebhookSubscriptionPath, `{"error":"secret is required"}`, suite.subscriptionBody(subscription), http.StatusBadRequest)
+	subscription = valid()
+	subscription.EventTypes = []string{"renamed"}
+	testBadRequest(suite, http.MethodPost, consts.WebhookSubscriptionPath,
+		`{"error":"invalid event type renamed, valid event types are [created updated deleted expired]"}`, suite.subscriptionBody(subscription), http.StatusBadRequest)
+	subscription = valid()
+	subscription.Collections = []string{consts.WebhookDeliveriesCollection}
+	testBadRequest(suite, http.MethodPost, consts.WebhookSubscriptionPath, `{"error":"invalid collection v1_webhook_deliveries"}`, suite.subscriptionBody(subscription), http.StatusBadRequest)
+	subscription = valid()
+	subscription.URL = "ftp://example.com"
+	testBadRequest(suite, http.MethodPost, consts.WebhookSubscriptionPath,
+		`{"error":"invalid url ftp://example.com, an http or https url is required"}`, suite.subscriptionBody(subscription), http.StatusBadRequest)
+	subscription = valid()
+	subscription.Collections = append(subscription.Collections, consts.ClustersCollection)
+	subscription.InnerFilters = []map[string]string{{"name": "incident1"}}
+	testBadRequest(suite, http.MethodPost, consts.WebhookSubscriptionPath, `{"error":"innerFilters require a single collection"}`, suite.subscriptionBody(subscription), http.StatusBadRequest)
+	//users that cannot read the documents of a collection cannot subscribe to it
+	subscription = valid()
+	subscription.Collections = []string{consts.PostureExceptionPolicyCollection}
+	suite.loginWithRoles(defaultUserGUID)
+	testBadRequest(suite, http.MethodPost, consts.WebhookSubscriptionPath,
+		`{"error":"missing read permission for /v1_posture_exception_policy"}`, suite.subscriptionBody(subscription), http.StatusForbidden)
+	suite.loginWithRoles(defaultUserGUID, consts.ViewerRole)
+	subscription = suite.postSubscription(subscription)
+	w := suite.doRequest(http.MethodDelete, consts.WebhookSubscriptionPath+"/"+subscription.GUID, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.login(defaultUserGUID)
+
+	//the secret is not returned
+	subscription = suite.postSubscription(valid())
+	w = suite.doRequest(http.MethodGet, consts.WebhookSubscriptionPath+"/"+subscription.GUID, nil)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.NotContains(w.Body.String(), webhookSecret)
+	suite.NotContains(w.Body.String(), "secret")
+	//nor recorded in the audit trail
+	for _, event := range suite.getAuditEvents(map[string]string{"docGUID": subscription.GUID}) {
+		for _, change := range event.Diff {
+			suite.NotContains(string(change.New), webhookSecret)
+		}
+	}
+	w = suite.doRequest(http.MethodDelete, consts.WebhookSubscriptionPath+"/"+subscription.GUID, nil)
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+func (suite *MainTestSuite) TestWebhooks() {
+	target := newWebhookTarget()
+	defer target.Close()
+	subscriptions := []*types.WebhookSubscription{
+		suite.postSubscription(&types.WebhookSubscription{
+			PortalBase:  armotypes.PortalBase{Name: "all"},
+			EventTypes:  []string{types.WebhookEventCreated, types.WebhookEventUpdated, types.WebhookEventDeleted},
+			Collections: []string{consts.RuntimeIncidentCollection},
+			URL:         target.URL + "/ok",
+			Secret:      webhookSecret,
+		}),
+		suite.postSubscription(&types.WebhookSubscription{
+			PortalBase:   armotypes.PortalBase{Name: "dismissed"},
+			EventTypes:   []string{types.WebhookEventUpdated},
+			Collections:  []string{consts.RuntimeIncidentCollection},
+			InnerFilters: []map[string]string{{"isDismissed": "true"}},
+			URL:          target.URL + "/flaky",
+			Secret:       webhookSecret,
+		}),
+		suite.postSubscription(&types.WebhookSubscription{
+			PortalBase:  armotypes.PortalBase{Name: "down"},
+			EventTypes:  []string{types.WebhookEventCreated},
+			Collections: []string{consts.RuntimeIncidentCollection},
+			URL:         target.URL + "/down",
+			Secret:      webhookSecret,
+		}),
+		suite.postSubscription(&types.WebhookSubscription{
+			PortalBase:  armotypes.PortalBase{Name: "expired"},
+			EventTypes:  []string{types.WebhookEventExpired},
+			Collections: []string{consts.PostureExceptionPolicyCollection},
+			URL:         target.URL + "/expired",
+			Secret:      webhookSecret,
+		}),
+	}
+	defer func() {
+		for _, subscription := range subscriptions {
+			suite.doRequest(http.MethodDelete, consts.WebhookSubscriptionPath+"/"+subscription.GUID, nil)
+		}
+	}()
+
+	//created, updated and deleted events
+	incident := getRuntimeIncidentsMocks()[0]
+	w := suite.doRequest(http.MethodPost, consts.RuntimeIncidentPath, incident)
+	suite.Equal(http.StatusCreated, w.Code)
+	update := Clone(incident)
+	update.Attributes = map[string]interface{}{"test": "webhooks"}
+	w = suite.doRequest(http.MethodPut, consts.RuntimeIncidentPath, update)
+	suite.Equal(http.StatusOK, w.Code)
+	update.IsDismissed = true
+	w = suite.doRequest(http.MethodPut, consts.RuntimeIncidentPath, update)
+	suite.Equal(http.StatusOK, w.Code)
+	w = suite.doRequest(http.MethodDelete, consts.RuntimeIncidentPath+"/"+incident.GUID, nil)
+	suite.Equal(http.StatusOK, w.Code)
+
+	requests := suite.waitForRequests(target, "/ok", 4)
+	//deliveries are concurrent
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].event.Timestamp.Before(requests[j].event.Timestamp)
+	})
+	for i, eventType := range []string{types.WebhookEventCreated, types.WebhookEventUpdated, types.WebhookEventUpdated, types.WebhookEventDeleted} {
+		event := requests[i].event
+		suite.Equal(eventType, event.Type)
+		suite.Equal(defaultUserGUID, event.CustomerGUID)
+		suite.Equal(consts.RuntimeIncidentCollection, event.Collection)
+		suite.Equal(incident.GUID, event.DocGUID)
+		suite.NotEmpty(event.ID)
+		doc := decode[*types.RuntimeIncident](suite, event.Document)
+		suite.Equal(incident.GUID, doc.GUID)
+	}
+	suite.Empty(decode[*types.RuntimeIncident](suite, requests[0].event.Document).Attributes)
+	//the deleted event has the deleted document
+	suite.Equal(update.Attributes, decode[*types.RuntimeIncident](suite, requests[3].event.Document).Attributes)
+
+	//only the update that matches the inner filters is delivered, after a failed attempt
+	requests = suite.waitForRequests(target, "/flaky", 1)
+	suite.True(decode[*types.RuntimeIncident](suite, requests[0].event.Document).IsDismissed)
+	deliveries := suite.queryDeliveries(consts.WebhookDeliveryPath, map[string]string{"subscriptionGUID": subscriptions[1].GUID})
+	if suite.Len(deliveries, 1) {
+		suite.Equal(types.WebhookDeliveryDelivered, deliveries[0].Status)
+		suite.Equal(2, deliveries[0].Attempts)
+		suite.Equal(http.StatusOK, deliveries[0].LastStatusCode)
+		suite.Equal(requests[0].deliveryGUID, deliveries[0].GUID)
+	}
+
+	//deliveries that fail all attempts are moved to the dead letters
+	var deadLetters []*types.WebhookDelivery
+	deadline := time.Now().Add(5 * time.Second)
+	for len(deadLetters) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		deadLetters = suite.queryDeliveries(consts.WebhookDeadLetterPath, map[string]string{"subscriptionGUID": subscriptions[2].GUID})
+	}
+	if !suite.Len(deadLetters, 1) {
+		return
+	}
+	suite.Equal(types.WebhookDeliveryDead, deadLetters[0].Status)
+	suite.Equal(testWebhooksConfig.MaxAttempts, deadLetters[0].Attempts)
+	suite.Equal(http.StatusInternalServerError, deadLetters[0].LastStatusCode)
+	suite.Equal(types.WebhookEventCreated, deadLetters[0].Event.Type)
+	deliveries = suite.queryDeliveries(consts.WebhookDeliveryPath, map[string]string{"subscriptionGUID": subscriptions[2].GUID})
+	if suite.Len(deliveries, 1) {
+		suite.Equal(types.WebhookDeliveryDead, deliveries[0].Status)
+	}
+
+	//redelivery of a dead letter
+	target.setUp(true)
+	w = suite.doRequest(http.MethodPost, consts.WebhookDeadLetterPath+"/"+deadLetters[0].GUID+"/redeliver", nil)
+	suite.Equal(http.StatusOK, w.Code)
+	redelivery := decode[*types.WebhookDelivery](suite, w.Body.Bytes())
+	suite.Equal(types.WebhookDeliveryPending, redelivery.Status)
+	requests = suite.waitForRequests(target, "/down", 1)
+	suite.Equal(deadLetters[0].Event.ID, requests[0].event.ID)
+	suite.Equal(redelivery.GUID, requests[0].deliveryGUID)
+	suite.Empty(suite.queryDeliveries(consts.WebhookDeadLetterPath, map[string]string{"subscriptionGUID": subscriptions[2].GUID}))
+	w = suite.doRequest(http.MethodPost, consts.WebhookDeadLetterPath+"/"+deadLetters[0].GUID+"/redeliver", nil)
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	//expired events are published once
+	posturePolicies, _ := loadJson[*types.PostureExceptionPolicy](posturePoliciesJson)
+	policy := posturePolicies[0]
+	//subscriptions get the expirations after they were created
+	expiration := time.Now().UTC().Add(100 * time.Millisecond).Truncate(time.Millisecond)
+	policy.ExpirationDate = &expiration
+	policy = testPostDoc(suite, consts.PostureExceptionPolicyPath, policy, commonCmpFilter)
+	time.Sleep(time.Until(expiration))
+	webhooks.SweepExpired(context.Background(), expiration.Add(-time.Hour), time.Now().UTC())
+	webhooks.SweepExpired(context.Background(), expiration.Add(-time.Hour), time.Now().UTC())
+	requests = suite.waitForRequests(target, "/expired", 1)
+	suite.Equal(types.WebhookEventExpired, requests[0].event.Type)
+	suite.Equal(policy.GUID, requests[0].event.DocGUID)
+	suite.True(expiration.Equal(requests[0].event.Timestamp))
+	suite.Equal(policy.Name, decode[*types.PostureExceptionPolicy](suite, requests[0].event.Document).Name)
+	testDeleteDocByGUID(suite, consts.PostureExceptionPolicyPath, policy, commonCmpFilter)
+
+	//events of other customers are not delivered
+	suite.login("other-customer-guid")
+	w = suite.doRequest(http.MethodPost, consts.RuntimeIncidentPath, getRuntimeIncidentsMocks()[1])
+	suite.Equal(http.StatusCreated, w.Code)
+	suite.Empty(suite.queryDeliveries(consts.WebhookDeliveryPath, map[string]string{}))
+	suite.login(defaultUserGUID)
+	time.Sleep(5 * time.Duration(testWebhooksConfig.PollIntervalMillis) * time.Millisecond)
+	suite.Len(target.received("/ok"), 4)
+	suite.Len(target.received("/flaky"), 1)
+	suite.Len(target.received("/down"), 1)
+	suite.Len(target.received("/expired"), 1)
+}